@@ -229,6 +229,19 @@ func (m *EncryptMessage[T]) Decrypt(encryptor key.Encryptor, externalData []byte
 	return nil
 }
 
+// DecryptWithPolicy decrypts a COSE_Encrypt object with a Encryptor, like EncryptMessage.Decrypt,
+// and checks the protected header parameters, the encryptor's key and the algorithms
+// of the recipients, such as the key wrap and ECDH-ES algorithms, against the Policy first.
+func (m *EncryptMessage[T]) DecryptWithPolicy(policy *Policy, encryptor key.Encryptor, externalData []byte) error {
+	if err := policy.Check(m.Protected, encryptor.Key()); err != nil {
+		return err
+	}
+	if err := policy.checkRecipients(m.recipients); err != nil {
+		return err
+	}
+	return m.Decrypt(encryptor, externalData)
+}
+
 // encryptMessage represents a COSE_Encrypt structure to encode and decode.
 type encryptMessage struct {
 	_           struct{} `cbor:",toarray"`
@@ -207,6 +207,15 @@ func (m *Encrypt0Message[T]) Decrypt(encryptor key.Encryptor, externalData []byt
 	return nil
 }
 
+// DecryptWithPolicy decrypts a COSE_Encrypt0 object with a Encryptor, like Encrypt0Message.Decrypt,
+// and checks the protected header parameters and the encryptor's key against the Policy first.
+func (m *Encrypt0Message[T]) DecryptWithPolicy(policy *Policy, encryptor key.Encryptor, externalData []byte) error {
+	if err := policy.Check(m.Protected, encryptor.Key()); err != nil {
+		return err
+	}
+	return m.Decrypt(encryptor, externalData)
+}
+
 // encrypt0Message represents a COSE_Encrypt0 structure to encode and decode.
 type encrypt0Message struct {
 	_           struct{} `cbor:",toarray"`
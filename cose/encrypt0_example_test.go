@@ -60,9 +60,9 @@ func ExampleEncrypt0Message() {
 		panic(err)
 	}
 	fmt.Printf("Payload: %#v\n", obj2.Payload)
-	// Payload: cwt.Claims{Issuer:"ldc:ca", Subject:"ldc:chain", Audience:"ldc:txpool", Expiration:0x638c103b, NotBefore:0x0, IssuedAt:0x0, CWTID:key.ByteStr{0x1, 0x2, 0x3, 0x4}}
+	// Payload: cwt.Claims{Issuer:"ldc:ca", Subject:"ldc:chain", Audience:"ldc:txpool", ExtraAudiences:cwt.ClaimStrings(nil), Expiration:0x638c103b, NotBefore:0x0, IssuedAt:0x0, CWTID:key.ByteStr{0x1, 0x2, 0x3, 0x4}, Confirmation:(*cwt.Confirmation)(nil), Scope:(*cwt.Scope)(nil), ACEProfile:0, CNonce:key.ByteStr(nil), Exi:0x0, Status:(*cwt.Status)(nil)}
 
 	// Output:
 	// CWT(89 bytes): d08343a1010aa2044b6f75722d73656372657432...
-	// Payload: cwt.Claims{Issuer:"ldc:ca", Subject:"ldc:chain", Audience:"ldc:txpool", Expiration:0x638c103b, NotBefore:0x0, IssuedAt:0x0, CWTID:key.ByteStr{0x1, 0x2, 0x3, 0x4}}
+	// Payload: cwt.Claims{Issuer:"ldc:ca", Subject:"ldc:chain", Audience:"ldc:txpool", ExtraAudiences:cwt.ClaimStrings(nil), Expiration:0x638c103b, NotBefore:0x0, IssuedAt:0x0, CWTID:key.ByteStr{0x1, 0x2, 0x3, 0x4}, Confirmation:(*cwt.Confirmation)(nil), Scope:(*cwt.Scope)(nil), ACEProfile:0, CNonce:key.ByteStr(nil), Exi:0x0, Status:(*cwt.Status)(nil)}
 }
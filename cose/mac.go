@@ -146,6 +146,19 @@ func (m *MacMessage[T]) Verify(macer key.MACer, externalData []byte) error {
 	return macer.MACVerify(m.toMac, m.mm.Tag)
 }
 
+// VerifyWithPolicy verifies a COSE_Mac object' MAC with a MACer, like MacMessage.Verify,
+// and checks the protected header parameters, the macer's key and the algorithms
+// of the recipients, such as the key wrap and ECDH-ES algorithms, against the Policy first.
+func (m *MacMessage[T]) VerifyWithPolicy(policy *Policy, macer key.MACer, externalData []byte) error {
+	if err := policy.Check(m.Protected, macer.Key()); err != nil {
+		return err
+	}
+	if err := policy.checkRecipients(m.recipients); err != nil {
+		return err
+	}
+	return m.Verify(macer, externalData)
+}
+
 // macMessage represents a COSE_Mac structure to encode and decode.
 type macMessage struct {
 	_           struct{} `cbor:",toarray"`
@@ -126,6 +126,15 @@ func (m *Mac0Message[T]) Verify(macer key.MACer, externalData []byte) error {
 	return macer.MACVerify(m.toMac, m.mm.Tag)
 }
 
+// VerifyWithPolicy verifies a COSE_Mac0 object' MAC with a MACer, like Mac0Message.Verify,
+// and checks the protected header parameters and the macer's key against the Policy first.
+func (m *Mac0Message[T]) VerifyWithPolicy(policy *Policy, macer key.MACer, externalData []byte) error {
+	if err := policy.Check(m.Protected, macer.Key()); err != nil {
+		return err
+	}
+	return m.Verify(macer, externalData)
+}
+
 // mac0Message represents a COSE_Mac0 structure to encode and decode.
 type mac0Message struct {
 	_           struct{} `cbor:",toarray"`
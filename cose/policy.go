@@ -0,0 +1,138 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cose
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+// ErrPolicyViolation is the error wrapped by the errors of Policy.Check.
+var ErrPolicyViolation = errors.New("policy violation")
+
+// Policy is a security policy for verifying, MAC-verifying and decrypting COSE objects,
+// such as a FIPS-like profile. It is checked against the protected header parameters
+// and the key before the verification or decryption.
+// A nil Policy allows everything.
+//
+//	policy := &cose.Policy{
+//		Algorithms:          []key.Alg{iana.AlgorithmES256, iana.AlgorithmES384, iana.AlgorithmA256GCM},
+//		MinKeySizes:         map[int]int{iana.KeyTypeSymmetric: 256},
+//		ForbidTruncatedMAC:  true,
+//		RequireProtectedAlg: true,
+//	}
+//	err := obj.VerifyWithPolicy(policy, verifier, nil)
+type Policy struct {
+	// Algorithms is the allowed algorithms, all algorithms are allowed if it is empty.
+	Algorithms []key.Alg
+	// MinKeySizes is the minimum key size in bits for the key types,
+	// such as {iana.KeyTypeRSA: 2048, iana.KeyTypeSymmetric: 128}. See key.Description.Size.
+	MinKeySizes map[int]int
+	// ForbidTruncatedMAC forbids the MAC algorithms with 64-bit tags,
+	// HMAC 256/64, AES-MAC 128/64 and AES-MAC 256/64.
+	ForbidTruncatedMAC bool
+	// RequireProtectedAlg requires the algorithm in the protected header parameters,
+	// so that the algorithm is integrity protected.
+	RequireProtectedAlg bool
+}
+
+// Check checks the protected header parameters and the key against the policy.
+// The algorithm is the "alg" header parameter if present, otherwise the key's algorithm.
+// The returned error wraps ErrPolicyViolation if the policy is violated.
+func (p *Policy) Check(protected Headers, k key.Key) error {
+	if p == nil {
+		return nil
+	}
+	if k == nil {
+		return errors.New("cose/cose: Policy.Check: nil key")
+	}
+
+	alg := k.Alg()
+	if protected.Has(iana.HeaderParameterAlg) {
+		v, err := protected.GetInt(iana.HeaderParameterAlg)
+		if err != nil {
+			return fmt.Errorf("cose/cose: Policy.Check: invalid alg header parameter, %w", err)
+		}
+		alg = key.Alg(v)
+	} else if p.RequireProtectedAlg {
+		return fmt.Errorf("cose/cose: Policy.Check: %w, missing alg in protected header", ErrPolicyViolation)
+	}
+
+	if err := p.checkAlg(alg); err != nil {
+		return fmt.Errorf("cose/cose: Policy.Check: %w", err)
+	}
+
+	if min, ok := p.MinKeySizes[k.Kty()]; ok {
+		d, err := key.Describe(k)
+		if err != nil {
+			return fmt.Errorf("cose/cose: Policy.Check: %w", err)
+		}
+		if d.Size < min {
+			return fmt.Errorf("cose/cose: Policy.Check: %w, key size %d is less than %d",
+				ErrPolicyViolation, d.Size, min)
+		}
+	}
+	return nil
+}
+
+// checkRecipients checks the algorithm of the recipients and their nested recipients
+// against the policy, such as the key wrap, ECDH-ES/SS and direct key agreement algorithms.
+// The algorithm is the "alg" header parameter in the protected header parameters if present,
+// otherwise in the unprotected ones, since the protected header parameters of a direct
+// recipient must be empty. The recipients have no key, so MinKeySizes is not checked.
+func (p *Policy) checkRecipients(recipients []*Recipient) error {
+	if p == nil {
+		return nil
+	}
+
+	for _, r := range recipients {
+		h := r.Protected
+		if !h.Has(iana.HeaderParameterAlg) {
+			h = r.Unprotected
+		}
+		v, err := h.GetInt(iana.HeaderParameterAlg)
+		if err != nil {
+			return fmt.Errorf("cose/cose: Policy.Check: invalid recipient alg header parameter, %w", err)
+		}
+		if err := p.checkAlg(key.Alg(v)); err != nil {
+			return fmt.Errorf("cose/cose: Policy.Check: recipient %w", err)
+		}
+		if err := p.checkRecipients(r.recipients); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Policy) checkAlg(alg key.Alg) error {
+	if len(p.Algorithms) > 0 && !containsAlg(p.Algorithms, alg) {
+		return fmt.Errorf("%w, algorithm %d is not allowed", ErrPolicyViolation, alg)
+	}
+
+	if p.ForbidTruncatedMAC && isTruncatedMAC(alg) {
+		return fmt.Errorf("%w, truncated MAC algorithm %d is not allowed", ErrPolicyViolation, alg)
+	}
+	return nil
+}
+
+func containsAlg(algs []key.Alg, alg key.Alg) bool {
+	for _, a := range algs {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+func isTruncatedMAC(alg key.Alg) bool {
+	switch alg {
+	case iana.AlgorithmHMAC_256_64, iana.AlgorithmAES_MAC_128_64, iana.AlgorithmAES_MAC_256_64:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,285 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cose
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/aesgcm"
+	"github.com/ldclabs/cose/key/ecdsa"
+	"github.com/ldclabs/cose/key/ed25519"
+	"github.com/ldclabs/cose/key/hmac"
+)
+
+func TestPolicy(t *testing.T) {
+	t.Run("Check", func(t *testing.T) {
+		assert := assert.New(t)
+
+		okp := key.Key{
+			iana.KeyParameterKty:    iana.KeyTypeOKP,
+			iana.KeyParameterAlg:    iana.AlgorithmEdDSA,
+			iana.OKPKeyParameterCrv: iana.EllipticCurveEd25519,
+		}
+		hmac64 := key.Key{
+			iana.KeyParameterKty:        iana.KeyTypeSymmetric,
+			iana.KeyParameterAlg:        iana.AlgorithmHMAC_256_64,
+			iana.SymmetricKeyParameterK: make([]byte, 32),
+		}
+		a128 := key.Key{
+			iana.KeyParameterKty:        iana.KeyTypeSymmetric,
+			iana.KeyParameterAlg:        iana.AlgorithmA128GCM,
+			iana.SymmetricKeyParameterK: make([]byte, 16),
+		}
+		withAlg := func(alg int) Headers { return Headers{iana.HeaderParameterAlg: alg} }
+
+		var nilPolicy *Policy
+		assert.NoError(nilPolicy.Check(nil, nil))
+		assert.NoError((&Policy{}).Check(nil, okp))
+
+		for i, tc := range []struct {
+			policy    *Policy
+			protected Headers
+			k         key.Key
+			err       string
+			violation bool
+		}{
+			{&Policy{RequireProtectedAlg: true}, withAlg(iana.AlgorithmEdDSA), okp, "", false},
+			{&Policy{RequireProtectedAlg: true}, Headers{}, okp, "missing alg in protected header", true},
+			{&Policy{Algorithms: []key.Alg{iana.AlgorithmEdDSA}}, nil, okp, "", false},
+			{&Policy{Algorithms: []key.Alg{iana.AlgorithmES256}}, nil, okp, "algorithm -8 is not allowed", true},
+			{&Policy{Algorithms: []key.Alg{iana.AlgorithmEdDSA}}, withAlg(iana.AlgorithmES256), okp,
+				"algorithm -7 is not allowed", true},
+			{&Policy{ForbidTruncatedMAC: true}, nil, hmac64, "truncated MAC algorithm 4 is not allowed", true},
+			{&Policy{ForbidTruncatedMAC: true}, withAlg(iana.AlgorithmAES_MAC_256_64), a128,
+				"truncated MAC algorithm 15 is not allowed", true},
+			{&Policy{ForbidTruncatedMAC: true}, nil, a128, "", false},
+			{&Policy{MinKeySizes: map[int]int{iana.KeyTypeSymmetric: 128}}, nil, a128, "", false},
+			{&Policy{MinKeySizes: map[int]int{iana.KeyTypeSymmetric: 256}}, nil, a128, "key size 128 is less than 256", true},
+			{&Policy{MinKeySizes: map[int]int{iana.KeyTypeSymmetric: 256}}, nil, okp, "", false},
+			{&Policy{MinKeySizes: map[int]int{iana.KeyTypeSymmetric: 256}}, nil,
+				key.Key{iana.KeyParameterKty: iana.KeyTypeSymmetric, iana.SymmetricKeyParameterK: 1}, "invalid parameter k", false},
+			{&Policy{}, Headers{iana.HeaderParameterAlg: "EdDSA"}, okp, "invalid alg header parameter", false},
+			{&Policy{}, nil, nil, "nil key", false},
+		} {
+			err := tc.policy.Check(tc.protected, tc.k)
+			if tc.err == "" {
+				assert.NoError(err, "test case %d", i)
+				continue
+			}
+			assert.ErrorContains(err, "cose/cose: Policy.Check: ", "test case %d", i)
+			assert.ErrorContains(err, tc.err, "test case %d", i)
+			assert.Equal(tc.violation, errors.Is(err, ErrPolicyViolation), "test case %d", i)
+		}
+	})
+
+	t.Run("Sign1Message", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		k, err := ecdsa.GenerateKey(iana.AlgorithmES256)
+		require.NoError(err)
+		signer, err := k.Signer()
+		require.NoError(err)
+		verifier, err := k.Verifier()
+		require.NoError(err)
+
+		obj := &Sign1Message[[]byte]{Payload: []byte("hello")}
+		data, err := obj.SignAndEncode(signer, nil)
+		require.NoError(err)
+
+		obj2 := &Sign1Message[[]byte]{}
+		require.NoError(obj2.UnmarshalCBOR(data))
+		assert.NoError(obj2.VerifyWithPolicy(&Policy{
+			Algorithms:          []key.Alg{iana.AlgorithmES256},
+			MinKeySizes:         map[int]int{iana.KeyTypeEC2: 256},
+			RequireProtectedAlg: true,
+		}, verifier, nil))
+		assert.NoError(obj2.VerifyWithPolicy(nil, verifier, nil))
+		err = obj2.VerifyWithPolicy(&Policy{MinKeySizes: map[int]int{iana.KeyTypeEC2: 384}}, verifier, nil)
+		assert.ErrorIs(err, ErrPolicyViolation)
+		assert.Error(obj2.VerifyWithPolicy(nil, verifier, []byte("external")))
+
+		// the algorithm is not protected
+		obj = &Sign1Message[[]byte]{Protected: Headers{}, Payload: []byte("hello")}
+		data, err = obj.SignAndEncode(signer, nil)
+		require.NoError(err)
+		obj2 = &Sign1Message[[]byte]{}
+		require.NoError(obj2.UnmarshalCBOR(data))
+		assert.NoError(obj2.VerifyWithPolicy(&Policy{Algorithms: []key.Alg{iana.AlgorithmES256}}, verifier, nil))
+		err = obj2.VerifyWithPolicy(&Policy{RequireProtectedAlg: true}, verifier, nil)
+		assert.ErrorContains(err, "missing alg in protected header")
+	})
+
+	t.Run("SignMessage", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		k1, err := ed25519.GenerateKey()
+		require.NoError(err)
+		k2, err := ecdsa.GenerateKey(iana.AlgorithmES384)
+		require.NoError(err)
+		ks := key.KeySet{k1, k2}
+		signers, err := ks.Signers()
+		require.NoError(err)
+		verifiers, err := ks.Verifiers()
+		require.NoError(err)
+
+		obj := &SignMessage[[]byte]{Payload: []byte("hello")}
+		data, err := obj.SignAndEncode(signers, nil)
+		require.NoError(err)
+
+		obj2 := &SignMessage[[]byte]{}
+		require.NoError(obj2.UnmarshalCBOR(data))
+		assert.NoError(obj2.VerifyWithPolicy(&Policy{
+			Algorithms:          []key.Alg{iana.AlgorithmEdDSA, iana.AlgorithmES384},
+			RequireProtectedAlg: true,
+		}, verifiers, nil))
+		err = obj2.VerifyWithPolicy(&Policy{Algorithms: []key.Alg{iana.AlgorithmEdDSA}}, verifiers, nil)
+		assert.ErrorContains(err, "algorithm -35 is not allowed")
+		err = (&SignMessage[[]byte]{}).VerifyWithPolicy(&Policy{}, verifiers, nil)
+		assert.ErrorContains(err, "should call SignMessage.UnmarshalCBOR")
+	})
+
+	t.Run("Mac0Message", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		for _, tc := range []struct {
+			alg int
+			err string
+		}{
+			{iana.AlgorithmHMAC_256_256, ""},
+			{iana.AlgorithmHMAC_256_64, "truncated MAC algorithm 4 is not allowed"},
+		} {
+			k, err := hmac.GenerateKey(tc.alg)
+			require.NoError(err)
+			macer, err := k.MACer()
+			require.NoError(err)
+
+			obj := &Mac0Message[[]byte]{Payload: []byte("hello")}
+			require.NoError(obj.Compute(macer, nil))
+			obj2 := &Mac0Message[[]byte]{Protected: obj.Protected, Unprotected: obj.Unprotected, mm: obj.mm}
+			err = obj2.VerifyWithPolicy(&Policy{ForbidTruncatedMAC: true}, macer, nil)
+			if tc.err == "" {
+				assert.NoError(err)
+			} else {
+				assert.ErrorContains(err, tc.err)
+			}
+		}
+	})
+
+	t.Run("MacMessage", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		k, err := hmac.GenerateKey(iana.AlgorithmHMAC_256_64)
+		require.NoError(err)
+		macer, err := k.MACer()
+		require.NoError(err)
+
+		obj := &MacMessage[[]byte]{Protected: Headers{iana.HeaderParameterAlg: iana.AlgorithmHMAC_256_64}}
+		err = obj.VerifyWithPolicy(&Policy{ForbidTruncatedMAC: true}, macer, nil)
+		assert.ErrorIs(err, ErrPolicyViolation)
+		err = obj.VerifyWithPolicy(nil, macer, nil)
+		assert.ErrorContains(err, "should call MacMessage.UnmarshalCBOR")
+
+		k, err = hmac.GenerateKey(iana.AlgorithmHMAC_256_256)
+		require.NoError(err)
+		macer, err = k.MACer()
+		require.NoError(err)
+
+		obj = &MacMessage[[]byte]{Payload: []byte("hello")}
+		require.NoError(obj.AddRecipient(&Recipient{
+			Protected:   Headers{iana.HeaderParameterAlg: iana.AlgorithmA128KW},
+			Unprotected: Headers{},
+			Ciphertext:  []byte{},
+		}))
+		data, err := obj.ComputeAndEncode(macer, nil)
+		require.NoError(err)
+
+		obj2 := &MacMessage[[]byte]{}
+		require.NoError(obj2.UnmarshalCBOR(data))
+		require.NoError(obj2.VerifyWithPolicy(&Policy{
+			Algorithms: []key.Alg{iana.AlgorithmHMAC_256_256, iana.AlgorithmA128KW},
+		}, macer, nil))
+		err = obj2.VerifyWithPolicy(&Policy{Algorithms: []key.Alg{iana.AlgorithmHMAC_256_256}}, macer, nil)
+		assert.ErrorIs(err, ErrPolicyViolation)
+		assert.ErrorContains(err, "recipient policy violation, algorithm -3 is not allowed")
+	})
+
+	t.Run("Encrypt0Message", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		k, err := aesgcm.GenerateKey(iana.AlgorithmA128GCM)
+		require.NoError(err)
+		encryptor, err := k.Encryptor()
+		require.NoError(err)
+
+		obj := &Encrypt0Message[[]byte]{Payload: []byte("hello")}
+		data, err := obj.EncryptAndEncode(encryptor, nil)
+		require.NoError(err)
+
+		obj2 := &Encrypt0Message[[]byte]{}
+		require.NoError(obj2.UnmarshalCBOR(data))
+		err = obj2.DecryptWithPolicy(&Policy{MinKeySizes: map[int]int{iana.KeyTypeSymmetric: 256}}, encryptor, nil)
+		assert.ErrorContains(err, "key size 128 is less than 256")
+		assert.Nil(obj2.Payload)
+		require.NoError(obj2.DecryptWithPolicy(&Policy{MinKeySizes: map[int]int{iana.KeyTypeSymmetric: 128}}, encryptor, nil))
+		assert.Equal([]byte("hello"), obj2.Payload)
+	})
+
+	t.Run("EncryptMessage", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		k, err := aesgcm.GenerateKey(iana.AlgorithmA128GCM)
+		require.NoError(err)
+		encryptor, err := k.Encryptor()
+		require.NoError(err)
+
+		obj := &EncryptMessage[[]byte]{Protected: Headers{iana.HeaderParameterAlg: iana.AlgorithmA128GCM}}
+		err = obj.DecryptWithPolicy(&Policy{Algorithms: []key.Alg{iana.AlgorithmA256GCM}}, encryptor, nil)
+		assert.ErrorContains(err, "algorithm 1 is not allowed")
+		err = obj.DecryptWithPolicy(&Policy{Algorithms: []key.Alg{iana.AlgorithmA128GCM}}, encryptor, nil)
+		assert.ErrorContains(err, "should call EncryptMessage.UnmarshalCBOR")
+
+		// the direct recipient has the algorithm in the unprotected header parameters,
+		// and its nested recipient uses a key agreement algorithm.
+		r := &Recipient{
+			Protected:   Headers{},
+			Unprotected: Headers{iana.HeaderParameterAlg: iana.AlgorithmDirect},
+			Ciphertext:  []byte{},
+		}
+		require.NoError(r.AddRecipient(&Recipient{
+			Protected:   Headers{iana.HeaderParameterAlg: iana.AlgorithmECDH_ES_HKDF_256},
+			Unprotected: Headers{},
+			Ciphertext:  []byte{},
+		}))
+		obj = &EncryptMessage[[]byte]{Payload: []byte("hello")}
+		require.NoError(obj.AddRecipient(r))
+		data, err := obj.EncryptAndEncode(encryptor, nil)
+		require.NoError(err)
+
+		obj2 := &EncryptMessage[[]byte]{}
+		require.NoError(obj2.UnmarshalCBOR(data))
+		err = obj2.DecryptWithPolicy(&Policy{Algorithms: []key.Alg{iana.AlgorithmA128GCM}}, encryptor, nil)
+		assert.ErrorContains(err, "recipient policy violation, algorithm -6 is not allowed")
+		err = obj2.DecryptWithPolicy(&Policy{
+			Algorithms: []key.Alg{iana.AlgorithmA128GCM, iana.AlgorithmDirect},
+		}, encryptor, nil)
+		assert.ErrorContains(err, "recipient policy violation, algorithm -25 is not allowed")
+		assert.Nil(obj2.Payload)
+		require.NoError(obj2.DecryptWithPolicy(&Policy{
+			Algorithms: []key.Alg{iana.AlgorithmA128GCM, iana.AlgorithmDirect, iana.AlgorithmECDH_ES_HKDF_256},
+		}, encryptor, nil))
+		assert.Equal([]byte("hello"), obj2.Payload)
+	})
+}
@@ -162,6 +162,22 @@ func (m *SignMessage[T]) Verify(verifiers key.Verifiers, externalData []byte) er
 	return nil
 }
 
+// VerifyWithPolicy verifies a COSE_Sign message with some Verifiers, like SignMessage.Verify,
+// and checks the protected header parameters of each signature and the verifier's key
+// against the Policy first.
+func (m *SignMessage[T]) VerifyWithPolicy(policy *Policy, verifiers key.Verifiers, externalData []byte) error {
+	if m.mm != nil {
+		for _, sig := range m.mm.Signatures {
+			if verifier := verifiers.Lookup(sig.Kid()); verifier != nil {
+				if err := policy.Check(sig.Protected, verifier.Key()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return m.Verify(verifiers, externalData)
+}
+
 // signMessage represents a COSE_Sign structure to encode and decode.
 type signMessage struct {
 	_           struct{} `cbor:",toarray"`
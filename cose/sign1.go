@@ -124,6 +124,15 @@ func (m *Sign1Message[T]) Verify(verifier key.Verifier, externalData []byte) err
 	return verifier.Verify(m.toSign, m.mm.Signature)
 }
 
+// VerifyWithPolicy verifies a COSE_Sign1 message with a Verifier, like Sign1Message.Verify,
+// and checks the protected header parameters and the verifier's key against the Policy first.
+func (m *Sign1Message[T]) VerifyWithPolicy(policy *Policy, verifier key.Verifier, externalData []byte) error {
+	if err := policy.Check(m.Protected, verifier.Key()); err != nil {
+		return err
+	}
+	return m.Verify(verifier, externalData)
+}
+
 // sign1Message represents a COSE_Sign1 structure to encode and decode.
 type sign1Message struct {
 	_           struct{} `cbor:",toarray"`
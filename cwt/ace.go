@@ -0,0 +1,454 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+// REST methods of the AIF-REST permissions.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc9237#section-2.3
+const (
+	AIFMethodGET    uint64 = 1 << 0
+	AIFMethodPOST   uint64 = 1 << 1
+	AIFMethodPUT    uint64 = 1 << 2
+	AIFMethodDELETE uint64 = 1 << 3
+	AIFMethodFETCH  uint64 = 1 << 4
+	AIFMethodPATCH  uint64 = 1 << 5
+	AIFMethodIPATCH uint64 = 1 << 6
+)
+
+// AIFEntry represents an entry of the AIF-REST data model, a resource path and the allowed methods.
+type AIFEntry struct {
+	_ struct{} `cbor:",toarray"`
+	// Toid is the resource path, such as "/s/temp".
+	Toid string
+	// Tperm is the set of allowed methods, such as AIFMethodGET | AIFMethodPUT.
+	Tperm uint64
+}
+
+// AIF represents the Authorization Information Format (AIF) structured scope.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc9237
+type AIF []AIFEntry
+
+// Allows returns true if the method is allowed on the resource path.
+func (a AIF) Allows(toid string, method uint64) bool {
+	for _, e := range a {
+		if e.Toid == toid && e.Tperm&method == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Scope represents the "scope" claim, a text string of space-separated scope tokens,
+// a binary string, or an AIF structured scope. Only one of them should be present.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc9200#section-5.8.1
+type Scope struct {
+	// Tokens is the scope tokens of the text string scope.
+	Tokens []string
+	// Binary is the binary string scope.
+	Binary key.ByteStr
+	// AIF is the AIF structured scope.
+	AIF AIF
+}
+
+// Has returns true if the text string scope has the given scope token.
+func (s *Scope) Has(token string) bool {
+	for _, t := range s.Tokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalCBOR implements the CBOR Marshaler interface for Scope.
+func (s Scope) MarshalCBOR() ([]byte, error) {
+	switch {
+	case len(s.AIF) > 0:
+		return key.MarshalCBOR(s.AIF)
+	case len(s.Binary) > 0:
+		return key.MarshalCBOR(s.Binary)
+	default:
+		return key.MarshalCBOR(strings.Join(s.Tokens, " "))
+	}
+}
+
+// UnmarshalCBOR implements the CBOR Unmarshaler interface for Scope.
+func (s *Scope) UnmarshalCBOR(data []byte) error {
+	if s == nil {
+		return errors.New("cose/cwt: Scope.UnmarshalCBOR: nil Scope")
+	}
+
+	var v any
+	if err := key.UnmarshalCBOR(data, &v); err != nil {
+		return err
+	}
+
+	switch x := v.(type) {
+	case string:
+		*s = Scope{Tokens: strings.Fields(x)}
+	case []byte:
+		*s = Scope{Binary: x}
+	case []any:
+		var aif AIF
+		if err := key.UnmarshalCBOR(data, &aif); err != nil {
+			return fmt.Errorf("cose/cwt: Scope.UnmarshalCBOR: invalid AIF, %w", err)
+		}
+		*s = Scope{AIF: aif}
+	default:
+		return fmt.Errorf("cose/cwt: Scope.UnmarshalCBOR: invalid value type %T", v)
+	}
+	return nil
+}
+
+// MarshalJSON implements encoding/json interface for Scope.
+// The text string scope is encoded as a string, the AIF structured scope as an array of
+// [toid, tperm] arrays, and the binary string scope as a {"binary": base64url} object,
+// so that it is not confused with the text string scope.
+func (s Scope) MarshalJSON() ([]byte, error) {
+	switch {
+	case len(s.AIF) > 0:
+		entries := make([][2]any, len(s.AIF))
+		for i, e := range s.AIF {
+			entries[i] = [2]any{e.Toid, e.Tperm}
+		}
+		return json.Marshal(entries)
+	case len(s.Binary) > 0:
+		return json.Marshal(map[string]string{"binary": s.Binary.Base64()})
+	default:
+		return json.Marshal(strings.Join(s.Tokens, " "))
+	}
+}
+
+// UnmarshalJSON implements encoding/json interface for Scope.
+// It decodes the text string, binary string and AIF structured forms of MarshalJSON.
+func (s *Scope) UnmarshalJSON(data []byte) error {
+	if s == nil {
+		return errors.New("cose/cwt: Scope.UnmarshalJSON: nil Scope")
+	}
+
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch x := v.(type) {
+	case string:
+		*s = Scope{Tokens: strings.Fields(x)}
+	case map[string]any:
+		b64, ok := x["binary"].(string)
+		if !ok || len(x) != 1 {
+			return errors.New("cose/cwt: Scope.UnmarshalJSON: invalid binary string scope")
+		}
+		bin, err := decodeBase64URL(b64)
+		if err != nil {
+			return fmt.Errorf("cose/cwt: Scope.UnmarshalJSON: invalid binary string scope, %w", err)
+		}
+		*s = Scope{Binary: bin}
+	case []any:
+		var entries [][2]json.RawMessage
+		if err := json.Unmarshal(data, &entries); err != nil || len(entries) == 0 {
+			return fmt.Errorf("cose/cwt: Scope.UnmarshalJSON: invalid AIF %s", data)
+		}
+		aif := make(AIF, len(entries))
+		for i, e := range entries {
+			if json.Unmarshal(e[0], &aif[i].Toid) != nil || json.Unmarshal(e[1], &aif[i].Tperm) != nil {
+				return fmt.Errorf("cose/cwt: Scope.UnmarshalJSON: invalid AIF entry %d", i)
+			}
+		}
+		*s = Scope{AIF: aif}
+	default:
+		return fmt.Errorf("cose/cwt: Scope.UnmarshalJSON: invalid value type %T", v)
+	}
+	return nil
+}
+
+// ExtractScope converts a claim value to a *Scope.
+func ExtractScope(val any) (*Scope, error) {
+	if s, ok := val.(*Scope); ok {
+		return s, nil
+	}
+
+	data, err := key.MarshalCBOR(val)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scope{}
+	if err = key.UnmarshalCBOR(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetScope returns the "scope" claim as a *Scope, or a error.
+// If the claim is not present, it returns (nil, nil).
+func (cm ClaimsMap) GetScope() (*Scope, error) {
+	v, ok := cm[iana.CWTClaimScope]
+	if !ok {
+		return nil, nil
+	}
+
+	s, err := ExtractScope(v)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt: ClaimsMap.GetScope: %w", err)
+	}
+	return s, nil
+}
+
+// aceRules returns the Rules for the ACE claims in RFC9200 according to the options provided.
+func (v *Validator) aceRules() []*Rule {
+	scope := NewRule(iana.CWTClaimScope, ExtractScope, nil)
+
+	profile := NewRule(iana.CWTClaimACEProfile, ExtractInt64, func(p int64) error {
+		if v.opts.ExpectedACEProfile != 0 && int64(v.opts.ExpectedACEProfile) != p {
+			return fmt.Errorf("ace_profile mismatch, expected %d, got %d", v.opts.ExpectedACEProfile, p)
+		}
+		return nil
+	})
+	profile.Required = v.opts.ExpectedACEProfile != 0
+
+	cnonce := NewRule(iana.CWTClaimCNonce, ExtractBytes, func(cnonce []byte) error {
+		if v.opts.VerifyCNonce != nil {
+			return v.opts.VerifyCNonce(cnonce)
+		}
+		return nil
+	})
+	cnonce.Required = v.opts.VerifyCNonce != nil
+
+	exi := NewClaimsRule(iana.CWTClaimExi, ExtractUint64, v.validateExi)
+
+	return []*Rule{scope, profile, cnonce, exi}
+}
+
+// validateExi validates the "exi" claim, the expiration time relative to
+// the first time the token is received, with the ValidatorOpts.ExiTracker.
+func (v *Validator) validateExi(claims ClaimsMap, exi uint64) error {
+	if v.opts.ExiTracker == nil {
+		return errors.New("no ExiTracker configured for the exi claim")
+	}
+
+	cti, err := claims.GetBytes(iana.CWTClaimCti)
+	if err != nil || len(cti) == 0 {
+		return errors.New("token with the exi claim doesn't have a valid cti claim")
+	}
+
+	var seq uint64
+	if err = key.UnmarshalCBOR(cti, &seq); err != nil {
+		return fmt.Errorf("token with the exi claim has an invalid sequence number, %w", err)
+	}
+	return v.opts.ExiTracker.Verify(seq, exi, v.now())
+}
+
+// recordExi records the token with the "exi" claim with the ValidatorOpts.ExiTracker,
+// after the token has passed all the rules.
+func (v *Validator) recordExi(claims ClaimsMap) {
+	if v.opts.ExiTracker == nil || !claims.Has(iana.CWTClaimExi) {
+		return
+	}
+
+	exi, err := ExtractUint64(claims[iana.CWTClaimExi])
+	if err != nil {
+		return
+	}
+	cti, _ := claims.GetBytes(iana.CWTClaimCti)
+	var seq uint64
+	if err = key.UnmarshalCBOR(cti, &seq); err != nil {
+		return
+	}
+	v.opts.ExiTracker.Record(seq, exi, v.now())
+}
+
+// ExiCounter generates the "cti" claims of the tokens with the "exi" claim on the authorization server.
+// It keeps a Sequence Number for each resource server (the audience), and the "cti" claim is
+// the CBOR encoding of the Sequence Number.
+// The counters should be persisted with Last and restored with Restore between restarts.
+// It is safe for concurrent use.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc9200#section-5.10.3
+type ExiCounter struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+}
+
+// NewExiCounter creates a new ExiCounter.
+func NewExiCounter() *ExiCounter {
+	return &ExiCounter{counters: make(map[string]uint64)}
+}
+
+// Next increases the Sequence Number for the audience, and returns the "cti" claim.
+func (c *ExiCounter) Next(audience string) key.ByteStr {
+	c.mu.Lock()
+	c.counters[audience]++
+	seq := c.counters[audience]
+	c.mu.Unlock()
+
+	return key.MustMarshalCBOR(seq)
+}
+
+// Last returns the last Sequence Number for the audience.
+func (c *ExiCounter) Last(audience string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counters[audience]
+}
+
+// Restore restores the last Sequence Number for the audience.
+// It is ignored if it is less than the current one.
+func (c *ExiCounter) Restore(audience string, last uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if last > c.counters[audience] {
+		c.counters[audience] = last
+	}
+}
+
+// ExiTracker tracks the tokens with the "exi" claim on the resource server.
+// It records the expiration time of the tokens from the first time they are received,
+// and rejects the tokens with a Sequence Number that is not greater than
+// the highest Sequence Number of the expired tokens.
+// A resource server should use an ExiTracker for each authorization server.
+// It is safe for concurrent use.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc9200#section-5.10.3
+type ExiTracker struct {
+	mu        sync.Mutex
+	tokens    map[uint64]time.Time
+	expiries  exiHeap
+	watermark uint64
+}
+
+// NewExiTracker creates a new ExiTracker.
+func NewExiTracker() *ExiTracker {
+	return &ExiTracker{tokens: make(map[uint64]time.Time)}
+}
+
+// Check records the token with the Sequence Number and the "exi" claim when it is received the first time,
+// and returns an error if the token has expired. It is Verify followed by Record.
+func (t *ExiTracker) Check(seq, exi uint64, now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.verify(seq, exi, now); err != nil {
+		return err
+	}
+	t.record(seq, exi, now)
+	return nil
+}
+
+// Verify returns an error if the token with the Sequence Number and the "exi" claim has expired,
+// without recording it. The Validator verifies the token when the rules are evaluated,
+// and records it with Record after all the rules pass.
+func (t *ExiTracker) Verify(seq, exi uint64, now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.verify(seq, exi, now)
+}
+
+// Record records the token with the Sequence Number and the "exi" claim when it is received the first time.
+// It is ignored if the token has been recorded.
+func (t *ExiTracker) Record(seq, exi uint64, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.expire(now)
+	t.record(seq, exi, now)
+}
+
+func (t *ExiTracker) verify(seq, exi uint64, now time.Time) error {
+	t.expire(now)
+
+	exp, ok := t.tokens[seq]
+	if !ok {
+		if seq <= t.watermark {
+			return errors.New("token has expired")
+		}
+		exp = exiExpiration(exi, now)
+	}
+
+	if !now.Before(exp) {
+		return errors.New("token has expired")
+	}
+	return nil
+}
+
+func (t *ExiTracker) record(seq, exi uint64, now time.Time) {
+	if _, ok := t.tokens[seq]; ok || seq <= t.watermark {
+		return
+	}
+
+	exp := exiExpiration(exi, now)
+	t.tokens[seq] = exp
+	heap.Push(&t.expiries, exiEntry{seq: seq, exp: exp})
+}
+
+// expire removes the expired tokens in the order of the expiration time, and raises the watermark.
+func (t *ExiTracker) expire(now time.Time) {
+	for len(t.expiries) > 0 && !now.Before(t.expiries[0].exp) {
+		e := heap.Pop(&t.expiries).(exiEntry)
+		delete(t.tokens, e.seq)
+		if e.seq > t.watermark {
+			t.watermark = e.seq
+		}
+	}
+}
+
+func exiExpiration(exi uint64, now time.Time) time.Time {
+	if exi > math.MaxInt64/uint64(time.Second) {
+		exi = math.MaxInt64 / uint64(time.Second)
+	}
+	return now.Add(time.Duration(exi) * time.Second)
+}
+
+type exiEntry struct {
+	seq uint64
+	exp time.Time
+}
+
+// exiHeap is a min-heap of the tracked tokens ordered by the expiration time.
+type exiHeap []exiEntry
+
+func (h exiHeap) Len() int           { return len(h) }
+func (h exiHeap) Less(i, j int) bool { return h[i].exp.Before(h[j].exp) }
+func (h exiHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *exiHeap) Push(x any)        { *h = append(*h, x.(exiEntry)) }
+func (h *exiHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// Watermark returns the highest Sequence Number of the expired tokens.
+// It should be persisted and restored with Restore between restarts.
+func (t *ExiTracker) Watermark() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.watermark
+}
+
+// Restore restores the highest Sequence Number of the expired tokens.
+// It is ignored if it is less than the current one.
+func (t *ExiTracker) Restore(watermark uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if watermark > t.watermark {
+		t.watermark = watermark
+	}
+}
@@ -0,0 +1,311 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScope(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	for _, tc := range []struct {
+		title string
+		scope Scope
+		cbor  []byte
+		json  string
+	}{
+		{
+			"text string scope",
+			Scope{Tokens: []string{"read", "write"}},
+			key.HexBytesify("6a72656164207772697465"),
+			`"read write"`,
+		},
+		{
+			"binary string scope",
+			Scope{Binary: key.ByteStr{1, 2}},
+			key.HexBytesify("420102"),
+			`{"binary":"AQI"}`,
+		},
+		{
+			"AIF scope",
+			Scope{AIF: AIF{{Toid: "/s/temp", Tperm: AIFMethodGET}, {Toid: "/a/led", Tperm: AIFMethodGET | AIFMethodPUT}}},
+			key.HexBytesify("8282672f732f74656d700182662f612f6c656405"),
+			`[["/s/temp",1],["/a/led",5]]`,
+		},
+	} {
+		data, err := key.MarshalCBOR(tc.scope)
+		require.NoError(err, tc.title)
+		assert.Equal(tc.cbor, data, tc.title)
+
+		var s Scope
+		require.NoError(key.UnmarshalCBOR(data, &s), tc.title)
+		assert.Equal(tc.scope, s, tc.title)
+
+		jsondata, err := json.Marshal(tc.scope)
+		require.NoError(err, tc.title)
+		assert.Equal(tc.json, string(jsondata), tc.title)
+
+		s = Scope{}
+		require.NoError(json.Unmarshal(jsondata, &s), tc.title)
+		assert.Equal(tc.scope, s, tc.title)
+
+		claims := Claims{Issuer: "ldc:as", Scope: &tc.scope}
+		jsondata, err = json.Marshal(claims)
+		require.NoError(err, tc.title)
+		var claims2 Claims
+		require.NoError(json.Unmarshal(jsondata, &claims2), tc.title)
+		assert.Equal(claims, claims2, tc.title)
+
+		cm := ClaimsMap{}
+		require.NoError(key.UnmarshalCBOR((&Claims{Scope: &tc.scope}).Bytesify(), &cm), tc.title)
+		s2, err := cm.GetScope()
+		require.NoError(err, tc.title)
+		assert.Equal(tc.scope, *s2, tc.title)
+	}
+
+	s := &Scope{Tokens: []string{"read", "write"}}
+	assert.True(s.Has("read"))
+	assert.False(s.Has("delete"))
+
+	aif := AIF{{Toid: "/a/led", Tperm: AIFMethodGET | AIFMethodPUT}}
+	assert.True(aif.Allows("/a/led", AIFMethodGET))
+	assert.True(aif.Allows("/a/led", AIFMethodGET|AIFMethodPUT))
+	assert.False(aif.Allows("/a/led", AIFMethodDELETE))
+	assert.False(aif.Allows("/s/temp", AIFMethodGET))
+
+	var ns *Scope
+	assert.ErrorContains(ns.UnmarshalCBOR([]byte{0x60}), "nil Scope")
+	assert.ErrorContains(s.UnmarshalCBOR([]byte{0x01}), "invalid value type uint64")
+	assert.ErrorContains(s.UnmarshalCBOR(key.HexBytesify("8101")), "invalid AIF")
+	assert.ErrorContains(ns.UnmarshalJSON([]byte(`""`)), "nil Scope")
+	for _, tc := range []struct {
+		json string
+		err  string
+	}{
+		{`1`, "invalid value type float64"},
+		{`{"bin":"AQI"}`, "invalid binary string scope"},
+		{`{"binary":"!!"}`, "invalid binary string scope"},
+		{`[]`, "invalid AIF"},
+		{`[1]`, "invalid AIF"},
+		{`[["/s/temp"]]`, "invalid AIF entry 0"},
+		{`[["/s/temp",-1]]`, "invalid AIF entry 0"},
+	} {
+		assert.ErrorContains(json.Unmarshal([]byte(tc.json), s), tc.err, tc.json)
+	}
+
+	s2, err := ClaimsMap{}.GetScope()
+	require.NoError(err)
+	assert.Nil(s2)
+	_, err = ClaimsMap{iana.CWTClaimScope: 1}.GetScope()
+	assert.ErrorContains(err, "cose/cwt: ClaimsMap.GetScope")
+	s2, err = ExtractScope(s)
+	require.NoError(err)
+	assert.Equal(s, s2)
+}
+
+func TestExiCounter(t *testing.T) {
+	assert := assert.New(t)
+
+	c := NewExiCounter()
+	assert.Equal(uint64(0), c.Last("rs1"))
+	assert.Equal(key.ByteStr{0x01}, c.Next("rs1"))
+	assert.Equal(key.ByteStr{0x02}, c.Next("rs1"))
+	assert.Equal(key.ByteStr{0x01}, c.Next("rs2"))
+	assert.Equal(uint64(2), c.Last("rs1"))
+
+	c.Restore("rs1", 1)
+	assert.Equal(uint64(2), c.Last("rs1"))
+	c.Restore("rs1", 1000)
+	assert.Equal(key.ByteStr{0x19, 0x03, 0xe9}, c.Next("rs1"))
+}
+
+func TestExiTracker(t *testing.T) {
+	assert := assert.New(t)
+
+	tr := NewExiTracker()
+	now := time.Unix(1000, 0)
+
+	assert.NoError(tr.Check(1, 60, now))
+	assert.NoError(tr.Check(2, 120, now))
+	// expiration is relative to the first time the token is received
+	assert.NoError(tr.Check(1, 60, now.Add(59*time.Second)))
+	assert.NoError(tr.Check(3, 60, now.Add(59*time.Second)))
+	assert.Equal(uint64(0), tr.Watermark())
+
+	assert.ErrorContains(tr.Check(1, 60, now.Add(60*time.Second)), "token has expired")
+	assert.Equal(uint64(1), tr.Watermark())
+	assert.NoError(tr.Check(2, 120, now.Add(60*time.Second)))
+	assert.NoError(tr.Check(3, 60, now.Add(60*time.Second)))
+
+	assert.ErrorContains(tr.Check(3, 60, now.Add(119*time.Second)), "token has expired")
+	assert.Equal(uint64(3), tr.Watermark())
+	// token 2 is still valid
+	assert.NoError(tr.Check(2, 120, now.Add(119*time.Second)))
+	// tokens with a sequence number less than the watermark are rejected
+	assert.ErrorContains(tr.Check(2, 120, now.Add(120*time.Second)), "token has expired")
+	assert.Equal(uint64(3), tr.Watermark())
+
+	assert.ErrorContains(tr.Check(4, 0, now), "token has expired")
+	assert.NoError(tr.Check(5, 1<<63, now))
+
+	// Verify does not record the token
+	tr = NewExiTracker()
+	assert.NoError(tr.Verify(1, 60, now))
+	assert.NoError(tr.Verify(1, 60, now.Add(60*time.Second)))
+	tr.Record(1, 60, now)
+	tr.Record(1, 60, now.Add(30*time.Second))
+	assert.NoError(tr.Verify(1, 60, now.Add(59*time.Second)))
+	assert.ErrorContains(tr.Verify(1, 60, now.Add(60*time.Second)), "token has expired")
+	assert.Equal(uint64(1), tr.Watermark())
+	// the expired tokens are not recorded again
+	tr.Record(1, 60, now.Add(61*time.Second))
+	assert.ErrorContains(tr.Verify(1, 60, now.Add(61*time.Second)), "token has expired")
+
+	// the tokens expire in the order of the expiration time
+	tr = NewExiTracker()
+	for seq := uint64(100); seq > 0; seq-- {
+		assert.NoError(tr.Check(seq, seq, now))
+	}
+	assert.ErrorContains(tr.Check(50, 50, now.Add(50*time.Second)), "token has expired")
+	assert.Equal(uint64(50), tr.Watermark())
+	assert.NoError(tr.Check(51, 51, now.Add(50*time.Second)))
+	assert.Len(tr.tokens, 50)
+	assert.Len(tr.expiries, 50)
+
+	tr = NewExiTracker()
+	tr.Restore(10)
+	tr.Restore(5)
+	assert.Equal(uint64(10), tr.Watermark())
+	assert.ErrorContains(tr.Check(9, 60, now), "token has expired")
+	assert.NoError(tr.Check(11, 60, now))
+}
+
+func TestValidatorACE(t *testing.T) {
+	t.Run("scope", func(t *testing.T) {
+		assert := assert.New(t)
+
+		va, err := NewValidator(&ValidatorOpts{AllowMissingExpiration: true})
+		require.NoError(t, err)
+
+		assert.NoError(va.Validate(&Claims{Scope: &Scope{Tokens: []string{"read"}}}))
+		assert.NoError(va.ValidateMap(ClaimsMap{iana.CWTClaimScope: []byte{1}}))
+		assert.NoError(va.ValidateMap(ClaimsMap{iana.CWTClaimScope: []any{[]any{"/s/temp", 1}}}))
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{iana.CWTClaimScope: 1}),
+			"token has an invalid scope claim")
+
+		va, err = NewValidator(&ValidatorOpts{
+			AllowMissingExpiration: true,
+			Rules: []*Rule{NewRule(iana.CWTClaimScope, ExtractScope, func(s *Scope) error {
+				if !s.AIF.Allows("/s/temp", AIFMethodGET) {
+					return errors.New("scope doesn't allow GET /s/temp")
+				}
+				return nil
+			})},
+		})
+		require.NoError(t, err)
+		assert.NoError(va.ValidateMap(ClaimsMap{iana.CWTClaimScope: []any{[]any{"/s/temp", 1}}}))
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{iana.CWTClaimScope: []any{[]any{"/s/temp", 2}}}),
+			"scope doesn't allow GET /s/temp")
+	})
+
+	t.Run("ace_profile", func(t *testing.T) {
+		assert := assert.New(t)
+
+		va, err := NewValidator(&ValidatorOpts{AllowMissingExpiration: true})
+		require.NoError(t, err)
+		assert.NoError(va.Validate(&Claims{ACEProfile: iana.ACEProfileCoapDTLS}))
+
+		va, err = NewValidator(&ValidatorOpts{
+			AllowMissingExpiration: true,
+			ExpectedACEProfile:     iana.ACEProfileCoapOSCORE,
+		})
+		require.NoError(t, err)
+		assert.NoError(va.Validate(&Claims{ACEProfile: iana.ACEProfileCoapOSCORE}))
+		assert.ErrorContains(va.Validate(&Claims{ACEProfile: iana.ACEProfileCoapDTLS}),
+			"ace_profile mismatch, expected 2, got 1")
+		assert.ErrorContains(va.Validate(&Claims{}),
+			"token doesn't have the ace_profile claim")
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{iana.CWTClaimACEProfile: "coap_oscore"}),
+			"token has an invalid ace_profile claim")
+	})
+
+	t.Run("cnonce", func(t *testing.T) {
+		assert := assert.New(t)
+
+		nonce := []byte{1, 2, 3, 4}
+		va, err := NewValidator(&ValidatorOpts{
+			AllowMissingExpiration: true,
+			VerifyCNonce: func(cnonce []byte) error {
+				if !bytes.Equal(cnonce, nonce) {
+					return errors.New("unknown cnonce")
+				}
+				return nil
+			},
+		})
+		require.NoError(t, err)
+		assert.NoError(va.Validate(&Claims{CNonce: nonce}))
+		assert.ErrorContains(va.Validate(&Claims{CNonce: []byte{1}}), "unknown cnonce")
+		assert.ErrorContains(va.Validate(&Claims{}), "token doesn't have the cnonce claim")
+	})
+
+	t.Run("exi", func(t *testing.T) {
+		assert := assert.New(t)
+
+		now := time.Unix(1000, 0)
+		va, err := NewValidator(&ValidatorOpts{AllowMissingExpiration: true, FixedNow: now})
+		require.NoError(t, err)
+
+		counter := NewExiCounter()
+		claims := &Claims{Exi: 60, CWTID: counter.Next("rs1")}
+		assert.ErrorContains(va.Validate(claims), "no ExiTracker configured")
+
+		tracker := NewExiTracker()
+		va, err = NewValidator(&ValidatorOpts{AllowMissingExpiration: true, FixedNow: now, ExiTracker: tracker})
+		require.NoError(t, err)
+		assert.NoError(va.Validate(claims))
+		assert.ErrorContains(va.Validate(&Claims{Exi: 60}), "token with the exi claim doesn't have a valid cti claim")
+		assert.ErrorContains(va.Validate(&Claims{Exi: 60, CWTID: []byte{0xff}}), "invalid sequence number")
+
+		va, err = NewValidator(&ValidatorOpts{AllowMissingExpiration: true, FixedNow: now.Add(time.Minute), ExiTracker: tracker})
+		require.NoError(t, err)
+		assert.ErrorContains(va.Validate(claims), "token has expired")
+		assert.NoError(va.Validate(&Claims{Exi: 60, CWTID: counter.Next("rs1")}))
+
+		// the rejected tokens are not recorded
+		tracker = NewExiTracker()
+		opts := &ValidatorOpts{AllowMissingExpiration: true, FixedNow: now, ExiTracker: tracker, ExpectedIssuer: "as1"}
+		va, err = NewValidator(opts)
+		require.NoError(t, err)
+		claims = &Claims{Exi: 60, CWTID: counter.Next("rs1"), Issuer: "as2"}
+		assert.ErrorContains(va.Validate(claims), "issuer mismatch")
+
+		opts.FixedNow = now.Add(time.Minute)
+		va, err = NewValidator(opts)
+		require.NoError(t, err)
+		claims.Issuer = "as1"
+		assert.NoError(va.Validate(claims), "received the first time")
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{
+			iana.CWTClaimExi: 60, iana.CWTClaimCti: counter.Next("rs1"), iana.CWTClaimIss: "as1", iana.CatM: "POST",
+		}, &Request{Method: "GET"}), "method \"GET\" is not allowed")
+
+		opts.FixedNow = now.Add(2 * time.Minute)
+		va, err = NewValidator(opts)
+		require.NoError(t, err)
+		assert.ErrorContains(va.Validate(claims), "token has expired")
+		cm := ClaimsMap{iana.CWTClaimExi: 60, iana.CWTClaimCti: counter.Next("rs1"), iana.CWTClaimIss: "as1"}
+		assert.NoError(va.ValidateMap(cm))
+		assert.Equal(uint64(3), tracker.Watermark())
+	})
+}
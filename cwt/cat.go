@@ -0,0 +1,337 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+// claimNames maps the registered CWT claim keys to their names.
+//
+// Reference https://www.iana.org/assignments/cwt/cwt.xhtml
+var claimNames = map[any]string{
+	iana.CWTClaimIss:         "iss",
+	iana.CWTClaimSub:         "sub",
+	iana.CWTClaimAud:         "aud",
+	iana.CWTClaimExp:         "exp",
+	iana.CWTClaimNbf:         "nbf",
+	iana.CWTClaimIat:         "iat",
+	iana.CWTClaimCti:         "cti",
+	iana.CWTClaimCnf:         "cnf",
+	iana.CWTClaimScope:       "scope",
+	iana.CWTClaimNonce:       "nonce",
+	iana.CWTClaimACEProfile:  "ace_profile",
+	iana.CWTClaimCNonce:      "cnonce",
+	iana.CWTClaimExi:         "exi",
+	iana.CWTClaimUEID:        "ueid",
+	iana.CWTClaimSUEIDs:      "sueids",
+	iana.CWTClaimOEMID:       "oemid",
+	iana.CWTClaimHWModel:     "hwmodel",
+	iana.CWTClaimHWVersion:   "hwversion",
+	iana.CWTClaimUptime:      "uptime",
+	iana.CWTClaimSecureBoot:  "oemboot",
+	iana.CWTClaimDebugStatus: "dbgstat",
+	iana.CWTClaimLocation:    "location",
+	iana.CWTClaimProfile:     "eat_profile",
+	iana.CWTClaimSubmodules:  "submods",
+	iana.CWTClaimStatusList:  "status_list",
+	iana.CWTClaimTTL:         "ttl",
+	iana.CWTClaimStatus:      "status",
+}
+
+// catClaimNames maps the Common Access Token (CAT) claim keys to their names.
+//
+// Reference CTA-5007 Common Access Token.
+var catClaimNames = map[any]string{
+	iana.Crit:          "crit",
+	iana.CatReplay:     "catreplay",
+	iana.CatPor:        "catpor",
+	iana.CatV:          "catv",
+	iana.Catnip:        "catnip",
+	iana.Catu:          "catu",
+	iana.CatM:          "catm",
+	iana.Catalpn:       "catalpn",
+	iana.Cath:          "cath",
+	iana.CatGeoISO3166: "catgeoiso3166",
+	iana.CatGeoCoord:   "catgeocoord",
+	iana.CatGeoAlt:     "catgeoalt",
+	iana.CatTPK:        "cattpk",
+	iana.CatIf:         "catif",
+	iana.CatR:          "catr",
+	iana.CatDpopW:      "catdpopw",
+	iana.CatDpopJti:    "catdpopjti",
+	iana.Geohash:       "geohash",
+	iana.Or:            "or",
+	iana.Nor:           "nor",
+	iana.And:           "and",
+	iana.Enc:           "enc",
+}
+
+// CATVersion is the version of the CAT specification supported by the Validator.
+const CATVersion = 1
+
+// supportedCATClaims is the set of CAT claims that the Validator understands.
+var supportedCATClaims = map[any]bool{
+	iana.Crit:      true,
+	iana.CatV:      true,
+	iana.CatIf:     true,
+	iana.CatM:      true,
+	iana.Catalpn:   true,
+	iana.Cath:      true,
+	iana.CatPor:    true,
+	iana.CatReplay: true,
+}
+
+// UnknownClaimPolicy defines how the Validator handles the CAT claims that it does not support.
+type UnknownClaimPolicy int
+
+const (
+	// IgnoreUnknownClaims ignores the unsupported CAT claims, unless they are listed in the "crit" claim.
+	IgnoreUnknownClaims UnknownClaimPolicy = iota
+	// RejectUnknownClaims rejects tokens with unsupported CAT claims.
+	RejectUnknownClaims
+)
+
+// ClaimName returns the name of the given claim key, such as "exp" for iana.CWTClaimExp,
+// "catm" for iana.CatM. If the claim key is unknown, it returns the claim key formatted as a string.
+func ClaimName(claim any) string {
+	if name, ok := claimNames[claim]; ok {
+		return name
+	}
+	if name, ok := catClaimNames[claim]; ok {
+		return name
+	}
+	return fmt.Sprint(claim)
+}
+
+// ClaimError is returned when a claim in a token fails validation.
+type ClaimError struct {
+	// Claim is the key of the failing claim, int or string.
+	Claim any
+	// Err is the reason why the claim failed.
+	Err error
+	// Response is the response to return for the failing claim, described by the "catif" claim.
+	// It is nil if the token does not describe one.
+	Response *CatIfResponse
+}
+
+// Error implements the error interface.
+func (e *ClaimError) Error() string {
+	return fmt.Sprintf("cose/cwt: %s claim rejected, %v", ClaimName(e.Claim), e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ClaimError) Unwrap() error {
+	return e.Err
+}
+
+// catRules returns the Rules for the CAT claims that do not depend on the request:
+// "catv" (version), "crit" (critical claims) and the unsupported CAT claims
+// according to the UnknownCATClaims policy.
+func (v *Validator) catRules() []*Rule {
+	catv := NewRule(iana.CatV, ExtractInt64, func(ver int64) error {
+		if ver != CATVersion {
+			return fmt.Errorf("unsupported version %d", ver)
+		}
+		return nil
+	})
+
+	crit := NewRule(iana.Crit, extractCrit, func(crit []any) error {
+		for _, c := range crit {
+			if !v.understands(c) {
+				return fmt.Errorf("unsupported critical claim %s", ClaimName(c))
+			}
+		}
+		return nil
+	})
+
+	rules := []*Rule{catv, crit}
+	if v.opts.UnknownCATClaims == RejectUnknownClaims {
+		unknown := make([]any, 0, len(catClaimNames))
+		for c := range catClaimNames {
+			if !supportedCATClaims[c] {
+				unknown = append(unknown, c)
+			}
+		}
+		// the int keys first, then the text keys, such as "geohash".
+		sort.Slice(unknown, func(i, j int) bool {
+			a, aInt := unknown[i].(int)
+			b, bInt := unknown[j].(int)
+			if aInt != bInt {
+				return aInt
+			}
+			if aInt {
+				return a < b
+			}
+			return unknown[i].(string) < unknown[j].(string)
+		})
+
+		for _, c := range unknown {
+			rules = append(rules, &Rule{
+				Claim: c,
+				validate: func(ClaimsMap, any) error {
+					return errors.New("unsupported claim")
+				},
+			})
+		}
+	}
+	return rules
+}
+
+// understands returns true if the claim is one of the registered claims in RFC8392 that
+// the Claims decodes, a claim that has Rules in the Validator, or a supported CAT claim.
+func (v *Validator) understands(claim any) bool {
+	if c, ok := claim.(int); ok && c >= iana.CWTClaimIss && c <= iana.CWTClaimCti {
+		return true
+	}
+	if _, ok := v.rules.rules[claim]; ok {
+		return true
+	}
+	return supportedCATClaims[claim]
+}
+
+// extractCrit converts the "crit" claim value, a non-empty array of claim keys, to a slice of claim keys.
+func extractCrit(val any) ([]any, error) {
+	var crit []any
+	switch x := val.(type) {
+	case []any:
+		crit = make([]any, 0, len(x))
+		for _, c := range x {
+			ck, err := toClaimKey(c)
+			if err != nil {
+				return nil, err
+			}
+			crit = append(crit, ck)
+		}
+	case []int:
+		for _, c := range x {
+			crit = append(crit, c)
+		}
+	}
+	if len(crit) == 0 {
+		return nil, fmt.Errorf("invalid value %v", val)
+	}
+	return crit, nil
+}
+
+// Match represents a CAT match object, it maps match types to match values.
+// The match types are the iana.Exact, iana.Prefix, iana.Suffix, iana.Contains,
+// iana.Regex, iana.Sha256 and iana.Sha512 constants.
+//
+// Reference CTA-5007 Common Access Token.
+type Match key.CoseMap
+
+// Matches returns true if the given string satisfies all match types in the Match.
+// It returns an error if the Match is empty or malformed.
+func (m Match) Matches(s string) (bool, error) {
+	if len(m) == 0 {
+		return false, fmt.Errorf("cose/cwt: Match.Matches: empty match object")
+	}
+
+	for k, v := range m {
+		mt, err := key.ToInt(k)
+		if err != nil {
+			return false, fmt.Errorf("cose/cwt: Match.Matches: invalid match type %v", k)
+		}
+
+		var ok bool
+		switch mt {
+		case iana.Exact, iana.Prefix, iana.Suffix, iana.Contains:
+			str, isStr := v.(string)
+			if !isStr {
+				return false, fmt.Errorf("cose/cwt: Match.Matches: invalid value type %T for match type %d", v, mt)
+			}
+
+			switch mt {
+			case iana.Exact:
+				ok = s == str
+			case iana.Prefix:
+				ok = strings.HasPrefix(s, str)
+			case iana.Suffix:
+				ok = strings.HasSuffix(s, str)
+			default:
+				ok = strings.Contains(s, str)
+			}
+
+		case iana.Regex:
+			// the value is a regular expression, or an array whose first element is the regular expression.
+			strs, err := toStrings(v)
+			if err != nil || len(strs) == 0 {
+				return false, fmt.Errorf("cose/cwt: Match.Matches: invalid value type %T for match type %d", v, mt)
+			}
+			re, err := regexp.Compile(strs[0])
+			if err != nil {
+				return false, fmt.Errorf("cose/cwt: Match.Matches: invalid regular expression, %w", err)
+			}
+			ok = re.MatchString(s)
+
+		case iana.Sha256, iana.Sha512:
+			sum, isBytes := v.([]byte)
+			if !isBytes {
+				return false, fmt.Errorf("cose/cwt: Match.Matches: invalid value type %T for match type %d", v, mt)
+			}
+
+			if mt == iana.Sha256 {
+				h := sha256.Sum256([]byte(s))
+				ok = bytes.Equal(h[:], sum)
+			} else {
+				h := sha512.Sum512([]byte(s))
+				ok = bytes.Equal(h[:], sum)
+			}
+
+		default:
+			return false, fmt.Errorf("cose/cwt: Match.Matches: unsupported match type %d", mt)
+		}
+
+		if !ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// toStrings converts a tstr or an array of tstr to a slice of strings.
+func toStrings(v any) ([]string, error) {
+	switch x := v.(type) {
+	case string:
+		return []string{x}, nil
+
+	case []string:
+		return x, nil
+
+	case []any:
+		strs := make([]string, len(x))
+		for i, e := range x {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid element type %T", e)
+			}
+			strs[i] = s
+		}
+		return strs, nil
+
+	default:
+		return nil, fmt.Errorf("invalid value type %T", v)
+	}
+}
+
+// toMatch converts a CBOR-decoded match object to a Match.
+func toMatch(v any) (Match, error) {
+	m, err := key.CoseMap{0: v}.GetMap(0)
+	if err != nil {
+		return nil, err
+	}
+	return Match(m), nil
+}
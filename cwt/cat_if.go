@@ -0,0 +1,126 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+// CatIfResponse represents the response described by the CAT "catif" claim,
+// it should be returned to the client when the corresponding claim fails.
+//
+// The "catif" claim is a map of claim keys to responses:
+//
+//	catif = { + claim-key => [ status: uint, ? headers: { + tstr => header-value } ] }
+//	header-value = tstr / [ + (tstr / { iana.CatIfData => claim-key }) ]
+//
+// A header value can be composed of several parts, a part { iana.CatIfData => claim-key }
+// is replaced with the value of the claim in the token, such as a redirect URL with the token's "cti".
+type CatIfResponse struct {
+	// Status is the HTTP status code, such as http.StatusFound.
+	Status int
+	// Header is the HTTP response header, such as "Location".
+	Header http.Header
+}
+
+// catIfResponses decodes the "catif" claim into responses, keyed by the claim keys.
+// It returns (nil, nil) if the claims has no "catif" claim.
+func catIfResponses(claims ClaimsMap) (map[any]*CatIfResponse, error) {
+	val, ok := claims[iana.CatIf]
+	if !ok {
+		return nil, nil
+	}
+
+	m, err := toMatch(val) // a map of claim keys to responses
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make(map[any]*CatIfResponse, len(m))
+	for ck, rv := range m {
+		arr, ok := rv.([]any)
+		if !ok || len(arr) == 0 || len(arr) > 2 {
+			return nil, fmt.Errorf("invalid response for claim %s", ClaimName(ck))
+		}
+
+		status, err := key.ToInt(arr[0])
+		if err != nil || status < 100 || status > 599 {
+			return nil, fmt.Errorf("invalid status %v for claim %s", arr[0], ClaimName(ck))
+		}
+
+		resp := &CatIfResponse{Status: status, Header: http.Header{}}
+		if len(arr) == 2 {
+			headers, err := toMatch(arr[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid headers for claim %s, %w", ClaimName(ck), err)
+			}
+
+			for hk, hv := range headers {
+				name, ok := hk.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid header name %v for claim %s", hk, ClaimName(ck))
+				}
+
+				value, err := catIfHeaderValue(claims, hv)
+				if err != nil {
+					return nil, fmt.Errorf("invalid header %q for claim %s, %w", name, ClaimName(ck), err)
+				}
+				resp.Header.Add(name, value)
+			}
+		}
+
+		responses[ck] = resp
+	}
+
+	return responses, nil
+}
+
+// catIfHeaderValue composes a header value with the given parts.
+func catIfHeaderValue(claims ClaimsMap, val any) (string, error) {
+	switch x := val.(type) {
+	case string:
+		return x, nil
+
+	case []any:
+		var b strings.Builder
+		for _, part := range x {
+			if s, ok := part.(string); ok {
+				b.WriteString(s)
+				continue
+			}
+
+			ref, err := toMatch(part)
+			if err != nil {
+				return "", err
+			}
+			ck, ok := ref[iana.CatIfData]
+			if !ok || len(ref) != 1 {
+				return "", fmt.Errorf("invalid part %v", part)
+			}
+			if k, err := key.ToInt(ck); err == nil {
+				ck = k
+			}
+
+			switch v := claims[ck].(type) {
+			case nil:
+				return "", fmt.Errorf("missing claim %s", ClaimName(ck))
+			case string:
+				b.WriteString(v)
+			case []byte:
+				b.WriteString(key.ByteStr(v).Base64())
+			default:
+				fmt.Fprint(&b, v)
+			}
+		}
+		return b.String(), nil
+
+	default:
+		return "", fmt.Errorf("invalid value type %T", val)
+	}
+}
@@ -0,0 +1,130 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatIf(t *testing.T) {
+	va, err := NewValidator(&ValidatorOpts{AllowMissingExpiration: true})
+	require.NoError(t, err)
+	req := &Request{Method: "GET", ALPN: "h2"}
+
+	t.Run("response", func(t *testing.T) {
+		assert := assert.New(t)
+
+		claims := ClaimsMap{
+			iana.CWTClaimCti: []byte{1, 2, 3, 4},
+			iana.CatM:        "POST",
+			iana.Catalpn:     "h2",
+			iana.CatIf: map[any]any{
+				iana.CatM: []any{http.StatusFound, map[any]any{
+					"Location": []any{"https://example.com/renew?cti=", map[any]any{iana.CatIfData: iana.CWTClaimCti}},
+					"X-Reason": "method",
+				}},
+				iana.Catalpn: []any{http.StatusForbidden},
+			},
+		}
+
+		// decoded from CBOR
+		var claims2 ClaimsMap
+		require.NoError(t, key.UnmarshalCBOR(claims.Bytesify(), &claims2))
+
+		for _, cm := range []ClaimsMap{claims, claims2} {
+			err := va.ValidateRequest(cm, req)
+			var ce *ClaimError
+			require.True(t, errors.As(err, &ce))
+			assert.Equal(iana.CatM, ce.Claim)
+			require.NotNil(t, ce.Response)
+			assert.Equal(http.StatusFound, ce.Response.Status)
+			assert.Equal("https://example.com/renew?cti=AQIDBA", ce.Response.Header.Get("Location"))
+			assert.Equal("method", ce.Response.Header.Get("X-Reason"))
+		}
+
+		claims[iana.CatM] = "GET"
+		claims[iana.Catalpn] = "h3"
+		err := va.ValidateRequest(claims, req)
+		var ce *ClaimError
+		require.True(t, errors.As(err, &ce))
+		assert.Equal(iana.Catalpn, ce.Claim)
+		require.NotNil(t, ce.Response)
+		assert.Equal(http.StatusForbidden, ce.Response.Status)
+		assert.Equal(0, len(ce.Response.Header))
+
+		delete(claims, iana.CatIf)
+		err = va.ValidateRequest(claims, req)
+		require.True(t, errors.As(err, &ce))
+		assert.Nil(ce.Response)
+	})
+
+	t.Run("registered claims", func(t *testing.T) {
+		assert := assert.New(t)
+
+		claims := ClaimsMap{
+			iana.CWTClaimExp: 123,
+			iana.CatIf: map[any]any{
+				iana.CWTClaimExp: []any{http.StatusFound, map[any]any{"Location": "https://example.com/renew"}},
+			},
+		}
+		err := va.ValidateRequest(claims, req)
+		assert.ErrorContains(err, "token has expired")
+
+		var ce *ClaimError
+		require.True(t, errors.As(err, &ce))
+		assert.Equal(iana.CWTClaimExp, ce.Claim)
+		require.NotNil(t, ce.Response)
+		assert.Equal(http.StatusFound, ce.Response.Status)
+		assert.Equal("https://example.com/renew", ce.Response.Header.Get("Location"))
+	})
+
+	t.Run("header parts", func(t *testing.T) {
+		assert := assert.New(t)
+
+		claims := ClaimsMap{
+			iana.CWTClaimIss: "ldclabs",
+			iana.CWTClaimExp: 9999999999,
+			iana.CatM:        "POST",
+			iana.CatIf: map[any]any{
+				iana.CatM: []any{http.StatusFound, map[any]any{
+					"Location": []any{"/", map[any]any{iana.CatIfData: iana.CWTClaimIss}, "/", map[any]any{iana.CatIfData: iana.CWTClaimExp}},
+				}},
+			},
+		}
+		var ce *ClaimError
+		require.True(t, errors.As(va.ValidateRequest(claims, req), &ce))
+		assert.Equal("/ldclabs/9999999999", ce.Response.Header.Get("Location"))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert := assert.New(t)
+
+		for _, tc := range []struct {
+			catif any
+			err   string
+		}{
+			{"302", "catif claim rejected"},
+			{map[any]any{iana.CatM: 302}, "invalid response for claim catm"},
+			{map[any]any{iana.CatM: []any{}}, "invalid response for claim catm"},
+			{map[any]any{iana.CatM: []any{302, map[any]any{}, 1}}, "invalid response for claim catm"},
+			{map[any]any{iana.CatM: []any{"302"}}, "invalid status 302 for claim catm"},
+			{map[any]any{iana.CatM: []any{600}}, "invalid status 600 for claim catm"},
+			{map[any]any{iana.CatM: []any{302, "Location"}}, "invalid headers for claim catm"},
+			{map[any]any{iana.CatM: []any{302, map[any]any{1: "/"}}}, "invalid header name 1 for claim catm"},
+			{map[any]any{iana.CatM: []any{302, map[any]any{"Location": 1}}}, `invalid header "Location" for claim catm, invalid value type int`},
+			{map[any]any{iana.CatM: []any{302, map[any]any{"Location": []any{1}}}}, `invalid header "Location" for claim catm`},
+			{map[any]any{iana.CatM: []any{302, map[any]any{"Location": []any{map[any]any{1: 1}}}}}, `invalid header "Location" for claim catm, invalid part`},
+			{map[any]any{iana.CatM: []any{302, map[any]any{"Location": []any{map[any]any{iana.CatIfData: iana.CWTClaimSub}}}}}, `invalid header "Location" for claim catm, missing claim sub`},
+		} {
+			assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.CatIf: tc.catif}, req), tc.err)
+		}
+	})
+}
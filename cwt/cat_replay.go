@@ -0,0 +1,204 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+// Replay modes of the CAT "catreplay" claim.
+const (
+	// ReplayPermitted permits the token to be used more than once.
+	ReplayPermitted = 0
+	// ReplayProhibited rejects the token when it has been used before.
+	ReplayProhibited = 1
+	// ReplayReuseDetection permits the token to be used more than once,
+	// but reports the reuse with ValidatorOpts.OnReuseDetected.
+	ReplayReuseDetection = 2
+)
+
+// ReplayStore records the CWT IDs ("cti") of the tokens that have been used.
+// Implementations must be safe for concurrent use.
+type ReplayStore interface {
+	// Seen records the given CWT ID, and returns true if it has been recorded before.
+	// exp is the expiration time of the token, or the zero time if the token has no expiration,
+	// the store can forget the CWT ID after exp.
+	Seen(cti []byte, exp time.Time) (bool, error)
+}
+
+// validateCatReplay validates the "catreplay" claim with the ValidatorOpts.ReplayStore.
+func (v *Validator) validateCatReplay(claims ClaimsMap, val any, _ *Request) error {
+	mode, err := key.ToInt(val)
+	if err != nil {
+		return err
+	}
+
+	switch mode {
+	case ReplayPermitted:
+		return nil
+
+	case ReplayProhibited, ReplayReuseDetection:
+		// continue
+
+	default:
+		return fmt.Errorf("unsupported replay mode %d", mode)
+	}
+
+	if v.opts.ReplayStore == nil {
+		return errors.New("no ReplayStore configured")
+	}
+
+	cti, err := claims.GetBytes(iana.CWTClaimCti)
+	if err != nil {
+		return fmt.Errorf("invalid cti claim, %w", err)
+	}
+	if len(cti) == 0 {
+		return errors.New("missing cti claim")
+	}
+
+	var expAt time.Time
+	if exp, _ := claims.GetUint64(iana.CWTClaimExp); exp > 0 {
+		expAt = toTime(exp)
+	}
+
+	seen, err := v.opts.ReplayStore.Seen(cti, expAt)
+	switch {
+	case err != nil:
+		return err
+
+	case seen && mode == ReplayProhibited:
+		return fmt.Errorf("token %x has been used before", cti)
+
+	case seen && v.opts.OnReuseDetected != nil:
+		v.opts.OnReuseDetected(claims)
+	}
+
+	return nil
+}
+
+// LRUReplayStore is a ReplayStore that keeps the most recently used CWT IDs in memory.
+// The least recently used CWT ID is evicted when the store is full.
+type LRUReplayStore struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// NewLRUReplayStore creates a new LRUReplayStore that keeps at most size CWT IDs.
+func NewLRUReplayStore(size int) (*LRUReplayStore, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("cose/cwt: NewLRUReplayStore: invalid size %d", size)
+	}
+
+	return &LRUReplayStore{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+	}, nil
+}
+
+// Seen implements the ReplayStore interface.
+// The expiration time is ignored, CWT IDs are only evicted when the store is full.
+func (s *LRUReplayStore) Seen(cti []byte, _ time.Time) (bool, error) {
+	id := string(cti)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.items[id]; ok {
+		s.ll.MoveToFront(e)
+		return true, nil
+	}
+
+	s.items[id] = s.ll.PushFront(id)
+	if s.ll.Len() > s.size {
+		e := s.ll.Back()
+		s.ll.Remove(e)
+		delete(s.items, e.Value.(string))
+	}
+	return false, nil
+}
+
+// Len returns the number of CWT IDs in the store.
+func (s *LRUReplayStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+// TTLReplayStore is a ReplayStore that keeps CWT IDs in memory until the tokens expire,
+// but no longer than the TTL.
+type TTLReplayStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	items     map[string]time.Time
+	nextPurge time.Time
+	clock     Clock
+}
+
+// NewTTLReplayStore creates a new TTLReplayStore that keeps CWT IDs at most ttl.
+// clock provides the current time to expire the CWT IDs, it should be the same Clock
+// as ValidatorOpts.Clock. SystemClock is used if it is nil.
+func NewTTLReplayStore(ttl time.Duration, clock Clock) (*TTLReplayStore, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("cose/cwt: NewTTLReplayStore: invalid ttl %v", ttl)
+	}
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	return &TTLReplayStore{
+		ttl:   ttl,
+		items: make(map[string]time.Time),
+		clock: clock,
+	}, nil
+}
+
+// Seen implements the ReplayStore interface.
+func (s *TTLReplayStore) Seen(cti []byte, exp time.Time) (bool, error) {
+	id := string(cti)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	if !now.Before(s.nextPurge) {
+		s.purge(now)
+	}
+
+	if until, ok := s.items[id]; ok && now.Before(until) {
+		return true, nil
+	}
+
+	until := now.Add(s.ttl)
+	if !exp.IsZero() && exp.Before(until) {
+		until = exp
+	}
+	s.items[id] = until
+	return false, nil
+}
+
+// Len returns the number of CWT IDs in the store, including the expired ones that are not purged yet.
+func (s *TTLReplayStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+func (s *TTLReplayStore) purge(now time.Time) {
+	for id, until := range s.items {
+		if !now.Before(until) {
+			delete(s.items, id)
+		}
+	}
+	s.nextPurge = now.Add(s.ttl)
+}
@@ -0,0 +1,199 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUReplayStore(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := NewLRUReplayStore(0)
+	assert.ErrorContains(err, "invalid size 0")
+	assert.Nil(s)
+
+	s, err = NewLRUReplayStore(2)
+	require.NoError(t, err)
+
+	seen, err := s.Seen([]byte{1}, time.Time{})
+	require.NoError(t, err)
+	assert.False(seen)
+	seen, _ = s.Seen([]byte{2}, time.Time{})
+	assert.False(seen)
+	seen, _ = s.Seen([]byte{1}, time.Time{})
+	assert.True(seen)
+	assert.Equal(2, s.Len())
+
+	// evicts the least recently used {2}
+	seen, _ = s.Seen([]byte{3}, time.Time{})
+	assert.False(seen)
+	assert.Equal(2, s.Len())
+	seen, _ = s.Seen([]byte{1}, time.Time{})
+	assert.True(seen)
+	seen, _ = s.Seen([]byte{2}, time.Time{})
+	assert.False(seen)
+}
+
+func TestTTLReplayStore(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := NewTTLReplayStore(0, nil)
+	assert.ErrorContains(err, "invalid ttl")
+	assert.Nil(s)
+
+	s, err = NewTTLReplayStore(time.Minute, nil)
+	require.NoError(t, err)
+	assert.NotNil(s.clock)
+
+	now := time.Unix(3600, 0)
+	s, err = NewTTLReplayStore(time.Minute, ClockFunc(func() time.Time { return now }))
+	require.NoError(t, err)
+
+	seen, err := s.Seen([]byte{1}, time.Time{})
+	require.NoError(t, err)
+	assert.False(seen)
+	seen, _ = s.Seen([]byte{2}, now.Add(10*time.Second))
+	assert.False(seen)
+	seen, _ = s.Seen([]byte{1}, time.Time{})
+	assert.True(seen)
+	seen, _ = s.Seen([]byte{2}, now.Add(10*time.Second))
+	assert.True(seen)
+
+	// {2} expired with the token
+	now = now.Add(10 * time.Second)
+	seen, _ = s.Seen([]byte{2}, now.Add(10*time.Second))
+	assert.False(seen)
+	seen, _ = s.Seen([]byte{1}, time.Time{})
+	assert.True(seen)
+
+	// {1} expired with the ttl, and all expired items are purged
+	now = now.Add(time.Minute)
+	assert.Equal(2, s.Len())
+	seen, _ = s.Seen([]byte{3}, time.Time{})
+	assert.False(seen)
+	assert.Equal(1, s.Len())
+	seen, _ = s.Seen([]byte{1}, time.Time{})
+	assert.False(seen)
+}
+
+func TestReplayStoreConcurrency(t *testing.T) {
+	lru, err := NewLRUReplayStore(100)
+	require.NoError(t, err)
+	ttl, err := NewTTLReplayStore(time.Minute, nil)
+	require.NoError(t, err)
+
+	for _, s := range []ReplayStore{lru, ttl} {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		first := 0
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				seen, err := s.Seen([]byte("same"), time.Time{})
+				assert.NoError(t, err)
+				if !seen {
+					mu.Lock()
+					first++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, first, fmt.Sprintf("%T", s))
+	}
+}
+
+func TestValidateCatReplay(t *testing.T) {
+	req := &Request{Method: "GET"}
+
+	t.Run("ReplayPermitted", func(t *testing.T) {
+		assert := assert.New(t)
+
+		va, err := NewValidator(&ValidatorOpts{AllowMissingExpiration: true})
+		require.NoError(t, err)
+		claims := ClaimsMap{iana.CatReplay: ReplayPermitted}
+		assert.NoError(va.ValidateRequest(claims, req))
+		assert.NoError(va.ValidateRequest(claims, req))
+
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.CatReplay: 1},
+			req), "catreplay claim rejected, no ReplayStore configured")
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.CatReplay: 3},
+			req), "catreplay claim rejected, unsupported replay mode 3")
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.CatReplay: "1"},
+			req), "catreplay claim rejected")
+	})
+
+	t.Run("ReplayProhibited", func(t *testing.T) {
+		assert := assert.New(t)
+
+		store, err := NewLRUReplayStore(10)
+		require.NoError(t, err)
+		va, err := NewValidator(&ValidatorOpts{AllowMissingExpiration: true, ReplayStore: store})
+		require.NoError(t, err)
+
+		claims := ClaimsMap{iana.CatReplay: ReplayProhibited, iana.CWTClaimCti: []byte{1, 2, 3, 4}}
+		assert.NoError(va.ValidateRequest(claims, req))
+		assert.ErrorContains(va.ValidateRequest(claims, req),
+			"catreplay claim rejected, token 01020304 has been used before")
+
+		// rejected requests are not recorded
+		claims = ClaimsMap{iana.CatReplay: ReplayProhibited, iana.CWTClaimCti: []byte{5}, iana.CatM: "POST"}
+		assert.ErrorContains(va.ValidateRequest(claims, req), "catm claim rejected")
+		claims[iana.CatM] = "GET"
+		assert.NoError(va.ValidateRequest(claims, req))
+
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.CatReplay: ReplayProhibited}, req),
+			"catreplay claim rejected, missing cti claim")
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.CatReplay: ReplayProhibited, iana.CWTClaimCti: 1}, req),
+			"catreplay claim rejected, invalid cti claim")
+
+		// the TTLReplayStore uses the same Clock as the Validator
+		clock := FixedClock(time.Unix(1700000000, 0))
+		ttl, err := NewTTLReplayStore(time.Hour, clock)
+		require.NoError(t, err)
+		va, err = NewValidator(&ValidatorOpts{Clock: clock, ReplayStore: ttl})
+		require.NoError(t, err)
+		claims = ClaimsMap{
+			iana.CatReplay:   ReplayProhibited,
+			iana.CWTClaimCti: []byte{6},
+			iana.CWTClaimExp: uint64(clock.Now().Add(time.Minute).Unix()),
+		}
+		assert.NoError(va.ValidateRequest(claims, req))
+		assert.ErrorContains(va.ValidateRequest(claims, req),
+			"catreplay claim rejected, token 06 has been used before")
+	})
+
+	t.Run("ReplayReuseDetection", func(t *testing.T) {
+		assert := assert.New(t)
+
+		store, err := NewTTLReplayStore(time.Hour, nil)
+		require.NoError(t, err)
+		var reused []ClaimsMap
+		va, err := NewValidator(&ValidatorOpts{
+			ReplayStore:     store,
+			OnReuseDetected: func(claims ClaimsMap) { reused = append(reused, claims) },
+		})
+		require.NoError(t, err)
+
+		claims := ClaimsMap{
+			iana.CatReplay:   ReplayReuseDetection,
+			iana.CWTClaimCti: []byte{1, 2, 3, 4},
+			iana.CWTClaimExp: time.Now().Unix() + 60,
+		}
+		assert.NoError(va.ValidateRequest(claims, req))
+		assert.Equal(0, len(reused))
+		assert.NoError(va.ValidateRequest(claims, req))
+		assert.Equal(1, len(reused))
+		assert.Equal(claims, reused[0])
+	})
+}
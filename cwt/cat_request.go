@@ -0,0 +1,186 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ldclabs/cose/iana"
+)
+
+// Request represents the properties of an HTTP request that
+// the CAT request-binding claims are validated against.
+type Request struct {
+	// Method is the HTTP method, such as "GET".
+	Method string
+	// ALPN is the negotiated application-layer protocol, such as "h2".
+	ALPN string
+	// Header is the HTTP request header.
+	Header http.Header
+}
+
+// RequestFromHTTP returns a *Request with the given *http.Request.
+func RequestFromHTTP(r *http.Request) *Request {
+	req := &Request{
+		Method: r.Method,
+		Header: r.Header,
+	}
+
+	if r.TLS != nil {
+		req.ALPN = r.TLS.NegotiatedProtocol
+	}
+	return req
+}
+
+// ValidateRequest validates a ClaimsMap according to the options provided as ValidateMap,
+// including the "crit" (critical claims) and "catv" (version) claims and the ValidatorOpts.UnknownCATClaims
+// policy, and then validates the request-bound CAT claims against the given request:
+// "catm" (allowed methods), "catalpn" (allowed ALPN), "cath" (header match rules),
+// "catpor" (probability of rejection) and "catreplay" (replay protection).
+// If a CAT claim fails, the error is a *ClaimError that reports the claim,
+// and the response described by the "catif" claim. The response is also attached to
+// the violations of the *ValidationError returned by ValidateMap.
+func (v *Validator) ValidateRequest(claims ClaimsMap, req *Request) error {
+	if claims == nil {
+		return errors.New("cose/cwt: Validator.ValidateRequest: nil ClaimsMap")
+	}
+	if req == nil {
+		return errors.New("cose/cwt: Validator.ValidateRequest: nil Request")
+	}
+
+	responses, err := catIfResponses(claims)
+	if err != nil {
+		return &ClaimError{Claim: iana.CatIf, Err: err}
+	}
+
+	if err := v.rules.Validate(claims); err != nil {
+		var ve *ValidationError
+		if errors.As(err, &ve) {
+			for _, ce := range ve.Violations {
+				ce.Response = responses[ce.Claim]
+			}
+		}
+		return err
+	}
+
+	for _, c := range []struct {
+		claim    int
+		validate func(ClaimsMap, any, *Request) error
+	}{
+		{iana.CatM, validateCatM},
+		{iana.Catalpn, validateCatalpn},
+		{iana.Cath, validateCath},
+		{iana.CatPor, v.validateCatPor},
+		// replay protection should be the last one, so that rejected requests are not recorded.
+		{iana.CatReplay, v.validateCatReplay},
+	} {
+		if val, ok := claims[c.claim]; ok {
+			if err := c.validate(claims, val, req); err != nil {
+				return &ClaimError{Claim: c.claim, Err: err, Response: responses[c.claim]}
+			}
+		}
+	}
+
+	v.commit(claims)
+	return nil
+}
+
+// validateCatM validates the "catm" claim, a method or an array of methods.
+func validateCatM(_ ClaimsMap, val any, req *Request) error {
+	methods, err := toStrings(val)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range methods {
+		if strings.EqualFold(m, req.Method) {
+			return nil
+		}
+	}
+	return fmt.Errorf("method %q is not allowed", req.Method)
+}
+
+// validateCatalpn validates the "catalpn" claim, an ALPN identifier or an array of ALPN identifiers.
+func validateCatalpn(_ ClaimsMap, val any, req *Request) error {
+	alpns, err := toStrings(val)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range alpns {
+		if a == req.ALPN {
+			return nil
+		}
+	}
+	return fmt.Errorf("ALPN %q is not allowed", req.ALPN)
+}
+
+// validateCath validates the "cath" claim, a map of header names to match objects.
+// Every header in the claim must be present in the request, and one of its values must match.
+func validateCath(_ ClaimsMap, val any, req *Request) error {
+	rules, err := toMatch(val)
+	if err != nil {
+		return err
+	}
+
+	for name, rule := range rules {
+		hn, ok := name.(string)
+		if !ok {
+			return fmt.Errorf("invalid header name %v", name)
+		}
+
+		m, err := toMatch(rule)
+		if err != nil {
+			return fmt.Errorf("invalid match object for header %q, %w", hn, err)
+		}
+
+		matched := false
+		for _, hv := range req.Header.Values(hn) {
+			if matched, err = m.Matches(hv); err != nil {
+				return err
+			}
+			if matched {
+				break
+			}
+		}
+
+		if !matched {
+			return fmt.Errorf("header %q does not match", hn)
+		}
+	}
+
+	return nil
+}
+
+// validateCatPor validates the "catpor" claim, a percentage in [0, 100]
+// that the request should be rejected with.
+func (v *Validator) validateCatPor(_ ClaimsMap, val any, _ *Request) error {
+	por, err := ClaimsMap{iana.CatPor: val}.GetUint64(iana.CatPor)
+	if err != nil {
+		return err
+	}
+	if por > 100 {
+		return fmt.Errorf("invalid probability %d", por)
+	}
+
+	r := v.opts.Rand
+	if r == nil {
+		r = rand.Reader
+	}
+
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return err
+	}
+	if uint64(binary.BigEndian.Uint32(buf[:])%100) < por {
+		return fmt.Errorf("request rejected with probability %d%%", por)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestFromHTTP(t *testing.T) {
+	assert := assert.New(t)
+
+	r := httptest.NewRequest(http.MethodPost, "https://example.com/video.m3u8", nil)
+	r.Header.Set("User-Agent", "player/1.0")
+	req := RequestFromHTTP(r)
+	assert.Equal(http.MethodPost, req.Method)
+	assert.Equal("", req.ALPN)
+	assert.Equal("player/1.0", req.Header.Get("User-Agent"))
+
+	r.TLS = nil
+	assert.Equal("", RequestFromHTTP(r).ALPN)
+
+	r.TLS = &tls.ConnectionState{NegotiatedProtocol: "http/1.1"}
+	assert.Equal("http/1.1", RequestFromHTTP(r).ALPN)
+}
+
+func TestValidateRequest(t *testing.T) {
+	va, err := NewValidator(&ValidatorOpts{AllowMissingExpiration: true})
+	require.NoError(t, err)
+
+	req := &Request{
+		Method: http.MethodGet,
+		ALPN:   "h2",
+		Header: http.Header{
+			"User-Agent": []string{"curl/8.0", "player/1.0"},
+			"Referer":    []string{"https://example.com/index.html"},
+		},
+	}
+
+	t.Run("common", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{}, nil), "nil Request")
+		assert.ErrorContains(va.ValidateRequest(nil, req), "nil ClaimsMap")
+		assert.NoError(va.ValidateRequest(ClaimsMap{}, req))
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.CWTClaimExp: 123}, req), "token has expired")
+	})
+
+	t.Run("catm", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.NoError(va.ValidateRequest(ClaimsMap{iana.CatM: "GET"}, req))
+		assert.NoError(va.ValidateRequest(ClaimsMap{iana.CatM: []any{"head", "get"}}, req))
+
+		err := va.ValidateRequest(ClaimsMap{iana.CatM: []any{"POST", "PUT"}}, req)
+		var ce *ClaimError
+		require.True(t, errors.As(err, &ce))
+		assert.Equal(iana.CatM, ce.Claim)
+		assert.ErrorContains(err, `catm claim rejected, method "GET" is not allowed`)
+
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.CatM: 1}, req), "catm claim rejected, invalid value type int")
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.CatM: []any{1}}, req), "catm claim rejected, invalid element type")
+	})
+
+	t.Run("catalpn", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.NoError(va.ValidateRequest(ClaimsMap{iana.Catalpn: "h2"}, req))
+		assert.NoError(va.ValidateRequest(ClaimsMap{iana.Catalpn: []any{"h3", "h2"}}, req))
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.Catalpn: []any{"h3", "http/1.1"}}, req),
+			`catalpn claim rejected, ALPN "h2" is not allowed`)
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.Catalpn: 1}, req), "catalpn claim rejected")
+	})
+
+	t.Run("cath", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.NoError(va.ValidateRequest(ClaimsMap{iana.Cath: map[any]any{
+			"user-agent": map[any]any{iana.Prefix: "player/"},
+			"Referer":    map[any]any{iana.Suffix: ".html", iana.Contains: "example.com"},
+		}}, req))
+
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.Cath: map[any]any{
+			"User-Agent": map[any]any{iana.Exact: "player/2.0"},
+		}}, req), `cath claim rejected, header "User-Agent" does not match`)
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.Cath: map[any]any{
+			"Origin": map[any]any{iana.Contains: ""},
+		}}, req), `cath claim rejected, header "Origin" does not match`)
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.Cath: map[any]any{
+			"User-Agent": map[any]any{iana.Exact: 1},
+		}}, req), "cath claim rejected, cose/cwt: Match.Matches: invalid value type int")
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.Cath: map[any]any{
+			"User-Agent": "player/1.0",
+		}}, req), `cath claim rejected, invalid match object for header "User-Agent"`)
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.Cath: map[any]any{
+			1: map[any]any{iana.Exact: "player/1.0"},
+		}}, req), "cath claim rejected, invalid header name 1")
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.Cath: "User-Agent"}, req),
+			"cath claim rejected")
+
+		// decoded from CBOR
+		claims := ClaimsMap{iana.Cath: map[any]any{
+			"User-Agent": map[any]any{iana.Exact: "curl/8.0"},
+		}}
+		var claims2 ClaimsMap
+		require.NoError(t, key.UnmarshalCBOR(claims.Bytesify(), &claims2))
+		assert.NoError(va.ValidateRequest(claims2, req))
+	})
+
+	t.Run("catpor", func(t *testing.T) {
+		assert := assert.New(t)
+
+		roll := func(n uint32) *bytes.Reader {
+			return bytes.NewReader([]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)})
+		}
+
+		va, err := NewValidator(&ValidatorOpts{AllowMissingExpiration: true, Rand: roll(10)})
+		require.NoError(t, err)
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.CatPor: 11}, req),
+			"catpor claim rejected, request rejected with probability 11%")
+
+		va, err = NewValidator(&ValidatorOpts{AllowMissingExpiration: true, Rand: roll(10)})
+		require.NoError(t, err)
+		assert.NoError(va.ValidateRequest(ClaimsMap{iana.CatPor: 10}, req))
+
+		va, err = NewValidator(&ValidatorOpts{AllowMissingExpiration: true, Rand: roll(0)})
+		require.NoError(t, err)
+		assert.NoError(va.ValidateRequest(ClaimsMap{iana.CatPor: 0}, req))
+
+		va, err = NewValidator(&ValidatorOpts{AllowMissingExpiration: true, Rand: roll(0)})
+		require.NoError(t, err)
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.CatPor: 101}, req), "invalid probability 101")
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.CatPor: "10"}, req), "catpor claim rejected")
+
+		va, err = NewValidator(&ValidatorOpts{AllowMissingExpiration: true, Rand: bytes.NewReader(nil)})
+		require.NoError(t, err)
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.CatPor: 50}, req), "EOF")
+
+		va, err = NewValidator(&ValidatorOpts{AllowMissingExpiration: true})
+		require.NoError(t, err)
+		assert.NoError(va.ValidateRequest(ClaimsMap{iana.CatPor: 0}, req))
+		assert.ErrorContains(va.ValidateRequest(ClaimsMap{iana.CatPor: 100}, req), "catpor claim rejected")
+	})
+}
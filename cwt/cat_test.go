@@ -0,0 +1,159 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"testing"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	sum256 := sha256.Sum256([]byte("hello world"))
+	sum512 := sha512.Sum512([]byte("hello world"))
+
+	for _, tc := range []struct {
+		m   Match
+		ok  bool
+		err string
+	}{
+		{Match{iana.Exact: "hello world"}, true, ""},
+		{Match{iana.Exact: "hello"}, false, ""},
+		{Match{iana.Prefix: "hello"}, true, ""},
+		{Match{iana.Prefix: "world"}, false, ""},
+		{Match{iana.Suffix: "world"}, true, ""},
+		{Match{iana.Suffix: "hello"}, false, ""},
+		{Match{iana.Contains: "o w"}, true, ""},
+		{Match{iana.Contains: "ow"}, false, ""},
+		{Match{iana.Regex: "^h.+d$"}, true, ""},
+		{Match{iana.Regex: []any{"^w"}}, false, ""},
+		{Match{iana.Sha256: sum256[:]}, true, ""},
+		{Match{iana.Sha256: sum512[:]}, false, ""},
+		{Match{iana.Sha512: sum512[:]}, true, ""},
+		{Match{iana.Prefix: "hello", iana.Suffix: "world"}, true, ""},
+		{Match{iana.Prefix: "hello", iana.Suffix: "hello"}, false, ""},
+		{Match{}, false, "empty match object"},
+		{Match{"exact": "hello"}, false, "invalid match type"},
+		{Match{99: "hello"}, false, "unsupported match type 99"},
+		{Match{iana.Exact: 1}, false, "invalid value type int for match type 0"},
+		{Match{iana.Regex: 1}, false, "invalid value type int for match type 4"},
+		{Match{iana.Regex: "("}, false, "invalid regular expression"},
+		{Match{iana.Sha256: "hello"}, false, "invalid value type string for match type -1"},
+	} {
+		ok, err := tc.m.Matches("hello world")
+		if tc.err != "" {
+			assert.ErrorContains(err, tc.err)
+		} else {
+			assert.NoError(err)
+		}
+		assert.Equal(tc.ok, ok, tc.m)
+	}
+}
+
+func TestClaimError(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("catm", ClaimName(iana.CatM))
+	assert.Equal("catdpopjti", ClaimName(iana.CatDpopJti))
+	assert.Equal("999", ClaimName(999))
+
+	reason := errors.New("some reason")
+	err := &ClaimError{Claim: iana.Catalpn, Err: reason}
+	assert.Equal("cose/cwt: catalpn claim rejected, some reason", err.Error())
+	assert.ErrorIs(err, reason)
+}
+
+func TestValidateCatV(t *testing.T) {
+	assert := assert.New(t)
+
+	va, err := NewValidator(&ValidatorOpts{AllowMissingExpiration: true})
+	require.NoError(t, err)
+	req := &Request{Method: "GET"}
+
+	assert.NoError(va.ValidateRequest(ClaimsMap{iana.CatV: 1}, req))
+	assert.NoError(va.ValidateMap(ClaimsMap{iana.CatV: 1}))
+
+	for _, tc := range []struct {
+		val any
+		err string
+	}{
+		{2, "unsupported version 2"},
+		{"1", "token has an invalid catv claim"},
+	} {
+		for _, err := range []error{
+			va.ValidateRequest(ClaimsMap{iana.CatV: tc.val}, req),
+			va.ValidateMap(ClaimsMap{iana.CatV: tc.val}),
+		} {
+			var ve *ValidationError
+			require.ErrorAs(t, err, &ve)
+			assert.True(ve.Has(iana.CatV))
+			assert.ErrorContains(err, tc.err)
+		}
+	}
+}
+
+func TestValidateUnknownClaims(t *testing.T) {
+	assert := assert.New(t)
+
+	req := &Request{Method: "GET"}
+	va, err := NewValidator(&ValidatorOpts{AllowMissingExpiration: true})
+	require.NoError(t, err)
+
+	assert.NoError(va.ValidateRequest(ClaimsMap{iana.Catu: map[any]any{}, 999: 1}, req))
+	assert.NoError(va.ValidateRequest(ClaimsMap{iana.Crit: []any{uint64(iana.CatM), iana.CWTClaimExp}}, req))
+	assert.NoError(va.ValidateRequest(ClaimsMap{iana.Crit: []int{iana.CatV}}, req))
+	// the claims that have Rules are understood
+	assert.NoError(va.ValidateMap(ClaimsMap{iana.Crit: []any{
+		uint64(iana.CWTClaimCnf), uint64(iana.CWTClaimExi), uint64(iana.CWTClaimACEProfile), uint64(iana.CWTClaimStatus),
+	}}))
+
+	for _, tc := range []struct {
+		crit any
+		err  string
+	}{
+		{[]any{iana.Catu}, "unsupported critical claim catu"},
+		{[]any{iana.CatDpopJti}, "unsupported critical claim catdpopjti"},
+		{[]any{"role"}, "unsupported critical claim role"},
+		{[]any{}, "token has an invalid crit claim, invalid value []"},
+		{iana.CatM, "token has an invalid crit claim, invalid value 271"},
+	} {
+		for _, err := range []error{
+			va.ValidateRequest(ClaimsMap{iana.Crit: tc.crit}, req),
+			va.ValidateMap(ClaimsMap{iana.Crit: tc.crit}),
+		} {
+			var ve *ValidationError
+			require.ErrorAs(t, err, &ve)
+			assert.True(ve.Has(iana.Crit))
+			assert.ErrorContains(err, tc.err)
+		}
+	}
+
+	va, err = NewValidator(&ValidatorOpts{
+		AllowMissingExpiration: true,
+		UnknownCATClaims:       RejectUnknownClaims,
+		Rules:                  []*Rule{NewRule("role", ExtractString, nil)},
+	})
+	require.NoError(t, err)
+	assert.NoError(va.ValidateRequest(ClaimsMap{iana.CatM: "GET", iana.CatV: 1, 999: 1}, req))
+	assert.NoError(va.ValidateMap(ClaimsMap{iana.Crit: []any{"role"}, "role": "admin"}))
+
+	for _, claim := range []any{iana.Catu, iana.Geohash} {
+		for _, err := range []error{
+			va.ValidateRequest(ClaimsMap{claim: "x"}, req),
+			va.ValidateMap(ClaimsMap{claim: "x"}),
+		} {
+			var ce *ClaimError
+			require.ErrorAs(t, err, &ce)
+			assert.Equal(claim, ce.Claim)
+			assert.ErrorContains(err, "unsupported claim")
+		}
+	}
+}
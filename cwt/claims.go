@@ -6,18 +6,114 @@
 package cwt
 
 import (
+	"encoding/json"
+	"errors"
+
 	"github.com/ldclabs/cose/key"
 )
 
 // Claims represents a set of common claims for CWT.
 type Claims struct {
-	Issuer     string      `cbor:"1,keyasint,omitempty" json:"iss,omitempty"`
-	Subject    string      `cbor:"2,keyasint,omitempty" json:"sub,omitempty"`
-	Audience   string      `cbor:"3,keyasint,omitempty" json:"aud,omitempty"`
-	Expiration uint64      `cbor:"4,keyasint,omitempty" json:"exp,omitempty"` // seconds since epoch
-	NotBefore  uint64      `cbor:"5,keyasint,omitempty" json:"nbf,omitempty"` // seconds since epoch
-	IssuedAt   uint64      `cbor:"6,keyasint,omitempty" json:"iat,omitempty"` // seconds since epoch
-	CWTID      key.ByteStr `cbor:"7,keyasint,omitempty" json:"cti,omitempty"`
+	Issuer         string        `cbor:"1,keyasint,omitempty" json:"iss,omitempty"`
+	Subject        string        `cbor:"2,keyasint,omitempty" json:"sub,omitempty"`
+	Audience       string        `cbor:"3,keyasint,omitempty" json:"aud,omitempty"`
+	ExtraAudiences ClaimStrings  `cbor:"-" json:"-"`                                // the audiences following Audience in the "aud" claim
+	Expiration     uint64        `cbor:"4,keyasint,omitempty" json:"exp,omitempty"` // seconds since epoch
+	NotBefore      uint64        `cbor:"5,keyasint,omitempty" json:"nbf,omitempty"` // seconds since epoch
+	IssuedAt       uint64        `cbor:"6,keyasint,omitempty" json:"iat,omitempty"` // seconds since epoch
+	CWTID          key.ByteStr   `cbor:"7,keyasint,omitempty" json:"cti,omitempty"`
+	Confirmation   *Confirmation `cbor:"8,keyasint,omitempty" json:"cnf,omitempty"`
+	Scope          *Scope        `cbor:"9,keyasint,omitempty" json:"scope,omitempty"`
+	ACEProfile     int           `cbor:"38,keyasint,omitempty" json:"ace_profile,omitempty"`
+	CNonce         key.ByteStr   `cbor:"39,keyasint,omitempty" json:"cnonce,omitempty"`
+	Exi            uint64        `cbor:"40,keyasint,omitempty" json:"exi,omitempty"` // seconds since the token is received
+	Status         *Status       `cbor:"65535,keyasint,omitempty" json:"status,omitempty"`
+}
+
+// Audiences returns all audiences in the "aud" claim, Audience followed by ExtraAudiences.
+func (c *Claims) Audiences() ClaimStrings {
+	if c.Audience == "" {
+		return c.ExtraAudiences
+	}
+	return append(ClaimStrings{c.Audience}, c.ExtraAudiences...)
+}
+
+// MarshalCBOR implements the CBOR Marshaler interface for Claims.
+// The "aud" claim is omitted if it is empty, and encoded as a text string if it has only one element.
+func (c Claims) MarshalCBOR() ([]byte, error) {
+	type claims Claims
+	v := struct {
+		claims
+		Audience any `cbor:"3,keyasint,omitempty"`
+	}{claims: claims(c)}
+
+	if aud := c.Audiences(); len(aud) > 0 {
+		v.Audience = aud
+	}
+	return key.MarshalCBOR(v)
+}
+
+// UnmarshalCBOR implements the CBOR Unmarshaler interface for Claims.
+// The first element of the "aud" claim is decoded to Audience, and the others to ExtraAudiences.
+func (c *Claims) UnmarshalCBOR(data []byte) error {
+	if c == nil {
+		return errors.New("cose/cwt: Claims.UnmarshalCBOR: nil Claims")
+	}
+
+	type claims Claims
+	var v struct {
+		claims
+		Audience ClaimStrings `cbor:"3,keyasint,omitempty"`
+	}
+	if err := key.UnmarshalCBOR(data, &v); err != nil {
+		return err
+	}
+	*c = Claims(v.claims)
+	c.setAudiences(v.Audience)
+	return nil
+}
+
+// MarshalJSON implements encoding/json interface for Claims.
+func (c Claims) MarshalJSON() ([]byte, error) {
+	type claims Claims
+	if len(c.ExtraAudiences) == 0 {
+		return json.Marshal(claims(c))
+	}
+
+	v := struct {
+		claims
+		Audience ClaimStrings `json:"aud,omitempty"`
+	}{claims: claims(c), Audience: c.Audiences()}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements encoding/json interface for Claims.
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	if c == nil {
+		return errors.New("cose/cwt: Claims.UnmarshalJSON: nil Claims")
+	}
+
+	type claims Claims
+	var v struct {
+		claims
+		Audience ClaimStrings `json:"aud,omitempty"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*c = Claims(v.claims)
+	c.setAudiences(v.Audience)
+	return nil
+}
+
+func (c *Claims) setAudiences(aud ClaimStrings) {
+	c.Audience, c.ExtraAudiences = "", nil
+	if len(aud) > 0 {
+		c.Audience = aud[0]
+	}
+	if len(aud) > 1 {
+		c.ExtraAudiences = aud[1:]
+	}
 }
 
 // Bytesify returns a CBOR-encoded byte slice.
@@ -26,3 +122,71 @@ func (c *Claims) Bytesify() []byte {
 	b, _ := key.MarshalCBOR(c)
 	return b
 }
+
+// ClaimStrings represents a claim value that is a text string or an array of text strings,
+// such as the "aud" claim. It is encoded as a text string if it has only one element.
+type ClaimStrings []string
+
+// Has returns true if the ClaimStrings contains the given string.
+func (cs ClaimStrings) Has(s string) bool {
+	for _, v := range cs {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalCBOR implements the CBOR Marshaler interface for ClaimStrings.
+func (cs ClaimStrings) MarshalCBOR() ([]byte, error) {
+	if len(cs) == 1 {
+		return key.MarshalCBOR(cs[0])
+	}
+	return key.MarshalCBOR([]string(cs))
+}
+
+// UnmarshalCBOR implements the CBOR Unmarshaler interface for ClaimStrings.
+func (cs *ClaimStrings) UnmarshalCBOR(data []byte) error {
+	if cs == nil {
+		return errors.New("cose/cwt: ClaimStrings.UnmarshalCBOR: nil ClaimStrings")
+	}
+
+	var v any
+	if err := key.UnmarshalCBOR(data, &v); err != nil {
+		return err
+	}
+
+	strs, err := toStrings(v)
+	if err != nil {
+		return errors.New("cose/cwt: ClaimStrings.UnmarshalCBOR: " + err.Error())
+	}
+	*cs = strs
+	return nil
+}
+
+// MarshalJSON implements encoding/json interface for ClaimStrings.
+func (cs ClaimStrings) MarshalJSON() ([]byte, error) {
+	if len(cs) == 1 {
+		return json.Marshal(cs[0])
+	}
+	return json.Marshal([]string(cs))
+}
+
+// UnmarshalJSON implements encoding/json interface for ClaimStrings.
+func (cs *ClaimStrings) UnmarshalJSON(data []byte) error {
+	if cs == nil {
+		return errors.New("cose/cwt: ClaimStrings.UnmarshalJSON: nil ClaimStrings")
+	}
+
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	strs, err := toStrings(v)
+	if err != nil {
+		return errors.New("cose/cwt: ClaimStrings.UnmarshalJSON: " + err.Error())
+	}
+	*cs = strs
+	return nil
+}
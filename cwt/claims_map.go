@@ -4,6 +4,8 @@
 package cwt
 
 import (
+	"fmt"
+
 	"github.com/ldclabs/cose/key"
 )
 
@@ -57,6 +59,20 @@ func (cm ClaimsMap) GetString(claim any) (string, error) {
 	return key.CoseMap(cm).GetString(claim)
 }
 
+// GetStrings returns the value of the given claim as a slice of strings, or a error.
+// The claim value should be a text string or an array of text strings, such as the "aud" claim.
+// If the claim is not present, it returns (nil, nil).
+func (cm ClaimsMap) GetStrings(claim any) ([]string, error) {
+	if v, ok := cm[claim]; ok {
+		strs, err := toStrings(v)
+		if err != nil {
+			return nil, fmt.Errorf("cose/cwt: ClaimsMap.GetStrings: %w", err)
+		}
+		return strs, nil
+	}
+	return nil, nil
+}
+
 // GetMap returns the value of the given parameter as a key.CoseMap, or a error.
 func (cm ClaimsMap) GetMap(claim any) (key.CoseMap, error) {
 	return key.CoseMap(cm).GetMap(claim)
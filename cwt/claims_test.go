@@ -60,6 +60,75 @@ func TestClaims(t *testing.T) {
 	}
 }
 
+func TestClaimStrings(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var cs *ClaimStrings
+	assert.ErrorContains(cs.UnmarshalCBOR([]byte{0x60}), "nil ClaimStrings")
+	assert.ErrorContains(cs.UnmarshalJSON([]byte(`""`)), "nil ClaimStrings")
+
+	claims := Claims{Audience: "alice", ExtraAudiences: ClaimStrings{"bob"}}
+	assert.Equal(ClaimStrings{"alice", "bob"}, claims.Audiences())
+	assert.Equal(ClaimStrings{"bob"}, (&Claims{ExtraAudiences: ClaimStrings{"bob"}}).Audiences())
+	assert.Nil((&Claims{}).Audiences())
+	data, err := key.MarshalCBOR(claims)
+	require.NoError(err)
+	assert.Equal(key.HexBytesify("a1038265616c69636563626f62"), data)
+
+	jsondata, err := json.Marshal(claims)
+	require.NoError(err)
+	assert.Equal(`{"aud":["alice","bob"]}`, string(jsondata))
+
+	var claims2 Claims
+	require.NoError(key.UnmarshalCBOR(data, &claims2))
+	assert.Equal(claims, claims2)
+	assert.True(claims2.Audiences().Has("bob"))
+	assert.False(claims2.Audiences().Has("carol"))
+
+	var claims3 Claims
+	require.NoError(json.Unmarshal(jsondata, &claims3))
+	assert.Equal(claims, claims3)
+
+	var cm ClaimsMap
+	require.NoError(key.UnmarshalCBOR(data, &cm))
+	aud, err := cm.GetStrings(iana.CWTClaimAud)
+	require.NoError(err)
+	assert.Equal([]string{"alice", "bob"}, aud)
+
+	aud, err = cm.GetStrings(iana.CWTClaimSub)
+	require.NoError(err)
+	assert.Nil(aud)
+
+	cm[iana.CWTClaimAud] = 123
+	_, err = cm.GetStrings(iana.CWTClaimAud)
+	assert.ErrorContains(err, "cose/cwt: ClaimsMap.GetStrings: invalid value type int")
+
+	assert.ErrorContains(key.UnmarshalCBOR(key.HexBytesify("a1038201626f62"), &claims2),
+		"cose/cwt: ClaimStrings.UnmarshalCBOR: invalid element type uint64")
+	assert.ErrorContains(json.Unmarshal([]byte(`{"aud":[1]}`), &claims2),
+		"cose/cwt: ClaimStrings.UnmarshalJSON: invalid element type float64")
+
+	// a single audience is a text string, and the Audience is source-compatible
+	claims = Claims{Audience: "alice"}
+	data, err = key.MarshalCBOR(claims)
+	require.NoError(err)
+	assert.Equal(key.HexBytesify("a10365616c696365"), data)
+	claims2 = Claims{}
+	require.NoError(key.UnmarshalCBOR(key.HexBytesify("a1038165616c696365"), &claims2))
+	assert.Equal(claims, claims2)
+	jsondata, err = json.Marshal(claims)
+	require.NoError(err)
+	assert.Equal(`{"aud":"alice"}`, string(jsondata))
+	claims3 = Claims{}
+	require.NoError(json.Unmarshal([]byte(`{"aud":["alice"]}`), &claims3))
+	assert.Equal(claims, claims3)
+
+	var nilClaims *Claims
+	assert.ErrorContains(nilClaims.UnmarshalCBOR(data), "nil Claims")
+	assert.ErrorContains(nilClaims.UnmarshalJSON(jsondata), "nil Claims")
+}
+
 func TestClaimsSign1AndVerify(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
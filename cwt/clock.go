@@ -0,0 +1,45 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"time"
+)
+
+// Clock provides the current time to validate the time-based claims.
+// It should be safe for concurrent use.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock that returns the system time.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// ClockFunc is an adapter to use a function as a Clock.
+type ClockFunc func() time.Time
+
+// Now implements the Clock interface.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// FixedClock is a Clock that always returns the same time.
+type FixedClock time.Time
+
+// Now implements the Clock interface.
+func (c FixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// OffsetClock returns a Clock that adds the offset to the time of the base Clock,
+// such as a device clock that is corrected with the drift measured against a trusted time source.
+// If the base Clock is nil, SystemClock is used.
+func OffsetClock(base Clock, offset time.Duration) Clock {
+	if base == nil {
+		base = SystemClock
+	}
+	return ClockFunc(func() time.Time {
+		return base.Now().Add(offset)
+	})
+}
@@ -0,0 +1,26 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClock(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Unix(1700000000, 0)
+	assert.Equal(now, FixedClock(now).Now())
+	assert.Equal(now, ClockFunc(func() time.Time { return now }).Now())
+	assert.WithinDuration(time.Now(), SystemClock.Now(), time.Second)
+
+	c := OffsetClock(FixedClock(now), -time.Hour)
+	assert.Equal(now.Add(-time.Hour), c.Now())
+
+	c = OffsetClock(nil, time.Hour)
+	assert.WithinDuration(time.Now().Add(time.Hour), c.Now(), time.Second)
+}
@@ -0,0 +1,228 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+// Confirmation represents the "cnf" claim that declares the proof-of-possession key of a CWT.
+// Only one of the confirmation methods should be present.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc8747
+type Confirmation struct {
+	// Key is a public key, the COSE_Key confirmation method.
+	Key key.Key `cbor:"1,keyasint,omitempty" json:"COSE_Key,omitempty"`
+	// EncryptedKey is a COSE_Encrypt0 object that encrypts a symmetric key,
+	// the Encrypted_COSE_Key confirmation method.
+	EncryptedKey cbor.RawMessage `cbor:"2,keyasint,omitempty" json:"Encrypted_COSE_Key,omitempty"`
+	// Kid is the key identifier of a key known by the recipient, the kid confirmation method.
+	Kid key.ByteStr `cbor:"3,keyasint,omitempty" json:"kid,omitempty"`
+}
+
+// ConfirmKey returns a *Confirmation that binds the public key of the given asymmetric key.
+// The private key parameters are never included.
+// Symmetric keys should be bound with ConfirmEncryptedKey.
+func ConfirmKey(k key.Key) (*Confirmation, error) {
+	if k.Kty() == iana.KeyTypeSymmetric {
+		return nil, errors.New("cose/cwt: ConfirmKey: symmetric key should be encrypted, use ConfirmEncryptedKey")
+	}
+
+	verifier, err := k.Verifier()
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt: ConfirmKey: %w", err)
+	}
+	return &Confirmation{Key: verifier.Key()}, nil
+}
+
+// ConfirmEncryptedKey returns a *Confirmation that binds the given key encrypted with the encryptor,
+// the encryptor is the key shared by the issuer and the recipient of the CWT.
+func ConfirmEncryptedKey(k key.Key, encryptor key.Encryptor) (*Confirmation, error) {
+	obj := &cose.Encrypt0Message[key.Key]{Payload: k}
+	data, err := obj.EncryptAndEncode(encryptor, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt: ConfirmEncryptedKey: %w", err)
+	}
+	return &Confirmation{EncryptedKey: data}, nil
+}
+
+// ConfirmKid returns a *Confirmation that binds the key with the given key identifier.
+func ConfirmKid(kid []byte) *Confirmation {
+	return &Confirmation{Kid: kid}
+}
+
+// MarshalCBOR implements the CBOR Marshaler interface for Confirmation.
+// The confirmation methods that are not present are omitted.
+func (c Confirmation) MarshalCBOR() ([]byte, error) {
+	m := key.CoseMap{}
+	if len(c.Key) > 0 {
+		m[iana.CWTConfirmationCOSEKey] = c.Key
+	}
+	if len(c.EncryptedKey) > 0 {
+		m[iana.CWTConfirmationEncryptedCOSEKey] = c.EncryptedKey
+	}
+	if len(c.Kid) > 0 {
+		m[iana.CWTConfirmationKid] = c.Kid
+	}
+	return key.MarshalCBOR(m)
+}
+
+// Validate returns an error if the Confirmation does not have exactly one confirmation method.
+func (c *Confirmation) Validate() error {
+	n := 0
+	if len(c.Key) > 0 {
+		n++
+	}
+	if len(c.EncryptedKey) > 0 {
+		n++
+	}
+	if len(c.Kid) > 0 {
+		n++
+	}
+
+	if n != 1 {
+		return fmt.Errorf("cose/cwt: Confirmation.Validate: expected one confirmation method, got %d", n)
+	}
+	return nil
+}
+
+// ConfirmedKey returns the proof-of-possession key.
+// The encryptor decrypts the Encrypted_COSE_Key, and keys is looked up with the kid,
+// they can be nil if the confirmation method does not need them.
+func (c *Confirmation) ConfirmedKey(encryptor key.Encryptor, keys key.KeySet) (key.Key, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(c.Key) > 0:
+		return c.Key, nil
+
+	case len(c.EncryptedKey) > 0:
+		if encryptor == nil {
+			return nil, errors.New("cose/cwt: Confirmation.ConfirmedKey: nil Encryptor for the encrypted key")
+		}
+		obj, err := cose.DecryptEncrypt0Message[key.Key](encryptor, c.EncryptedKey, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cose/cwt: Confirmation.ConfirmedKey: %w", err)
+		}
+		return obj.Payload, nil
+
+	default:
+		k := keys.Lookup(c.Kid)
+		if k == nil {
+			return nil, fmt.Errorf("cose/cwt: Confirmation.ConfirmedKey: no key for kid %x", []byte(c.Kid))
+		}
+		return k, nil
+	}
+}
+
+// GetConfirmation returns the "cnf" claim as a *Confirmation, or a error.
+// If the claim is not present, it returns (nil, nil).
+func (cm ClaimsMap) GetConfirmation() (*Confirmation, error) {
+	v, ok := cm[iana.CWTClaimCnf]
+	if !ok {
+		return nil, nil
+	}
+
+	cnf, err := ExtractConfirmation(v)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt: ClaimsMap.GetConfirmation: %w", err)
+	}
+	return cnf, nil
+}
+
+// ExtractConfirmation converts a claim value to a *Confirmation.
+func ExtractConfirmation(val any) (*Confirmation, error) {
+	if cnf, ok := val.(*Confirmation); ok {
+		return cnf, cnf.Validate()
+	}
+
+	data, err := key.MarshalCBOR(val)
+	if err != nil {
+		return nil, err
+	}
+
+	cnf := &Confirmation{}
+	if err = key.UnmarshalCBOR(data, cnf); err != nil {
+		return nil, err
+	}
+	return cnf, cnf.Validate()
+}
+
+// VerifyProof verifies a proof-of-possession message, a COSE_Sign1 object signed by the confirmed key,
+// or a COSE_Mac0 object MACed by the confirmed symmetric key, and returns the payload of the message.
+// The payload is usually a challenge from the recipient, the caller should check it.
+// `externalData` should be the same as the one used when creating the proof.
+func VerifyProof(confirmedKey key.Key, proof, externalData []byte) ([]byte, error) {
+	var tag cbor.RawTag
+	if err := key.UnmarshalCBOR(proof, &tag); err != nil {
+		return nil, fmt.Errorf("cose/cwt: VerifyProof: invalid proof, %w", err)
+	}
+
+	if tag.Number == iana.CBORTagCWT {
+		if err := key.UnmarshalCBOR(tag.Content, &tag); err != nil {
+			return nil, fmt.Errorf("cose/cwt: VerifyProof: invalid proof, %w", err)
+		}
+	}
+
+	switch tag.Number {
+	case iana.CBORTagCOSESign1:
+		obj := &cose.Sign1Message[[]byte]{}
+		if err := obj.UnmarshalCBOR(tag.Content); err != nil {
+			return nil, fmt.Errorf("cose/cwt: VerifyProof: %w", err)
+		}
+		if err := checkProofKid(confirmedKey, obj.Protected, obj.Unprotected); err != nil {
+			return nil, err
+		}
+
+		verifier, err := confirmedKey.Verifier()
+		if err != nil {
+			return nil, fmt.Errorf("cose/cwt: VerifyProof: %w", err)
+		}
+		if err = obj.Verify(verifier, externalData); err != nil {
+			return nil, fmt.Errorf("cose/cwt: VerifyProof: %w", err)
+		}
+		return obj.Payload, nil
+
+	case iana.CBORTagCOSEMac0:
+		obj := &cose.Mac0Message[[]byte]{}
+		if err := obj.UnmarshalCBOR(tag.Content); err != nil {
+			return nil, fmt.Errorf("cose/cwt: VerifyProof: %w", err)
+		}
+		if err := checkProofKid(confirmedKey, obj.Protected, obj.Unprotected); err != nil {
+			return nil, err
+		}
+
+		macer, err := confirmedKey.MACer()
+		if err != nil {
+			return nil, fmt.Errorf("cose/cwt: VerifyProof: %w", err)
+		}
+		if err = obj.Verify(macer, externalData); err != nil {
+			return nil, fmt.Errorf("cose/cwt: VerifyProof: %w", err)
+		}
+		return obj.Payload, nil
+
+	default:
+		return nil, fmt.Errorf("cose/cwt: VerifyProof: unsupported COSE structure with tag %d", tag.Number)
+	}
+}
+
+// checkProofKid checks that the proof message is created by the confirmed key
+// if both of them have a key identifier.
+func checkProofKid(confirmedKey key.Key, protected, unprotected cose.Headers) error {
+	kid := LookupKid(protected, unprotected)
+	if len(kid) > 0 && len(confirmedKey.Kid()) > 0 && !bytes.Equal(kid, confirmedKey.Kid()) {
+		return fmt.Errorf("cose/cwt: VerifyProof: kid mismatch, expected %x, got %x", []byte(confirmedKey.Kid()), kid)
+	}
+	return nil
+}
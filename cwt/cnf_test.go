@@ -0,0 +1,191 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/aesgcm"
+	"github.com/ldclabs/cose/key/ecdsa"
+	"github.com/ldclabs/cose/key/hmac"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmation(t *testing.T) {
+
+	holderKey, err := ecdsa.GenerateKey(iana.AlgorithmES256)
+	require.NoError(t, err)
+
+	cnf, err := ConfirmKey(holderKey)
+	require.NoError(t, err)
+	require.NoError(t, cnf.Validate())
+	assert.Equal(t, holderKey.Kid(), cnf.Key.Kid())
+	assert.False(t, cnf.Key.Has(iana.EC2KeyParameterD), "should not include the private key")
+
+	symKey, err := hmac.GenerateKey(iana.AlgorithmHMAC_256_256)
+	require.NoError(t, err)
+	_, err = ConfirmKey(symKey)
+	assert.ErrorContains(t, err, "use ConfirmEncryptedKey")
+	_, err = ConfirmKey(key.Key{iana.KeyParameterKty: iana.KeyTypeOKP})
+	assert.ErrorContains(t, err, "cose/cwt: ConfirmKey")
+
+	assert.ErrorContains(t, (&Confirmation{}).Validate(), "expected one confirmation method, got 0")
+	assert.ErrorContains(t, (&Confirmation{Key: holderKey, Kid: []byte{1}}).Validate(),
+		"expected one confirmation method, got 2")
+
+	// Claims with cnf
+	claims := &Claims{Issuer: "ldc:ca", Confirmation: cnf}
+	data, err := key.MarshalCBOR(claims)
+	require.NoError(t, err)
+
+	var claims2 Claims
+	require.NoError(t, key.UnmarshalCBOR(data, &claims2))
+	assert.Equal(t, data, claims2.Bytesify())
+
+	var cm ClaimsMap
+	require.NoError(t, key.UnmarshalCBOR(data, &cm))
+	cnf2, err := cm.GetConfirmation()
+	require.NoError(t, err)
+	k, err := cnf2.ConfirmedKey(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, cnf.Key.Bytesify(), k.Bytesify())
+
+	jsondata, err := json.Marshal(ClaimsMap{iana.CWTClaimCnf: ConfirmKid([]byte{1, 2, 3})})
+	require.NoError(t, err)
+	assert.Contains(t, string(jsondata), `"kid":"010203"`)
+
+	cnf2, err = ClaimsMap{}.GetConfirmation()
+	require.NoError(t, err)
+	assert.Nil(t, cnf2)
+	_, err = ClaimsMap{iana.CWTClaimCnf: map[any]any{4: []byte{1}}}.GetConfirmation()
+	assert.ErrorContains(t, err, "cose/cwt: ClaimsMap.GetConfirmation: cose/cwt: Confirmation.Validate")
+	_, err = ClaimsMap{iana.CWTClaimCnf: "cnf"}.GetConfirmation()
+	assert.ErrorContains(t, err, "cose/cwt: ClaimsMap.GetConfirmation")
+
+	t.Run("Encrypted_COSE_Key", func(t *testing.T) {
+		assert := assert.New(t)
+
+		ek, err := aesgcm.GenerateKey(iana.AlgorithmA128GCM)
+		require.NoError(t, err)
+		encryptor, err := ek.Encryptor()
+		require.NoError(t, err)
+
+		cnf, err := ConfirmEncryptedKey(symKey, encryptor)
+		require.NoError(t, err)
+		require.NoError(t, cnf.Validate())
+
+		cm := ClaimsMap{}
+		require.NoError(t, key.UnmarshalCBOR(ClaimsMap{iana.CWTClaimCnf: cnf}.Bytesify(), &cm))
+		cnf2, err := cm.GetConfirmation()
+		require.NoError(t, err)
+
+		_, err = cnf2.ConfirmedKey(nil, nil)
+		assert.ErrorContains(err, "nil Encryptor")
+
+		k, err := cnf2.ConfirmedKey(encryptor, nil)
+		require.NoError(t, err)
+		assert.Equal(symKey.Bytesify(), k.Bytesify())
+
+		ek2, err := aesgcm.GenerateKey(iana.AlgorithmA128GCM)
+		require.NoError(t, err)
+		ek2.SetKid(ek.Kid())
+		encryptor2, err := ek2.Encryptor()
+		require.NoError(t, err)
+		_, err = cnf2.ConfirmedKey(encryptor2, nil)
+		assert.ErrorContains(err, "cose/cwt: Confirmation.ConfirmedKey")
+
+		// proof with MAC
+		macer, err := k.MACer()
+		require.NoError(t, err)
+		obj := &cose.Mac0Message[[]byte]{Payload: []byte("challenge")}
+		proof, err := obj.ComputeAndEncode(macer, nil)
+		require.NoError(t, err)
+
+		payload, err := VerifyProof(k, proof, nil)
+		require.NoError(t, err)
+		assert.Equal([]byte("challenge"), payload)
+
+		_, err = VerifyProof(holderKey, proof, nil)
+		assert.ErrorContains(err, "cose/cwt: VerifyProof: kid mismatch")
+		_, err = VerifyProof(key.Key{iana.KeyParameterKty: iana.KeyTypeSymmetric}, proof, nil)
+		assert.ErrorContains(err, "cose/cwt: VerifyProof")
+	})
+
+	t.Run("kid", func(t *testing.T) {
+		assert := assert.New(t)
+
+		cnf := ConfirmKid(holderKey.Kid())
+		require.NoError(t, cnf.Validate())
+
+		_, err := cnf.ConfirmedKey(nil, nil)
+		assert.ErrorContains(err, "no key for kid")
+
+		k, err := cnf.ConfirmedKey(nil, key.KeySet{symKey, holderKey})
+		require.NoError(t, err)
+		assert.Equal(holderKey, k)
+	})
+
+	t.Run("VerifyProof", func(t *testing.T) {
+		assert := assert.New(t)
+
+		signer, err := holderKey.Signer()
+		require.NoError(t, err)
+		obj := &cose.Sign1Message[[]byte]{Payload: []byte("challenge")}
+		proof, err := obj.SignAndEncode(signer, []byte("external"))
+		require.NoError(t, err)
+
+		payload, err := VerifyProof(cnf.Key, proof, []byte("external"))
+		require.NoError(t, err)
+		assert.Equal([]byte("challenge"), payload)
+
+		_, err = VerifyProof(cnf.Key, proof, nil)
+		assert.ErrorContains(err, "cose/cwt: VerifyProof")
+
+		otherKey, err := ecdsa.GenerateKey(iana.AlgorithmES256)
+		require.NoError(t, err)
+		_, err = VerifyProof(otherKey, proof, []byte("external"))
+		assert.ErrorContains(err, "kid mismatch")
+		delete(otherKey, iana.KeyParameterKid)
+		_, err = VerifyProof(otherKey, proof, []byte("external"))
+		assert.ErrorContains(err, "invalid signature")
+
+		_, err = VerifyProof(cnf.Key, []byte{0xa0}, nil)
+		assert.ErrorContains(err, "invalid proof")
+		_, err = VerifyProof(cnf.Key, append([]byte{0xd8, 0x3d}, proof...), []byte("external"))
+		require.NoError(t, err)
+
+		enc, err := aesgcm.GenerateKey(iana.AlgorithmA128GCM)
+		require.NoError(t, err)
+		encryptor, err := enc.Encryptor()
+		require.NoError(t, err)
+		e0 := &cose.Encrypt0Message[[]byte]{Payload: []byte("challenge")}
+		proof, err = e0.EncryptAndEncode(encryptor, nil)
+		require.NoError(t, err)
+		_, err = VerifyProof(cnf.Key, proof, nil)
+		assert.ErrorContains(err, "unsupported COSE structure with tag 16")
+	})
+
+	t.Run("holder-of-key token", func(t *testing.T) {
+		assert := assert.New(t)
+
+		va, err := NewValidator(&ValidatorOpts{
+			AllowMissingExpiration: true,
+			RequireConfirmation:    true,
+			FixedNow:               time.Unix(1670123000, 0),
+		})
+		require.NoError(t, err)
+
+		assert.ErrorContains(va.Validate(&Claims{Issuer: "ldc:ca"}),
+			"token doesn't have a proof-of-possession key")
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{iana.CWTClaimCnf: map[any]any{}}),
+			"token has an invalid cnf claim")
+		assert.NoError(va.Validate(claims))
+	})
+}
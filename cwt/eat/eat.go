@@ -0,0 +1,408 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package eat implements the Entity Attestation Token (EAT) claims as defined in RFC9711.
+// https://datatracker.ietf.org/doc/html/rfc9711.
+package eat
+
+import (
+	"crypto/subtle"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/ldclabs/cose/cwt"
+	"github.com/ldclabs/cose/key"
+)
+
+// UEID types, the first byte of the UEID.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc9711#section-4.2.1
+const (
+	// RAND, 16, 24 or 32 random bytes.
+	UEIDTypeRAND = 0x01
+	// IEEE EUI, 6 bytes of EUI-48 or 8 bytes of EUI-64.
+	UEIDTypeEUI = 0x02
+	// IMEI, 14 bytes of the IMEI digits.
+	UEIDTypeIMEI = 0x03
+)
+
+// Debug status values of the "dbgstat" claim.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc9711#section-4.2.9
+const (
+	DebugEnabled = iota
+	DebugDisabled
+	DebugDisabledSinceBoot
+	DebugDisabledPermanently
+	DebugDisabledFullyAndPermanently
+)
+
+// Claims represents the claims-set of an Entity Attestation Token (EAT).
+// It does not include the registered CWT claims, such as "iss" and "exp",
+// use ClaimsMap to merge them.
+type Claims struct {
+	MAROEPrefix key.ByteStr            `cbor:"-258,keyasint,omitempty" json:"eat_maroe_prefix,omitempty"`
+	FDO         cbor.RawMessage        `cbor:"-257,keyasint,omitempty" json:"eat_fdo,omitempty"`
+	Nonce       Nonce                  `cbor:"10,keyasint,omitempty" json:"eat_nonce,omitempty"`
+	UEID        key.ByteStr            `cbor:"256,keyasint,omitempty" json:"ueid,omitempty"`
+	SUEIDs      map[string]key.ByteStr `cbor:"257,keyasint,omitempty" json:"sueids,omitempty"`
+	OEMID       *OEMID                 `cbor:"258,keyasint,omitempty" json:"oemid,omitempty"`
+	HWModel     key.ByteStr            `cbor:"259,keyasint,omitempty" json:"hwmodel,omitempty"`
+	HWVersion   *Version               `cbor:"260,keyasint,omitempty" json:"hwversion,omitempty"`
+	Uptime      uint64                 `cbor:"261,keyasint,omitempty" json:"uptime,omitempty"`
+	OEMBoot     *bool                  `cbor:"262,keyasint,omitempty" json:"oemboot,omitempty"`
+	DebugStatus *int                   `cbor:"263,keyasint,omitempty" json:"dbgstat,omitempty"`
+	Location    *Location              `cbor:"264,keyasint,omitempty" json:"location,omitempty"`
+	Profile     Profile                `cbor:"265,keyasint,omitempty" json:"eat_profile,omitempty"`
+	Submodules  map[string]*Submodule  `cbor:"266,keyasint,omitempty" json:"submods,omitempty"`
+	BootCount   uint64                 `cbor:"267,keyasint,omitempty" json:"bootcount,omitempty"`
+	BootSeed    key.ByteStr            `cbor:"268,keyasint,omitempty" json:"bootseed,omitempty"`
+	SWName      string                 `cbor:"270,keyasint,omitempty" json:"swname,omitempty"`
+	SWVersion   *Version               `cbor:"271,keyasint,omitempty" json:"swversion,omitempty"`
+	IntendedUse int                    `cbor:"275,keyasint,omitempty" json:"intuse,omitempty"`
+}
+
+// MarshalCBOR implements the CBOR Marshaler interface for Claims.
+// The empty "eat_nonce" and "eat_profile" claims are omitted.
+func (c Claims) MarshalCBOR() ([]byte, error) {
+	type claims Claims
+	v := struct {
+		claims
+		Nonce   any `cbor:"10,keyasint,omitempty"`
+		Profile any `cbor:"265,keyasint,omitempty"`
+	}{claims: claims(c)}
+
+	if len(c.Nonce) > 0 {
+		v.Nonce = c.Nonce
+	}
+	if c.Profile != "" {
+		v.Profile = c.Profile
+	}
+	return key.MarshalCBOR(v)
+}
+
+// ClaimsFrom returns the EAT claims in the given cwt.ClaimsMap, such as the claims of a verified CWT.
+func ClaimsFrom(cm cwt.ClaimsMap) (*Claims, error) {
+	data, err := key.MarshalCBOR(cm)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt/eat: ClaimsFrom: %w", err)
+	}
+
+	c := &Claims{}
+	if err = key.UnmarshalCBOR(data, c); err != nil {
+		return nil, fmt.Errorf("cose/cwt/eat: ClaimsFrom: %w", err)
+	}
+	return c, nil
+}
+
+// ClaimsMap returns the claims as a cwt.ClaimsMap,
+// the registered CWT claims can be added to it before issuing the token.
+func (c *Claims) ClaimsMap() (cwt.ClaimsMap, error) {
+	data, err := key.MarshalCBOR(c)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt/eat: Claims.ClaimsMap: %w", err)
+	}
+
+	cm := cwt.ClaimsMap{}
+	if err = key.UnmarshalCBOR(data, &cm); err != nil {
+		return nil, fmt.Errorf("cose/cwt/eat: Claims.ClaimsMap: %w", err)
+	}
+	return cm, nil
+}
+
+// Bytesify returns a CBOR-encoded byte slice.
+// It returns nil if MarshalCBOR failed.
+func (c *Claims) Bytesify() []byte {
+	b, _ := key.MarshalCBOR(c)
+	return b
+}
+
+// Nonce represents the "eat_nonce" claim, one or more nonces.
+// It is encoded as a byte string if it has only one nonce.
+type Nonce []key.ByteStr
+
+// Has returns true if the Nonce has the given nonce, it compares in constant time.
+func (n Nonce) Has(nonce []byte) bool {
+	found := 0
+	for _, v := range n {
+		found |= subtle.ConstantTimeCompare(v, nonce)
+	}
+	return found == 1
+}
+
+// MarshalCBOR implements the CBOR Marshaler interface for Nonce.
+func (n Nonce) MarshalCBOR() ([]byte, error) {
+	if len(n) == 1 {
+		return key.MarshalCBOR(n[0])
+	}
+	return key.MarshalCBOR([]key.ByteStr(n))
+}
+
+// UnmarshalCBOR implements the CBOR Unmarshaler interface for Nonce.
+func (n *Nonce) UnmarshalCBOR(data []byte) error {
+	if n == nil {
+		return errors.New("cose/cwt/eat: Nonce.UnmarshalCBOR: nil Nonce")
+	}
+
+	if len(data) > 0 && data[0]>>5 == 2 { // byte string
+		var b key.ByteStr
+		if err := key.UnmarshalCBOR(data, &b); err != nil {
+			return err
+		}
+		*n = Nonce{b}
+		return nil
+	}
+
+	var bs []key.ByteStr
+	if err := key.UnmarshalCBOR(data, &bs); err != nil {
+		return fmt.Errorf("cose/cwt/eat: Nonce.UnmarshalCBOR: %w", err)
+	}
+	*n = bs
+	return nil
+}
+
+// OEMID represents the "oemid" claim, a random or IEEE based identifier (byte string),
+// or an IANA Private Enterprise Number (integer).
+type OEMID struct {
+	// ID is a random 16 bytes identifier, or a 3 bytes IEEE OUI.
+	ID key.ByteStr
+	// PEN is an IANA Private Enterprise Number.
+	PEN uint64
+}
+
+// MarshalCBOR implements the CBOR Marshaler interface for OEMID.
+func (o OEMID) MarshalCBOR() ([]byte, error) {
+	if len(o.ID) > 0 {
+		return key.MarshalCBOR(o.ID)
+	}
+	return key.MarshalCBOR(o.PEN)
+}
+
+// UnmarshalCBOR implements the CBOR Unmarshaler interface for OEMID.
+func (o *OEMID) UnmarshalCBOR(data []byte) error {
+	if o == nil {
+		return errors.New("cose/cwt/eat: OEMID.UnmarshalCBOR: nil OEMID")
+	}
+
+	var v any
+	if err := key.UnmarshalCBOR(data, &v); err != nil {
+		return err
+	}
+
+	switch x := v.(type) {
+	case []byte:
+		*o = OEMID{ID: x}
+	case uint64:
+		*o = OEMID{PEN: x}
+	default:
+		return fmt.Errorf("cose/cwt/eat: OEMID.UnmarshalCBOR: invalid value type %T", v)
+	}
+	return nil
+}
+
+// Version represents the "hwversion" and "swversion" claims, a version and an optional version scheme.
+type Version struct {
+	Version string
+	// Scheme is the CoSWID version scheme, it is omitted if it is zero.
+	Scheme int
+}
+
+// MarshalCBOR implements the CBOR Marshaler interface for Version.
+func (v Version) MarshalCBOR() ([]byte, error) {
+	if v.Scheme == 0 {
+		return key.MarshalCBOR([]any{v.Version})
+	}
+	return key.MarshalCBOR([]any{v.Version, v.Scheme})
+}
+
+// UnmarshalCBOR implements the CBOR Unmarshaler interface for Version.
+func (v *Version) UnmarshalCBOR(data []byte) error {
+	if v == nil {
+		return errors.New("cose/cwt/eat: Version.UnmarshalCBOR: nil Version")
+	}
+
+	var arr []any
+	if err := key.UnmarshalCBOR(data, &arr); err != nil {
+		return fmt.Errorf("cose/cwt/eat: Version.UnmarshalCBOR: %w", err)
+	}
+	if len(arr) == 0 || len(arr) > 2 {
+		return fmt.Errorf("cose/cwt/eat: Version.UnmarshalCBOR: invalid array length %d", len(arr))
+	}
+
+	ver, ok := arr[0].(string)
+	if !ok {
+		return fmt.Errorf("cose/cwt/eat: Version.UnmarshalCBOR: invalid version type %T", arr[0])
+	}
+
+	*v = Version{Version: ver}
+	if len(arr) == 2 {
+		scheme, err := key.ToInt(arr[1])
+		if err != nil {
+			return fmt.Errorf("cose/cwt/eat: Version.UnmarshalCBOR: invalid scheme, %w", err)
+		}
+		v.Scheme = scheme
+	}
+	return nil
+}
+
+// Location represents the "location" claim.
+type Location struct {
+	Latitude         float64  `cbor:"1,keyasint" json:"latitude"`
+	Longitude        float64  `cbor:"2,keyasint" json:"longitude"`
+	Altitude         *float64 `cbor:"3,keyasint,omitempty" json:"altitude,omitempty"`
+	Accuracy         *float64 `cbor:"4,keyasint,omitempty" json:"accuracy,omitempty"`
+	AltitudeAccuracy *float64 `cbor:"5,keyasint,omitempty" json:"altitude_accuracy,omitempty"`
+	Heading          *float64 `cbor:"6,keyasint,omitempty" json:"heading,omitempty"`
+	Speed            *float64 `cbor:"7,keyasint,omitempty" json:"speed,omitempty"`
+	Timestamp        int64    `cbor:"8,keyasint,omitempty" json:"timestamp,omitempty"`
+	Age              uint64   `cbor:"9,keyasint,omitempty" json:"age,omitempty"`
+}
+
+// Profile represents the "eat_profile" claim, a URI or an OID in dotted-decimal notation, such as "2.16.840.1.113741.1.16.1".
+// The OID is encoded as an unwrapped OID byte string.
+type Profile string
+
+// MarshalCBOR implements the CBOR Marshaler interface for Profile.
+func (p Profile) MarshalCBOR() ([]byte, error) {
+	if oid, ok := parseOID(string(p)); ok {
+		data, err := asn1.Marshal(oid)
+		if err != nil {
+			return nil, fmt.Errorf("cose/cwt/eat: Profile.MarshalCBOR: %w", err)
+		}
+		// remove the tag and the length of the DER encoding
+		var raw asn1.RawValue
+		if _, err = asn1.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("cose/cwt/eat: Profile.MarshalCBOR: %w", err)
+		}
+		return key.MarshalCBOR(raw.Bytes)
+	}
+	return key.MarshalCBOR(string(p))
+}
+
+// UnmarshalCBOR implements the CBOR Unmarshaler interface for Profile.
+func (p *Profile) UnmarshalCBOR(data []byte) error {
+	if p == nil {
+		return errors.New("cose/cwt/eat: Profile.UnmarshalCBOR: nil Profile")
+	}
+
+	var v any
+	if err := key.UnmarshalCBOR(data, &v); err != nil {
+		return err
+	}
+
+	switch x := v.(type) {
+	case string:
+		*p = Profile(x)
+	case []byte:
+		der, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagOID, Bytes: x})
+		if err != nil {
+			return fmt.Errorf("cose/cwt/eat: Profile.UnmarshalCBOR: %w", err)
+		}
+		var oid asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(der, &oid); err != nil {
+			return fmt.Errorf("cose/cwt/eat: Profile.UnmarshalCBOR: invalid OID, %w", err)
+		}
+		*p = Profile(oid.String())
+	case cbor.Tag:
+		return fmt.Errorf("cose/cwt/eat: Profile.UnmarshalCBOR: unsupported tag %d", x.Number)
+	default:
+		return fmt.Errorf("cose/cwt/eat: Profile.UnmarshalCBOR: invalid value type %T", v)
+	}
+	return nil
+}
+
+func parseOID(s string) (asn1.ObjectIdentifier, bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return nil, false
+	}
+
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, part := range parts {
+		if part == "" || len(part) > 9 {
+			return nil, false
+		}
+		n := 0
+		for _, c := range part {
+			if c < '0' || c > '9' {
+				return nil, false
+			}
+			n = n*10 + int(c-'0')
+		}
+		oid[i] = n
+	}
+	return oid, true
+}
+
+// Submodule represents a submodule in the "submods" claim. Only one of the fields should be present.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc9711#section-4.2.18
+type Submodule struct {
+	// Claims is the claims-set of the submodule.
+	Claims *Claims
+	// Token is a nested EAT, a CBOR-encoded CWT that should be verified separately.
+	Token []byte
+	// Digest is the digest of a detached EAT claims-set.
+	Digest *DetachedDigest
+}
+
+// DetachedDigest represents a digest of a detached EAT claims-set.
+type DetachedDigest struct {
+	_ struct{} `cbor:",toarray"`
+	// Alg is the COSE hash algorithm, such as iana.AlgorithmSHA_256.
+	Alg int
+	// Value is the digest value.
+	Value key.ByteStr
+}
+
+// MarshalCBOR implements the CBOR Marshaler interface for Submodule.
+func (s Submodule) MarshalCBOR() ([]byte, error) {
+	switch {
+	case s.Claims != nil:
+		return key.MarshalCBOR(s.Claims)
+	case len(s.Token) > 0:
+		return key.MarshalCBOR(s.Token)
+	case s.Digest != nil:
+		return key.MarshalCBOR(s.Digest)
+	default:
+		return nil, errors.New("cose/cwt/eat: Submodule.MarshalCBOR: empty Submodule")
+	}
+}
+
+// UnmarshalCBOR implements the CBOR Unmarshaler interface for Submodule.
+func (s *Submodule) UnmarshalCBOR(data []byte) error {
+	if s == nil {
+		return errors.New("cose/cwt/eat: Submodule.UnmarshalCBOR: nil Submodule")
+	}
+	if len(data) == 0 {
+		return errors.New("cose/cwt/eat: Submodule.UnmarshalCBOR: empty data")
+	}
+
+	switch data[0] >> 5 {
+	case 5: // map, claims-set
+		c := &Claims{}
+		if err := key.UnmarshalCBOR(data, c); err != nil {
+			return err
+		}
+		*s = Submodule{Claims: c}
+	case 2: // byte string, nested token
+		var token []byte
+		if err := key.UnmarshalCBOR(data, &token); err != nil {
+			return err
+		}
+		*s = Submodule{Token: token}
+	case 4: // array, detached digest
+		d := &DetachedDigest{}
+		if err := key.UnmarshalCBOR(data, d); err != nil {
+			return err
+		}
+		*s = Submodule{Digest: d}
+	default:
+		return fmt.Errorf("cose/cwt/eat: Submodule.UnmarshalCBOR: unsupported submodule type 0x%x", data[0])
+	}
+	return nil
+}
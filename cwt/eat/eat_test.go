@@ -0,0 +1,219 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package eat
+
+import (
+	"testing"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaims(t *testing.T) {
+	t.Run("empty claims", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		data, err := key.MarshalCBOR(&Claims{})
+		require.NoError(err)
+		assert.Equal([]byte{0xa0}, data)
+
+		var c Claims
+		require.NoError(key.UnmarshalCBOR(data, &c))
+		assert.Equal(Claims{}, c)
+	})
+
+	t.Run("typed claims", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		oemboot := true
+		dbgstat := DebugDisabledSinceBoot
+		altitude := 12.5
+		claims := &Claims{
+			Nonce:       Nonce{key.HexBytesify("948f8860d13a463e")},
+			UEID:        key.HexBytesify("0198f50a4ff6c05861c8860d13a638ea4f"),
+			OEMID:       &OEMID{ID: key.HexBytesify("894823")},
+			HWModel:     key.ByteStr("model-x"),
+			HWVersion:   &Version{Version: "1.3.4", Scheme: 1},
+			Uptime:      3600,
+			OEMBoot:     &oemboot,
+			DebugStatus: &dbgstat,
+			Location:    &Location{Latitude: 31.2, Longitude: 121.5, Altitude: &altitude},
+			Profile:     "tag:example.com,2024:eat-profile",
+			BootCount:   7,
+			SWName:      "firmware",
+			SWVersion:   &Version{Version: "2.0"},
+			IntendedUse: 2,
+			Submodules: map[string]*Submodule{
+				"radio": {Claims: &Claims{SWName: "radio-fw", SWVersion: &Version{Version: "1.0"}}},
+				"tee":   {Token: key.HexBytesify("d28443a10126a0")},
+				"rom":   {Digest: &DetachedDigest{Alg: iana.AlgorithmSHA_256, Value: key.HexBytesify("0102030405")}},
+			},
+		}
+
+		data, err := key.MarshalCBOR(claims)
+		require.NoError(err)
+
+		var c Claims
+		require.NoError(key.UnmarshalCBOR(data, &c))
+		assert.Equal(*claims, c)
+		assert.Equal(data, c.Bytesify())
+
+		cm, err := claims.ClaimsMap()
+		require.NoError(err)
+		assert.Equal("firmware", cm.Get(iana.CWTClaimSWName))
+		assert.Equal(uint64(7), cm.Get(iana.CWTClaimBootCount))
+		assert.Equal([]byte(claims.Nonce[0]), cm.Get(iana.CWTClaimNonce))
+
+		cm[iana.CWTClaimIss] = "ldc:ca"
+		c2, err := ClaimsFrom(cm)
+		require.NoError(err)
+		assert.Equal(*claims, *c2)
+	})
+}
+
+func TestClaimLabels(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// Reference https://datatracker.ietf.org/doc/html/rfc9711#name-cbor-web-token-claims-regis
+	for _, tc := range []struct {
+		label int
+		want  int
+	}{
+		{iana.CWTClaimUEID, 256},
+		{iana.CWTClaimSUEIDs, 257},
+		{iana.CWTClaimOEMID, 258},
+		{iana.CWTClaimHWModel, 259},
+		{iana.CWTClaimHWVersion, 260},
+		{iana.CWTClaimUptime, 261},
+		{iana.CWTClaimBootCount, 267},
+		{iana.CWTClaimBootSeed, 268},
+		{iana.CWTClaimSWName, 270},
+		{iana.CWTClaimSWVersion, 271},
+		{iana.CWTClaimIntendedUse, 275},
+	} {
+		assert.Equal(tc.want, tc.label)
+	}
+
+	// {270: "fw", 271: ["1.0"]}
+	data := key.HexBytesify("a219010e62667719010f8163312e30")
+	var c Claims
+	require.NoError(key.UnmarshalCBOR(data, &c))
+	assert.Equal("fw", c.SWName)
+	assert.Equal(&Version{Version: "1.0"}, c.SWVersion)
+	assert.Equal(data, c.Bytesify())
+}
+
+func TestNonce(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	n := Nonce{key.ByteStr{1, 2, 3}}
+	data, err := key.MarshalCBOR(n)
+	require.NoError(err)
+	assert.Equal(key.HexBytesify("43010203"), data)
+
+	var n2 Nonce
+	require.NoError(key.UnmarshalCBOR(data, &n2))
+	assert.Equal(n, n2)
+	assert.True(n2.Has([]byte{1, 2, 3}))
+	assert.False(n2.Has([]byte{1, 2}))
+
+	n = Nonce{key.ByteStr{1, 2, 3}, key.ByteStr{4, 5}}
+	data, err = key.MarshalCBOR(n)
+	require.NoError(err)
+	assert.Equal(key.HexBytesify("8243010203420405"), data)
+
+	n2 = nil
+	require.NoError(key.UnmarshalCBOR(data, &n2))
+	assert.Equal(n, n2)
+	assert.True(n2.Has([]byte{4, 5}))
+
+	assert.Error(key.UnmarshalCBOR(key.HexBytesify("6161"), &n2))
+	var np *Nonce
+	assert.ErrorContains(np.UnmarshalCBOR(data), "nil Nonce")
+}
+
+func TestOEMID(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	for _, o := range []OEMID{{ID: key.ByteStr{1, 2, 3}}, {PEN: 76543}} {
+		data, err := key.MarshalCBOR(o)
+		require.NoError(err)
+
+		var o2 OEMID
+		require.NoError(key.UnmarshalCBOR(data, &o2))
+		assert.Equal(o, o2)
+	}
+
+	var o OEMID
+	assert.ErrorContains(key.UnmarshalCBOR(key.HexBytesify("6161"), &o), "invalid value type")
+	var op *OEMID
+	assert.ErrorContains(op.UnmarshalCBOR([]byte{0x01}), "nil OEMID")
+}
+
+func TestVersion(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	v := Version{Version: "1.3.4", Scheme: 1}
+	data, err := key.MarshalCBOR(v)
+	require.NoError(err)
+	assert.Equal(key.HexBytesify("8265312e332e3401"), data)
+
+	var v2 Version
+	require.NoError(key.UnmarshalCBOR(data, &v2))
+	assert.Equal(v, v2)
+
+	data, err = key.MarshalCBOR(Version{Version: "2.0"})
+	require.NoError(err)
+	assert.Equal(key.HexBytesify("8163322e30"), data)
+
+	assert.ErrorContains(key.UnmarshalCBOR(key.HexBytesify("80"), &v2), "invalid array length")
+	assert.ErrorContains(key.UnmarshalCBOR(key.HexBytesify("8101"), &v2), "invalid version type")
+	assert.ErrorContains(key.UnmarshalCBOR(key.HexBytesify("8263322e3061"+"61"), &v2), "invalid scheme")
+}
+
+func TestProfile(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	for _, tc := range []struct {
+		profile Profile
+		cbor    []byte
+	}{
+		{"https://example.com/eat-profile", key.HexBytesify("781f68747470733a2f2f6578616d706c652e636f6d2f6561742d70726f66696c65")},
+		{"2.16.840.1.113741.1.16.1", key.HexBytesify("4a6086480186f84d011001")},
+	} {
+		data, err := key.MarshalCBOR(tc.profile)
+		require.NoError(err)
+		assert.Equal(tc.cbor, data)
+
+		var p Profile
+		require.NoError(key.UnmarshalCBOR(data, &p))
+		assert.Equal(tc.profile, p)
+	}
+
+	var p Profile
+	assert.ErrorContains(key.UnmarshalCBOR(key.HexBytesify("01"), &p), "invalid value type")
+	assert.ErrorContains(key.UnmarshalCBOR(key.HexBytesify("d86f43010203"), &p), "unsupported tag 111")
+}
+
+func TestSubmodule(t *testing.T) {
+	assert := assert.New(t)
+
+	var s Submodule
+	_, err := key.MarshalCBOR(s)
+	assert.ErrorContains(err, "empty Submodule")
+	assert.ErrorContains(key.UnmarshalCBOR(key.HexBytesify("6161"), &s), "unsupported submodule type")
+
+	var sp *Submodule
+	assert.ErrorContains(sp.UnmarshalCBOR([]byte{0xa0}), "nil Submodule")
+	assert.ErrorContains(sp.UnmarshalCBOR(nil), "nil Submodule")
+}
@@ -0,0 +1,210 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package eat
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ldclabs/cose/cwt"
+)
+
+const (
+	eatMaxSubmoduleLevels = 4
+	eatMinNonceSize       = 8
+	eatMaxNonceSize       = 64
+)
+
+// ValidatorOpts defines validation options for EAT validators.
+type ValidatorOpts struct {
+	// Nonce is the nonce supplied by the relying party for freshness,
+	// the "eat_nonce" claim is required and should contain it if it is set.
+	Nonce []byte
+	// Profile is the expected "eat_profile" claim, a URI or an OID in dotted-decimal notation.
+	// The claim is required if it is set.
+	Profile string
+	// CheckProfile checks the claims against the requirements of the profile, such as the required claims.
+	// It is called after the other checks.
+	CheckProfile func(claims *Claims) error
+	// VerifyNested verifies a nested EAT in the "submods" claim, such as with a cwt.Verifier,
+	// and returns its claims. The nested tokens are rejected if it is nil.
+	VerifyNested func(token []byte) (cwt.ClaimsMap, error)
+}
+
+// Validator validates the EAT claims of a verified CWT.
+// The registered CWT claims, such as "exp", should be validated with a cwt.Validator,
+// or a cwt.Verifier before this.
+type Validator struct {
+	opts ValidatorOpts
+}
+
+// NewValidator creates a new EAT Validator.
+func NewValidator(opts *ValidatorOpts) (*Validator, error) {
+	if opts == nil {
+		return nil, errors.New("cose/cwt/eat: NewValidator: nil ValidatorOpts")
+	}
+	if len(opts.Nonce) > 0 && (len(opts.Nonce) < eatMinNonceSize || len(opts.Nonce) > eatMaxNonceSize) {
+		return nil, fmt.Errorf("cose/cwt/eat: NewValidator: invalid nonce size %d, expected %d to %d bytes",
+			len(opts.Nonce), eatMinNonceSize, eatMaxNonceSize)
+	}
+
+	return &Validator{opts: *opts}, nil
+}
+
+// Validate validates the EAT claims in the ClaimsMap and returns them as a *Claims.
+// The submodules, including the nested EATs, are validated too,
+// but the nonce and the profile are only checked on the top-level claims.
+func (v *Validator) Validate(cm cwt.ClaimsMap) (*Claims, error) {
+	claims, err := ClaimsFrom(cm)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = v.validate(claims, 0); err != nil {
+		return nil, fmt.Errorf("cose/cwt/eat: Validator.Validate: %w", err)
+	}
+	return claims, nil
+}
+
+func (v *Validator) validate(claims *Claims, level int) error {
+	if level == 0 {
+		if err := v.checkNonce(claims.Nonce); err != nil {
+			return err
+		}
+
+		if v.opts.Profile != "" && string(claims.Profile) != v.opts.Profile {
+			return fmt.Errorf("eat_profile mismatch, expected %q, got %q", v.opts.Profile, claims.Profile)
+		}
+	}
+
+	if err := checkClaims(claims); err != nil {
+		return err
+	}
+
+	for name, sub := range claims.Submodules {
+		if err := v.validateSubmodule(sub, level+1); err != nil {
+			return fmt.Errorf("submodule %q: %w", name, err)
+		}
+	}
+
+	if level == 0 && v.opts.CheckProfile != nil {
+		return v.opts.CheckProfile(claims)
+	}
+	return nil
+}
+
+func (v *Validator) validateSubmodule(sub *Submodule, level int) error {
+	if level > eatMaxSubmoduleLevels {
+		return fmt.Errorf("too many submodule levels, expected <= %d", eatMaxSubmoduleLevels)
+	}
+	if sub == nil {
+		return errors.New("nil submodule")
+	}
+
+	switch {
+	case sub.Claims != nil:
+		return v.validate(sub.Claims, level)
+
+	case len(sub.Token) > 0:
+		if v.opts.VerifyNested == nil {
+			return errors.New("nested token is not allowed without VerifyNested")
+		}
+
+		cm, err := v.opts.VerifyNested(sub.Token)
+		if err != nil {
+			return fmt.Errorf("invalid nested token, %w", err)
+		}
+		if sub.Claims, err = ClaimsFrom(cm); err != nil {
+			return err
+		}
+		return v.validate(sub.Claims, level)
+
+	case sub.Digest != nil:
+		if len(sub.Digest.Value) == 0 {
+			return errors.New("empty detached digest")
+		}
+		return nil
+
+	default:
+		return errors.New("empty submodule")
+	}
+}
+
+// checkNonce checks the "eat_nonce" claim against the expected nonce for freshness.
+func (v *Validator) checkNonce(nonce Nonce) error {
+	for _, n := range nonce {
+		if len(n) < eatMinNonceSize || len(n) > eatMaxNonceSize {
+			return fmt.Errorf("invalid eat_nonce size %d, expected %d to %d bytes",
+				len(n), eatMinNonceSize, eatMaxNonceSize)
+		}
+	}
+
+	if len(v.opts.Nonce) > 0 {
+		if len(nonce) == 0 {
+			return errors.New("token doesn't have the eat_nonce claim")
+		}
+		if !nonce.Has(v.opts.Nonce) {
+			return errors.New("eat_nonce mismatch")
+		}
+	}
+	return nil
+}
+
+// checkClaims checks the formats of the claims.
+func checkClaims(c *Claims) error {
+	if len(c.UEID) > 0 {
+		if err := checkUEID(c.UEID); err != nil {
+			return fmt.Errorf("invalid ueid, %w", err)
+		}
+	}
+	for name, id := range c.SUEIDs {
+		if err := checkUEID(id); err != nil {
+			return fmt.Errorf("invalid sueid %q, %w", name, err)
+		}
+	}
+
+	if c.OEMID != nil && len(c.OEMID.ID) > 0 && len(c.OEMID.ID) != 3 && len(c.OEMID.ID) != 16 {
+		return fmt.Errorf("invalid oemid size %d, expected 3 or 16 bytes", len(c.OEMID.ID))
+	}
+
+	if len(c.HWModel) > 32 {
+		return fmt.Errorf("invalid hwmodel size %d, expected <= 32 bytes", len(c.HWModel))
+	}
+
+	if c.DebugStatus != nil && (*c.DebugStatus < DebugEnabled || *c.DebugStatus > DebugDisabledFullyAndPermanently) {
+		return fmt.Errorf("invalid dbgstat %d", *c.DebugStatus)
+	}
+
+	if l := c.Location; l != nil {
+		if l.Latitude < -90 || l.Latitude > 90 || l.Longitude < -180 || l.Longitude > 180 {
+			return fmt.Errorf("invalid location (%v, %v)", l.Latitude, l.Longitude)
+		}
+	}
+	return nil
+}
+
+// checkUEID checks the type and the length of a UEID.
+func checkUEID(id []byte) error {
+	if len(id) == 0 {
+		return errors.New("empty UEID")
+	}
+
+	switch id[0] {
+	case UEIDTypeRAND:
+		if n := len(id) - 1; n != 16 && n != 24 && n != 32 {
+			return fmt.Errorf("invalid RAND UEID size %d", n)
+		}
+	case UEIDTypeEUI:
+		if n := len(id) - 1; n != 6 && n != 8 {
+			return fmt.Errorf("invalid EUI UEID size %d", n)
+		}
+	case UEIDTypeIMEI:
+		if n := len(id) - 1; n != 14 {
+			return fmt.Errorf("invalid IMEI UEID size %d", n)
+		}
+	default:
+		return fmt.Errorf("unknown UEID type 0x%x", id[0])
+	}
+	return nil
+}
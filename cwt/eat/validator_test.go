@@ -0,0 +1,227 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package eat
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldclabs/cose/cwt"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/ed25519"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidator(t *testing.T) {
+	nonce := key.HexBytesify("948f8860d13a463e8e")
+	ueid := key.HexBytesify("0198f50a4ff6c05861c8860d13a638ea4f")
+
+	t.Run("NewValidator", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := NewValidator(nil)
+		assert.ErrorContains(err, "nil ValidatorOpts")
+
+		_, err = NewValidator(&ValidatorOpts{Nonce: []byte{1, 2, 3}})
+		assert.ErrorContains(err, "invalid nonce size 3")
+
+		_, err = NewValidator(&ValidatorOpts{})
+		assert.NoError(err)
+	})
+
+	t.Run("nonce", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		va, err := NewValidator(&ValidatorOpts{Nonce: nonce})
+		require.NoError(err)
+
+		cm := cwt.ClaimsMap{iana.CWTClaimNonce: nonce}
+		claims, err := va.Validate(cm)
+		require.NoError(err)
+		assert.Equal(Nonce{nonce}, claims.Nonce)
+
+		cm[iana.CWTClaimNonce] = []any{key.HexBytesify("0102030405060708"), nonce}
+		_, err = va.Validate(cm)
+		assert.NoError(err)
+
+		cm[iana.CWTClaimNonce] = key.HexBytesify("0102030405060708")
+		_, err = va.Validate(cm)
+		assert.ErrorContains(err, "eat_nonce mismatch")
+
+		cm[iana.CWTClaimNonce] = []byte{1, 2, 3}
+		_, err = va.Validate(cm)
+		assert.ErrorContains(err, "invalid eat_nonce size 3")
+
+		_, err = va.Validate(cwt.ClaimsMap{})
+		assert.ErrorContains(err, "doesn't have the eat_nonce claim")
+
+		_, err = va.Validate(cwt.ClaimsMap{iana.CWTClaimNonce: "nonce"})
+		assert.ErrorContains(err, "cose/cwt/eat: ClaimsFrom")
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		va, err := NewValidator(&ValidatorOpts{
+			Profile: "2.16.840.1.113741.1.16.1",
+			CheckProfile: func(claims *Claims) error {
+				if len(claims.UEID) == 0 {
+					return errors.New("ueid is required by the profile")
+				}
+				return nil
+			},
+		})
+		require.NoError(err)
+
+		cm, err := (&Claims{Profile: "2.16.840.1.113741.1.16.1", UEID: ueid}).ClaimsMap()
+		require.NoError(err)
+		_, err = va.Validate(cm)
+		assert.NoError(err)
+
+		delete(cm, iana.CWTClaimUEID)
+		_, err = va.Validate(cm)
+		assert.ErrorContains(err, "ueid is required by the profile")
+
+		cm[iana.CWTClaimProfile] = "https://example.com/eat-profile"
+		_, err = va.Validate(cm)
+		assert.ErrorContains(err, `eat_profile mismatch, expected "2.16.840.1.113741.1.16.1", got "https://example.com/eat-profile"`)
+
+		delete(cm, iana.CWTClaimProfile)
+		_, err = va.Validate(cm)
+		assert.ErrorContains(err, "eat_profile mismatch")
+	})
+
+	t.Run("claims format", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		va, err := NewValidator(&ValidatorOpts{})
+		require.NoError(err)
+
+		dbgstat := 5
+		for _, tc := range []struct {
+			claims *Claims
+			err    string
+		}{
+			{&Claims{UEID: key.HexBytesify("0102")}, "invalid ueid, invalid RAND UEID size 1"},
+			{&Claims{UEID: key.HexBytesify("02010203040506")}, ""},
+			{&Claims{UEID: key.HexBytesify("020102")}, "invalid EUI UEID size 2"},
+			{&Claims{UEID: key.HexBytesify("030102")}, "invalid IMEI UEID size 2"},
+			{&Claims{UEID: key.HexBytesify("090102")}, "unknown UEID type 0x9"},
+			{&Claims{SUEIDs: map[string]key.ByteStr{"tls": key.HexBytesify("0102")}}, `invalid sueid "tls"`},
+			{&Claims{OEMID: &OEMID{ID: key.HexBytesify("0102")}}, "invalid oemid size 2"},
+			{&Claims{OEMID: &OEMID{PEN: 76543}}, ""},
+			{&Claims{HWModel: make(key.ByteStr, 33)}, "invalid hwmodel size 33"},
+			{&Claims{DebugStatus: &dbgstat}, "invalid dbgstat 5"},
+			{&Claims{Location: &Location{Latitude: 91}}, "invalid location"},
+			{&Claims{Location: &Location{Latitude: 31.2, Longitude: 121.5}}, ""},
+		} {
+			cm, err := tc.claims.ClaimsMap()
+			require.NoError(err)
+
+			_, err = va.Validate(cm)
+			if tc.err == "" {
+				assert.NoError(err)
+			} else {
+				assert.ErrorContains(err, tc.err)
+			}
+		}
+	})
+
+	t.Run("submodules", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		k, err := ed25519.GenerateKey()
+		require.NoError(err)
+		signer, err := k.Signer()
+		require.NoError(err)
+		verifier, err := k.Verifier()
+		require.NoError(err)
+
+		is, err := cwt.NewSign1Issuer(signer)
+		require.NoError(err)
+		cv, err := cwt.NewValidator(&cwt.ValidatorOpts{ExpectedIssuer: "ldc:tee", FixedNow: time.Unix(1670123000, 0)})
+		require.NoError(err)
+		ve, err := cwt.NewVerifier(&cwt.VerifierOpts{Verifiers: key.Verifiers{verifier}, Validator: cv})
+		require.NoError(err)
+
+		teeClaims, err := (&Claims{SWName: "tee-os", UEID: ueid}).ClaimsMap()
+		require.NoError(err)
+		teeClaims[iana.CWTClaimIss] = "ldc:tee"
+		teeClaims[iana.CWTClaimExp] = 1670123000 + 3600
+		teeToken, err := is.Issue(teeClaims, nil)
+		require.NoError(err)
+
+		claims := &Claims{
+			Nonce: Nonce{nonce},
+			Submodules: map[string]*Submodule{
+				"radio": {Claims: &Claims{SWName: "radio-fw", Submodules: map[string]*Submodule{
+					"dsp": {Claims: &Claims{SWName: "dsp-fw"}},
+				}}},
+				"tee": {Token: teeToken},
+				"rom": {Digest: &DetachedDigest{Alg: iana.AlgorithmSHA_256, Value: key.HexBytesify("0102030405")}},
+			},
+		}
+		cm, err := claims.ClaimsMap()
+		require.NoError(err)
+
+		va, err := NewValidator(&ValidatorOpts{Nonce: nonce})
+		require.NoError(err)
+		_, err = va.Validate(cm)
+		assert.ErrorContains(err, `submodule "tee": nested token is not allowed without VerifyNested`)
+
+		va, err = NewValidator(&ValidatorOpts{
+			Nonce: nonce,
+			VerifyNested: func(token []byte) (cwt.ClaimsMap, error) {
+				tk, err := ve.Verify(token, nil)
+				if err != nil {
+					return nil, err
+				}
+				return tk.Claims, nil
+			},
+		})
+		require.NoError(err)
+		res, err := va.Validate(cm)
+		require.NoError(err)
+		assert.Equal("tee-os", res.Submodules["tee"].Claims.SWName)
+		assert.Equal("dsp-fw", res.Submodules["radio"].Claims.Submodules["dsp"].Claims.SWName)
+
+		claims.Submodules["tee"] = &Submodule{Token: append(teeToken[:len(teeToken)-1:len(teeToken)-1], teeToken[len(teeToken)-1]^0xff)}
+		cm, err = claims.ClaimsMap()
+		require.NoError(err)
+		_, err = va.Validate(cm)
+		assert.ErrorContains(err, `submodule "tee": invalid nested token`)
+
+		delete(claims.Submodules, "tee")
+		claims.Submodules["radio"].Claims.UEID = key.HexBytesify("0102")
+		cm, err = claims.ClaimsMap()
+		require.NoError(err)
+		_, err = va.Validate(cm)
+		assert.ErrorContains(err, `submodule "radio": invalid ueid`)
+
+		claims.Submodules["radio"].Claims.UEID = nil
+		claims.Submodules["rom"].Digest.Value = nil
+		cm, err = claims.ClaimsMap()
+		require.NoError(err)
+		_, err = va.Validate(cm)
+		assert.ErrorContains(err, `submodule "rom": empty detached digest`)
+
+		delete(claims.Submodules, "rom")
+		sub := &Claims{SWName: "leaf"}
+		for i := 0; i < eatMaxSubmoduleLevels; i++ {
+			sub = &Claims{Submodules: map[string]*Submodule{"sub": {Claims: sub}}}
+		}
+		claims.Submodules["deep"] = &Submodule{Claims: sub}
+		cm, err = claims.ClaimsMap()
+		require.NoError(err)
+		_, err = va.Validate(cm)
+		assert.ErrorContains(err, "too many submodule levels")
+	})
+}
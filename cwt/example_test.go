@@ -11,6 +11,7 @@ import (
 	"github.com/ldclabs/cose/cwt"
 	"github.com/ldclabs/cose/iana"
 	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/aesgcm"
 	"github.com/ldclabs/cose/key/ecdsa"
 	"github.com/ldclabs/cose/key/ed25519"
 )
@@ -154,3 +155,85 @@ func ExampleClaimsMap() {
 	// Validate Claims: cose/cwt: Validator.Validate: token has expired
 	// CBOR(50 bytes): a501666c64633a636102696c64633a636861696e036a6c64633a7478706f6f6c041a638c103b096a726561642c7772697465
 }
+
+func ExampleVerifier() {
+	// Create a ed25519 signer key and an AES-GCM key
+	privKey, err := ed25519.GenerateKey()
+	if err != nil {
+		panic(err)
+	}
+	signer, err := privKey.Signer()
+	if err != nil {
+		panic(err)
+	}
+	pubKey, err := ed25519.ToPublicKey(privKey)
+	if err != nil {
+		panic(err)
+	}
+	verifier, err := pubKey.Verifier()
+	if err != nil {
+		panic(err)
+	}
+
+	encKey, err := aesgcm.GenerateKey(iana.AlgorithmA128GCM)
+	if err != nil {
+		panic(err)
+	}
+	encryptor, err := encKey.Encryptor()
+	if err != nil {
+		panic(err)
+	}
+
+	// Issue a nested CWT, signed and then encrypted
+	signIssuer, err := cwt.NewSign1Issuer(signer)
+	if err != nil {
+		panic(err)
+	}
+	encIssuer, err := cwt.NewEncrypt0Issuer(encryptor)
+	if err != nil {
+		panic(err)
+	}
+
+	claims := &cwt.Claims{
+		Issuer:     "ldc:ca",
+		Subject:    "ldc:chain",
+		Audience:   "ldc:txpool",
+		Expiration: 1670123579,
+		CWTID:      []byte{1, 2, 3, 4},
+	}
+	token, err := signIssuer.Issue(claims, nil)
+	if err != nil {
+		panic(err)
+	}
+	token, err = encIssuer.Wrap(token, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	// Verify and validate the CWT
+	validator, err := cwt.NewValidator(&cwt.ValidatorOpts{
+		ExpectedIssuer:   "ldc:ca",
+		ExpectedAudience: "ldc:txpool",
+		FixedNow:         time.Unix(1670123000, 0),
+	})
+	if err != nil {
+		panic(err)
+	}
+	cwtVerifier, err := cwt.NewVerifier(&cwt.VerifierOpts{
+		Verifiers:  key.Verifiers{verifier},
+		Encryptors: []key.Encryptor{encryptor},
+		Validator:  validator,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	tk, err := cwtVerifier.Verify(token, nil)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Subject: %s\n", tk.Claims[iana.CWTClaimSub])
+
+	// Output:
+	// Subject: ldc:chain
+}
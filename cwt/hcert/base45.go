@@ -0,0 +1,83 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package hcert
+
+import (
+	"fmt"
+	"strings"
+)
+
+const base45Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+var base45Index = func() [256]int8 {
+	var idx [256]int8
+	for i := range idx {
+		idx[i] = -1
+	}
+	for i := 0; i < len(base45Alphabet); i++ {
+		idx[base45Alphabet[i]] = int8(i)
+	}
+	return idx
+}()
+
+// EncodeBase45 encodes the data with the Base45 encoding.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc9285
+func EncodeBase45(data []byte) string {
+	var sb strings.Builder
+	sb.Grow((len(data)/2)*3 + 2)
+
+	for i := 0; i+1 < len(data); i += 2 {
+		n := int(data[i])<<8 | int(data[i+1])
+		sb.WriteByte(base45Alphabet[n%45])
+		sb.WriteByte(base45Alphabet[(n/45)%45])
+		sb.WriteByte(base45Alphabet[n/2025])
+	}
+	if len(data)%2 == 1 {
+		n := int(data[len(data)-1])
+		sb.WriteByte(base45Alphabet[n%45])
+		sb.WriteByte(base45Alphabet[n/45])
+	}
+	return sb.String()
+}
+
+// DecodeBase45 decodes the Base45 encoded string.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc9285
+func DecodeBase45(s string) ([]byte, error) {
+	if len(s)%3 == 1 {
+		return nil, fmt.Errorf("cose/cwt/hcert: DecodeBase45: invalid length %d", len(s))
+	}
+
+	out := make([]byte, 0, len(s)/3*2+1)
+	for i := 0; i < len(s); i += 3 {
+		chunk := s[i:]
+		if len(chunk) > 3 {
+			chunk = chunk[:3]
+		}
+
+		n, m := 0, 1
+		for j := 0; j < len(chunk); j++ {
+			v := base45Index[chunk[j]]
+			if v < 0 {
+				return nil, fmt.Errorf("cose/cwt/hcert: DecodeBase45: invalid character %q at %d", chunk[j], i+j)
+			}
+			n += int(v) * m
+			m *= 45
+		}
+
+		if len(chunk) == 3 {
+			if n > 0xffff {
+				return nil, fmt.Errorf("cose/cwt/hcert: DecodeBase45: invalid chunk %q", chunk)
+			}
+			out = append(out, byte(n>>8), byte(n))
+		} else {
+			if n > 0xff {
+				return nil, fmt.Errorf("cose/cwt/hcert: DecodeBase45: invalid chunk %q", chunk)
+			}
+			out = append(out, byte(n))
+		}
+	}
+	return out, nil
+}
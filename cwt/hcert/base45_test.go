@@ -0,0 +1,47 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package hcert
+
+import (
+	"testing"
+
+	"github.com/ldclabs/cose/key"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBase45(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	for _, tc := range []struct {
+		data []byte
+		text string
+	}{
+		{[]byte{}, ""},
+		{[]byte("AB"), "BB8"},
+		{[]byte("Hello!!"), "%69 VD92EX0"},
+		{[]byte("base-45"), "UJCLQE7W581"},
+		{[]byte("ietf!"), "QED8WEX0"},
+		{key.HexBytesify("ffff"), "FGW"},
+		{key.HexBytesify("00"), "00"},
+	} {
+		assert.Equal(tc.text, EncodeBase45(tc.data))
+
+		data, err := DecodeBase45(tc.text)
+		require.NoError(err)
+		assert.Equal(tc.data, data)
+	}
+
+	_, err := DecodeBase45("GGW")
+	assert.ErrorContains(err, `invalid chunk "GGW"`)
+	_, err = DecodeBase45("BB8:")
+	assert.ErrorContains(err, "invalid length 4")
+	_, err = DecodeBase45("GGW0")
+	assert.ErrorContains(err, "invalid length")
+	_, err = DecodeBase45("ZZ")
+	assert.ErrorContains(err, `invalid chunk "ZZ"`)
+	_, err = DecodeBase45("BB8qed")
+	assert.ErrorContains(err, `invalid character 'q' at 3`)
+}
@@ -0,0 +1,158 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package hcert implements the Electronic Health Certificate (HCERT) of the EU Digital COVID Certificate (DCC).
+// The HCERT is a CWT signed with COSE_Sign1, compressed with zlib, encoded with Base45,
+// and prefixed with a context identifier, such as "HC1:".
+// https://github.com/ehn-dcc-development/hcert-spec.
+package hcert
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+// ContextIdentifier is the context identifier of the HCERT version 1.
+const ContextIdentifier = "HC1:"
+
+// maxPayloadSize is the maximum size of the decompressed COSE_Sign1 object.
+const maxPayloadSize = 64 * 1024
+
+// Claims represents the claims of an HCERT.
+type Claims struct {
+	Issuer     string `cbor:"1,keyasint,omitempty" json:"iss,omitempty"`
+	Expiration uint64 `cbor:"4,keyasint,omitempty" json:"exp,omitempty"` // seconds since epoch
+	IssuedAt   uint64 `cbor:"6,keyasint,omitempty" json:"iat,omitempty"` // seconds since epoch
+	HCert      HCert  `cbor:"-260,keyasint" json:"hcert"`
+}
+
+// HCert represents the "hcert" claim.
+type HCert struct {
+	// DGC is the EU Digital COVID Certificate v1 ("eu_DGC_v1").
+	DGC *DGC `cbor:"1,keyasint,omitempty" json:"1,omitempty"`
+}
+
+// DGC represents the EU Digital COVID Certificate payload. Only one of the
+// vaccination, test and recovery groups should be present.
+//
+// Reference https://github.com/ehn-dcc-development/eu-dcc-schema
+type DGC struct {
+	Version      string        `cbor:"ver" json:"ver"`
+	Name         Name          `cbor:"nam" json:"nam"`
+	DateOfBirth  string        `cbor:"dob" json:"dob"`
+	Vaccinations []Vaccination `cbor:"v,omitempty" json:"v,omitempty"`
+	Tests        []Test        `cbor:"t,omitempty" json:"t,omitempty"`
+	Recoveries   []Recovery    `cbor:"r,omitempty" json:"r,omitempty"`
+}
+
+// Name represents the name of the certificate holder.
+type Name struct {
+	FamilyName             string `cbor:"fn,omitempty" json:"fn,omitempty"`
+	StandardizedFamilyName string `cbor:"fnt" json:"fnt"`
+	GivenName              string `cbor:"gn,omitempty" json:"gn,omitempty"`
+	StandardizedGivenName  string `cbor:"gnt,omitempty" json:"gnt,omitempty"`
+}
+
+// Vaccination represents a vaccination entry.
+type Vaccination struct {
+	Target        string `cbor:"tg" json:"tg"`
+	Vaccine       string `cbor:"vp" json:"vp"`
+	Product       string `cbor:"mp" json:"mp"`
+	Manufacturer  string `cbor:"ma" json:"ma"`
+	DoseNumber    int    `cbor:"dn" json:"dn"`
+	TotalDoses    int    `cbor:"sd" json:"sd"`
+	Date          string `cbor:"dt" json:"dt"`
+	Country       string `cbor:"co" json:"co"`
+	Issuer        string `cbor:"is" json:"is"`
+	CertificateID string `cbor:"ci" json:"ci"`
+}
+
+// Test represents a test entry.
+type Test struct {
+	Target        string `cbor:"tg" json:"tg"`
+	TestType      string `cbor:"tt" json:"tt"`
+	TestName      string `cbor:"nm,omitempty" json:"nm,omitempty"`
+	TestDevice    string `cbor:"ma,omitempty" json:"ma,omitempty"`
+	SampleTime    string `cbor:"sc" json:"sc"`
+	Result        string `cbor:"tr" json:"tr"`
+	TestCenter    string `cbor:"tc,omitempty" json:"tc,omitempty"`
+	Country       string `cbor:"co" json:"co"`
+	Issuer        string `cbor:"is" json:"is"`
+	CertificateID string `cbor:"ci" json:"ci"`
+}
+
+// Recovery represents a recovery entry.
+type Recovery struct {
+	Target        string `cbor:"tg" json:"tg"`
+	FirstPositive string `cbor:"fr" json:"fr"`
+	Country       string `cbor:"co" json:"co"`
+	Issuer        string `cbor:"is" json:"is"`
+	ValidFrom     string `cbor:"df" json:"df"`
+	ValidUntil    string `cbor:"du" json:"du"`
+	CertificateID string `cbor:"ci" json:"ci"`
+}
+
+// Issue signs the claims with the signer, and encodes the HCERT to the text of a QR code.
+// The key identifier of the signer should be the first 8 bytes of the SHA-256 fingerprint of the DSC certificate.
+func Issue(signer key.Signer, claims *Claims) (string, error) {
+	protected := cose.Headers{}
+	if alg := signer.Key().Alg(); alg != iana.AlgorithmReserved {
+		protected[iana.HeaderParameterAlg] = alg
+	}
+	if kid := signer.Key().Kid(); len(kid) > 0 {
+		protected[iana.HeaderParameterKid] = kid
+	}
+
+	obj := &cose.Sign1Message[*Claims]{Protected: protected, Unprotected: cose.Headers{}, Payload: claims}
+	data, err := obj.SignAndEncode(signer, nil)
+	if err != nil {
+		return "", fmt.Errorf("cose/cwt/hcert: Issue: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw, _ := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	if _, err = zw.Write(data); err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return "", fmt.Errorf("cose/cwt/hcert: Issue: %w", err)
+	}
+	return ContextIdentifier + EncodeBase45(buf.Bytes()), nil
+}
+
+// Decode decodes the text of a QR code to the COSE_Sign1 object without verifying it.
+// The zlib compression is optional.
+func Decode(qr string) ([]byte, error) {
+	if !strings.HasPrefix(qr, ContextIdentifier) {
+		return nil, fmt.Errorf("cose/cwt/hcert: Decode: invalid context identifier, expected %q", ContextIdentifier)
+	}
+
+	data, err := DecodeBase45(qr[len(ContextIdentifier):])
+	if err != nil {
+		return nil, err
+	}
+
+	// zlib header, CMF 0x78 with the deflate method and 32K window
+	if len(data) > 0 && data[0] == 0x78 {
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("cose/cwt/hcert: Decode: %w", err)
+		}
+		defer zr.Close()
+
+		if data, err = io.ReadAll(io.LimitReader(zr, maxPayloadSize+1)); err != nil {
+			return nil, fmt.Errorf("cose/cwt/hcert: Decode: %w", err)
+		}
+		if len(data) > maxPayloadSize {
+			return nil, fmt.Errorf("cose/cwt/hcert: Decode: payload too large, expected <= %d bytes", maxPayloadSize)
+		}
+	}
+	return data, nil
+}
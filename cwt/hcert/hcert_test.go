@@ -0,0 +1,126 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package hcert
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDSCKey is the private key of a test Document Signer Certificate (DSC) with kid dd6c4fd4a1e8e3a4.
+var testDSCKey = key.HexBytesify("a501020248dd6c4fd4a1e8e3a40326200123582048a605b1b716008884943c2606077ee25247a488ea26ae3bc927102c3224523b")
+
+func testClaims() *Claims {
+	return &Claims{
+		Issuer:     "AT",
+		IssuedAt:   1700000000,
+		Expiration: 1731536000,
+		HCert: HCert{DGC: &DGC{
+			Version:     "1.3.0",
+			DateOfBirth: "1990-01-01",
+			Name: Name{
+				FamilyName:             "Musterfrau",
+				StandardizedFamilyName: "MUSTERFRAU",
+				GivenName:              "Erika",
+				StandardizedGivenName:  "ERIKA",
+			},
+			Vaccinations: []Vaccination{{
+				Target:        "840539006",
+				Vaccine:       "1119349007",
+				Product:       "EU/1/20/1528",
+				Manufacturer:  "ORG-100030215",
+				DoseNumber:    2,
+				TotalDoses:    2,
+				Date:          "2023-09-01",
+				Country:       "AT",
+				Issuer:        "Ministry of Health, Austria",
+				CertificateID: "URN:UVCI:01:AT:10807843F94AEE0EE5093FBC254BD813#B",
+			}},
+		}},
+	}
+}
+
+func TestClaims(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	claims := testClaims()
+	data, err := key.MarshalCBOR(claims)
+	require.NoError(err)
+
+	var cm map[int]any
+	require.NoError(key.UnmarshalCBOR(data, &cm))
+	assert.Equal("AT", cm[iana.CWTClaimIss])
+	hcert, ok := cm[iana.CWTClaimHCert].(map[any]any)
+	require.True(ok)
+	dgc, ok := hcert[uint64(1)].(map[any]any)
+	require.True(ok)
+	assert.Equal("1.3.0", dgc["ver"])
+	assert.NotContains(dgc, "t")
+
+	var c Claims
+	require.NoError(key.UnmarshalCBOR(data, &c))
+	assert.Equal(claims, &c)
+
+	jsondata, err := json.Marshal(claims.HCert.DGC)
+	require.NoError(err)
+	assert.Contains(string(jsondata), `"nam":{"fn":"Musterfrau","fnt":"MUSTERFRAU","gn":"Erika","gnt":"ERIKA"}`)
+}
+
+func TestIssueAndDecode(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var k key.Key
+	require.NoError(key.UnmarshalCBOR(testDSCKey, &k))
+	signer, err := k.Signer()
+	require.NoError(err)
+
+	qr, err := Issue(signer, testClaims())
+	require.NoError(err)
+	assert.Equal(ContextIdentifier, qr[:4])
+
+	data, err := Decode(qr)
+	require.NoError(err)
+
+	obj := &cose.Sign1Message[cbor.RawMessage]{}
+	require.NoError(obj.UnmarshalCBOR(data))
+	kid, err := obj.Protected.GetBytes(iana.HeaderParameterKid)
+	require.NoError(err)
+	assert.Equal(key.HexBytesify("dd6c4fd4a1e8e3a4"), kid)
+	alg, err := obj.Protected.GetInt(iana.HeaderParameterAlg)
+	require.NoError(err)
+	assert.Equal(iana.AlgorithmES256, alg)
+
+	// the zlib compression is optional.
+	data2, err := Decode(ContextIdentifier + EncodeBase45(data))
+	require.NoError(err)
+	assert.Equal(data, data2)
+
+	_, err = Decode("HC2:" + EncodeBase45(data))
+	assert.ErrorContains(err, "invalid context identifier")
+
+	_, err = Decode(ContextIdentifier + "GGW")
+	assert.ErrorContains(err, "cose/cwt/hcert: DecodeBase45")
+
+	_, err = Decode(ContextIdentifier + EncodeBase45([]byte{0x78, 0x01, 0x02}))
+	assert.ErrorContains(err, "cose/cwt/hcert: Decode")
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err = zw.Write(make([]byte, maxPayloadSize+1))
+	require.NoError(err)
+	require.NoError(zw.Close())
+	_, err = Decode(ContextIdentifier + EncodeBase45(buf.Bytes()))
+	assert.ErrorContains(err, "payload too large")
+}
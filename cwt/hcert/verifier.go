@@ -0,0 +1,107 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package hcert
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/cwt"
+	"github.com/ldclabs/cose/key"
+)
+
+// VerifierOpts defines the trust list and the validator for HCERT verifiers.
+type VerifierOpts struct {
+	// TrustList is the public keys of the Document Signer Certificates (DSC),
+	// every key should have a key identifier.
+	TrustList key.KeySet
+	// Validator validates the "exp" and "iat" claims of the verified HCERTs. It is required.
+	Validator *cwt.Validator
+}
+
+// Verifier verifies HCERTs with the keys in the trust list.
+type Verifier struct {
+	verifiers key.Verifiers
+	validator *cwt.Validator
+}
+
+// NewVerifier creates a new HCERT Verifier.
+func NewVerifier(opts *VerifierOpts) (*Verifier, error) {
+	if opts == nil {
+		return nil, errors.New("cose/cwt/hcert: NewVerifier: nil VerifierOpts")
+	}
+	if opts.Validator == nil {
+		return nil, errors.New("cose/cwt/hcert: NewVerifier: nil Validator")
+	}
+	if len(opts.TrustList) == 0 {
+		return nil, errors.New("cose/cwt/hcert: NewVerifier: empty TrustList")
+	}
+
+	v := &Verifier{
+		verifiers: make(key.Verifiers, 0, len(opts.TrustList)),
+		validator: opts.Validator,
+	}
+	for _, k := range opts.TrustList {
+		kid := []byte(k.Kid())
+		if len(kid) == 0 {
+			return nil, errors.New("cose/cwt/hcert: NewVerifier: key without kid in TrustList")
+		}
+
+		verifier, err := k.Verifier()
+		if err != nil {
+			return nil, fmt.Errorf("cose/cwt/hcert: NewVerifier: invalid key %x, %w", kid, err)
+		}
+		v.verifiers = append(v.verifiers, verifier)
+	}
+	return v, nil
+}
+
+// Verify decodes the text of a QR code, verifies the COSE_Sign1 signature with the key
+// in the trust list selected by the "kid" header parameter, validates the claims,
+// and returns the typed HCERT claims.
+func (v *Verifier) Verify(qr string) (*Claims, error) {
+	data, err := Decode(qr)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &cose.Sign1Message[cbor.RawMessage]{}
+	if err = obj.UnmarshalCBOR(data); err != nil {
+		return nil, fmt.Errorf("cose/cwt/hcert: Verifier.Verify: %w", err)
+	}
+
+	// the kid should be in the protected header, but it may be in the unprotected header.
+	kid := cwt.LookupKid(obj.Protected, obj.Unprotected)
+	if len(kid) == 0 {
+		return nil, errors.New("cose/cwt/hcert: Verifier.Verify: missing kid")
+	}
+	verifier := cwt.LookupKey(v.verifiers, kid)
+	if verifier == nil {
+		return nil, fmt.Errorf("cose/cwt/hcert: Verifier.Verify: no key in TrustList for kid %x", kid)
+	}
+
+	if err = obj.Verify(verifier, nil); err != nil {
+		return nil, fmt.Errorf("cose/cwt/hcert: Verifier.Verify: %w", err)
+	}
+
+	var cm cwt.ClaimsMap
+	if err = key.UnmarshalCBOR(obj.Payload, &cm); err != nil {
+		return nil, fmt.Errorf("cose/cwt/hcert: Verifier.Verify: invalid claims, %w", err)
+	}
+	if err = v.validator.ValidateMap(cm); err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	if err = key.UnmarshalCBOR(obj.Payload, claims); err != nil {
+		return nil, fmt.Errorf("cose/cwt/hcert: Verifier.Verify: invalid claims, %w", err)
+	}
+	if claims.HCert.DGC == nil {
+		return nil, errors.New("cose/cwt/hcert: Verifier.Verify: missing eu_DGC_v1 in the hcert claim")
+	}
+	return claims, nil
+}
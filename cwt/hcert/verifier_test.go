@@ -0,0 +1,168 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package hcert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ldclabs/cose/cwt"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/ecdsa"
+	"github.com/ldclabs/cose/key/hmac"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTrustList is a sample trust list with the public keys of the test DSCs.
+var testTrustList = []string{
+	"a601020248dd6c4fd4a1e8e3a403262001215820536b4bd727a3ae3321daf8e470171e0a63eb7ae4d8d71599c5508739c38bd8d0225820da0bdbd32ff5bbf5c45e440cf76c39203c4fb4fd5ce47ce45cbe42753c06e202",
+}
+
+// testQR is a sample HCERT signed by the test DSC with kid dd6c4fd4a1e8e3a4.
+const testQR = "HC1:NCFJ20.80T9WTWGSLKC 4N997VDS*Q*JTO KFBBM:2*70TB8FN062DTR4WY0J C1LUD97TK0F90KECTHGWJC0FDTA6AIA%G7X+AQB9746IG77TA$96T476:6/Q6M*8CR63Y8R46WX8F46VL6/G8SF6DR64S8+96QK4WJCT3ETB8XJC$+DXJCCWENF6QF63W5CA7746%JC+QEEK3ZED+EDKWE3EFX3ET34X C:VDS7DM347%EKWEMED.JCBECB1A-:8$966469L6OF6VX6Q$D.UDRYA 96NF6L/5SW6Y57KQEPD09WEQDD+Q6TW6FA7C466KCN9E%961A6DL6FA7D46JPCT3E5JDOA73467463W5/A6..DX%DZJC6/DTZ9 QE5$C .CJEC JC1/D3Z8WED1ECW.CCWE.Y92OAGY82+8UB8MPCG/D5 C5IA5N9$PC5$CUZC$$5Y$527B7M3*254%N ROGEEU43R:4CKN5SLU%OJ40OAWPAP18QW5TDG46-RSML+DI5T5BSKIO2X:CY04/8P2 FTJE 2QH$3TZRYNBZSU3H0K.EJ:F"
+
+func loadTrustList(t *testing.T) key.KeySet {
+	var ks key.KeySet
+	for _, s := range testTrustList {
+		var k key.Key
+		require.NoError(t, key.UnmarshalCBOR(key.HexBytesify(s), &k))
+		ks = append(ks, k)
+	}
+
+	other, err := ecdsa.GenerateKey(iana.AlgorithmES256)
+	require.NoError(t, err)
+	other.SetKid(key.HexBytesify("0102030405060708"))
+	otherPub, err := ecdsa.ToPublicKey(other)
+	require.NoError(t, err)
+	return append(ks, otherPub)
+}
+
+func TestVerifier(t *testing.T) {
+	trustList := loadTrustList(t)
+	validator, err := cwt.NewValidator(&cwt.ValidatorOpts{FixedNow: time.Unix(1710000000, 0)})
+	require.NoError(t, err)
+
+	t.Run("NewVerifier", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := NewVerifier(nil)
+		assert.ErrorContains(err, "nil VerifierOpts")
+
+		_, err = NewVerifier(&VerifierOpts{TrustList: trustList})
+		assert.ErrorContains(err, "nil Validator")
+
+		_, err = NewVerifier(&VerifierOpts{Validator: validator})
+		assert.ErrorContains(err, "empty TrustList")
+
+		k, err := hmac.GenerateKey(iana.AlgorithmHMAC_256_256)
+		require.NoError(t, err)
+		delete(k, iana.KeyParameterKid)
+		_, err = NewVerifier(&VerifierOpts{TrustList: key.KeySet{k}, Validator: validator})
+		assert.ErrorContains(err, "key without kid")
+
+		_, err = NewVerifier(&VerifierOpts{TrustList: key.KeySet{{iana.KeyParameterKid: []byte{1}}}, Validator: validator})
+		assert.ErrorContains(err, "invalid key 01")
+	})
+
+	t.Run("Verify fixture", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		v, err := NewVerifier(&VerifierOpts{TrustList: trustList, Validator: validator})
+		require.NoError(err)
+
+		claims, err := v.Verify(testQR)
+		require.NoError(err)
+		assert.Equal(testClaims(), claims)
+		assert.Equal("ERIKA", claims.HCert.DGC.Name.StandardizedGivenName)
+		assert.Equal(2, claims.HCert.DGC.Vaccinations[0].DoseNumber)
+
+		expired, err := cwt.NewValidator(&cwt.ValidatorOpts{FixedNow: time.Unix(1740000000, 0)})
+		require.NoError(err)
+		v2, err := NewVerifier(&VerifierOpts{TrustList: trustList, Validator: expired})
+		require.NoError(err)
+		_, err = v2.Verify(testQR)
+		assert.ErrorContains(err, "token has expired")
+
+		v3, err := NewVerifier(&VerifierOpts{TrustList: trustList[1:], Validator: validator})
+		require.NoError(err)
+		_, err = v3.Verify(testQR)
+		assert.ErrorContains(err, "no key in TrustList for kid dd6c4fd4a1e8e3a4")
+
+		_, err = v.Verify("HC1:" + testQR)
+		assert.ErrorContains(err, "cose/cwt/hcert: DecodeBase45")
+	})
+
+	t.Run("Verify issued", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		v, err := NewVerifier(&VerifierOpts{TrustList: trustList, Validator: validator})
+		require.NoError(err)
+
+		var k key.Key
+		require.NoError(key.UnmarshalCBOR(testDSCKey, &k))
+		signer, err := k.Signer()
+		require.NoError(err)
+
+		claims := testClaims()
+		claims.HCert.DGC.Vaccinations = nil
+		claims.HCert.DGC.Tests = []Test{{
+			Target:        "840539006",
+			TestType:      "LP6464-4",
+			SampleTime:    "2024-03-01T08:00:00Z",
+			Result:        "260415000",
+			TestCenter:    "Test Center Vienna",
+			Country:       "AT",
+			Issuer:        "Ministry of Health, Austria",
+			CertificateID: "URN:UVCI:01:AT:71EE2559DE38C6BF7304FB65A1A451EC#3",
+		}}
+		qr, err := Issue(signer, claims)
+		require.NoError(err)
+		c, err := v.Verify(qr)
+		require.NoError(err)
+		assert.Equal(claims, c)
+
+		claims.HCert.DGC = nil
+		qr, err = Issue(signer, claims)
+		require.NoError(err)
+		_, err = v.Verify(qr)
+		assert.ErrorContains(err, "missing eu_DGC_v1")
+
+		// signed by a key with the same kid that is not in the trust list.
+		fake, err := ecdsa.GenerateKey(iana.AlgorithmES256)
+		require.NoError(err)
+		fake.SetKid(k.Kid())
+		fakeSigner, err := fake.Signer()
+		require.NoError(err)
+		qr, err = Issue(fakeSigner, testClaims())
+		require.NoError(err)
+		_, err = v.Verify(qr)
+		assert.ErrorContains(err, "cose/cwt/hcert: Verifier.Verify")
+
+		qr, err = Issue(signer, testClaims())
+		require.NoError(err)
+		data, err := Decode(qr)
+		require.NoError(err)
+		_, err = v.Verify(ContextIdentifier + EncodeBase45(data[:len(data)-1]))
+		assert.ErrorContains(err, "cose/cwt/hcert: Verifier.Verify")
+
+		// signed without a kid, even if the trust list has only one key.
+		noKid := key.Key{}
+		for p, val := range k {
+			noKid[p] = val
+		}
+		delete(noKid, iana.KeyParameterKid)
+		noKidSigner, err := noKid.Signer()
+		require.NoError(err)
+		qr, err = Issue(noKidSigner, testClaims())
+		require.NoError(err)
+		v1, err := NewVerifier(&VerifierOpts{TrustList: trustList[:1], Validator: validator})
+		require.NoError(err)
+		_, err = v1.Verify(qr)
+		assert.ErrorContains(err, "cose/cwt/hcert: Verifier.Verify: missing kid")
+	})
+}
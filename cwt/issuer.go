@@ -0,0 +1,138 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+// Issuer issues CBOR Web Tokens (CWT) protected with COSE_Sign1, COSE_Mac0 or COSE_Encrypt0.
+// The tokens are tagged with the CWT CBOR tag 61.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc8392#section-7.1
+type Issuer struct {
+	signer    key.Signer
+	macer     key.MACer
+	encryptor key.Encryptor
+}
+
+// NewSign1Issuer creates a new Issuer that signs tokens with COSE_Sign1.
+func NewSign1Issuer(signer key.Signer) (*Issuer, error) {
+	if signer == nil {
+		return nil, errors.New("cose/cwt: NewSign1Issuer: nil Signer")
+	}
+	return &Issuer{signer: signer}, nil
+}
+
+// NewMac0Issuer creates a new Issuer that MACs tokens with COSE_Mac0.
+func NewMac0Issuer(macer key.MACer) (*Issuer, error) {
+	if macer == nil {
+		return nil, errors.New("cose/cwt: NewMac0Issuer: nil MACer")
+	}
+	return &Issuer{macer: macer}, nil
+}
+
+// NewEncrypt0Issuer creates a new Issuer that encrypts tokens with COSE_Encrypt0.
+func NewEncrypt0Issuer(encryptor key.Encryptor) (*Issuer, error) {
+	if encryptor == nil {
+		return nil, errors.New("cose/cwt: NewEncrypt0Issuer: nil Encryptor")
+	}
+	return &Issuer{encryptor: encryptor}, nil
+}
+
+// Issue encodes the claims, a *Claims, a ClaimsMap or any CBOR-encodable claims set,
+// protects it with the Issuer, and returns the CWT tagged with the CWT CBOR tag.
+// `externalData` can be nil. https://datatracker.ietf.org/doc/html/rfc9052#name-externally-supplied-data
+func (i *Issuer) Issue(claims any, externalData []byte) ([]byte, error) {
+	if claims == nil {
+		return nil, errors.New("cose/cwt: Issuer.Issue: nil claims")
+	}
+
+	payload, err := key.MarshalCBOR(claims)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt: Issuer.Issue: %w", err)
+	}
+
+	data, err := i.protect(payload, externalData)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt: Issuer.Issue: %w", err)
+	}
+	return data, nil
+}
+
+// Wrap protects an issued CWT with the Issuer again, and returns the nested CWT,
+// such as a signed CWT that is then encrypted.
+// The CWT CBOR tag is removed from the inner CWT, and is added to the outer CWT.
+// `externalData` can be nil. https://datatracker.ietf.org/doc/html/rfc9052#name-externally-supplied-data
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc8392#appendix-A.6
+func (i *Issuer) Wrap(token, externalData []byte) ([]byte, error) {
+	var tag cbor.RawTag
+	if err := key.UnmarshalCBOR(token, &tag); err != nil {
+		return nil, fmt.Errorf("cose/cwt: Issuer.Wrap: invalid CWT, %w", err)
+	}
+
+	inner := token
+	if tag.Number == iana.CBORTagCWT {
+		inner = tag.Content
+	}
+
+	data, err := i.protect(inner, externalData)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt: Issuer.Wrap: %w", err)
+	}
+	return data, nil
+}
+
+func (i *Issuer) protect(payload, externalData []byte) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	switch {
+	case i.signer != nil:
+		obj := &cose.Sign1Message[cbor.RawMessage]{Payload: payload}
+		data, err = obj.SignAndEncode(i.signer, externalData)
+
+	case i.macer != nil:
+		obj := &cose.Mac0Message[cbor.RawMessage]{Payload: payload}
+		data, err = obj.ComputeAndEncode(i.macer, externalData)
+
+	case i.encryptor != nil:
+		obj := &cose.Encrypt0Message[cbor.RawMessage]{Payload: payload}
+		data, err = obj.EncryptAndEncode(i.encryptor, externalData)
+
+	default:
+		return nil, errors.New("invalid Issuer, should be created by NewSign1Issuer, NewMac0Issuer or NewEncrypt0Issuer")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return withCWTTag(data)
+}
+
+// withCWTTag adds the CWT CBOR tag to the tagged COSE object if it is not present.
+func withCWTTag(data []byte) ([]byte, error) {
+	var tag cbor.RawTag
+	if err := key.UnmarshalCBOR(data, &tag); err != nil {
+		return nil, err
+	}
+	if tag.Number == iana.CBORTagCWT {
+		return data, nil
+	}
+
+	return key.MarshalCBOR(cbor.Tag{
+		Number:  iana.CBORTagCWT,
+		Content: cbor.RawMessage(data),
+	})
+}
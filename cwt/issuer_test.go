@@ -0,0 +1,106 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"testing"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/aesgcm"
+	"github.com/ldclabs/cose/key/ed25519"
+	"github.com/ldclabs/cose/key/hmac"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuer(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	_, err := NewSign1Issuer(nil)
+	assert.ErrorContains(err, "nil Signer")
+	_, err = NewMac0Issuer(nil)
+	assert.ErrorContains(err, "nil MACer")
+	_, err = NewEncrypt0Issuer(nil)
+	assert.ErrorContains(err, "nil Encryptor")
+
+	_, err = (&Issuer{}).Issue(&Claims{}, nil)
+	assert.ErrorContains(err, "invalid Issuer")
+
+	k, err := ed25519.GenerateKey()
+	require.NoError(err)
+	signer, err := k.Signer()
+	require.NoError(err)
+	pk, err := ed25519.ToPublicKey(k)
+	require.NoError(err)
+	verifier, err := pk.Verifier()
+	require.NoError(err)
+
+	k, err = hmac.GenerateKey(iana.AlgorithmHMAC_256_256)
+	require.NoError(err)
+	macer, err := k.MACer()
+	require.NoError(err)
+
+	k, err = aesgcm.GenerateKey(iana.AlgorithmA128GCM)
+	require.NoError(err)
+	encryptor, err := k.Encryptor()
+	require.NoError(err)
+
+	claims := &Claims{Issuer: "ldc:ca", Subject: "ldc:chain"}
+
+	sign1Issuer, err := NewSign1Issuer(signer)
+	require.NoError(err)
+	_, err = sign1Issuer.Issue(nil, nil)
+	assert.ErrorContains(err, "nil claims")
+
+	data, err := sign1Issuer.Issue(claims, []byte("external"))
+	require.NoError(err)
+	assert.Equal([]byte{0xd8, 0x3d, 0xd2}, data[:3])
+
+	s1, err := cose.VerifySign1Message[Claims](verifier, data, []byte("external"))
+	require.NoError(err)
+	assert.Equal(*claims, s1.Payload)
+	assert.Equal(signer.Key().Kid(), key.ByteStr(LookupKid(s1.Protected, s1.Unprotected)))
+
+	mac0Issuer, err := NewMac0Issuer(macer)
+	require.NoError(err)
+	data, err = mac0Issuer.Issue(ClaimsMap{iana.CWTClaimIss: "ldc:ca"}, nil)
+	require.NoError(err)
+	assert.Equal([]byte{0xd8, 0x3d, 0xd1}, data[:3])
+
+	m0, err := cose.VerifyMac0Message[ClaimsMap](macer, data, nil)
+	require.NoError(err)
+	assert.Equal("ldc:ca", m0.Payload[iana.CWTClaimIss])
+
+	encrypt0Issuer, err := NewEncrypt0Issuer(encryptor)
+	require.NoError(err)
+	data, err = encrypt0Issuer.Issue(claims, nil)
+	require.NoError(err)
+	assert.Equal([]byte{0xd8, 0x3d, 0xd0}, data[:3])
+
+	e0, err := cose.DecryptEncrypt0Message[Claims](encryptor, data, nil)
+	require.NoError(err)
+	assert.Equal(*claims, e0.Payload)
+
+	// nested CWT, signed and then encrypted
+	_, err = encrypt0Issuer.Wrap([]byte{0xa0}, nil)
+	assert.ErrorContains(err, "cose/cwt: Issuer.Wrap: invalid CWT")
+
+	inner, err := sign1Issuer.Issue(claims, nil)
+	require.NoError(err)
+	data, err = encrypt0Issuer.Wrap(inner, nil)
+	require.NoError(err)
+	assert.Equal([]byte{0xd8, 0x3d, 0xd0}, data[:3])
+
+	e0b, err := cose.DecryptEncrypt0Message[[]byte](encryptor, data, nil)
+	require.NoError(err)
+	// the CWT tag is removed from the inner CWT
+	assert.Equal(inner[2:], e0b.Payload)
+
+	s1, err = cose.VerifySign1Message[Claims](verifier, e0b.Payload, nil)
+	require.NoError(err)
+	assert.Equal(*claims, s1.Payload)
+}
@@ -0,0 +1,459 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+// JWTValueKind defines how the value of a claim is converted between CWT and JWT.
+type JWTValueKind int
+
+const (
+	// JWTValueAny converts the value structurally, the byte strings are converted to base64url strings,
+	// and the integer map keys are converted to decimal strings.
+	// The base64url strings are not converted back to byte strings.
+	JWTValueAny JWTValueKind = iota
+	// JWTValueBytes converts a byte string to a base64url string (without padding), and back.
+	JWTValueBytes
+	// JWTValueBytesArray converts a byte string or an array of byte strings to a base64url string
+	// or an array of base64url strings, and back, such as the "nonce" claim of EAT.
+	JWTValueBytesArray
+	// JWTValueConfirmation converts the "cnf" claim to the RFC 7800 confirmation, and back.
+	// The COSE_Key is converted to a JWK with key.Key.ToJWK and key.KeyFromJWK.
+	// The kid is a text string in JWT as the JWK kid, so it is used as is if it is printable UTF-8,
+	// otherwise it is base64url encoded, and the JWT kid is converted to its UTF-8 bytes.
+	// The Encrypted_COSE_Key has no JWT counterpart.
+	//
+	// Reference https://datatracker.ietf.org/doc/html/rfc7800#section-3
+	JWTValueConfirmation
+	// JWTValueID converts a byte string identifier to a string, and back, such as the "cti" claim.
+	// The JWT identifier is an arbitrary string, so it is converted to its UTF-8 bytes, and
+	// the identifier that is printable UTF-8 is used as is, otherwise it is base64url encoded.
+	JWTValueID
+)
+
+// JWTClaim maps a registered CWT claim key to a JWT claim name.
+type JWTClaim struct {
+	// Label is the CWT claim key, such as iana.CWTClaimIss.
+	Label int
+	// Name is the JWT claim name, such as "iss".
+	Name string
+	// Kind defines how the value is converted.
+	Kind JWTValueKind
+}
+
+// JWTRegistry converts claims between a CWT ClaimsMap and a JWT claims set.
+// The registered claim keys are mapped to the names, and the private claims
+// with text string keys are kept as-is. It is safe for concurrent use.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc8392#section-3
+type JWTRegistry struct {
+	mu      sync.RWMutex
+	byLabel map[int]JWTClaim
+	byName  map[string]JWTClaim
+}
+
+// DefaultJWTRegistry is the JWTRegistry used by ClaimsMap.ToJWT and ClaimsMapFromJWT.
+var DefaultJWTRegistry = NewJWTRegistry()
+
+// NewJWTRegistry creates a JWTRegistry with the registered CWT claims that have a JWT counterpart.
+func NewJWTRegistry() *JWTRegistry {
+	r := &JWTRegistry{
+		byLabel: make(map[int]JWTClaim),
+		byName:  make(map[string]JWTClaim),
+	}
+
+	for _, c := range []JWTClaim{
+		{iana.CWTClaimIss, "iss", JWTValueAny},
+		{iana.CWTClaimSub, "sub", JWTValueAny},
+		{iana.CWTClaimAud, "aud", JWTValueAny},
+		{iana.CWTClaimExp, "exp", JWTValueAny},
+		{iana.CWTClaimNbf, "nbf", JWTValueAny},
+		{iana.CWTClaimIat, "iat", JWTValueAny},
+		{iana.CWTClaimCti, "jti", JWTValueID},
+		{iana.CWTClaimCnf, "cnf", JWTValueConfirmation},
+		{iana.CWTClaimScope, "scope", JWTValueAny},
+		{iana.CWTClaimNonce, "nonce", JWTValueBytesArray},
+		{iana.CWTClaimACEProfile, "ace_profile", JWTValueAny},
+		{iana.CWTClaimCNonce, "cnonce", JWTValueBytes},
+		{iana.CWTClaimExi, "exi", JWTValueAny},
+		{iana.CWTClaimUEID, "ueid", JWTValueBytes},
+		{iana.CWTClaimHWModel, "hwmodel", JWTValueBytes},
+		{iana.CWTClaimUptime, "uptime", JWTValueAny},
+		{iana.CWTClaimSecureBoot, "oemboot", JWTValueAny},
+		{iana.CWTClaimDebugStatus, "dbgstat", JWTValueAny},
+		{iana.CWTClaimLocation, "location", JWTValueAny},
+		{iana.CWTClaimProfile, "eat_profile", JWTValueAny},
+		{iana.CWTClaimStatusList, "status_list", JWTValueAny},
+		{iana.CWTClaimTTL, "ttl", JWTValueAny},
+		{iana.CWTClaimStatus, "status", JWTValueAny},
+	} {
+		r.byLabel[c.Label] = c
+		r.byName[c.Name] = c
+	}
+	return r
+}
+
+// Register registers a claim mapping. It returns an error if the label or the name is already registered.
+func (r *JWTRegistry) Register(c JWTClaim) error {
+	if c.Name == "" {
+		return errors.New("cose/cwt: JWTRegistry.Register: empty claim name")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.byLabel[c.Label]; ok {
+		return fmt.Errorf("cose/cwt: JWTRegistry.Register: claim %d already registered", c.Label)
+	}
+	if _, ok := r.byName[c.Name]; ok {
+		return fmt.Errorf("cose/cwt: JWTRegistry.Register: claim %q already registered", c.Name)
+	}
+	r.byLabel[c.Label] = c
+	r.byName[c.Name] = c
+	return nil
+}
+
+// ToJWT converts a ClaimsMap to a JWT claims set.
+// It returns an error if a claim key is an integer that is not registered.
+func (r *JWTRegistry) ToJWT(cm ClaimsMap) (map[string]any, error) {
+	// normalize the typed values, such as *Confirmation, to the generic CBOR data model.
+	var norm ClaimsMap
+	if err := key.UnmarshalCBOR(cm.Bytesify(), &norm); err != nil {
+		return nil, fmt.Errorf("cose/cwt: JWTRegistry.ToJWT: %w", err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	claims := make(map[string]any, len(norm))
+	for k, v := range norm {
+		var name string
+		kind := JWTValueAny
+
+		switch x := k.(type) {
+		case int:
+			c, ok := r.byLabel[x]
+			if !ok {
+				return nil, fmt.Errorf("cose/cwt: JWTRegistry.ToJWT: no JWT name registered for claim %d", x)
+			}
+			name, kind = c.Name, c.Kind
+		case string:
+			if c, ok := r.byName[x]; ok {
+				return nil, fmt.Errorf("cose/cwt: JWTRegistry.ToJWT: claim %q conflicts with the registered claim %d", x, c.Label)
+			}
+			name = x
+		default:
+			return nil, fmt.Errorf("cose/cwt: JWTRegistry.ToJWT: invalid claim key type %T", k)
+		}
+
+		jv, err := toJWTClaim(kind, v)
+		if err != nil {
+			return nil, fmt.Errorf("cose/cwt: JWTRegistry.ToJWT: claim %q, %w", name, err)
+		}
+		claims[name] = jv
+	}
+	return claims, nil
+}
+
+// FromJWT converts a JWT claims set to a ClaimsMap. The registered names are mapped to the claim keys,
+// the other claims are kept with text string keys. Integral JSON numbers are converted to integers.
+func (r *JWTRegistry) FromJWT(claims map[string]any) (ClaimsMap, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cm := make(ClaimsMap, len(claims))
+	for name, v := range claims {
+		c, ok := r.byName[name]
+		if !ok {
+			cv, err := fromJWTValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("cose/cwt: JWTRegistry.FromJWT: claim %q, %w", name, err)
+			}
+			cm[name] = cv
+			continue
+		}
+
+		cv, err := fromJWTClaim(c.Kind, v)
+		if err != nil {
+			return nil, fmt.Errorf("cose/cwt: JWTRegistry.FromJWT: claim %q, %w", name, err)
+		}
+		cm[c.Label] = cv
+	}
+	return cm, nil
+}
+
+// MarshalJWT converts a ClaimsMap to a JSON-encoded JWT claims set.
+func (r *JWTRegistry) MarshalJWT(cm ClaimsMap) ([]byte, error) {
+	claims, err := r.ToJWT(cm)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(claims)
+}
+
+// UnmarshalJWT converts a JSON-encoded JWT claims set to a ClaimsMap.
+func (r *JWTRegistry) UnmarshalJWT(data []byte) (ClaimsMap, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var claims map[string]any
+	if err := dec.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("cose/cwt: JWTRegistry.UnmarshalJWT: %w", err)
+	}
+	if claims == nil {
+		return nil, errors.New("cose/cwt: JWTRegistry.UnmarshalJWT: invalid JWT claims set")
+	}
+	return r.FromJWT(claims)
+}
+
+// ToJWT converts the ClaimsMap to a JWT claims set with the DefaultJWTRegistry.
+func (cm ClaimsMap) ToJWT() (map[string]any, error) {
+	return DefaultJWTRegistry.ToJWT(cm)
+}
+
+// ClaimsMapFromJWT converts a JWT claims set to a ClaimsMap with the DefaultJWTRegistry.
+func ClaimsMapFromJWT(claims map[string]any) (ClaimsMap, error) {
+	return DefaultJWTRegistry.FromJWT(claims)
+}
+
+// toJWTClaim converts a CBOR claim value to a JWT claim value according to the kind.
+func toJWTClaim(kind JWTValueKind, v any) (any, error) {
+	switch kind {
+	case JWTValueID:
+		b, ok := v.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("should be a byte string, got %T", v)
+		}
+		return textOrBase64URL(b), nil
+
+	case JWTValueBytes:
+		b, ok := v.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("should be a byte string, got %T", v)
+		}
+		return base64.RawURLEncoding.EncodeToString(b), nil
+
+	case JWTValueBytesArray:
+		if arr, ok := v.([]any); ok {
+			strs := make([]any, len(arr))
+			for i, e := range arr {
+				b, ok := e.([]byte)
+				if !ok {
+					return nil, fmt.Errorf("should be an array of byte strings, got %T element", e)
+				}
+				strs[i] = base64.RawURLEncoding.EncodeToString(b)
+			}
+			return strs, nil
+		}
+		return toJWTClaim(JWTValueBytes, v)
+
+	case JWTValueConfirmation:
+		cnf, err := ExtractConfirmation(v)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case cnf.Key != nil:
+			jwk, err := cnf.Key.ToJWK()
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"jwk": jwk}, nil
+		case len(cnf.Kid) > 0:
+			return map[string]any{"kid": textOrBase64URL(cnf.Kid)}, nil
+		default:
+			return nil, errors.New("Encrypted_COSE_Key has no JWT counterpart")
+		}
+
+	default:
+		return toJWTValue(v)
+	}
+}
+
+// fromJWTClaim converts a JWT claim value to a CBOR claim value according to the kind.
+func fromJWTClaim(kind JWTValueKind, v any) (any, error) {
+	switch kind {
+	case JWTValueBytes:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("should be a base64url string, got %T", v)
+		}
+		return decodeBase64URL(s)
+
+	case JWTValueID:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("should be a string, got %T", v)
+		}
+		return []byte(s), nil
+
+	case JWTValueBytesArray:
+		if arr, ok := v.([]any); ok {
+			bs := make([]any, len(arr))
+			for i, e := range arr {
+				b, err := fromJWTClaim(JWTValueBytes, e)
+				if err != nil {
+					return nil, err
+				}
+				bs[i] = b
+			}
+			return bs, nil
+		}
+		return fromJWTClaim(JWTValueBytes, v)
+
+	case JWTValueConfirmation:
+		m, ok := v.(map[string]any)
+		if !ok || len(m) != 1 {
+			return nil, fmt.Errorf("should be a confirmation object with one member, got %v", v)
+		}
+		var name string
+		var mv any
+		for name, mv = range m {
+		}
+
+		switch name {
+		case "jwk":
+			jwk, ok := mv.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid jwk confirmation method, got %T", mv)
+			}
+			k, err := key.KeyFromJWK(jwk)
+			if err != nil {
+				return nil, err
+			}
+			return map[any]any{iana.CWTConfirmationCOSEKey: k}, nil
+
+		case "kid":
+			s, ok := mv.(string)
+			if !ok || s == "" {
+				return nil, fmt.Errorf("invalid kid confirmation method, got %v", mv)
+			}
+			return map[any]any{iana.CWTConfirmationKid: []byte(s)}, nil
+
+		default:
+			return nil, fmt.Errorf("unsupported confirmation method %q", name)
+		}
+
+	default:
+		return fromJWTValue(v)
+	}
+}
+
+func toJWTValue(v any) (any, error) {
+	switch x := v.(type) {
+	case []byte:
+		return base64.RawURLEncoding.EncodeToString(x), nil
+	case []any:
+		arr := make([]any, len(x))
+		for i, e := range x {
+			jv, err := toJWTValue(e)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = jv
+		}
+		return arr, nil
+	case map[any]any:
+		m := make(map[string]any, len(x))
+		for k, e := range x {
+			jv, err := toJWTValue(e)
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(k)] = jv
+		}
+		return m, nil
+	case time.Time:
+		// the epoch-based date/time (tag 1) is converted to a NumericDate.
+		return x.Unix(), nil
+	case cbor.Tag:
+		return nil, fmt.Errorf("unsupported CBOR tag %d", x.Number)
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil, fmt.Errorf("unsupported float value %v", x)
+		}
+		return x, nil
+	default:
+		return v, nil
+	}
+}
+
+func fromJWTValue(v any) (any, error) {
+	switch x := v.(type) {
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := x.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	case float64:
+		if x == math.Trunc(x) && math.Abs(x) < 1<<53 {
+			return int64(x), nil
+		}
+		return x, nil
+	case []any:
+		arr := make([]any, len(x))
+		for i, e := range x {
+			cv, err := fromJWTValue(e)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = cv
+		}
+		return arr, nil
+	case map[string]any:
+		m := make(map[any]any, len(x))
+		for k, e := range x {
+			cv, err := fromJWTValue(e)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = cv
+		}
+		return m, nil
+	default:
+		return v, nil
+	}
+}
+
+// textOrBase64URL returns the byte string as is if it is printable UTF-8,
+// otherwise the base64url string.
+func textOrBase64URL(b []byte) string {
+	if !utf8.Valid(b) {
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) {
+			return base64.RawURLEncoding.EncodeToString(b)
+		}
+	}
+	return string(b)
+}
+
+// decodeBase64URL decodes a base64url string with or without padding.
+func decodeBase64URL(s string) ([]byte, error) {
+	if len(s)%4 == 0 && len(s) > 0 && s[len(s)-1] == '=' {
+		return base64.URLEncoding.DecodeString(s)
+	}
+	return base64.RawURLEncoding.DecodeString(s)
+}
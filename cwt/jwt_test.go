@@ -0,0 +1,260 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/ed25519"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTRegistry(t *testing.T) {
+	t.Run("ToJWT and FromJWT", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		cm := ClaimsMap{
+			iana.CWTClaimIss:   "coap://as.example.com",
+			iana.CWTClaimSub:   "erikw",
+			iana.CWTClaimAud:   []string{"coap://light.example.com", "coap://door.example.com"},
+			iana.CWTClaimExp:   1444064944,
+			iana.CWTClaimIat:   1443944944,
+			iana.CWTClaimCti:   key.HexBytesify("0b71"),
+			iana.CWTClaimCnf:   ConfirmKid(key.HexBytesify("dfd1aa976d8d4575a0fe34b96de2bfad")),
+			iana.CWTClaimScope: "read write",
+			"private":          "value",
+			"device":           map[string]any{"id": []byte{1, 2, 3}, "temp": 21.5},
+		}
+
+		claims, err := cm.ToJWT()
+		require.NoError(err)
+
+		data, err := json.Marshal(claims)
+		require.NoError(err)
+		assert.JSONEq(`{
+			"iss": "coap://as.example.com",
+			"sub": "erikw",
+			"aud": ["coap://light.example.com", "coap://door.example.com"],
+			"exp": 1444064944,
+			"iat": 1443944944,
+			"jti": "C3E",
+			"cnf": {"kid": "39Gql22NRXWg_jS5beK_rQ"},
+			"scope": "read write",
+			"private": "value",
+			"device": {"id": "AQID", "temp": 21.5}
+		}`, string(data))
+
+		data2, err := DefaultJWTRegistry.MarshalJWT(cm)
+		require.NoError(err)
+		assert.JSONEq(string(data), string(data2))
+
+		cm2, err := DefaultJWTRegistry.UnmarshalJWT(data)
+		require.NoError(err)
+		assert.Equal("coap://as.example.com", cm2[iana.CWTClaimIss])
+		assert.Equal([]any{"coap://light.example.com", "coap://door.example.com"}, cm2[iana.CWTClaimAud])
+		assert.Equal(int64(1444064944), cm2[iana.CWTClaimExp])
+		// the binary cti is base64url encoded, and the JWT id is text
+		assert.Equal([]byte("C3E"), cm2[iana.CWTClaimCti])
+		assert.Equal("value", cm2["private"])
+		assert.Equal(map[any]any{"id": "AQID", "temp": 21.5}, cm2["device"])
+
+		aud, err := cm2.GetStrings(iana.CWTClaimAud)
+		require.NoError(err)
+		assert.Equal([]string{"coap://light.example.com", "coap://door.example.com"}, aud)
+
+		// the binary kid is base64url encoded, and the JWT kid is text
+		cnf, err := cm2.GetConfirmation()
+		require.NoError(err)
+		assert.Equal(key.ByteStr("39Gql22NRXWg_jS5beK_rQ"), cnf.Kid)
+
+		cm3, err := ClaimsMapFromJWT(claims)
+		require.NoError(err)
+		assert.Equal([]byte("C3E"), cm3[iana.CWTClaimCti])
+	})
+
+	t.Run("cnf", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		k, err := ed25519.GenerateKey()
+		require.NoError(err)
+		pk, err := ed25519.ToPublicKey(k)
+		require.NoError(err)
+		// the JWK kid is a text string
+		pk.SetKid([]byte("holder-key"))
+		cnf, err := ConfirmKey(pk)
+		require.NoError(err)
+
+		data, err := DefaultJWTRegistry.MarshalJWT(ClaimsMap{iana.CWTClaimCnf: cnf})
+		require.NoError(err)
+		var claims map[string]map[string]map[string]any
+		require.NoError(json.Unmarshal(data, &claims))
+		assert.Equal("OKP", claims["cnf"]["jwk"]["kty"])
+		assert.Equal("Ed25519", claims["cnf"]["jwk"]["crv"])
+
+		cm, err := DefaultJWTRegistry.UnmarshalJWT(data)
+		require.NoError(err)
+		cnf2, err := cm.GetConfirmation()
+		require.NoError(err)
+		assert.Equal(pk.Bytesify(), cnf2.Key.Bytesify())
+
+		// the text kids of a JWT are used as is, including the ones that happen to be base64url
+		for _, kid := range []string{"dfd1aa97-6d8d", "key1", "abcd"} {
+			cm, err = ClaimsMapFromJWT(map[string]any{"cnf": map[string]any{"kid": kid}})
+			require.NoError(err)
+			cnf2, err = cm.GetConfirmation()
+			require.NoError(err)
+			assert.Equal(key.ByteStr(kid), cnf2.Kid)
+
+			claims, err := cm.ToJWT()
+			require.NoError(err)
+			assert.Equal(map[string]any{"cnf": map[string]any{"kid": kid}}, claims)
+		}
+
+		_, err = DefaultJWTRegistry.ToJWT(ClaimsMap{iana.CWTClaimCnf: &Confirmation{EncryptedKey: []byte{0x80}}})
+		assert.ErrorContains(err, `claim "cnf", Encrypted_COSE_Key has no JWT counterpart`)
+		_, err = ClaimsMapFromJWT(map[string]any{"cnf": map[string]any{"jwe": "eyJhbGciOi"}})
+		assert.ErrorContains(err, `claim "cnf", unsupported confirmation method "jwe"`)
+		_, err = ClaimsMapFromJWT(map[string]any{"cnf": map[string]any{"jwk": map[string]any{"kty": "foo"}}})
+		assert.ErrorContains(err, `claim "cnf"`)
+		_, err = ClaimsMapFromJWT(map[string]any{"cnf": "kid"})
+		assert.ErrorContains(err, `claim "cnf", should be a confirmation object`)
+	})
+
+	t.Run("nonce", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		for _, tc := range []struct {
+			nonce any
+			jwt   string
+		}{
+			{[]byte{1, 2, 3}, `{"nonce":"AQID"}`},
+			{[]any{[]byte{1, 2, 3}, []byte{4, 5}}, `{"nonce":["AQID","BAU"]}`},
+		} {
+			data, err := DefaultJWTRegistry.MarshalJWT(ClaimsMap{iana.CWTClaimNonce: tc.nonce})
+			require.NoError(err)
+			assert.Equal(tc.jwt, string(data))
+
+			cm, err := DefaultJWTRegistry.UnmarshalJWT(data)
+			require.NoError(err)
+			assert.Equal(tc.nonce, cm[iana.CWTClaimNonce])
+		}
+
+		_, err := DefaultJWTRegistry.ToJWT(ClaimsMap{iana.CWTClaimNonce: []any{"AQID"}})
+		assert.ErrorContains(err, `claim "nonce", should be an array of byte strings`)
+		_, err = DefaultJWTRegistry.UnmarshalJWT([]byte(`{"nonce":["AQID",1]}`))
+		assert.ErrorContains(err, `claim "nonce", should be a base64url string`)
+	})
+
+	t.Run("jti", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		// the JWT ids are arbitrary strings, including the ones that happen to be base64url
+		for _, jti := range []string{"id-1:x", "!!", "C3E", "550e8400-e29b-41d4-a716-446655440000"} {
+			cm, err := ClaimsMapFromJWT(map[string]any{"jti": jti})
+			require.NoError(err)
+			assert.Equal([]byte(jti), cm[iana.CWTClaimCti], jti)
+
+			claims, err := cm.ToJWT()
+			require.NoError(err)
+			assert.Equal(map[string]any{"jti": jti}, claims)
+		}
+	})
+
+	t.Run("Claims", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		claims := Claims{
+			Issuer:     "ldc:ca",
+			Subject:    "ldc:chain",
+			Audience:   "ldc:txpool",
+			Expiration: 1670123579,
+			CWTID:      key.ByteStr("ldc:1234"),
+		}
+		var cm ClaimsMap
+		require.NoError(key.UnmarshalCBOR(key.MustMarshalCBOR(claims), &cm))
+
+		data, err := DefaultJWTRegistry.MarshalJWT(cm)
+		require.NoError(err)
+		assert.Equal(`{"aud":"ldc:txpool","exp":1670123579,"iss":"ldc:ca","jti":"ldc:1234","sub":"ldc:chain"}`, string(data))
+
+		cm2, err := DefaultJWTRegistry.UnmarshalJWT(data)
+		require.NoError(err)
+		var claims2 Claims
+		require.NoError(key.UnmarshalCBOR(key.MustMarshalCBOR(cm2), &claims2))
+		assert.Equal(claims, claims2)
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		r := NewJWTRegistry()
+		assert.ErrorContains(r.Register(JWTClaim{Label: 1000}), "empty claim name")
+		assert.ErrorContains(r.Register(JWTClaim{Label: iana.CWTClaimIss, Name: "issuer"}), "claim 1 already registered")
+		assert.ErrorContains(r.Register(JWTClaim{Label: 1000, Name: "iss"}), `claim "iss" already registered`)
+
+		cm := ClaimsMap{1000: []byte{0xff, 0xfe}}
+		_, err := r.ToJWT(cm)
+		assert.ErrorContains(err, "no JWT name registered for claim 1000")
+
+		require.NoError(r.Register(JWTClaim{Label: 1000, Name: "device_key", Kind: JWTValueBytes}))
+		claims, err := r.ToJWT(cm)
+		require.NoError(err)
+		assert.Equal(map[string]any{"device_key": "__4"}, claims)
+
+		cm2, err := r.FromJWT(map[string]any{"device_key": "__4="})
+		require.NoError(err)
+		assert.Equal(cm, cm2)
+
+		_, err = DefaultJWTRegistry.ToJWT(cm)
+		assert.ErrorContains(err, "no JWT name registered for claim 1000")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := DefaultJWTRegistry.ToJWT(ClaimsMap{iana.CWTClaimCti: "not bytes"})
+		assert.ErrorContains(err, `claim "jti", should be a byte string, got string`)
+
+		_, err = DefaultJWTRegistry.ToJWT(ClaimsMap{"iss": "ldc:ca"})
+		assert.ErrorContains(err, `claim "iss" conflicts with the registered claim 1`)
+
+		claims, err := DefaultJWTRegistry.ToJWT(ClaimsMap{iana.CWTClaimIat: cbor.Tag{Number: 1, Content: uint64(1443944944)}})
+		assert.NoError(err)
+		assert.Equal(map[string]any{"iat": int64(1443944944)}, claims)
+
+		_, err = DefaultJWTRegistry.ToJWT(ClaimsMap{"url": cbor.Tag{Number: 32, Content: "https://example.com"}})
+		assert.ErrorContains(err, `claim "url", unsupported CBOR tag 32`)
+
+		_, err = DefaultJWTRegistry.ToJWT(ClaimsMap{"temp": math.Inf(1)})
+		assert.ErrorContains(err, "unsupported float value")
+
+		_, err = DefaultJWTRegistry.FromJWT(map[string]any{"jti": 123})
+		assert.ErrorContains(err, `claim "jti", should be a string, got int`)
+
+		_, err = DefaultJWTRegistry.FromJWT(map[string]any{"cnonce": "!!"})
+		assert.ErrorContains(err, `claim "cnonce"`)
+
+		_, err = DefaultJWTRegistry.UnmarshalJWT([]byte(`[1]`))
+		assert.ErrorContains(err, "cose/cwt: JWTRegistry.UnmarshalJWT")
+
+		_, err = DefaultJWTRegistry.UnmarshalJWT([]byte(`null`))
+		assert.ErrorContains(err, "invalid JWT claims set")
+
+		cm, err := DefaultJWTRegistry.FromJWT(map[string]any{"exp": float64(1444064944), "ratio": 0.5, "big": 1e300})
+		assert.NoError(err)
+		assert.Equal(ClaimsMap{iana.CWTClaimExp: int64(1444064944), "ratio": 0.5, "big": 1e300}, cm)
+	})
+}
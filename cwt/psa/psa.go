@@ -0,0 +1,219 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package psa implements the Arm Platform Security Architecture (PSA) attestation token.
+// https://datatracker.ietf.org/doc/html/draft-tschofenig-rats-psa-token-09.
+package psa
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/cwt"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+// PSA profiles of the "eat_profile" claim.
+const (
+	// ProfilePSA2 is the PSA attestation token profile.
+	ProfilePSA2 = "http://arm.com/psa/2.0.0"
+	// ProfilePSATFM is the PSA attestation token profile of the Trusted Firmware-M.
+	ProfilePSATFM = "tag:psacertified.org,2023:psa#tfm"
+)
+
+// Lifecycle represents the PSA security lifecycle. The upper byte is the lifecycle state,
+// and the lower byte is IMPLEMENTATION DEFINED.
+type Lifecycle uint64
+
+// PSA security lifecycle states.
+const (
+	LifecycleUnknown                Lifecycle = 0x0000
+	LifecycleAssemblyAndTest        Lifecycle = 0x1000
+	LifecyclePSARoTProvisioning     Lifecycle = 0x2000
+	LifecycleSecured                Lifecycle = 0x3000
+	LifecycleNonPSARoTDebug         Lifecycle = 0x4000
+	LifecycleRecoverablePSARoTDebug Lifecycle = 0x5000
+	LifecycleDecommissioned         Lifecycle = 0x6000
+)
+
+// State returns the lifecycle state without the IMPLEMENTATION DEFINED lower byte.
+func (l Lifecycle) State() Lifecycle {
+	return l & 0xff00
+}
+
+// Valid returns true if the lifecycle state is a known state.
+func (l Lifecycle) Valid() bool {
+	return l <= 0xffff && l.State() <= LifecycleDecommissioned && l.State()&0x0f00 == 0
+}
+
+// String implements the fmt.Stringer interface for Lifecycle.
+func (l Lifecycle) String() string {
+	if !l.Valid() {
+		return fmt.Sprintf("invalid(0x%04x)", uint64(l))
+	}
+
+	switch l.State() {
+	case LifecycleUnknown:
+		return "unknown"
+	case LifecycleAssemblyAndTest:
+		return "assembly-and-test"
+	case LifecyclePSARoTProvisioning:
+		return "psa-rot-provisioning"
+	case LifecycleSecured:
+		return "secured"
+	case LifecycleNonPSARoTDebug:
+		return "non-psa-rot-debug"
+	case LifecycleRecoverablePSARoTDebug:
+		return "recoverable-psa-rot-debug"
+	default:
+		return "decommissioned"
+	}
+}
+
+// SoftwareComponent represents a measured software component in the "psa-software-components" claim.
+type SoftwareComponent struct {
+	// MeasurementType is a short string that represents the role of the component, such as "BL".
+	MeasurementType string `cbor:"1,keyasint,omitempty" json:"measurement-type,omitempty"`
+	// MeasurementValue is the hash of the component. It is required.
+	MeasurementValue key.ByteStr `cbor:"2,keyasint" json:"measurement-value"`
+	// Version is the issued software version of the component.
+	Version string `cbor:"4,keyasint,omitempty" json:"version,omitempty"`
+	// SignerID is the hash of the public key that signed the component. It is required.
+	SignerID key.ByteStr `cbor:"5,keyasint" json:"signer-id"`
+	// MeasurementDesc is the hash algorithm used to compute the measurement value, such as "sha-256".
+	MeasurementDesc string `cbor:"6,keyasint,omitempty" json:"measurement-desc,omitempty"`
+}
+
+// Claims represents the claims of a PSA attestation token.
+type Claims struct {
+	Nonce                  key.ByteStr         `cbor:"10,keyasint" json:"eat_nonce"`
+	InstanceID             key.ByteStr         `cbor:"256,keyasint" json:"ueid"`
+	Profile                string              `cbor:"265,keyasint" json:"eat_profile"`
+	ClientID               int                 `cbor:"2394,keyasint" json:"psa-client-id"`
+	SecurityLifecycle      Lifecycle           `cbor:"2395,keyasint" json:"psa-security-lifecycle"`
+	ImplementationID       key.ByteStr         `cbor:"2396,keyasint" json:"psa-implementation-id"`
+	BootSeed               key.ByteStr         `cbor:"2397,keyasint,omitempty" json:"psa-boot-seed,omitempty"`
+	CertificationReference string              `cbor:"2398,keyasint,omitempty" json:"psa-certification-reference,omitempty"`
+	SoftwareComponents     []SoftwareComponent `cbor:"2399,keyasint" json:"psa-software-components"`
+	VerificationService    string              `cbor:"2400,keyasint,omitempty" json:"psa-verification-service-indicator,omitempty"`
+}
+
+// mandatoryClaims is the claims that must be present in a PSA attestation token.
+var mandatoryClaims = []struct {
+	claim int
+	name  string
+}{
+	{iana.CWTClaimNonce, "eat_nonce"},
+	{iana.CWTClaimUEID, "ueid"},
+	{iana.CWTClaimProfile, "eat_profile"},
+	{iana.CWTClaimPSAClientID, "psa-client-id"},
+	{iana.CWTClaimPSASecurityLifecycle, "psa-security-lifecycle"},
+	{iana.CWTClaimPSAImplementationID, "psa-implementation-id"},
+	{iana.CWTClaimPSASoftwareComponents, "psa-software-components"},
+}
+
+var certificationReferenceRe = regexp.MustCompile(`^[0-9]{13}(-[0-9]{5})?$`)
+
+// Bytesify returns a CBOR-encoded byte slice.
+// It returns nil if MarshalCBOR failed.
+func (c *Claims) Bytesify() []byte {
+	b, _ := key.MarshalCBOR(c)
+	return b
+}
+
+// Validate validates the formats of the claims and the security lifecycle.
+func (c *Claims) Validate() error {
+	if err := checkHashSize(c.Nonce); err != nil {
+		return fmt.Errorf("cose/cwt/psa: Claims.Validate: invalid eat_nonce, %w", err)
+	}
+
+	if len(c.InstanceID) != 33 || c.InstanceID[0] != 0x01 {
+		return fmt.Errorf("cose/cwt/psa: Claims.Validate: invalid ueid, expected 33 bytes of RAND type, got %d bytes", len(c.InstanceID))
+	}
+
+	if c.Profile == "" {
+		return errors.New("cose/cwt/psa: Claims.Validate: empty eat_profile")
+	}
+
+	if !c.SecurityLifecycle.Valid() {
+		return fmt.Errorf("cose/cwt/psa: Claims.Validate: invalid psa-security-lifecycle 0x%04x", uint64(c.SecurityLifecycle))
+	}
+
+	if len(c.ImplementationID) != 32 {
+		return fmt.Errorf("cose/cwt/psa: Claims.Validate: invalid psa-implementation-id, expected 32 bytes, got %d", len(c.ImplementationID))
+	}
+
+	if n := len(c.BootSeed); n > 0 && (n < 8 || n > 32) {
+		return fmt.Errorf("cose/cwt/psa: Claims.Validate: invalid psa-boot-seed, expected 8 to 32 bytes, got %d", n)
+	}
+
+	if c.CertificationReference != "" && !certificationReferenceRe.MatchString(c.CertificationReference) {
+		return fmt.Errorf("cose/cwt/psa: Claims.Validate: invalid psa-certification-reference %q", c.CertificationReference)
+	}
+
+	if len(c.SoftwareComponents) == 0 {
+		return errors.New("cose/cwt/psa: Claims.Validate: empty psa-software-components")
+	}
+	for i, sc := range c.SoftwareComponents {
+		if err := checkHashSize(sc.MeasurementValue); err != nil {
+			return fmt.Errorf("cose/cwt/psa: Claims.Validate: software component %d has invalid measurement-value, %w", i, err)
+		}
+		if err := checkHashSize(sc.SignerID); err != nil {
+			return fmt.Errorf("cose/cwt/psa: Claims.Validate: software component %d has invalid signer-id, %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ParseClaims decodes the claims of a PSA attestation token, checks the mandatory claims and validates them.
+func ParseClaims(data []byte) (*Claims, error) {
+	var cm cwt.ClaimsMap
+	if err := key.UnmarshalCBOR(data, &cm); err != nil {
+		return nil, fmt.Errorf("cose/cwt/psa: ParseClaims: %w", err)
+	}
+
+	for _, m := range mandatoryClaims {
+		if !cm.Has(m.claim) {
+			return nil, fmt.Errorf("cose/cwt/psa: ParseClaims: missing mandatory claim %s", m.name)
+		}
+	}
+
+	c := &Claims{}
+	if err := key.UnmarshalCBOR(data, c); err != nil {
+		return nil, fmt.Errorf("cose/cwt/psa: ParseClaims: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Parse decodes a PSA attestation token and its claims without verifying the signature.
+// It is useful to lookup the IAK with the instance ID before verifying the token.
+func Parse(token []byte) (*cose.Sign1Message[cbor.RawMessage], *Claims, error) {
+	obj := &cose.Sign1Message[cbor.RawMessage]{}
+	if err := obj.UnmarshalCBOR(token); err != nil {
+		return nil, nil, fmt.Errorf("cose/cwt/psa: Parse: %w", err)
+	}
+
+	c, err := ParseClaims(obj.Payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return obj, c, nil
+}
+
+// checkHashSize checks the size of a hash value, it should be 32, 48 or 64 bytes.
+func checkHashSize(h []byte) error {
+	switch len(h) {
+	case 32, 48, 64:
+		return nil
+	default:
+		return fmt.Errorf("expected 32, 48 or 64 bytes, got %d", len(h))
+	}
+}
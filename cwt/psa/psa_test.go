@@ -0,0 +1,162 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package psa
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/cwt"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testClaims() *Claims {
+	return &Claims{
+		Nonce:                  bytes.Repeat([]byte{0x01}, 32),
+		InstanceID:             append([]byte{0x01}, bytes.Repeat([]byte{0xa0}, 32)...),
+		Profile:                ProfilePSA2,
+		ClientID:               1,
+		SecurityLifecycle:      LifecycleSecured | 0x01,
+		ImplementationID:       bytes.Repeat([]byte{0xaa}, 32),
+		BootSeed:               bytes.Repeat([]byte{0xde}, 32),
+		CertificationReference: "1234567890123-12345",
+		SoftwareComponents: []SoftwareComponent{
+			{
+				MeasurementType:  "BL",
+				MeasurementValue: bytes.Repeat([]byte{0x01}, 32),
+				Version:          "2.1.0",
+				SignerID:         bytes.Repeat([]byte{0x02}, 32),
+				MeasurementDesc:  "sha-256",
+			},
+			{
+				MeasurementType:  "PRoT",
+				MeasurementValue: bytes.Repeat([]byte{0x03}, 32),
+				SignerID:         bytes.Repeat([]byte{0x02}, 32),
+			},
+		},
+		VerificationService: "https://psa-verifier.org",
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		l     Lifecycle
+		state Lifecycle
+		str   string
+	}{
+		{LifecycleUnknown, LifecycleUnknown, "unknown"},
+		{LifecycleAssemblyAndTest | 0x12, LifecycleAssemblyAndTest, "assembly-and-test"},
+		{LifecyclePSARoTProvisioning, LifecyclePSARoTProvisioning, "psa-rot-provisioning"},
+		{LifecycleSecured | 0xff, LifecycleSecured, "secured"},
+		{LifecycleNonPSARoTDebug, LifecycleNonPSARoTDebug, "non-psa-rot-debug"},
+		{LifecycleRecoverablePSARoTDebug, LifecycleRecoverablePSARoTDebug, "recoverable-psa-rot-debug"},
+		{LifecycleDecommissioned | 0x01, LifecycleDecommissioned, "decommissioned"},
+	} {
+		assert.True(tc.l.Valid(), tc.str)
+		assert.Equal(tc.state, tc.l.State(), tc.str)
+		assert.Equal(tc.str, tc.l.String())
+	}
+
+	for _, l := range []Lifecycle{0x0100, 0x3100, 0x7000, 0x10000} {
+		assert.False(l.Valid())
+		assert.Contains(l.String(), "invalid")
+	}
+}
+
+func TestClaims(t *testing.T) {
+	t.Run("ParseClaims", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		claims := testClaims()
+		data, err := key.MarshalCBOR(claims)
+		require.NoError(err)
+		assert.Equal(data, claims.Bytesify())
+
+		c, err := ParseClaims(data)
+		require.NoError(err)
+		assert.Equal(claims, c)
+
+		var cm cwt.ClaimsMap
+		require.NoError(key.UnmarshalCBOR(data, &cm))
+		for _, m := range mandatoryClaims {
+			v := cm[m.claim]
+			delete(cm, m.claim)
+			_, err = ParseClaims(cm.Bytesify())
+			assert.ErrorContains(err, "missing mandatory claim "+m.name)
+			cm[m.claim] = v
+		}
+
+		cm[iana.CWTClaimPSASoftwareComponents] = "BL"
+		_, err = ParseClaims(cm.Bytesify())
+		assert.ErrorContains(err, "cose/cwt/psa: ParseClaims")
+
+		_, err = ParseClaims([]byte{0x01})
+		assert.ErrorContains(err, "cose/cwt/psa: ParseClaims")
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.NoError(testClaims().Validate())
+
+		for _, tc := range []struct {
+			update func(c *Claims)
+			err    string
+		}{
+			{func(c *Claims) { c.Nonce = c.Nonce[:16] }, "invalid eat_nonce, expected 32, 48 or 64 bytes, got 16"},
+			{func(c *Claims) { c.InstanceID[0] = 0x02 }, "invalid ueid"},
+			{func(c *Claims) { c.InstanceID = c.InstanceID[:17] }, "invalid ueid"},
+			{func(c *Claims) { c.Profile = "" }, "empty eat_profile"},
+			{func(c *Claims) { c.SecurityLifecycle = 0x3100 }, "invalid psa-security-lifecycle 0x3100"},
+			{func(c *Claims) { c.ImplementationID = c.ImplementationID[:16] }, "invalid psa-implementation-id"},
+			{func(c *Claims) { c.BootSeed = c.BootSeed[:4] }, "invalid psa-boot-seed"},
+			{func(c *Claims) { c.CertificationReference = "1234-5678" }, "invalid psa-certification-reference"},
+			{func(c *Claims) { c.SoftwareComponents = nil }, "empty psa-software-components"},
+			{func(c *Claims) { c.SoftwareComponents[1].MeasurementValue = nil }, "software component 1 has invalid measurement-value"},
+			{func(c *Claims) { c.SoftwareComponents[0].SignerID = []byte{1} }, "software component 0 has invalid signer-id"},
+		} {
+			c := testClaims()
+			tc.update(c)
+			assert.ErrorContains(c.Validate(), tc.err)
+		}
+
+		c := testClaims()
+		c.BootSeed = nil
+		c.CertificationReference = "1234567890123"
+		assert.NoError(c.Validate())
+	})
+
+	t.Run("Parse", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		obj := &cose.Sign1Message[*Claims]{
+			Protected: cose.Headers{iana.HeaderParameterAlg: iana.AlgorithmES256},
+			Payload:   testClaims(),
+		}
+		data, err := key.MarshalCBOR(obj.Payload)
+		require.NoError(err)
+
+		// a token without a valid signature can be parsed, but not verified.
+		token := key.MustMarshalCBOR([]any{key.MustMarshalCBOR(obj.Protected), map[any]any{}, data, []byte{1, 2, 3}})
+		msg, c, err := Parse(token)
+		require.NoError(err)
+		assert.Equal(testClaims(), c)
+		assert.Equal([]byte{1, 2, 3}, msg.Signature())
+
+		_, _, err = Parse([]byte{0x01})
+		assert.ErrorContains(err, "cose/cwt/psa: Parse")
+
+		token = key.MustMarshalCBOR([]any{key.MustMarshalCBOR(obj.Protected), map[any]any{}, []byte{0xa0}, []byte{1, 2, 3}})
+		_, _, err = Parse(token)
+		assert.ErrorContains(err, "missing mandatory claim")
+	})
+}
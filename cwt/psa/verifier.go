@@ -0,0 +1,105 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package psa
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/key"
+)
+
+// VerifierOpts defines the options for PSA attestation token verifiers.
+type VerifierOpts struct {
+	// Profiles is the accepted "eat_profile" claims.
+	// Default is ProfilePSA2 and ProfilePSATFM.
+	Profiles []string
+	// Lifecycles is the accepted security lifecycle states.
+	// Default is LifecycleSecured and LifecycleNonPSARoTDebug, the states that the PSA RoT is trustworthy.
+	Lifecycles []Lifecycle
+}
+
+// Verifier verifies PSA attestation tokens signed by the Initial Attestation Key (IAK) of the device.
+type Verifier struct {
+	verifier   key.Verifier
+	profiles   []string
+	lifecycles []Lifecycle
+}
+
+// NewVerifier creates a PSA attestation token Verifier with the IAK public key of the device.
+// opts can be nil.
+func NewVerifier(iak key.Key, opts *VerifierOpts) (*Verifier, error) {
+	verifier, err := iak.Verifier()
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt/psa: NewVerifier: %w", err)
+	}
+
+	v := &Verifier{
+		verifier:   verifier,
+		profiles:   []string{ProfilePSA2, ProfilePSATFM},
+		lifecycles: []Lifecycle{LifecycleSecured, LifecycleNonPSARoTDebug},
+	}
+	if opts != nil {
+		if len(opts.Profiles) > 0 {
+			v.profiles = opts.Profiles
+		}
+		if len(opts.Lifecycles) > 0 {
+			v.lifecycles = opts.Lifecycles
+		}
+	}
+	return v, nil
+}
+
+// Verify verifies the COSE_Sign1 signature of the PSA attestation token with the IAK,
+// decodes and validates the claims, and checks the nonce supplied by the relying party.
+func (v *Verifier) Verify(token, nonce []byte) (*Claims, error) {
+	if len(nonce) == 0 {
+		return nil, errors.New("cose/cwt/psa: Verifier.Verify: empty nonce")
+	}
+
+	obj, err := cose.VerifySign1Message[cbor.RawMessage](v.verifier, token, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt/psa: Verifier.Verify: %w", err)
+	}
+
+	c, err := ParseClaims(obj.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare(c.Nonce, nonce) != 1 {
+		return nil, errors.New("cose/cwt/psa: Verifier.Verify: eat_nonce mismatch")
+	}
+
+	if !v.acceptProfile(c.Profile) {
+		return nil, fmt.Errorf("cose/cwt/psa: Verifier.Verify: unsupported eat_profile %q", c.Profile)
+	}
+
+	if !v.acceptLifecycle(c.SecurityLifecycle) {
+		return nil, fmt.Errorf("cose/cwt/psa: Verifier.Verify: untrusted psa-security-lifecycle %s", c.SecurityLifecycle)
+	}
+	return c, nil
+}
+
+func (v *Verifier) acceptProfile(profile string) bool {
+	for _, p := range v.profiles {
+		if p == profile {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Verifier) acceptLifecycle(l Lifecycle) bool {
+	for _, s := range v.lifecycles {
+		if s.State() == l.State() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package psa
+
+import (
+	"testing"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/ecdsa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifier(t *testing.T) {
+	iak, err := ecdsa.GenerateKey(iana.AlgorithmES256)
+	require.NoError(t, err)
+	signer, err := iak.Signer()
+	require.NoError(t, err)
+	iakPub, err := ecdsa.ToPublicKey(iak)
+	require.NoError(t, err)
+
+	sign := func(c *Claims) []byte {
+		obj := &cose.Sign1Message[*Claims]{Payload: c}
+		token, err := obj.SignAndEncode(signer, nil)
+		require.NoError(t, err)
+		return token
+	}
+	nonce := testClaims().Nonce
+
+	t.Run("NewVerifier", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := NewVerifier(key.Key{}, nil)
+		assert.ErrorContains(err, "cose/cwt/psa: NewVerifier")
+
+		v, err := NewVerifier(iakPub, nil)
+		require.NoError(t, err)
+		assert.Equal([]string{ProfilePSA2, ProfilePSATFM}, v.profiles)
+		assert.Equal([]Lifecycle{LifecycleSecured, LifecycleNonPSARoTDebug}, v.lifecycles)
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		v, err := NewVerifier(iakPub, nil)
+		require.NoError(err)
+
+		c, err := v.Verify(sign(testClaims()), nonce)
+		require.NoError(err)
+		assert.Equal(testClaims(), c)
+		assert.Equal(LifecycleSecured, c.SecurityLifecycle.State())
+
+		_, err = v.Verify(sign(testClaims()), nil)
+		assert.ErrorContains(err, "empty nonce")
+
+		_, err = v.Verify(sign(testClaims()), nonce[1:])
+		assert.ErrorContains(err, "eat_nonce mismatch")
+
+		claims := testClaims()
+		claims.Profile = "PSA_IOT_PROFILE_1"
+		_, err = v.Verify(sign(claims), nonce)
+		assert.ErrorContains(err, `unsupported eat_profile "PSA_IOT_PROFILE_1"`)
+
+		claims = testClaims()
+		claims.SecurityLifecycle = LifecycleDecommissioned
+		_, err = v.Verify(sign(claims), nonce)
+		assert.ErrorContains(err, "untrusted psa-security-lifecycle decommissioned")
+
+		claims = testClaims()
+		claims.ImplementationID = nil
+		_, err = v.Verify(sign(claims), nonce)
+		assert.ErrorContains(err, "invalid psa-implementation-id")
+
+		token := sign(testClaims())
+		token[len(token)-1] ^= 0xff
+		_, err = v.Verify(token, nonce)
+		assert.ErrorContains(err, "cose/cwt/psa: Verifier.Verify")
+
+		other, err := ecdsa.GenerateKey(iana.AlgorithmES256)
+		require.NoError(err)
+		otherPub, err := ecdsa.ToPublicKey(other)
+		require.NoError(err)
+		v2, err := NewVerifier(otherPub, nil)
+		require.NoError(err)
+		_, err = v2.Verify(sign(testClaims()), nonce)
+		assert.ErrorContains(err, "cose/cwt/psa: Verifier.Verify")
+	})
+
+	t.Run("VerifierOpts", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		v, err := NewVerifier(iakPub, &VerifierOpts{
+			Profiles:   []string{"PSA_IOT_PROFILE_1"},
+			Lifecycles: []Lifecycle{LifecycleDecommissioned},
+		})
+		require.NoError(err)
+
+		claims := testClaims()
+		claims.Profile = "PSA_IOT_PROFILE_1"
+		claims.SecurityLifecycle = LifecycleDecommissioned | 0x02
+		c, err := v.Verify(sign(claims), nonce)
+		require.NoError(err)
+		assert.Equal(claims, c)
+
+		_, err = v.Verify(sign(testClaims()), nonce)
+		assert.ErrorContains(err, "unsupported eat_profile")
+	})
+}
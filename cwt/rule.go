@@ -0,0 +1,223 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ldclabs/cose/key"
+)
+
+// Extractor converts a claim value to a typed value.
+type Extractor[T any] func(val any) (T, error)
+
+// ExtractBool converts a claim value to a bool.
+func ExtractBool(val any) (bool, error) {
+	return key.CoseMap{0: val}.GetBool(0)
+}
+
+// ExtractInt64 converts a claim value to an int64.
+func ExtractInt64(val any) (int64, error) {
+	return key.CoseMap{0: val}.GetInt64(0)
+}
+
+// ExtractUint64 converts a claim value to an uint64.
+func ExtractUint64(val any) (uint64, error) {
+	return key.CoseMap{0: val}.GetUint64(0)
+}
+
+// ExtractBytes converts a claim value to a slice of bytes.
+func ExtractBytes(val any) ([]byte, error) {
+	return key.CoseMap{0: val}.GetBytes(0)
+}
+
+// ExtractString converts a claim value to a string.
+func ExtractString(val any) (string, error) {
+	return key.CoseMap{0: val}.GetString(0)
+}
+
+// ExtractStrings converts a claim value, a tstr or an array of tstr, to a slice of strings.
+func ExtractStrings(val any) ([]string, error) {
+	strs, err := toStrings(val)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt: ExtractStrings: %w", err)
+	}
+	return strs, nil
+}
+
+// ExtractMap converts a claim value to a key.CoseMap.
+func ExtractMap(val any) (key.CoseMap, error) {
+	return key.CoseMap{0: val}.GetMap(0)
+}
+
+// Rule defines how a claim is validated.
+type Rule struct {
+	// Claim is the claim key, int or string.
+	Claim any
+	// Required reports whether the claim must be present.
+	// If it is false, the rule is skipped when the claim is missing.
+	Required bool
+	// MissingErr is the error reported when a required claim is missing.
+	// If it is nil, a default error is reported.
+	MissingErr error
+
+	validate func(claims ClaimsMap, val any) error
+}
+
+// NewRule creates an optional Rule for the given claim.
+// The claim value is converted by extract, and then checked by check if check is not nil.
+// For example, a rule for a private claim:
+//
+//	cwt.NewRule("role", cwt.ExtractString, func(role string) error {
+//		if role != "admin" {
+//			return fmt.Errorf("role %q is not allowed", role)
+//		}
+//		return nil
+//	})
+func NewRule[T any](claim any, extract Extractor[T], check func(T) error) *Rule {
+	if check == nil {
+		return NewClaimsRule[T](claim, extract, nil)
+	}
+	return NewClaimsRule(claim, extract, func(_ ClaimsMap, v T) error {
+		return check(v)
+	})
+}
+
+// NewClaimsRule creates an optional Rule for the given claim like NewRule,
+// but check is called with all the claims, for the claims that depend on other claims.
+func NewClaimsRule[T any](claim any, extract Extractor[T], check func(claims ClaimsMap, v T) error) *Rule {
+	return &Rule{
+		Claim: claim,
+		validate: func(claims ClaimsMap, val any) error {
+			v, err := extract(val)
+			if err != nil {
+				return fmt.Errorf("token has an invalid %s claim, %w", ClaimName(claim), err)
+			}
+			if check != nil {
+				return check(claims, v)
+			}
+			return nil
+		},
+	}
+}
+
+// RuleSet is a composable set of Rules, the Rules are registered per claim key.
+// More than one Rule can be registered for a claim.
+type RuleSet struct {
+	claims []any
+	rules  map[any][]*Rule
+}
+
+// NewRuleSet creates a new RuleSet with the given Rules.
+func NewRuleSet(rules ...*Rule) (*RuleSet, error) {
+	rs := &RuleSet{rules: make(map[any][]*Rule)}
+	if err := rs.Add(rules...); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// Add registers the given Rules to the RuleSet.
+func (rs *RuleSet) Add(rules ...*Rule) error {
+	for _, r := range rules {
+		if r == nil || r.validate == nil {
+			return fmt.Errorf("cose/cwt: RuleSet.Add: invalid Rule, should be created by NewRule")
+		}
+
+		claim, err := toClaimKey(r.Claim)
+		if err != nil {
+			return fmt.Errorf("cose/cwt: RuleSet.Add: %w", err)
+		}
+		r.Claim = claim
+
+		if _, ok := rs.rules[claim]; !ok {
+			rs.claims = append(rs.claims, claim)
+		}
+		rs.rules[claim] = append(rs.rules[claim], r)
+	}
+	return nil
+}
+
+// Validate validates the claims with all the Rules in the RuleSet.
+// It returns a *ValidationError that collects all violations, or nil.
+func (rs *RuleSet) Validate(claims ClaimsMap) error {
+	var violations []*ClaimError
+	for _, c := range rs.claims {
+		val, ok := claims[c]
+		for _, r := range rs.rules[c] {
+			var err error
+			switch {
+			case ok:
+				err = r.validate(claims, val)
+
+			case r.Required:
+				err = r.MissingErr
+				if err == nil {
+					err = fmt.Errorf("token doesn't have the %s claim", ClaimName(c))
+				}
+			}
+
+			if err != nil {
+				violations = append(violations, &ClaimError{Claim: c, Err: err})
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// ValidationError collects all the claim violations of a token.
+type ValidationError struct {
+	Violations []*ClaimError
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Err.Error()
+	}
+	return "cose/cwt: Validator.Validate: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the violations, so that errors.Is and errors.As can inspect them.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Violations))
+	for i, v := range e.Violations {
+		errs[i] = v
+	}
+	return errs
+}
+
+// Has returns true if the given claim has a violation.
+// The integer claim keys are compared by value, so int64(4) and uint64(4) are the same claim.
+func (e *ValidationError) Has(claim any) bool {
+	c, err := toClaimKey(claim)
+	if err != nil {
+		return false
+	}
+	for _, v := range e.Violations {
+		if vc, err := toClaimKey(v.Claim); err == nil && vc == c {
+			return true
+		}
+	}
+	return false
+}
+
+// toClaimKey normalizes a claim key to int or string.
+func toClaimKey(claim any) (any, error) {
+	if s, ok := claim.(string); ok {
+		return s, nil
+	}
+
+	c, err := key.ToInt(claim)
+	if err != nil {
+		return nil, fmt.Errorf("invalid claim key %v", claim)
+	}
+	return c, nil
+}
@@ -0,0 +1,131 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractors(t *testing.T) {
+	assert := assert.New(t)
+
+	b, err := ExtractBool(true)
+	assert.NoError(err)
+	assert.True(b)
+	_, err = ExtractBool(1)
+	assert.Error(err)
+
+	i, err := ExtractInt64(-1)
+	assert.NoError(err)
+	assert.Equal(int64(-1), i)
+	_, err = ExtractInt64("1")
+	assert.Error(err)
+
+	u, err := ExtractUint64(uint64(1))
+	assert.NoError(err)
+	assert.Equal(uint64(1), u)
+	_, err = ExtractUint64(-1)
+	assert.Error(err)
+
+	bs, err := ExtractBytes(key.ByteStr{1, 2})
+	assert.NoError(err)
+	assert.Equal([]byte{1, 2}, bs)
+	_, err = ExtractBytes("12")
+	assert.Error(err)
+
+	s, err := ExtractString("ldc")
+	assert.NoError(err)
+	assert.Equal("ldc", s)
+	_, err = ExtractString(1)
+	assert.Error(err)
+
+	ss, err := ExtractStrings("ldc")
+	assert.NoError(err)
+	assert.Equal([]string{"ldc"}, ss)
+	ss, err = ExtractStrings([]any{"a", "b"})
+	assert.NoError(err)
+	assert.Equal([]string{"a", "b"}, ss)
+	_, err = ExtractStrings([]any{"a", 1})
+	assert.ErrorContains(err, "cose/cwt: ExtractStrings: invalid element type int")
+
+	m, err := ExtractMap(map[any]any{1: "a"})
+	assert.NoError(err)
+	assert.Equal(key.CoseMap{1: "a"}, m)
+	_, err = ExtractMap("a")
+	assert.Error(err)
+}
+
+func TestRuleSet(t *testing.T) {
+	assert := assert.New(t)
+
+	rs, err := NewRuleSet(nil)
+	assert.ErrorContains(err, "invalid Rule")
+	assert.Nil(rs)
+
+	rs, err = NewRuleSet(&Rule{Claim: 1})
+	assert.ErrorContains(err, "invalid Rule")
+	assert.Nil(rs)
+
+	rs, err = NewRuleSet(NewRule(1.1, ExtractString, nil))
+	assert.ErrorContains(err, "invalid claim key 1.1")
+	assert.Nil(rs)
+
+	role := NewRule("role", ExtractString, func(role string) error {
+		if role != "admin" {
+			return fmt.Errorf("role %q is not allowed", role)
+		}
+		return nil
+	})
+	role.Required = true
+
+	level := NewRule(uint64(1000), ExtractUint64, func(level uint64) error {
+		if level < 3 {
+			return errors.New("level too low")
+		}
+		return nil
+	})
+	scope := NewRule(iana.CWTClaimScope, ExtractString, nil)
+	scope.Required = true
+	scope.MissingErr = errors.New("scope is required")
+
+	rs, err = NewRuleSet(role, level)
+	require.NoError(t, err)
+	require.NoError(t, rs.Add(scope))
+	assert.Equal(1000, level.Claim)
+
+	assert.NoError(rs.Validate(ClaimsMap{"role": "admin", 1000: 3, iana.CWTClaimScope: "read"}))
+	assert.NoError(rs.Validate(ClaimsMap{"role": "admin", iana.CWTClaimScope: "read"}))
+
+	err = rs.Validate(ClaimsMap{1000: 1})
+	assert.Equal(`cose/cwt: Validator.Validate: token doesn't have the role claim; level too low; scope is required`, err.Error())
+
+	var ve *ValidationError
+	require.True(t, errors.As(err, &ve))
+	assert.Equal(3, len(ve.Violations))
+	assert.True(ve.Has("role"))
+	assert.True(ve.Has(1000))
+	assert.True(ve.Has(iana.CWTClaimScope))
+	assert.False(ve.Has(iana.CWTClaimExp))
+	assert.True(ve.Has(int64(1000)))
+	assert.True(ve.Has(uint64(iana.CWTClaimScope)))
+	assert.False(ve.Has(uint64(iana.CWTClaimExp)))
+	assert.False(ve.Has(1.5))
+	assert.True((&ValidationError{Violations: []*ClaimError{{Claim: uint64(1000)}}}).Has(1000))
+
+	var ce *ClaimError
+	require.True(t, errors.As(err, &ce))
+	assert.Equal("role", ce.Claim)
+
+	err = rs.Validate(ClaimsMap{"role": "user", 1000: "3", iana.CWTClaimScope: 1})
+	assert.ErrorContains(err, `role "user" is not allowed`)
+	assert.ErrorContains(err, `token has an invalid 1000 claim`)
+	assert.ErrorContains(err, `token has an invalid scope claim`)
+}
@@ -0,0 +1,517 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package sdcwt implements Selective Disclosure CBOR Web Tokens (SD-CWT).
+// The issuer replaces the redactable claims with salted digests and sends the disclosures with the token,
+// the holder presents a subset of the disclosures with a key binding token,
+// and the verifier reconstructs and validates the disclosed claims.
+//
+// Reference https://datatracker.ietf.org/doc/html/draft-ietf-spice-sd-cwt
+package sdcwt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/cwt"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+const (
+	// HeaderParameterSDClaims is the unprotected header parameter that carries the disclosures,
+	// an array of byte strings.
+	HeaderParameterSDClaims = 17
+	// HeaderParameterSDAlg is the protected header parameter of the hash algorithm used for the digests.
+	HeaderParameterSDAlg = 18
+
+	// TagToBeRedacted marks a claim or an array element to be redacted by the issuer.
+	// It never appears in an issued SD-CWT.
+	TagToBeRedacted = 58
+	// TagRedactedElement wraps the digest of a redacted array element.
+	TagRedactedElement = 60
+
+	// MediaTypeSDCWT is the "typ" header parameter of a SD-CWT.
+	MediaTypeSDCWT = "application/sd+cwt"
+	// MediaTypeKBCWT is the "typ" header parameter of a key binding token.
+	MediaTypeKBCWT = "application/kb+cwt"
+)
+
+// RedactedClaimKeys is the map key of the digests of the redacted claims in a map.
+const RedactedClaimKeys = cbor.SimpleValue(59)
+
+const (
+	saltSize = 16
+	// maxDepth limits the nesting levels of the claims set.
+	maxDepth = 16
+)
+
+// nonRedactable are the claims that the verifier needs to process the SD-CWT,
+// they can not be redacted.
+var nonRedactable = []int{
+	iana.CWTClaimIss,
+	iana.CWTClaimExp,
+	iana.CWTClaimNbf,
+	iana.CWTClaimCnf,
+}
+
+// Redact marks a claim value as redactable.
+// In a map, the claim (the key and the value) is redacted, in an array, the element is redacted.
+// A redactable value can contain other redactable values.
+func Redact(value any) cbor.Tag {
+	return cbor.Tag{Number: TagToBeRedacted, Content: value}
+}
+
+// Disclosure discloses a redacted claim or a redacted array element.
+type Disclosure struct {
+	// Salt is the random salt of the disclosure.
+	Salt []byte
+	// Value is the value of the redacted claim or array element.
+	Value any
+	// Key is the key of the redacted claim, an int or a string.
+	// It is nil for a redacted array element.
+	Key any
+
+	raw []byte
+}
+
+// MarshalCBOR implements the CBOR Marshaler interface for Disclosure.
+// A decoded Disclosure is encoded to its original bytes.
+func (d *Disclosure) MarshalCBOR() ([]byte, error) {
+	if len(d.raw) > 0 {
+		return d.raw, nil
+	}
+
+	arr := []any{d.Salt, d.Value}
+	if d.Key != nil {
+		arr = append(arr, d.Key)
+	}
+	return key.MarshalCBOR(arr)
+}
+
+// UnmarshalCBOR implements the CBOR Unmarshaler interface for Disclosure.
+func (d *Disclosure) UnmarshalCBOR(data []byte) error {
+	if d == nil {
+		return errors.New("cose/cwt/sdcwt: Disclosure.UnmarshalCBOR: nil Disclosure")
+	}
+
+	var arr []any
+	if err := key.UnmarshalCBOR(data, &arr); err != nil {
+		return fmt.Errorf("cose/cwt/sdcwt: Disclosure.UnmarshalCBOR: %w", err)
+	}
+	if len(arr) != 2 && len(arr) != 3 {
+		return fmt.Errorf("cose/cwt/sdcwt: Disclosure.UnmarshalCBOR: invalid disclosure with %d items", len(arr))
+	}
+
+	salt, ok := arr[0].([]byte)
+	if !ok || len(salt) < saltSize {
+		return errors.New("cose/cwt/sdcwt: Disclosure.UnmarshalCBOR: invalid salt")
+	}
+
+	var k any
+	if len(arr) == 3 {
+		var err error
+		if k, err = claimKey(arr[2]); err != nil {
+			return fmt.Errorf("cose/cwt/sdcwt: Disclosure.UnmarshalCBOR: %w", err)
+		}
+	}
+
+	d.Salt, d.Value, d.Key = salt, arr[1], k
+	d.raw = append([]byte{}, data...)
+	return nil
+}
+
+// Digest returns the digest of the encoded disclosure with the given hash algorithm,
+// such as iana.AlgorithmSHA_256.
+func (d *Disclosure) Digest(alg int) ([]byte, error) {
+	data, err := d.MarshalCBOR()
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt/sdcwt: Disclosure.Digest: %w", err)
+	}
+	return digest(alg, data)
+}
+
+// Issuer issues SD-CWTs signed with COSE_Sign1.
+type Issuer struct {
+	signer key.Signer
+	alg    int
+}
+
+// NewIssuer creates a new SD-CWT Issuer. The hashAlg is the hash algorithm used for the digests,
+// iana.AlgorithmSHA_256, iana.AlgorithmSHA_384 or iana.AlgorithmSHA_512.
+func NewIssuer(signer key.Signer, hashAlg int) (*Issuer, error) {
+	if signer == nil {
+		return nil, errors.New("cose/cwt/sdcwt: NewIssuer: nil Signer")
+	}
+	if _, err := digest(hashAlg, nil); err != nil {
+		return nil, fmt.Errorf("cose/cwt/sdcwt: NewIssuer: %w", err)
+	}
+	return &Issuer{signer: signer, alg: hashAlg}, nil
+}
+
+// Issue redacts the claims marked with Redact, signs the claims set, and returns the SD-CWT
+// with all the disclosures in the "sd_claims" unprotected header parameter.
+// The nested maps in the claims set should be cwt.ClaimsMap, key.CoseMap, map[any]any or map[string]any,
+// and the nested arrays should be []any.
+// The "iss", "exp", "nbf" and "cnf" claims can not be redacted.
+func (i *Issuer) Issue(claims cwt.ClaimsMap) ([]byte, error) {
+	if claims == nil {
+		return nil, errors.New("cose/cwt/sdcwt: Issuer.Issue: nil claims")
+	}
+	for _, c := range nonRedactable {
+		if isRedactable(claims[c]) {
+			return nil, fmt.Errorf("cose/cwt/sdcwt: Issuer.Issue: claim %d can not be redacted", c)
+		}
+	}
+
+	r := &redactor{alg: i.alg}
+	payload, err := r.redact(map[any]any(claims), 0)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt/sdcwt: Issuer.Issue: %w", err)
+	}
+
+	data, err := key.MarshalCBOR(payload)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt/sdcwt: Issuer.Issue: %w", err)
+	}
+
+	obj := &cose.Sign1Message[cbor.RawMessage]{
+		Protected: cose.Headers{
+			iana.HeaderParameterTyp: MediaTypeSDCWT,
+			HeaderParameterSDAlg:    i.alg,
+		},
+		Unprotected: cose.Headers{},
+		Payload:     data,
+	}
+	if len(r.disclosures) > 0 {
+		obj.Unprotected[HeaderParameterSDClaims] = r.disclosures
+	}
+	if alg := i.signer.Key().Alg(); alg != iana.AlgorithmReserved {
+		obj.Protected[iana.HeaderParameterAlg] = alg
+	}
+	if kid := i.signer.Key().Kid(); len(kid) > 0 {
+		obj.Unprotected[iana.HeaderParameterKid] = kid
+	}
+
+	token, err := obj.SignAndEncode(i.signer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt/sdcwt: Issuer.Issue: %w", err)
+	}
+	return token, nil
+}
+
+type redactor struct {
+	alg         int
+	disclosures [][]byte
+}
+
+func (r *redactor) redact(v any, depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("too many nesting levels, expected <= %d", maxDepth)
+	}
+
+	switch x := v.(type) {
+	case cwt.ClaimsMap:
+		return r.redactMap(map[any]any(x), depth)
+	case key.CoseMap:
+		return r.redactMap(map[any]any(x), depth)
+	case map[any]any:
+		return r.redactMap(x, depth)
+	case map[string]any:
+		m := make(map[any]any, len(x))
+		for k, e := range x {
+			m[k] = e
+		}
+		return r.redactMap(m, depth)
+
+	case []any:
+		arr := make([]any, 0, len(x))
+		for _, e := range x {
+			if !isRedactable(e) {
+				ev, err := r.redact(e, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, ev)
+				continue
+			}
+
+			dg, err := r.disclose(e.(cbor.Tag).Content, nil, depth)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, cbor.Tag{Number: TagRedactedElement, Content: dg})
+		}
+		return arr, nil
+
+	case cbor.Tag:
+		if x.Number == TagToBeRedacted {
+			return nil, errors.New("unexpected redactable value")
+		}
+		c, err := r.redact(x.Content, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return cbor.Tag{Number: x.Number, Content: c}, nil
+
+	default:
+		return v, nil
+	}
+}
+
+func (r *redactor) redactMap(m map[any]any, depth int) (any, error) {
+	if _, ok := m[RedactedClaimKeys]; ok {
+		return nil, errors.New("unexpected redacted claim keys")
+	}
+
+	out := make(map[any]any, len(m))
+	var digests [][]byte
+	for k, e := range m {
+		if !isRedactable(e) {
+			ev, err := r.redact(e, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = ev
+			continue
+		}
+
+		ck, err := claimKey(k)
+		if err != nil {
+			return nil, err
+		}
+		dg, err := r.disclose(e.(cbor.Tag).Content, ck, depth)
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, dg)
+	}
+
+	if len(digests) > 0 {
+		// the digests are sorted so that the order of the claims is not leaked.
+		sort.Slice(digests, func(i, j int) bool { return bytes.Compare(digests[i], digests[j]) < 0 })
+		out[RedactedClaimKeys] = digests
+	}
+	return out, nil
+}
+
+func (r *redactor) disclose(value, k any, depth int) ([]byte, error) {
+	v, err := r.redact(value, depth+1)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Disclosure{Salt: key.GetRandomBytes(saltSize), Value: v, Key: k}
+	data, err := d.MarshalCBOR()
+	if err != nil {
+		return nil, err
+	}
+	r.disclosures = append(r.disclosures, data)
+	return digest(r.alg, data)
+}
+
+// revealer reconstructs the claims set with the disclosures.
+type revealer struct {
+	index map[string]*Disclosure
+	used  map[string]bool
+}
+
+// reveal reconstructs the claims set from the redacted payload and the disclosures.
+// The undisclosed claims and array elements are removed.
+// It returns an error if a disclosure is not referenced by the claims set.
+func reveal(payload []byte, alg int, disclosures []*Disclosure) (cwt.ClaimsMap, error) {
+	r := &revealer{
+		index: make(map[string]*Disclosure, len(disclosures)),
+		used:  make(map[string]bool, len(disclosures)),
+	}
+	for _, d := range disclosures {
+		dg, err := d.Digest(alg)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := r.index[string(dg)]; ok {
+			return nil, fmt.Errorf("duplicate disclosure %x", dg)
+		}
+		r.index[string(dg)] = d
+	}
+
+	var m map[any]any
+	if err := key.UnmarshalCBOR(payload, &m); err != nil {
+		return nil, fmt.Errorf("invalid claims, %w", err)
+	}
+	if m == nil {
+		return nil, errors.New("invalid claims, nil map")
+	}
+
+	v, err := r.reveal(m, 0)
+	if err != nil {
+		return nil, err
+	}
+	for dg := range r.index {
+		if !r.used[dg] {
+			return nil, fmt.Errorf("disclosure %x is not referenced", []byte(dg))
+		}
+	}
+
+	data, err := key.MarshalCBOR(v)
+	if err != nil {
+		return nil, err
+	}
+	var cm cwt.ClaimsMap
+	if err = key.UnmarshalCBOR(data, &cm); err != nil {
+		return nil, fmt.Errorf("invalid claims, %w", err)
+	}
+	return cm, nil
+}
+
+func (r *revealer) reveal(v any, depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("too many nesting levels, expected <= %d", maxDepth)
+	}
+
+	switch x := v.(type) {
+	case map[any]any:
+		out := make(map[any]any, len(x))
+		for k, e := range x {
+			if k == RedactedClaimKeys {
+				continue
+			}
+			ev, err := r.reveal(e, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			out[normKey(k)] = ev
+		}
+
+		if rk, ok := x[RedactedClaimKeys]; ok {
+			digests, ok := rk.([]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid redacted claim keys, expected array, got %T", rk)
+			}
+			for _, e := range digests {
+				d, err := r.lookup(e)
+				if err != nil {
+					return nil, err
+				}
+				if d == nil {
+					continue
+				}
+				if d.Key == nil {
+					return nil, errors.New("unexpected array element disclosure for a redacted claim")
+				}
+				if _, ok := out[d.Key]; ok {
+					return nil, fmt.Errorf("duplicate claim %v", d.Key)
+				}
+				if out[d.Key], err = r.reveal(d.Value, depth+1); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return out, nil
+
+	case []any:
+		arr := make([]any, 0, len(x))
+		for _, e := range x {
+			if tag, ok := e.(cbor.Tag); ok && tag.Number == TagRedactedElement {
+				d, err := r.lookup(tag.Content)
+				if err != nil {
+					return nil, err
+				}
+				if d == nil {
+					continue
+				}
+				if d.Key != nil {
+					return nil, fmt.Errorf("unexpected claim disclosure %v for a redacted array element", d.Key)
+				}
+				e = d.Value
+			}
+
+			ev, err := r.reveal(e, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, ev)
+		}
+		return arr, nil
+
+	case cbor.Tag:
+		if x.Number == TagToBeRedacted || x.Number == TagRedactedElement {
+			return nil, fmt.Errorf("unexpected tag %d", x.Number)
+		}
+		c, err := r.reveal(x.Content, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return cbor.Tag{Number: x.Number, Content: c}, nil
+
+	default:
+		return v, nil
+	}
+}
+
+// lookup returns the disclosure of the digest, or nil if the digest is not disclosed.
+func (r *revealer) lookup(v any) (*Disclosure, error) {
+	dg, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("invalid digest, expected bytes, got %T", v)
+	}
+	d, ok := r.index[string(dg)]
+	if !ok {
+		return nil, nil
+	}
+	if r.used[string(dg)] {
+		return nil, fmt.Errorf("disclosure %x is referenced more than once", dg)
+	}
+	r.used[string(dg)] = true
+	return d, nil
+}
+
+func isRedactable(v any) bool {
+	tag, ok := v.(cbor.Tag)
+	return ok && tag.Number == TagToBeRedacted
+}
+
+// claimKey returns the claim key as an int or a string.
+func claimKey(k any) (any, error) {
+	switch x := k.(type) {
+	case string:
+		return x, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		i, err := key.ToInt(x)
+		if err != nil {
+			return nil, fmt.Errorf("invalid claim key, %w", err)
+		}
+		return i, nil
+	}
+	return nil, fmt.Errorf("invalid claim key %v", k)
+}
+
+func normKey(k any) any {
+	if ck, err := claimKey(k); err == nil {
+		return ck
+	}
+	return k
+}
+
+func digest(alg int, data []byte) ([]byte, error) {
+	switch alg {
+	case iana.AlgorithmSHA_256:
+		sum := sha256.Sum256(data)
+		return sum[:], nil
+	case iana.AlgorithmSHA_384:
+		sum := sha512.Sum384(data)
+		return sum[:], nil
+	case iana.AlgorithmSHA_512:
+		sum := sha512.Sum512(data)
+		return sum[:], nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %d", alg)
+	}
+}
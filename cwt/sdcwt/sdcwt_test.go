@@ -0,0 +1,289 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package sdcwt
+
+import (
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/cwt"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/ed25519"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisclosure(t *testing.T) {
+	t.Run("claim", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		d := &Disclosure{
+			Salt:  key.HexBytesify("bae611067bb823486797da1ebbb52f83"),
+			Value: "ES",
+			Key:   "country",
+		}
+		data, err := d.MarshalCBOR()
+		require.NoError(err)
+		assert.Equal(key.HexBytesify("8350bae611067bb823486797da1ebbb52f8362455367636f756e747279"), data)
+
+		var d2 Disclosure
+		require.NoError(key.UnmarshalCBOR(data, &d2))
+		assert.Equal(d.Salt, d2.Salt)
+		assert.Equal(d.Value, d2.Value)
+		assert.Equal(d.Key, d2.Key)
+
+		dg, err := d.Digest(iana.AlgorithmSHA_256)
+		require.NoError(err)
+		assert.Len(dg, 32)
+		dg2, err := d2.Digest(iana.AlgorithmSHA_256)
+		require.NoError(err)
+		assert.Equal(dg, dg2)
+
+		dg, err = d.Digest(iana.AlgorithmSHA_384)
+		require.NoError(err)
+		assert.Len(dg, 48)
+		dg, err = d.Digest(iana.AlgorithmSHA_512)
+		require.NoError(err)
+		assert.Len(dg, 64)
+		_, err = d.Digest(iana.AlgorithmSHAKE256)
+		assert.ErrorContains(err, "unsupported hash algorithm -45")
+	})
+
+	t.Run("array element", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		d := &Disclosure{Salt: key.GetRandomBytes(16), Value: uint64(42)}
+		data, err := d.MarshalCBOR()
+		require.NoError(err)
+		assert.Equal(byte(0x82), data[0])
+
+		var d2 Disclosure
+		require.NoError(key.UnmarshalCBOR(data, &d2))
+		assert.Nil(d2.Key)
+		assert.Equal(uint64(42), d2.Value)
+
+		var d3 Disclosure
+		require.NoError(key.UnmarshalCBOR(key.MustMarshalCBOR([]any{d.Salt, 1, uint64(iana.CWTClaimSub)}), &d3))
+		assert.Equal(iana.CWTClaimSub, d3.Key)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var d *Disclosure
+		assert.ErrorContains(d.UnmarshalCBOR([]byte{0x80}), "nil Disclosure")
+
+		d = &Disclosure{}
+		assert.ErrorContains(d.UnmarshalCBOR([]byte{0xa0}), "cose/cwt/sdcwt: Disclosure.UnmarshalCBOR")
+		assert.ErrorContains(d.UnmarshalCBOR([]byte{0x81, 0x01}), "invalid disclosure with 1 items")
+		assert.ErrorContains(d.UnmarshalCBOR(key.MustMarshalCBOR([]any{[]byte{1, 2, 3}, 1})), "invalid salt")
+		assert.ErrorContains(d.UnmarshalCBOR(key.MustMarshalCBOR([]any{key.GetRandomBytes(16), 1, 1.5})), "invalid claim key")
+		assert.ErrorContains(d.UnmarshalCBOR(key.MustMarshalCBOR([]any{key.GetRandomBytes(16), 1, uint64(1 << 40)})), "invalid claim key")
+	})
+}
+
+func TestIssuer(t *testing.T) {
+	k, err := ed25519.GenerateKey()
+	require.NoError(t, err)
+	signer, err := k.Signer()
+	require.NoError(t, err)
+
+	t.Run("NewIssuer", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := NewIssuer(nil, iana.AlgorithmSHA_256)
+		assert.ErrorContains(err, "nil Signer")
+		_, err = NewIssuer(signer, iana.AlgorithmSHA_256_64)
+		assert.ErrorContains(err, "unsupported hash algorithm")
+	})
+
+	t.Run("Issue", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		issuer, err := NewIssuer(signer, iana.AlgorithmSHA_256)
+		require.NoError(err)
+
+		claims := cwt.ClaimsMap{
+			iana.CWTClaimIss: "https://issuer.example",
+			iana.CWTClaimSub: Redact("user-42"),
+			iana.CWTClaimExp: 1725330600,
+			"name":           Redact("Alice"),
+			"age_over":       []any{Redact(18), Redact(21), 65},
+			"address": Redact(map[string]any{
+				"country":  "ES",
+				"locality": Redact("Madrid"),
+			}),
+			"public": map[string]any{"a": 1},
+		}
+		data, err := issuer.Issue(claims)
+		require.NoError(err)
+
+		obj := &cose.Sign1Message[cbor.RawMessage]{}
+		require.NoError(obj.UnmarshalCBOR(data))
+		verifier, err := k.Verifier()
+		require.NoError(err)
+		require.NoError(obj.Verify(verifier, nil))
+		assert.Equal(MediaTypeSDCWT, obj.Protected[iana.HeaderParameterTyp])
+		alg, err := obj.Protected.GetInt(HeaderParameterSDAlg)
+		require.NoError(err)
+		assert.Equal(iana.AlgorithmSHA_256, alg)
+
+		// the redacted payload
+		var payload map[any]any
+		require.NoError(key.UnmarshalCBOR(obj.Payload, &payload))
+		assert.Len(payload, 5)
+		assert.Equal("https://issuer.example", payload[uint64(iana.CWTClaimIss)])
+		assert.NotContains(payload, uint64(iana.CWTClaimSub))
+		assert.NotContains(payload, "name")
+		assert.NotContains(payload, "address")
+		assert.Len(payload[RedactedClaimKeys], 3)
+		ages := payload["age_over"].([]any)
+		assert.Len(ages, 3)
+		assert.Equal(uint64(TagRedactedElement), ages[0].(cbor.Tag).Number)
+		assert.Equal(uint64(65), ages[2])
+		assert.Equal(map[any]any{"a": uint64(1)}, payload["public"])
+
+		tk, err := Parse(data)
+		require.NoError(err)
+		assert.Equal(iana.AlgorithmSHA_256, tk.HashAlg())
+		assert.Len(tk.Disclosures, 6)
+
+		cm, err := tk.Claims()
+		require.NoError(err)
+		assert.Equal(cwt.ClaimsMap{
+			iana.CWTClaimIss: "https://issuer.example",
+			iana.CWTClaimSub: "user-42",
+			iana.CWTClaimExp: uint64(1725330600),
+			"name":           "Alice",
+			"age_over":       []any{uint64(18), uint64(21), uint64(65)},
+			"address":        map[any]any{"country": "ES", "locality": "Madrid"},
+			"public":         map[any]any{"a": uint64(1)},
+		}, cm)
+
+		// issue without redactable claims
+		data, err = issuer.Issue(cwt.ClaimsMap{iana.CWTClaimIss: "https://issuer.example"})
+		require.NoError(err)
+		tk, err = Parse(data)
+		require.NoError(err)
+		assert.Len(tk.Disclosures, 0)
+		cm, err = tk.Claims()
+		require.NoError(err)
+		assert.Equal(cwt.ClaimsMap{iana.CWTClaimIss: "https://issuer.example"}, cm)
+	})
+
+	t.Run("Issue errors", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		issuer, err := NewIssuer(signer, iana.AlgorithmSHA_384)
+		require.NoError(err)
+
+		_, err = issuer.Issue(nil)
+		assert.ErrorContains(err, "nil claims")
+
+		for _, c := range []int{iana.CWTClaimIss, iana.CWTClaimExp, iana.CWTClaimNbf, iana.CWTClaimCnf} {
+			_, err = issuer.Issue(cwt.ClaimsMap{c: Redact(1)})
+			assert.ErrorContains(err, "can not be redacted")
+		}
+
+		_, err = issuer.Issue(cwt.ClaimsMap{"a": map[any]any{RedactedClaimKeys: []any{}}})
+		assert.ErrorContains(err, "unexpected redacted claim keys")
+
+		_, err = issuer.Issue(cwt.ClaimsMap{"a": cbor.Tag{Number: 1, Content: Redact(1)}})
+		assert.ErrorContains(err, "unexpected redactable value")
+
+		_, err = issuer.Issue(cwt.ClaimsMap{"a": Redact(Redact(1))})
+		assert.ErrorContains(err, "unexpected redactable value")
+
+		_, err = issuer.Issue(cwt.ClaimsMap{"a": map[any]any{1.5: Redact(1)}})
+		assert.ErrorContains(err, "invalid claim key")
+
+		v := any("deep")
+		for i := 0; i < maxDepth+1; i++ {
+			v = []any{v}
+		}
+		_, err = issuer.Issue(cwt.ClaimsMap{"a": v})
+		assert.ErrorContains(err, "too many nesting levels")
+	})
+}
+
+func TestReveal(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	d1 := &Disclosure{Salt: key.GetRandomBytes(16), Value: "Alice", Key: "name"}
+	d2 := &Disclosure{Salt: key.GetRandomBytes(16), Value: 18}
+	dg1, err := d1.Digest(iana.AlgorithmSHA_256)
+	require.NoError(err)
+	dg2, err := d2.Digest(iana.AlgorithmSHA_256)
+	require.NoError(err)
+
+	payload := key.MustMarshalCBOR(map[any]any{
+		iana.CWTClaimIss:  "https://issuer.example",
+		RedactedClaimKeys: [][]byte{dg1, key.GetRandomBytes(32)},
+		"ages":            []any{cbor.Tag{Number: TagRedactedElement, Content: dg2}, 65},
+	})
+
+	cm, err := reveal(payload, iana.AlgorithmSHA_256, []*Disclosure{d1, d2})
+	require.NoError(err)
+	assert.Equal(cwt.ClaimsMap{
+		iana.CWTClaimIss: "https://issuer.example",
+		"name":           "Alice",
+		"ages":           []any{uint64(18), uint64(65)},
+	}, cm)
+
+	cm, err = reveal(payload, iana.AlgorithmSHA_256, nil)
+	require.NoError(err)
+	assert.Equal(cwt.ClaimsMap{
+		iana.CWTClaimIss: "https://issuer.example",
+		"ages":           []any{uint64(65)},
+	}, cm)
+
+	_, err = reveal(payload, iana.AlgorithmSHA_256, []*Disclosure{d1, d1})
+	assert.ErrorContains(err, "duplicate disclosure")
+
+	d3 := &Disclosure{Salt: key.GetRandomBytes(16), Value: "Bob", Key: "name"}
+	_, err = reveal(payload, iana.AlgorithmSHA_256, []*Disclosure{d3})
+	assert.ErrorContains(err, "is not referenced")
+
+	_, err = reveal(payload, iana.AlgorithmSHA_384, []*Disclosure{d1})
+	assert.ErrorContains(err, "is not referenced")
+
+	// the digests are swapped between the claim and the array element.
+	_, err = reveal(key.MustMarshalCBOR(map[any]any{RedactedClaimKeys: [][]byte{dg2}}),
+		iana.AlgorithmSHA_256, []*Disclosure{d2})
+	assert.ErrorContains(err, "unexpected array element disclosure")
+	_, err = reveal(key.MustMarshalCBOR(map[any]any{"ages": []any{cbor.Tag{Number: TagRedactedElement, Content: dg1}}}),
+		iana.AlgorithmSHA_256, []*Disclosure{d1})
+	assert.ErrorContains(err, "unexpected claim disclosure name")
+
+	_, err = reveal(key.MustMarshalCBOR(map[any]any{"name": "Bob", RedactedClaimKeys: [][]byte{dg1}}),
+		iana.AlgorithmSHA_256, []*Disclosure{d1})
+	assert.ErrorContains(err, "duplicate claim name")
+
+	_, err = reveal(key.MustMarshalCBOR(map[any]any{"a": []any{dg1}, RedactedClaimKeys: [][]byte{dg1}}),
+		iana.AlgorithmSHA_256, []*Disclosure{d1})
+	assert.NoError(err)
+	_, err = reveal(key.MustMarshalCBOR(map[any]any{"a": map[any]any{RedactedClaimKeys: [][]byte{dg1}}, RedactedClaimKeys: [][]byte{dg1}}),
+		iana.AlgorithmSHA_256, []*Disclosure{d1})
+	assert.ErrorContains(err, "is referenced more than once")
+
+	_, err = reveal(key.MustMarshalCBOR(map[any]any{RedactedClaimKeys: "digest"}), iana.AlgorithmSHA_256, nil)
+	assert.ErrorContains(err, "invalid redacted claim keys")
+	_, err = reveal(key.MustMarshalCBOR(map[any]any{RedactedClaimKeys: []any{1}}), iana.AlgorithmSHA_256, nil)
+	assert.ErrorContains(err, "invalid digest")
+	_, err = reveal(key.MustMarshalCBOR(map[any]any{"a": Redact(1)}), iana.AlgorithmSHA_256, nil)
+	assert.ErrorContains(err, "unexpected tag 58")
+	_, err = reveal(key.MustMarshalCBOR(map[any]any{"a": cbor.Tag{Number: TagRedactedElement, Content: dg1}}), iana.AlgorithmSHA_256, nil)
+	assert.ErrorContains(err, "unexpected tag 60")
+	_, err = reveal([]byte{0xf6}, iana.AlgorithmSHA_256, nil)
+	assert.ErrorContains(err, "invalid claims")
+	_, err = reveal([]byte{0x80}, iana.AlgorithmSHA_256, nil)
+	assert.ErrorContains(err, "invalid claims")
+}
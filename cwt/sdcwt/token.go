@@ -0,0 +1,198 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package sdcwt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/cwt"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+// Token represents a SD-CWT held by the holder.
+type Token struct {
+	// Protected is the protected header parameters of the SD-CWT.
+	Protected cose.Headers
+	// Disclosures are the disclosures in the "sd_claims" unprotected header parameter.
+	Disclosures []*Disclosure
+
+	alg     int
+	raw     []byte
+	payload []byte
+}
+
+// Parse decodes a SD-CWT and its disclosures. It does not verify the signature of the SD-CWT.
+func Parse(data []byte) (*Token, error) {
+	obj := &cose.Sign1Message[cbor.RawMessage]{}
+	if err := obj.UnmarshalCBOR(data); err != nil {
+		return nil, fmt.Errorf("cose/cwt/sdcwt: Parse: %w", err)
+	}
+
+	t, err := tokenFrom(obj)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt/sdcwt: Parse: %w", err)
+	}
+	t.raw = append([]byte{}, data...)
+	return t, nil
+}
+
+func tokenFrom(obj *cose.Sign1Message[cbor.RawMessage]) (*Token, error) {
+	if typ, _ := obj.Protected.GetString(iana.HeaderParameterTyp); typ != MediaTypeSDCWT {
+		return nil, fmt.Errorf("invalid typ %q, expected %q", typ, MediaTypeSDCWT)
+	}
+
+	if !obj.Protected.Has(HeaderParameterSDAlg) {
+		return nil, errors.New("missing sd_alg")
+	}
+	alg, err := obj.Protected.GetInt(HeaderParameterSDAlg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sd_alg, %w", err)
+	}
+	if _, err = digest(alg, nil); err != nil {
+		return nil, err
+	}
+
+	disclosures, err := parseDisclosures(obj.Unprotected)
+	if err != nil {
+		return nil, err
+	}
+	return &Token{
+		Protected:   obj.Protected,
+		Disclosures: disclosures,
+		alg:         alg,
+		payload:     obj.Payload,
+	}, nil
+}
+
+// HashAlg returns the hash algorithm used for the digests of the SD-CWT.
+func (t *Token) HashAlg() int {
+	return t.alg
+}
+
+// Claims returns the claims set reconstructed with all the disclosures of the SD-CWT.
+func (t *Token) Claims() (cwt.ClaimsMap, error) {
+	cm, err := reveal(t.payload, t.alg, t.Disclosures)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt/sdcwt: Token.Claims: %w", err)
+	}
+	return cm, nil
+}
+
+// Select returns the disclosures of the redacted claims with the given keys,
+// the nested claims are not matched.
+func (t *Token) Select(keys ...any) []*Disclosure {
+	var rt []*Disclosure
+	for _, d := range t.Disclosures {
+		if d.Key == nil {
+			continue
+		}
+		for _, k := range keys {
+			if ck, err := claimKey(k); err == nil && ck == d.Key {
+				rt = append(rt, d)
+				break
+			}
+		}
+	}
+	return rt
+}
+
+// Present creates a presentation of the SD-CWT with the selected disclosures,
+// a key binding token signed by the holder's key that is confirmed by the "cnf" claim of the SD-CWT.
+// The claims of the key binding token should include the "aud" and "iat" claims,
+// and the "cnonce" claim if the verifier provided a nonce.
+func (t *Token) Present(holder key.Signer, disclosures []*Disclosure, claims cwt.ClaimsMap) ([]byte, error) {
+	if holder == nil {
+		return nil, errors.New("cose/cwt/sdcwt: Token.Present: nil Signer")
+	}
+	if !claims.Has(iana.CWTClaimAud) {
+		return nil, errors.New("cose/cwt/sdcwt: Token.Present: missing aud claim")
+	}
+	if !claims.Has(iana.CWTClaimIat) {
+		return nil, errors.New("cose/cwt/sdcwt: Token.Present: missing iat claim")
+	}
+
+	selected := make([][]byte, 0, len(disclosures))
+	for _, d := range disclosures {
+		data, err := d.MarshalCBOR()
+		if err != nil {
+			return nil, fmt.Errorf("cose/cwt/sdcwt: Token.Present: %w", err)
+		}
+		if !t.hasDisclosure(data) {
+			return nil, fmt.Errorf("cose/cwt/sdcwt: Token.Present: disclosure %v is not in the SD-CWT", d.Key)
+		}
+		selected = append(selected, data)
+	}
+
+	// the unprotected header parameters are not signed, the holder replaces the disclosures.
+	obj := &cose.Sign1Message[cbor.RawMessage]{}
+	if err := obj.UnmarshalCBOR(t.raw); err != nil {
+		return nil, fmt.Errorf("cose/cwt/sdcwt: Token.Present: %w", err)
+	}
+	delete(obj.Unprotected, HeaderParameterSDClaims)
+	if len(selected) > 0 {
+		obj.Unprotected[HeaderParameterSDClaims] = selected
+	}
+	sdcwt, err := obj.MarshalCBOR()
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt/sdcwt: Token.Present: %w", err)
+	}
+
+	kbt := &cose.Sign1Message[cwt.ClaimsMap]{
+		Protected: cose.Headers{
+			iana.HeaderParameterTyp:  MediaTypeKBCWT,
+			iana.HeaderParameterKCWT: cbor.RawMessage(sdcwt),
+		},
+		Payload: claims,
+	}
+	if alg := holder.Key().Alg(); alg != iana.AlgorithmReserved {
+		kbt.Protected[iana.HeaderParameterAlg] = alg
+	}
+
+	data, err := kbt.SignAndEncode(holder, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt/sdcwt: Token.Present: %w", err)
+	}
+	return data, nil
+}
+
+func (t *Token) hasDisclosure(data []byte) bool {
+	for _, d := range t.Disclosures {
+		if bytes.Equal(d.raw, data) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseDisclosures(unprotected cose.Headers) ([]*Disclosure, error) {
+	v, ok := unprotected[HeaderParameterSDClaims]
+	if !ok {
+		return nil, nil
+	}
+
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid sd_claims, expected array, got %T", v)
+	}
+
+	disclosures := make([]*Disclosure, 0, len(arr))
+	for _, e := range arr {
+		data, ok := e.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("invalid sd_claims, expected bytes, got %T", e)
+		}
+		d := &Disclosure{}
+		if err := d.UnmarshalCBOR(data); err != nil {
+			return nil, err
+		}
+		disclosures = append(disclosures, d)
+	}
+	return disclosures, nil
+}
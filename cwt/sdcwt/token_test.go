@@ -0,0 +1,153 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package sdcwt
+
+import (
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/cwt"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/ecdsa"
+	"github.com/ldclabs/cose/key/ed25519"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToken(t *testing.T) {
+	issuerKey, err := ed25519.GenerateKey()
+	require.NoError(t, err)
+	issuerKey.SetKid([]byte("issuer-1"))
+	signer, err := issuerKey.Signer()
+	require.NoError(t, err)
+	issuer, err := NewIssuer(signer, iana.AlgorithmSHA_256)
+	require.NoError(t, err)
+
+	holderKey, err := ecdsa.GenerateKey(iana.AlgorithmES256)
+	require.NoError(t, err)
+	holder, err := holderKey.Signer()
+	require.NoError(t, err)
+	cnf, err := cwt.ConfirmKey(holderKey)
+	require.NoError(t, err)
+
+	data, err := issuer.Issue(cwt.ClaimsMap{
+		iana.CWTClaimIss: "https://issuer.example",
+		iana.CWTClaimCnf: cnf,
+		"name":           Redact("Alice"),
+		"email":          Redact("alice@example.com"),
+		"address":        Redact(map[string]any{"locality": Redact("Madrid")}),
+	})
+	require.NoError(t, err)
+
+	t.Run("Select", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		tk, err := Parse(data)
+		require.NoError(err)
+		assert.Len(tk.Disclosures, 4)
+
+		ds := tk.Select("name", "address", "unknown")
+		require.Len(ds, 2)
+		for _, d := range ds {
+			assert.Contains([]any{"name", "address"}, d.Key)
+		}
+		assert.Len(tk.Select("locality"), 1)
+		assert.Len(tk.Select(1.5), 0)
+	})
+
+	t.Run("Present", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		tk, err := Parse(data)
+		require.NoError(err)
+
+		kbClaims := cwt.ClaimsMap{
+			iana.CWTClaimAud: "https://verifier.example",
+			iana.CWTClaimIat: 1725244200,
+		}
+		pr, err := tk.Present(holder, tk.Select("name"), kbClaims)
+		require.NoError(err)
+
+		kbt := &cose.Sign1Message[cwt.ClaimsMap]{}
+		require.NoError(kbt.UnmarshalCBOR(pr))
+		assert.Equal(MediaTypeKBCWT, kbt.Protected[iana.HeaderParameterTyp])
+		assert.Equal("https://verifier.example", kbt.Payload[iana.CWTClaimAud])
+
+		sd, err := Parse(key.MustMarshalCBOR(kbt.Protected[iana.HeaderParameterKCWT]))
+		require.NoError(err)
+		require.Len(sd.Disclosures, 1)
+		assert.Equal("name", sd.Disclosures[0].Key)
+		cm, err := sd.Claims()
+		require.NoError(err)
+		assert.Equal("Alice", cm["name"])
+		assert.NotContains(cm, "email")
+		assert.NotContains(cm, "address")
+
+		// the token held by the holder is not changed.
+		assert.Len(tk.Disclosures, 4)
+		tk2, err := Parse(data)
+		require.NoError(err)
+		assert.Len(tk2.Disclosures, 4)
+
+		// present without disclosures.
+		pr, err = tk.Present(holder, nil, kbClaims)
+		require.NoError(err)
+		kbt = &cose.Sign1Message[cwt.ClaimsMap]{}
+		require.NoError(kbt.UnmarshalCBOR(pr))
+		sd, err = Parse(key.MustMarshalCBOR(kbt.Protected[iana.HeaderParameterKCWT]))
+		require.NoError(err)
+		assert.Len(sd.Disclosures, 0)
+
+		_, err = tk.Present(nil, nil, kbClaims)
+		assert.ErrorContains(err, "nil Signer")
+		_, err = tk.Present(holder, nil, cwt.ClaimsMap{iana.CWTClaimIat: 1725244200})
+		assert.ErrorContains(err, "missing aud claim")
+		_, err = tk.Present(holder, nil, cwt.ClaimsMap{iana.CWTClaimAud: "https://verifier.example"})
+		assert.ErrorContains(err, "missing iat claim")
+
+		forged := &Disclosure{Salt: key.GetRandomBytes(16), Value: "Mallory", Key: "name"}
+		_, err = tk.Present(holder, []*Disclosure{forged}, kbClaims)
+		assert.ErrorContains(err, "disclosure name is not in the SD-CWT")
+	})
+
+	t.Run("Parse errors", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		_, err := Parse([]byte{0xa0})
+		assert.ErrorContains(err, "cose/cwt/sdcwt: Parse")
+
+		sign := func(protected, unprotected cose.Headers) []byte {
+			obj := &cose.Sign1Message[cbor.RawMessage]{
+				Protected:   protected,
+				Unprotected: unprotected,
+				Payload:     key.MustMarshalCBOR(cwt.ClaimsMap{}),
+			}
+			data, err := obj.SignAndEncode(signer, nil)
+			require.NoError(err)
+			return data
+		}
+
+		_, err = Parse(sign(cose.Headers{}, cose.Headers{}))
+		assert.ErrorContains(err, `invalid typ ""`)
+		_, err = Parse(sign(cose.Headers{iana.HeaderParameterTyp: MediaTypeSDCWT}, cose.Headers{}))
+		assert.ErrorContains(err, "missing sd_alg")
+		_, err = Parse(sign(cose.Headers{iana.HeaderParameterTyp: MediaTypeSDCWT, HeaderParameterSDAlg: "sha-256"}, cose.Headers{}))
+		assert.ErrorContains(err, "invalid sd_alg")
+		_, err = Parse(sign(cose.Headers{iana.HeaderParameterTyp: MediaTypeSDCWT, HeaderParameterSDAlg: iana.AlgorithmSHAKE256}, cose.Headers{}))
+		assert.ErrorContains(err, "unsupported hash algorithm")
+
+		protected := cose.Headers{iana.HeaderParameterTyp: MediaTypeSDCWT, HeaderParameterSDAlg: iana.AlgorithmSHA_256}
+		_, err = Parse(sign(protected, cose.Headers{HeaderParameterSDClaims: 1}))
+		assert.ErrorContains(err, "invalid sd_claims, expected array")
+		_, err = Parse(sign(protected, cose.Headers{HeaderParameterSDClaims: []any{"a"}}))
+		assert.ErrorContains(err, "invalid sd_claims, expected bytes")
+		_, err = Parse(sign(protected, cose.Headers{HeaderParameterSDClaims: [][]byte{{0x80}}}))
+		assert.ErrorContains(err, "invalid disclosure with 0 items")
+	})
+}
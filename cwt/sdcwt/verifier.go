@@ -0,0 +1,162 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package sdcwt
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/cwt"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+// VerifierOpts defines the keys and the validators for SD-CWT verifiers.
+type VerifierOpts struct {
+	// Issuers verify the signatures of the SD-CWTs.
+	// The key is selected with the "kid" header parameter,
+	// the only key is used if the "kid" is not present.
+	Issuers key.Verifiers
+	// HolderKeys are the holder's keys that are confirmed with the kid confirmation method.
+	// It can be nil if the "cnf" claims carry the COSE_Key.
+	HolderKeys key.KeySet
+	// Validator validates the reconstructed claims of the SD-CWTs. It is required.
+	Validator *cwt.Validator
+	// KBValidator validates the claims of the key binding tokens,
+	// such as the "aud" and the "cnonce" claims. It is required.
+	KBValidator *cwt.Validator
+}
+
+// Verifier verifies the presentations of SD-CWTs.
+type Verifier struct {
+	opts VerifierOpts
+}
+
+// Presentation represents a verified presentation of a SD-CWT.
+type Presentation struct {
+	// Claims is the claims set of the SD-CWT with the disclosed claims,
+	// the undisclosed claims and array elements are removed.
+	Claims cwt.ClaimsMap
+	// KBClaims is the claims set of the key binding token.
+	KBClaims cwt.ClaimsMap
+	// Disclosures are the disclosures presented by the holder.
+	Disclosures []*Disclosure
+	// Protected is the protected header parameters of the SD-CWT.
+	Protected cose.Headers
+}
+
+// NewVerifier creates a new SD-CWT Verifier.
+func NewVerifier(opts *VerifierOpts) (*Verifier, error) {
+	if opts == nil {
+		return nil, errors.New("cose/cwt/sdcwt: NewVerifier: nil VerifierOpts")
+	}
+	if len(opts.Issuers) == 0 {
+		return nil, errors.New("cose/cwt/sdcwt: NewVerifier: no Issuers provided")
+	}
+	if opts.Validator == nil {
+		return nil, errors.New("cose/cwt/sdcwt: NewVerifier: nil Validator")
+	}
+	if opts.KBValidator == nil {
+		return nil, errors.New("cose/cwt/sdcwt: NewVerifier: nil KBValidator")
+	}
+	return &Verifier{opts: *opts}, nil
+}
+
+// Verify verifies a presentation, the key binding token with the SD-CWT in the "kcwt" header parameter.
+// It verifies the signature of the SD-CWT with the issuer's key, reconstructs the claims with the disclosures,
+// verifies the key binding token with the key confirmed by the "cnf" claim,
+// and then validates the claims of both tokens.
+func (v *Verifier) Verify(data []byte) (*Presentation, error) {
+	p, err := v.verify(data)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt/sdcwt: Verifier.Verify: %w", err)
+	}
+
+	if err = v.opts.Validator.ValidateMap(p.Claims); err != nil {
+		return nil, err
+	}
+	if err = v.opts.KBValidator.ValidateMap(p.KBClaims); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (v *Verifier) verify(data []byte) (*Presentation, error) {
+	kbt := &cose.Sign1Message[cwt.ClaimsMap]{}
+	if err := kbt.UnmarshalCBOR(data); err != nil {
+		return nil, fmt.Errorf("invalid key binding token, %w", err)
+	}
+	if typ, _ := kbt.Protected.GetString(iana.HeaderParameterTyp); typ != MediaTypeKBCWT {
+		return nil, fmt.Errorf("invalid key binding token typ %q, expected %q", typ, MediaTypeKBCWT)
+	}
+	if kbt.Payload == nil {
+		return nil, errors.New("invalid key binding token, nil claims")
+	}
+	if !kbt.Payload.Has(iana.CWTClaimIat) {
+		return nil, errors.New("invalid key binding token, missing iat claim")
+	}
+
+	kcwt, ok := kbt.Protected[iana.HeaderParameterKCWT]
+	if !ok {
+		return nil, errors.New("invalid key binding token, missing kcwt")
+	}
+	// the protected header parameters are decoded, the SD-CWT is encoded again.
+	// It is not changed since its signed parts are byte strings.
+	raw, err := key.MarshalCBOR(kcwt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kcwt, %w", err)
+	}
+
+	obj := &cose.Sign1Message[cbor.RawMessage]{}
+	if err = obj.UnmarshalCBOR(raw); err != nil {
+		return nil, fmt.Errorf("invalid kcwt, %w", err)
+	}
+	t, err := tokenFrom(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	kid := cwt.LookupKid(obj.Protected, obj.Unprotected)
+	verifier := cwt.LookupKey(v.opts.Issuers, kid)
+	if verifier == nil {
+		return nil, fmt.Errorf("no Issuer for kid %x", kid)
+	}
+	if err = obj.Verify(verifier, nil); err != nil {
+		return nil, err
+	}
+
+	claims, err := reveal(t.payload, t.alg, t.Disclosures)
+	if err != nil {
+		return nil, err
+	}
+
+	cnf, err := claims.GetConfirmation()
+	if err != nil {
+		return nil, err
+	}
+	if cnf == nil {
+		return nil, errors.New("missing cnf claim")
+	}
+	hk, err := cnf.ConfirmedKey(nil, v.opts.HolderKeys)
+	if err != nil {
+		return nil, err
+	}
+	hv, err := hk.Verifier()
+	if err != nil {
+		return nil, err
+	}
+	if err = kbt.Verify(hv, nil); err != nil {
+		return nil, fmt.Errorf("invalid key binding token, %w", err)
+	}
+
+	return &Presentation{
+		Claims:      claims,
+		KBClaims:    kbt.Payload,
+		Disclosures: t.Disclosures,
+		Protected:   t.Protected,
+	}, nil
+}
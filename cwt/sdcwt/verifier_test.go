@@ -0,0 +1,303 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package sdcwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/cwt"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/ecdsa"
+	"github.com/ldclabs/cose/key/ed25519"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifier(t *testing.T) {
+	now := time.Unix(1725244200, 0)
+
+	issuerKey, err := ed25519.GenerateKey()
+	require.NoError(t, err)
+	issuerKey.SetKid([]byte("issuer-1"))
+	signer, err := issuerKey.Signer()
+	require.NoError(t, err)
+	issuerVerifier, err := issuerKey.Verifier()
+	require.NoError(t, err)
+	issuer, err := NewIssuer(signer, iana.AlgorithmSHA_256)
+	require.NoError(t, err)
+
+	holderKey, err := ecdsa.GenerateKey(iana.AlgorithmES256)
+	require.NoError(t, err)
+	holderKey.SetKid([]byte("holder-1"))
+	holder, err := holderKey.Signer()
+	require.NoError(t, err)
+	cnf, err := cwt.ConfirmKey(holderKey)
+	require.NoError(t, err)
+
+	validator, err := cwt.NewValidator(&cwt.ValidatorOpts{
+		ExpectedIssuer: "https://issuer.example",
+		FixedNow:       now,
+	})
+	require.NoError(t, err)
+	kbValidator, err := cwt.NewValidator(&cwt.ValidatorOpts{
+		ExpectedAudience:       "https://verifier.example",
+		AllowMissingExpiration: true,
+		ExpectIssuedInThePast:  true,
+		FixedNow:               now,
+	})
+	require.NoError(t, err)
+
+	claims := cwt.ClaimsMap{
+		iana.CWTClaimIss: "https://issuer.example",
+		iana.CWTClaimSub: Redact("user-42"),
+		iana.CWTClaimExp: now.Add(time.Hour).Unix(),
+		iana.CWTClaimCnf: cnf,
+		"name":           Redact("Alice"),
+		"age_over":       []any{Redact(18), Redact(21), Redact(65)},
+		"address": Redact(map[string]any{
+			"country":  "ES",
+			"locality": Redact("Madrid"),
+		}),
+	}
+	data, err := issuer.Issue(claims)
+	require.NoError(t, err)
+	tk, err := Parse(data)
+	require.NoError(t, err)
+
+	kbClaims := cwt.ClaimsMap{
+		iana.CWTClaimAud: "https://verifier.example",
+		iana.CWTClaimIat: now.Add(-time.Second).Unix(),
+	}
+
+	t.Run("NewVerifier", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := NewVerifier(nil)
+		assert.ErrorContains(err, "nil VerifierOpts")
+		_, err = NewVerifier(&VerifierOpts{Validator: validator, KBValidator: kbValidator})
+		assert.ErrorContains(err, "no Issuers provided")
+		_, err = NewVerifier(&VerifierOpts{Issuers: key.Verifiers{issuerVerifier}, KBValidator: kbValidator})
+		assert.ErrorContains(err, "nil Validator")
+		_, err = NewVerifier(&VerifierOpts{Issuers: key.Verifiers{issuerVerifier}, Validator: validator})
+		assert.ErrorContains(err, "nil KBValidator")
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		v, err := NewVerifier(&VerifierOpts{
+			Issuers:     key.Verifiers{issuerVerifier},
+			Validator:   validator,
+			KBValidator: kbValidator,
+		})
+		require.NoError(err)
+
+		var selected []*Disclosure
+		for _, d := range tk.Disclosures {
+			switch {
+			case d.Key == "name" || d.Key == "address":
+				selected = append(selected, d)
+			case d.Key == nil && d.Value == uint64(18):
+				selected = append(selected, d)
+			}
+		}
+		require.Len(selected, 3)
+
+		pr, err := tk.Present(holder, selected, kbClaims)
+		require.NoError(err)
+
+		p, err := v.Verify(pr)
+		require.NoError(err)
+		assert.Equal(cwt.ClaimsMap{
+			iana.CWTClaimIss: "https://issuer.example",
+			iana.CWTClaimExp: uint64(now.Add(time.Hour).Unix()),
+			iana.CWTClaimCnf: p.Claims[iana.CWTClaimCnf],
+			"name":           "Alice",
+			"age_over":       []any{uint64(18)},
+			"address":        map[any]any{"country": "ES"},
+		}, p.Claims)
+		assert.Equal("https://verifier.example", p.KBClaims[iana.CWTClaimAud])
+		assert.Len(p.Disclosures, 3)
+		assert.Equal(MediaTypeSDCWT, p.Protected[iana.HeaderParameterTyp])
+
+		pcnf, err := p.Claims.GetConfirmation()
+		require.NoError(err)
+		assert.Equal(cnf.Key.Kid(), pcnf.Key.Kid())
+
+		// present all the disclosures
+		pr, err = tk.Present(holder, tk.Disclosures, kbClaims)
+		require.NoError(err)
+		p, err = v.Verify(pr)
+		require.NoError(err)
+		assert.Equal("user-42", p.Claims[iana.CWTClaimSub])
+		assert.Equal([]any{uint64(18), uint64(21), uint64(65)}, p.Claims["age_over"])
+		assert.Equal(map[any]any{"country": "ES", "locality": "Madrid"}, p.Claims["address"])
+
+		// the nested disclosure without its parent is not referenced.
+		pr, err = tk.Present(holder, tk.Select("locality"), kbClaims)
+		require.NoError(err)
+		_, err = v.Verify(pr)
+		assert.ErrorContains(err, "is not referenced")
+	})
+
+	t.Run("Verify errors", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		v, err := NewVerifier(&VerifierOpts{
+			Issuers:     key.Verifiers{issuerVerifier},
+			Validator:   validator,
+			KBValidator: kbValidator,
+		})
+		require.NoError(err)
+
+		_, err = v.Verify([]byte{0xa0})
+		assert.ErrorContains(err, "invalid key binding token")
+
+		// the SD-CWT without a key binding token.
+		_, err = v.Verify(data)
+		assert.ErrorContains(err, "invalid key binding token")
+		_, err = v.Verify(signWith(t, signer, cose.Headers{iana.HeaderParameterTyp: MediaTypeSDCWT}))
+		assert.ErrorContains(err, `invalid key binding token typ "application/sd+cwt"`)
+
+		// the key binding token is signed by another key.
+		otherKey, err := ecdsa.GenerateKey(iana.AlgorithmES256)
+		require.NoError(err)
+		otherKey.SetKid([]byte("holder-1"))
+		other, err := otherKey.Signer()
+		require.NoError(err)
+		pr, err := tk.Present(other, tk.Select("name"), kbClaims)
+		require.NoError(err)
+		_, err = v.Verify(pr)
+		assert.ErrorContains(err, "invalid key binding token")
+
+		// the claims of the key binding token are validated.
+		pr, err = tk.Present(holder, tk.Select("name"), cwt.ClaimsMap{
+			iana.CWTClaimAud: "https://other.example",
+			iana.CWTClaimIat: now.Unix(),
+		})
+		require.NoError(err)
+		_, err = v.Verify(pr)
+		assert.ErrorContains(err, "aud")
+
+		// the SD-CWT is issued by an unknown issuer.
+		otherIssuer, err := NewIssuer(other, iana.AlgorithmSHA_256)
+		require.NoError(err)
+		data2, err := otherIssuer.Issue(cwt.ClaimsMap{iana.CWTClaimIss: "https://issuer.example", iana.CWTClaimCnf: cnf})
+		require.NoError(err)
+		tk2, err := Parse(data2)
+		require.NoError(err)
+		pr, err = tk2.Present(holder, nil, kbClaims)
+		require.NoError(err)
+		_, err = v.Verify(pr)
+		assert.ErrorContains(err, "no Issuer for kid 686f6c6465722d31")
+
+		// the claims of the SD-CWT are validated.
+		data2, err = issuer.Issue(cwt.ClaimsMap{
+			iana.CWTClaimIss: "https://issuer.example",
+			iana.CWTClaimExp: now.Add(-time.Hour).Unix(),
+			iana.CWTClaimCnf: cnf,
+		})
+		require.NoError(err)
+		tk2, err = Parse(data2)
+		require.NoError(err)
+		pr, err = tk2.Present(holder, nil, kbClaims)
+		require.NoError(err)
+		_, err = v.Verify(pr)
+		assert.ErrorContains(err, "token has expired")
+
+		// the SD-CWT without the cnf claim.
+		data2, err = issuer.Issue(cwt.ClaimsMap{iana.CWTClaimIss: "https://issuer.example"})
+		require.NoError(err)
+		tk2, err = Parse(data2)
+		require.NoError(err)
+		pr, err = tk2.Present(holder, nil, kbClaims)
+		require.NoError(err)
+		_, err = v.Verify(pr)
+		assert.ErrorContains(err, "missing cnf claim")
+
+		// the holder key confirmed with the kid method.
+		data2, err = issuer.Issue(cwt.ClaimsMap{
+			iana.CWTClaimIss: "https://issuer.example",
+			iana.CWTClaimExp: now.Add(time.Hour).Unix(),
+			iana.CWTClaimCnf: cwt.ConfirmKid(holderKey.Kid()),
+		})
+		require.NoError(err)
+		tk2, err = Parse(data2)
+		require.NoError(err)
+		pr, err = tk2.Present(holder, nil, kbClaims)
+		require.NoError(err)
+		_, err = v.Verify(pr)
+		assert.ErrorContains(err, "no key for kid 686f6c6465722d31")
+
+		holderPub, err := ecdsa.ToPublicKey(holderKey)
+		require.NoError(err)
+		v2, err := NewVerifier(&VerifierOpts{
+			Issuers:     key.Verifiers{issuerVerifier},
+			HolderKeys:  key.KeySet{holderPub},
+			Validator:   validator,
+			KBValidator: kbValidator,
+		})
+		require.NoError(err)
+		_, err = v2.Verify(pr)
+		assert.NoError(err)
+
+		// a forged disclosure is added to the SD-CWT.
+		kbt := &cose.Sign1Message[cwt.ClaimsMap]{}
+		pr, err = tk.Present(holder, nil, kbClaims)
+		require.NoError(err)
+		require.NoError(kbt.UnmarshalCBOR(pr))
+		sd := &cose.Sign1Message[cbor.RawMessage]{}
+		require.NoError(sd.UnmarshalCBOR(key.MustMarshalCBOR(kbt.Protected[iana.HeaderParameterKCWT])))
+		forged := &Disclosure{Salt: key.GetRandomBytes(16), Value: "Mallory", Key: "name"}
+		sd.Unprotected[HeaderParameterSDClaims] = []any{key.MustMarshalCBOR(forged)}
+		kbt2 := &cose.Sign1Message[cwt.ClaimsMap]{
+			Protected: cose.Headers{
+				iana.HeaderParameterAlg:  iana.AlgorithmES256,
+				iana.HeaderParameterTyp:  MediaTypeKBCWT,
+				iana.HeaderParameterKCWT: cbor.RawMessage(sd.Bytesify()),
+			},
+			Payload: kbClaims,
+		}
+		pr, err = kbt2.SignAndEncode(holder, nil)
+		require.NoError(err)
+		_, err = v.Verify(pr)
+		assert.ErrorContains(err, "is not referenced")
+
+		// the key binding token without iat or kcwt.
+		kbt2.Payload = cwt.ClaimsMap{iana.CWTClaimAud: "https://verifier.example"}
+		pr, err = kbt2.SignAndEncode(holder, nil)
+		require.NoError(err)
+		_, err = v.Verify(pr)
+		assert.ErrorContains(err, "missing iat claim")
+
+		kbt2.Payload = kbClaims
+		delete(kbt2.Protected, iana.HeaderParameterKCWT)
+		pr, err = kbt2.SignAndEncode(holder, nil)
+		require.NoError(err)
+		_, err = v.Verify(pr)
+		assert.ErrorContains(err, "missing kcwt")
+
+		kbt2.Protected[iana.HeaderParameterKCWT] = []byte{1, 2, 3}
+		pr, err = kbt2.SignAndEncode(holder, nil)
+		require.NoError(err)
+		_, err = v.Verify(pr)
+		assert.ErrorContains(err, "invalid kcwt")
+	})
+}
+
+func signWith(t *testing.T, signer key.Signer, protected cose.Headers) []byte {
+	obj := &cose.Sign1Message[cwt.ClaimsMap]{
+		Protected: protected,
+		Payload:   cwt.ClaimsMap{iana.CWTClaimIat: 1725244200},
+	}
+	data, err := obj.SignAndEncode(signer, nil)
+	require.NoError(t, err)
+	return data
+}
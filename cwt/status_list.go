@@ -0,0 +1,488 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+// Status values of the Token Status List.
+//
+// Reference https://datatracker.ietf.org/doc/html/draft-ietf-oauth-status-list#section-7
+const (
+	StatusValid     uint8 = 0x00
+	StatusInvalid   uint8 = 0x01
+	StatusSuspended uint8 = 0x02
+)
+
+const (
+	// maxStatusListSize is the maximum size of a decompressed status list, 16 MiB.
+	maxStatusListSize = 1 << 24
+	// defaultStatusCacheTTL is the cache TTL of the status lists without the "ttl" claim.
+	defaultStatusCacheTTL = 5 * time.Minute
+	// defaultStatusFetchTimeout is the timeout of fetching a status list.
+	defaultStatusFetchTimeout = 10 * time.Second
+)
+
+// Status represents the "status" claim of a token.
+type Status struct {
+	// StatusList references the status of the token in a status list.
+	StatusList *StatusListRef `cbor:"status_list,omitempty" json:"status_list,omitempty"`
+}
+
+// StatusListRef references an index in the status list provided by a Status List Token.
+type StatusListRef struct {
+	// Idx is the index of the token in the status list.
+	Idx uint64 `cbor:"idx" json:"idx"`
+	// URI identifies the Status List Token, it is the "sub" claim of the Status List Token.
+	URI string `cbor:"uri" json:"uri"`
+}
+
+// ExtractStatus converts a claim value to a *Status.
+func ExtractStatus(val any) (*Status, error) {
+	if s, ok := val.(*Status); ok {
+		return s, nil
+	}
+
+	data, err := key.MarshalCBOR(val)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Status{}
+	if err = key.UnmarshalCBOR(data, s); err != nil {
+		return nil, err
+	}
+	if s.StatusList != nil && s.StatusList.URI == "" {
+		return nil, errors.New("empty status list uri")
+	}
+	return s, nil
+}
+
+// GetStatus returns the "status" claim as a *Status, or a error.
+// If the claim is not present, it returns (nil, nil).
+func (cm ClaimsMap) GetStatus() (*Status, error) {
+	v, ok := cm[iana.CWTClaimStatus]
+	if !ok {
+		return nil, nil
+	}
+
+	s, err := ExtractStatus(v)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt: ClaimsMap.GetStatus: %w", err)
+	}
+	return s, nil
+}
+
+// StatusList represents the "status_list" claim of a Status List Token,
+// a compressed bitstring with the statuses of the tokens.
+type StatusList struct {
+	// Bits is the number of bits per status, 1, 2, 4 or 8.
+	Bits int `cbor:"bits" json:"bits"`
+	// Lst is the ZLIB compressed bitstring.
+	Lst key.ByteStr `cbor:"lst" json:"lst"`
+	// AggregationURI is the URI to retrieve the list of all Status List Tokens of the issuer.
+	AggregationURI string `cbor:"aggregation_uri,omitempty" json:"aggregation_uri,omitempty"`
+}
+
+// Decompress returns the decompressed bitstring of the status list.
+func (sl *StatusList) Decompress() ([]byte, error) {
+	if err := checkStatusBits(sl.Bits); err != nil {
+		return nil, fmt.Errorf("cose/cwt: StatusList.Decompress: %w", err)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(sl.Lst))
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt: StatusList.Decompress: %w", err)
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(io.LimitReader(zr, maxStatusListSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt: StatusList.Decompress: %w", err)
+	}
+	if len(data) > maxStatusListSize {
+		return nil, fmt.Errorf("cose/cwt: StatusList.Decompress: status list too large, expected <= %d bytes", maxStatusListSize)
+	}
+	return data, nil
+}
+
+// Get returns the status at the index.
+// It decompresses the status list, use a StatusChecker to check the statuses of many tokens.
+func (sl *StatusList) Get(idx uint64) (uint8, error) {
+	data, err := sl.Decompress()
+	if err != nil {
+		return 0, err
+	}
+	return getStatus(data, sl.Bits, idx)
+}
+
+// StatusListBuilder builds the status lists on the issuer side.
+// It is safe for concurrent use.
+type StatusListBuilder struct {
+	mu       sync.RWMutex
+	bits     int
+	statuses []byte
+}
+
+// NewStatusListBuilder creates a StatusListBuilder with the number of bits per status, 1, 2, 4 or 8,
+// and the size of the list. All statuses are StatusValid initially.
+// The size should be large enough to provide herd privacy.
+func NewStatusListBuilder(bits int, size uint64) (*StatusListBuilder, error) {
+	if err := checkStatusBits(bits); err != nil {
+		return nil, fmt.Errorf("cose/cwt: NewStatusListBuilder: %w", err)
+	}
+
+	n := (size*uint64(bits) + 7) / 8
+	if size == 0 || n > maxStatusListSize {
+		return nil, fmt.Errorf("cose/cwt: NewStatusListBuilder: invalid size %d", size)
+	}
+	return &StatusListBuilder{bits: bits, statuses: make([]byte, n)}, nil
+}
+
+// Size returns the number of statuses in the list.
+func (b *StatusListBuilder) Size() uint64 {
+	return uint64(len(b.statuses)) * 8 / uint64(b.bits)
+}
+
+// Set sets the status at the index.
+func (b *StatusListBuilder) Set(idx uint64, status uint8) error {
+	if idx >= b.Size() {
+		return fmt.Errorf("cose/cwt: StatusListBuilder.Set: index %d out of range", idx)
+	}
+	if b.bits < 8 && status >= 1<<b.bits {
+		return fmt.Errorf("cose/cwt: StatusListBuilder.Set: status %d exceeds %d bits", status, b.bits)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pos := idx * uint64(b.bits)
+	shift := pos % 8
+	mask := byte(1<<b.bits-1) << shift
+	b.statuses[pos/8] = b.statuses[pos/8]&^mask | status<<shift
+	return nil
+}
+
+// Get returns the status at the index.
+func (b *StatusListBuilder) Get(idx uint64) (uint8, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return getStatus(b.statuses, b.bits, idx)
+}
+
+// StatusList returns the compressed status list.
+func (b *StatusListBuilder) StatusList() (*StatusList, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var buf bytes.Buffer
+	zw, _ := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	_, err := zw.Write(b.statuses)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt: StatusListBuilder.StatusList: %w", err)
+	}
+	return &StatusList{Bits: b.bits, Lst: buf.Bytes()}, nil
+}
+
+// Claims returns the claims set of a Status List Token with the uri as the "sub" claim,
+// it should be issued by an Issuer. The ttl is the "ttl" claim, it is omitted if it is zero.
+func (b *StatusListBuilder) Claims(uri string, issuedAt time.Time, ttl time.Duration) (ClaimsMap, error) {
+	sl, err := b.StatusList()
+	if err != nil {
+		return nil, err
+	}
+
+	cm := ClaimsMap{
+		iana.CWTClaimSub:        uri,
+		iana.CWTClaimIat:        issuedAt.Unix(),
+		iana.CWTClaimStatusList: sl,
+	}
+	if ttl > 0 {
+		cm[iana.CWTClaimTTL] = uint64(ttl / time.Second)
+	}
+	return cm, nil
+}
+
+// StatusListFetcher fetches the Status List Token for the uri.
+type StatusListFetcher interface {
+	Fetch(ctx context.Context, uri string) ([]byte, error)
+}
+
+// MemoryFetcher is a StatusListFetcher with the Status List Tokens in memory, useful for tests.
+// It is safe for concurrent use.
+type MemoryFetcher struct {
+	mu     sync.RWMutex
+	tokens map[string][]byte
+}
+
+// NewMemoryFetcher creates a new MemoryFetcher.
+func NewMemoryFetcher() *MemoryFetcher {
+	return &MemoryFetcher{tokens: make(map[string][]byte)}
+}
+
+// Set sets the Status List Token for the uri.
+func (f *MemoryFetcher) Set(uri string, token []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens[uri] = token
+}
+
+// Fetch implements the StatusListFetcher interface.
+func (f *MemoryFetcher) Fetch(ctx context.Context, uri string) ([]byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	token, ok := f.tokens[uri]
+	if !ok {
+		return nil, fmt.Errorf("cose/cwt: MemoryFetcher.Fetch: status list %q not found", uri)
+	}
+	return token, nil
+}
+
+// FileFetcher is a StatusListFetcher that reads the Status List Tokens from the local files.
+// The uri should be a "file" URI, such as "file:///var/lib/status/1.cwt".
+type FileFetcher struct{}
+
+// Fetch implements the StatusListFetcher interface.
+func (FileFetcher) Fetch(ctx context.Context, uri string) ([]byte, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt: FileFetcher.Fetch: %w", err)
+	}
+	if u.Scheme != "file" || u.Path == "" {
+		return nil, fmt.Errorf("cose/cwt: FileFetcher.Fetch: invalid file uri %q", uri)
+	}
+
+	data, err := os.ReadFile(u.Path)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt: FileFetcher.Fetch: %w", err)
+	}
+	return data, nil
+}
+
+// StatusCheckerOpts defines the options for StatusCheckers.
+type StatusCheckerOpts struct {
+	// Fetcher fetches the Status List Tokens. It is required.
+	Fetcher StatusListFetcher
+	// Verifier verifies the Status List Tokens. It is required.
+	Verifier *Verifier
+	// CacheTTL is how long the status lists without the "ttl" claim are cached,
+	// and the maximum time the status lists with the "ttl" claim are cached.
+	// Default is 5 minutes.
+	CacheTTL time.Duration
+	// Timeout limits the time to fetch a status list, also when the context has no deadline,
+	// such as the checks of a Validator. Default is 10 seconds.
+	Timeout time.Duration
+	// Clock provides the current time for the cache, it is SystemClock if nil.
+	Clock Clock
+}
+
+// StatusChecker checks the statuses of the tokens on the verifier side.
+// The status lists are cached until the TTL expired. It is safe for concurrent use.
+type StatusChecker struct {
+	opts  StatusCheckerOpts
+	mu    sync.Mutex
+	cache map[string]*statusListEntry
+}
+
+type statusListEntry struct {
+	bits     int
+	statuses []byte
+	expires  time.Time
+}
+
+// NewStatusChecker creates a new StatusChecker.
+func NewStatusChecker(opts *StatusCheckerOpts) (*StatusChecker, error) {
+	if opts == nil {
+		return nil, errors.New("cose/cwt: NewStatusChecker: nil StatusCheckerOpts")
+	}
+	if opts.Fetcher == nil {
+		return nil, errors.New("cose/cwt: NewStatusChecker: nil Fetcher")
+	}
+	if opts.Verifier == nil {
+		return nil, errors.New("cose/cwt: NewStatusChecker: nil Verifier")
+	}
+
+	c := &StatusChecker{opts: *opts, cache: make(map[string]*statusListEntry)}
+	if c.opts.CacheTTL <= 0 {
+		c.opts.CacheTTL = defaultStatusCacheTTL
+	}
+	if c.opts.Timeout <= 0 {
+		c.opts.Timeout = defaultStatusFetchTimeout
+	}
+	if c.opts.Clock == nil {
+		c.opts.Clock = SystemClock
+	}
+	return c, nil
+}
+
+// Status returns the status referenced by the StatusListRef.
+func (c *StatusChecker) Status(ctx context.Context, ref *StatusListRef) (uint8, error) {
+	if ref == nil {
+		return 0, errors.New("cose/cwt: StatusChecker.Status: nil StatusListRef")
+	}
+
+	e, err := c.load(ctx, ref.URI)
+	if err != nil {
+		return 0, fmt.Errorf("cose/cwt: StatusChecker.Status: %w", err)
+	}
+	status, err := getStatus(e.statuses, e.bits, ref.Idx)
+	if err != nil {
+		return 0, fmt.Errorf("cose/cwt: StatusChecker.Status: %w", err)
+	}
+	return status, nil
+}
+
+// Check returns an error if the token with the claims is not valid in the status list.
+// It returns nil if the token doesn't have a status list reference.
+func (c *StatusChecker) Check(ctx context.Context, claims ClaimsMap) error {
+	s, err := claims.GetStatus()
+	if err != nil {
+		return err
+	}
+	if s == nil || s.StatusList == nil {
+		return nil
+	}
+
+	return c.check(ctx, s.StatusList)
+}
+
+// Purge removes the cached status list for the uri, the next check fetches it again.
+func (c *StatusChecker) Purge(uri string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.cache, uri)
+}
+
+func (c *StatusChecker) check(ctx context.Context, ref *StatusListRef) error {
+	status, err := c.Status(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case StatusValid:
+		return nil
+	case StatusInvalid:
+		return errors.New("token has been revoked")
+	case StatusSuspended:
+		return errors.New("token has been suspended")
+	default:
+		return fmt.Errorf("token has status 0x%02x", status)
+	}
+}
+
+func (c *StatusChecker) load(ctx context.Context, uri string) (*statusListEntry, error) {
+	now := c.opts.Clock.Now()
+
+	c.mu.Lock()
+	e, ok := c.cache[uri]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+	data, err := c.opts.Fetcher.Fetch(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := c.opts.Verifier.Verify(data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Status List Token, %w", err)
+	}
+
+	sub, _ := t.Claims.GetString(iana.CWTClaimSub)
+	if sub != uri {
+		return nil, fmt.Errorf("status list uri mismatch, expected %q, got %q", uri, sub)
+	}
+
+	sl := &StatusList{}
+	if err = convertClaim(t.Claims[iana.CWTClaimStatusList], sl); err != nil {
+		return nil, fmt.Errorf("invalid status_list claim, %w", err)
+	}
+
+	e = &statusListEntry{bits: sl.Bits}
+	if e.statuses, err = sl.Decompress(); err != nil {
+		return nil, err
+	}
+
+	ttl := c.opts.CacheTTL
+	if v, err := t.Claims.GetUint64(iana.CWTClaimTTL); err == nil && v > 0 && v < math.MaxInt64/uint64(time.Second) {
+		if d := time.Duration(v) * time.Second; d < ttl {
+			ttl = d
+		}
+	}
+	e.expires = now.Add(ttl)
+	if exp, err := t.Claims.GetUint64(iana.CWTClaimExp); err == nil && exp > 0 && toTime(exp).Before(e.expires) {
+		e.expires = toTime(exp)
+	}
+
+	c.mu.Lock()
+	c.cache[uri] = e
+	c.mu.Unlock()
+	return e, nil
+}
+
+// statusRules returns the Rules for the "status" claim according to the options provided.
+// The Validator has no context, the fetching is limited by StatusCheckerOpts.Timeout.
+func (v *Validator) statusRules() []*Rule {
+	status := NewRule(iana.CWTClaimStatus, ExtractStatus, func(s *Status) error {
+		if v.opts.StatusChecker != nil && s.StatusList != nil {
+			return v.opts.StatusChecker.check(context.Background(), s.StatusList)
+		}
+		return nil
+	})
+	return []*Rule{status}
+}
+
+// convertClaim converts a claim value to the typed value through CBOR.
+func convertClaim(val, v any) error {
+	if val == nil {
+		return errors.New("missing claim")
+	}
+
+	data, err := key.MarshalCBOR(val)
+	if err != nil {
+		return err
+	}
+	return key.UnmarshalCBOR(data, v)
+}
+
+func getStatus(statuses []byte, bits int, idx uint64) (uint8, error) {
+	if idx >= uint64(len(statuses))*8/uint64(bits) {
+		return 0, fmt.Errorf("index %d out of range", idx)
+	}
+
+	pos := idx * uint64(bits)
+	return statuses[pos/8] >> (pos % 8) & byte(1<<bits-1), nil
+}
+
+func checkStatusBits(bits int) error {
+	switch bits {
+	case 1, 2, 4, 8:
+		return nil
+	default:
+		return fmt.Errorf("invalid bits %d, expected 1, 2, 4 or 8", bits)
+	}
+}
@@ -0,0 +1,393 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/ed25519"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusListBuilder(t *testing.T) {
+	t.Run("NewStatusListBuilder", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := NewStatusListBuilder(3, 16)
+		assert.ErrorContains(err, "invalid bits 3")
+		_, err = NewStatusListBuilder(1, 0)
+		assert.ErrorContains(err, "invalid size 0")
+		_, err = NewStatusListBuilder(8, maxStatusListSize+1)
+		assert.ErrorContains(err, "invalid size")
+
+		b, err := NewStatusListBuilder(2, 10)
+		require.NoError(t, err)
+		assert.Equal(uint64(12), b.Size())
+	})
+
+	t.Run("draft example", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		// Reference https://datatracker.ietf.org/doc/html/draft-ietf-oauth-status-list#section-4.1
+		b, err := NewStatusListBuilder(1, 16)
+		require.NoError(err)
+		for i, s := range []uint8{1, 0, 0, 1, 1, 1, 0, 1, 1, 1, 0, 0, 0, 1, 0, 1} {
+			require.NoError(b.Set(uint64(i), s))
+		}
+		assert.Equal([]byte{0xb9, 0xa3}, b.statuses)
+
+		sl, err := b.StatusList()
+		require.NoError(err)
+		data, err := sl.Decompress()
+		require.NoError(err)
+		assert.Equal([]byte{0xb9, 0xa3}, data)
+
+		status, err := sl.Get(3)
+		require.NoError(err)
+		assert.Equal(StatusInvalid, status)
+		status, err = sl.Get(10)
+		require.NoError(err)
+		assert.Equal(StatusValid, status)
+		_, err = sl.Get(16)
+		assert.ErrorContains(err, "index 16 out of range")
+
+		b, err = NewStatusListBuilder(2, 12)
+		require.NoError(err)
+		for i, s := range []uint8{1, 2, 0, 3, 0, 1, 0, 1, 1, 2, 3, 3} {
+			require.NoError(b.Set(uint64(i), s))
+		}
+		assert.Equal([]byte{0xc9, 0x44, 0xf9}, b.statuses)
+
+		sl, err = b.StatusList()
+		require.NoError(err)
+		data, err = key.MarshalCBOR(sl)
+		require.NoError(err)
+
+		var sl2 StatusList
+		require.NoError(key.UnmarshalCBOR(data, &sl2))
+		assert.Equal(*sl, sl2)
+		status, err = sl2.Get(1)
+		require.NoError(err)
+		assert.Equal(StatusSuspended, status)
+	})
+
+	t.Run("Set and Get", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		b, err := NewStatusListBuilder(8, 4)
+		require.NoError(err)
+		require.NoError(b.Set(2, 0xff))
+		status, err := b.Get(2)
+		require.NoError(err)
+		assert.Equal(uint8(0xff), status)
+
+		b, err = NewStatusListBuilder(4, 4)
+		require.NoError(err)
+		assert.ErrorContains(b.Set(4, StatusInvalid), "index 4 out of range")
+		assert.ErrorContains(b.Set(0, 0x10), "status 16 exceeds 4 bits")
+
+		require.NoError(b.Set(1, 0x0a))
+		require.NoError(b.Set(0, 0x05))
+		require.NoError(b.Set(1, StatusSuspended))
+		status, err = b.Get(0)
+		require.NoError(err)
+		assert.Equal(uint8(0x05), status)
+		status, err = b.Get(1)
+		require.NoError(err)
+		assert.Equal(StatusSuspended, status)
+		_, err = b.Get(4)
+		assert.ErrorContains(err, "out of range")
+	})
+
+	t.Run("StatusList errors", func(t *testing.T) {
+		assert := assert.New(t)
+
+		sl := &StatusList{Bits: 3}
+		_, err := sl.Get(0)
+		assert.ErrorContains(err, "invalid bits 3")
+
+		sl = &StatusList{Bits: 1, Lst: []byte{1, 2, 3}}
+		_, err = sl.Decompress()
+		assert.ErrorContains(err, "cose/cwt: StatusList.Decompress")
+	})
+}
+
+func TestStatus(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	claims := Claims{
+		Issuer: "ldc:ca",
+		Status: &Status{StatusList: &StatusListRef{Idx: 1, URI: "https://example.com/statuslists/1"}},
+	}
+	data, err := key.MarshalCBOR(claims)
+	require.NoError(err)
+	assert.Equal(key.HexBytesify("a201666c64633a636119ffffa16b7374617475735f6c697374a263696478016375726978216874747073"+
+		"3a2f2f6578616d706c652e636f6d2f7374617475736c697374732f31"), data)
+
+	var cm ClaimsMap
+	require.NoError(key.UnmarshalCBOR(data, &cm))
+	s, err := cm.GetStatus()
+	require.NoError(err)
+	assert.Equal(claims.Status, s)
+
+	s, err = ClaimsMap{}.GetStatus()
+	require.NoError(err)
+	assert.Nil(s)
+
+	_, err = ClaimsMap{iana.CWTClaimStatus: "revoked"}.GetStatus()
+	assert.ErrorContains(err, "cose/cwt: ClaimsMap.GetStatus")
+
+	_, err = ClaimsMap{iana.CWTClaimStatus: map[string]any{"status_list": map[string]any{"idx": 1}}}.GetStatus()
+	assert.ErrorContains(err, "empty status list uri")
+}
+
+func TestStatusChecker(t *testing.T) {
+	const uri = "https://example.com/statuslists/1"
+	now := time.Unix(1700000000, 0)
+
+	k, err := ed25519.GenerateKey()
+	require.NoError(t, err)
+	signer, err := k.Signer()
+	require.NoError(t, err)
+	verifier, err := k.Verifier()
+	require.NoError(t, err)
+	issuer, err := NewSign1Issuer(signer)
+	require.NoError(t, err)
+
+	slValidator, err := NewValidator(&ValidatorOpts{AllowMissingExpiration: true, FixedNow: now})
+	require.NoError(t, err)
+	slVerifier, err := NewVerifier(&VerifierOpts{Verifiers: key.Verifiers{verifier}, Validator: slValidator})
+	require.NoError(t, err)
+
+	b, err := NewStatusListBuilder(2, 1024)
+	require.NoError(t, err)
+	require.NoError(t, b.Set(3, StatusInvalid))
+	require.NoError(t, b.Set(4, StatusSuspended))
+	require.NoError(t, b.Set(5, 0x03))
+
+	issueStatusList := func(uri string, ttl time.Duration) []byte {
+		cm, err := b.Claims(uri, now, ttl)
+		require.NoError(t, err)
+		token, err := issuer.Issue(cm, nil)
+		require.NoError(t, err)
+		return token
+	}
+
+	t.Run("NewStatusChecker", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := NewStatusChecker(nil)
+		assert.ErrorContains(err, "nil StatusCheckerOpts")
+		_, err = NewStatusChecker(&StatusCheckerOpts{Verifier: slVerifier})
+		assert.ErrorContains(err, "nil Fetcher")
+		_, err = NewStatusChecker(&StatusCheckerOpts{Fetcher: NewMemoryFetcher()})
+		assert.ErrorContains(err, "nil Verifier")
+
+		c, err := NewStatusChecker(&StatusCheckerOpts{Fetcher: NewMemoryFetcher(), Verifier: slVerifier})
+		require.NoError(t, err)
+		assert.Equal(defaultStatusCacheTTL, c.opts.CacheTTL)
+		assert.Equal(defaultStatusFetchTimeout, c.opts.Timeout)
+	})
+
+	t.Run("Check", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		fetcher := NewMemoryFetcher()
+		fetcher.Set(uri, issueStatusList(uri, 0))
+		c, err := NewStatusChecker(&StatusCheckerOpts{
+			Fetcher:  fetcher,
+			Verifier: slVerifier,
+			Clock:    FixedClock(now),
+		})
+		require.NoError(err)
+
+		ctx := context.Background()
+		for idx, expected := range []string{"", "", "", "token has been revoked", "token has been suspended", "token has status 0x03"} {
+			cm := ClaimsMap{iana.CWTClaimStatus: &Status{StatusList: &StatusListRef{Idx: uint64(idx), URI: uri}}}
+			err := c.Check(ctx, cm)
+			if expected == "" {
+				assert.NoError(err)
+			} else {
+				assert.EqualError(err, expected)
+			}
+		}
+
+		assert.NoError(c.Check(ctx, ClaimsMap{}))
+		assert.NoError(c.Check(ctx, ClaimsMap{iana.CWTClaimStatus: map[string]any{}}))
+		assert.ErrorContains(c.Check(ctx, ClaimsMap{iana.CWTClaimStatus: 1}), "cose/cwt: ClaimsMap.GetStatus")
+
+		_, err = c.Status(ctx, nil)
+		assert.ErrorContains(err, "nil StatusListRef")
+		_, err = c.Status(ctx, &StatusListRef{Idx: 1024, URI: uri})
+		assert.ErrorContains(err, "index 1024 out of range")
+		_, err = c.Status(ctx, &StatusListRef{Idx: 1, URI: "https://example.com/statuslists/2"})
+		assert.ErrorContains(err, `status list "https://example.com/statuslists/2" not found`)
+
+		fetcher.Set("https://example.com/statuslists/3", issueStatusList(uri, 0))
+		_, err = c.Status(ctx, &StatusListRef{Idx: 1, URI: "https://example.com/statuslists/3"})
+		assert.ErrorContains(err, "status list uri mismatch")
+
+		fetcher.Set("https://example.com/statuslists/4", []byte{0xa0})
+		_, err = c.Status(ctx, &StatusListRef{Idx: 1, URI: "https://example.com/statuslists/4"})
+		assert.ErrorContains(err, "invalid Status List Token")
+
+		token, err := issuer.Issue(ClaimsMap{iana.CWTClaimSub: "https://example.com/statuslists/5"}, nil)
+		require.NoError(err)
+		fetcher.Set("https://example.com/statuslists/5", token)
+		_, err = c.Status(ctx, &StatusListRef{Idx: 1, URI: "https://example.com/statuslists/5"})
+		assert.ErrorContains(err, "invalid status_list claim, missing claim")
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		current := now
+		fetcher := NewMemoryFetcher()
+		fetcher.Set(uri, issueStatusList(uri, time.Minute))
+		c, err := NewStatusChecker(&StatusCheckerOpts{
+			Fetcher:  fetcher,
+			Verifier: slVerifier,
+			CacheTTL: time.Hour,
+			Clock:    ClockFunc(func() time.Time { return current }),
+		})
+		require.NoError(err)
+
+		ctx := context.Background()
+		ref := &StatusListRef{Idx: 7, URI: uri}
+		status, err := c.Status(ctx, ref)
+		require.NoError(err)
+		assert.Equal(StatusValid, status)
+
+		// the token is revoked, but the cached status list is used until the "ttl" expired.
+		require.NoError(b.Set(7, StatusInvalid))
+		fetcher.Set(uri, issueStatusList(uri, time.Minute))
+		current = now.Add(59 * time.Second)
+		status, err = c.Status(ctx, ref)
+		require.NoError(err)
+		assert.Equal(StatusValid, status)
+
+		current = now.Add(time.Minute)
+		status, err = c.Status(ctx, ref)
+		require.NoError(err)
+		assert.Equal(StatusInvalid, status)
+
+		// Purge removes the cached status list.
+		require.NoError(b.Set(7, StatusValid))
+		fetcher.Set(uri, issueStatusList(uri, 0))
+		status, err = c.Status(ctx, ref)
+		require.NoError(err)
+		assert.Equal(StatusInvalid, status)
+		c.Purge(uri)
+		status, err = c.Status(ctx, ref)
+		require.NoError(err)
+		assert.Equal(StatusValid, status)
+
+		// the status list without the "ttl" claim is cached for CacheTTL.
+		require.NoError(b.Set(7, StatusSuspended))
+		fetcher.Set(uri, issueStatusList(uri, 0))
+		current = now.Add(time.Minute + 59*time.Minute)
+		status, err = c.Status(ctx, ref)
+		require.NoError(err)
+		assert.Equal(StatusValid, status)
+		current = now.Add(2 * time.Hour)
+		status, err = c.Status(ctx, ref)
+		require.NoError(err)
+		assert.Equal(StatusSuspended, status)
+		require.NoError(b.Set(7, StatusValid))
+	})
+
+	t.Run("FileFetcher", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "1.cwt")
+		fileURI := "file://" + filepath.ToSlash(path)
+		require.NoError(os.WriteFile(path, issueStatusList(fileURI, 0), 0600))
+
+		c, err := NewStatusChecker(&StatusCheckerOpts{Fetcher: FileFetcher{}, Verifier: slVerifier})
+		require.NoError(err)
+		status, err := c.Status(context.Background(), &StatusListRef{Idx: 3, URI: fileURI})
+		require.NoError(err)
+		assert.Equal(StatusInvalid, status)
+
+		_, err = FileFetcher{}.Fetch(context.Background(), "https://example.com/statuslists/1")
+		assert.ErrorContains(err, "invalid file uri")
+		_, err = FileFetcher{}.Fetch(context.Background(), "file://"+filepath.ToSlash(path)+".missing")
+		assert.ErrorContains(err, "cose/cwt: FileFetcher.Fetch")
+		_, err = FileFetcher{}.Fetch(context.Background(), ":")
+		assert.ErrorContains(err, "cose/cwt: FileFetcher.Fetch")
+	})
+
+	t.Run("Validator", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		fetcher := NewMemoryFetcher()
+		fetcher.Set(uri, issueStatusList(uri, 0))
+		c, err := NewStatusChecker(&StatusCheckerOpts{Fetcher: fetcher, Verifier: slVerifier})
+		require.NoError(err)
+
+		va, err := NewValidator(&ValidatorOpts{
+			ExpectedIssuer: "ldc:ca",
+			StatusChecker:  c,
+			FixedNow:       now,
+		})
+		require.NoError(err)
+
+		claims := &Claims{
+			Issuer:     "ldc:ca",
+			Expiration: uint64(now.Add(time.Hour).Unix()),
+			Status:     &Status{StatusList: &StatusListRef{Idx: 1, URI: uri}},
+		}
+		assert.NoError(va.Validate(claims))
+
+		claims.Status.StatusList.Idx = 3
+		err = va.Validate(claims)
+		assert.ErrorContains(err, "token has been revoked")
+		var ce *ClaimError
+		require.True(errors.As(err, &ce))
+		assert.Equal(iana.CWTClaimStatus, ce.Claim)
+
+		va, err = NewValidator(&ValidatorOpts{ExpectedIssuer: "ldc:ca", FixedNow: now})
+		require.NoError(err)
+		assert.NoError(va.Validate(claims))
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{
+			iana.CWTClaimIss:    "ldc:ca",
+			iana.CWTClaimExp:    claims.Expiration,
+			iana.CWTClaimStatus: "revoked",
+		}), "status")
+
+		c, err = NewStatusChecker(&StatusCheckerOpts{
+			Fetcher:  blockingFetcher{},
+			Verifier: slVerifier,
+			Timeout:  10 * time.Millisecond,
+		})
+		require.NoError(err)
+		va, err = NewValidator(&ValidatorOpts{ExpectedIssuer: "ldc:ca", StatusChecker: c, FixedNow: now})
+		require.NoError(err)
+		err = va.Validate(claims)
+		assert.ErrorIs(err, context.DeadlineExceeded)
+	})
+}
+
+// blockingFetcher blocks until the context is done.
+type blockingFetcher struct{}
+
+func (blockingFetcher) Fetch(ctx context.Context, uri string) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
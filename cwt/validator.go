@@ -4,32 +4,94 @@
 package cwt
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"time"
 
 	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
 )
 
 const (
 	cwtMaxClockSkewMinutes = 10
+	// cwtMaxLeeway is the maximum leeway of the "exp", "nbf" and "iat" claims,
+	// the leeways can be larger than the clock skew for the devices with drifting clocks.
+	cwtMaxLeeway = 24 * time.Hour
 )
 
 // ValidatorOpts defines validation options for CWT validators.
 type ValidatorOpts struct {
 	ExpectedIssuer   string
 	ExpectedAudience string
+	// ExpectedAudiences is the set of acceptable audiences in addition to ExpectedAudience.
+	// The token is accepted if one of its audiences is acceptable.
+	ExpectedAudiences []string
 
 	AllowMissingExpiration bool
 	ExpectIssuedInThePast  bool
 
+	// RequireConfirmation rejects the bearer tokens without the "cnf" claim,
+	// only the proof-of-possession tokens are accepted.
+	RequireConfirmation bool
+
+	// ClockSkew is the default leeway of the "exp", "nbf" and "iat" claims, at most 10 minutes.
 	ClockSkew time.Duration
-	FixedNow  time.Time
+	// FixedNow is the current time for the validation, it is ignored if Clock is set.
+	FixedNow time.Time
+	// Clock provides the current time for the validation.
+	// If both Clock and FixedNow are not set, SystemClock is used.
+	Clock Clock
+
+	// ExpLeeway is the leeway to accept the expired tokens. Default is ClockSkew, at most 24 hours.
+	ExpLeeway time.Duration
+	// NbfLeeway is the leeway to accept the tokens before the "nbf" claim. Default is ClockSkew, at most 24 hours.
+	NbfLeeway time.Duration
+	// IatLeeway is the leeway of the "iat" claim for ExpectIssuedInThePast and MaxAge.
+	// Default is ClockSkew, at most 24 hours.
+	IatLeeway time.Duration
+	// MaxAge rejects the tokens that were issued more than MaxAge ago according to the "iat" claim.
+	// If it is not zero, the "iat" claim is required.
+	MaxAge time.Duration
+
+	// Rand is the source of randomness for the CAT "catpor" claim.
+	// If it is nil, crypto/rand.Reader is used.
+	Rand io.Reader
+
+	// ReplayStore records the used tokens for the CAT "catreplay" claim.
+	// It is required if tokens with replay prohibited or reuse detection are validated.
+	ReplayStore ReplayStore
+	// OnReuseDetected is called when a token with reuse detection is used more than once.
+	OnReuseDetected func(claims ClaimsMap)
+
+	// UnknownCATClaims defines how the CAT claims that are not supported are handled.
+	// The default policy is IgnoreUnknownClaims.
+	UnknownCATClaims UnknownClaimPolicy
+
+	// ExpectedACEProfile is the expected "ace_profile" claim, such as iana.ACEProfileCoapOSCORE.
+	// If it is not zero, the claim is required.
+	ExpectedACEProfile int
+	// VerifyCNonce verifies the "cnonce" claim, the nonce that the resource server sent to the client.
+	// If it is not nil, the claim is required.
+	VerifyCNonce func(cnonce []byte) error
+	// ExiTracker tracks the tokens with the "exi" claim.
+	// It is required if tokens with the "exi" claim are validated.
+	ExiTracker *ExiTracker
+
+	// StatusChecker checks the "status" claim with the referenced status list,
+	// the revoked and suspended tokens are rejected.
+	// If it is nil, the "status" claim is only checked to be well-formed.
+	StatusChecker *StatusChecker
+
+	// Rules are the additional Rules to validate, such as the Rules for private claims.
+	Rules []*Rule
 }
 
 // Validator defines how CBOR Web Tokens (CWT) should be validated.
 type Validator struct {
-	opts ValidatorOpts
+	opts  ValidatorOpts
+	rules *RuleSet
 }
 
 // NewValidator creates a new CWT Validator.
@@ -42,129 +104,231 @@ func NewValidator(opts *ValidatorOpts) (*Validator, error) {
 		return nil, fmt.Errorf("cose/cwt: NewValidator: clock skew too large, expected <= %d minutes, got %f",
 			cwtMaxClockSkewMinutes, opts.ClockSkew.Minutes())
 	}
-	return &Validator{
+	for _, l := range []struct {
+		name   string
+		leeway time.Duration
+	}{
+		{"exp", opts.ExpLeeway},
+		{"nbf", opts.NbfLeeway},
+		{"iat", opts.IatLeeway},
+	} {
+		if l.leeway < 0 || l.leeway > cwtMaxLeeway {
+			return nil, fmt.Errorf("cose/cwt: NewValidator: invalid %s leeway, expected [0, %v], got %v",
+				l.name, cwtMaxLeeway, l.leeway)
+		}
+	}
+	if opts.MaxAge < 0 {
+		return nil, fmt.Errorf("cose/cwt: NewValidator: invalid max age %v", opts.MaxAge)
+	}
+
+	v := &Validator{
 		opts: *opts,
-	}, nil
+	}
+
+	var err error
+	if v.rules, err = NewRuleSet(v.registeredRules()...); err != nil {
+		return nil, err
+	}
+	if err = v.rules.Add(v.aceRules()...); err != nil {
+		return nil, err
+	}
+	if err = v.rules.Add(v.statusRules()...); err != nil {
+		return nil, err
+	}
+	if err = v.rules.Add(v.catRules()...); err != nil {
+		return nil, err
+	}
+	if err = v.rules.Add(opts.Rules...); err != nil {
+		return nil, fmt.Errorf("cose/cwt: NewValidator: %w", err)
+	}
+	return v, nil
 }
 
 // Validate validates a *Claims according to the options provided.
+// It returns a *ValidationError that collects all violations, or nil.
 func (v *Validator) Validate(claims *Claims) error {
 	if claims == nil {
 		return fmt.Errorf("cose/cwt: Validator.Validate: nil Claims")
 	}
 
-	now := time.Now()
-	if !v.opts.FixedNow.IsZero() {
-		now = v.opts.FixedNow
+	data, err := key.MarshalCBOR(claims)
+	if err != nil {
+		return fmt.Errorf("cose/cwt: Validator.Validate: %w", err)
 	}
 
-	if claims.Expiration == 0 && !v.opts.AllowMissingExpiration {
-		return fmt.Errorf("cose/cwt: Validator.Validate: token doesn't have an expiration set")
+	var cm ClaimsMap
+	if err = cm.UnmarshalCBOR(data); err != nil {
+		return fmt.Errorf("cose/cwt: Validator.Validate: %w", err)
 	}
-
-	if claims.Expiration > 0 {
-		if !toTime(claims.Expiration).After(now.Add(-v.opts.ClockSkew)) {
-			return fmt.Errorf("cose/cwt: Validator.Validate: token has expired")
-		}
+	if err = v.rules.Validate(cm); err != nil {
+		return err
 	}
+	v.commit(cm)
+	return nil
+}
 
-	if claims.NotBefore > 0 {
-		if t := toTime(claims.NotBefore); t.IsZero() || t.After(now.Add(v.opts.ClockSkew)) {
-			return fmt.Errorf("cose/cwt: Validator.Validate: token cannot be used yet")
-		}
+// ValidateMap validates a ClaimsMap according to the options provided.
+// It returns a *ValidationError that collects all violations, or nil.
+func (v *Validator) ValidateMap(claims ClaimsMap) error {
+	if claims == nil {
+		return fmt.Errorf("cose/cwt: Validator.Validate: nil ClaimsMap")
 	}
 
-	if claims.IssuedAt > 0 && v.opts.ExpectIssuedInThePast {
-		if t := toTime(claims.IssuedAt); t.IsZero() || t.After(now.Add(v.opts.ClockSkew)) {
-			return fmt.Errorf("cose/cwt: Validator.Validate: token has an invalid iat claim in the future")
-		}
+	if err := v.rules.Validate(claims); err != nil {
+		return err
 	}
+	v.commit(claims)
+	return nil
+}
 
-	if v.opts.ExpectedIssuer != "" && v.opts.ExpectedIssuer != claims.Issuer {
-		return fmt.Errorf("cose/cwt: Validator.Validate: issuer mismatch, expected %q, got %q",
-			v.opts.ExpectedIssuer, claims.Issuer)
-	}
+// commit records the state of the token that has passed all the rules, such as the "exi" claim,
+// so that a rejected token does not change the state of the Validator.
+func (v *Validator) commit(claims ClaimsMap) {
+	v.recordExi(claims)
+}
 
-	if v.opts.ExpectedAudience != "" && v.opts.ExpectedAudience != claims.Audience {
-		return fmt.Errorf("cose/cwt: Validator.Validate: audience mismatch, expected %q, got %q",
-			v.opts.ExpectedAudience, claims.Audience)
-	}
-	return nil
+// ValidationResult is the result of a successful validation.
+type ValidationResult struct {
+	// ValidatedAt is the time of the validation.
+	ValidatedAt time.Time
+	// ExpiresAt is the time when the token expires, the earlier of the "exp" claim and the MaxAge policy.
+	// It is zero if the token does not expire.
+	ExpiresAt time.Time
+	// Remaining is the remaining lifetime of the token, it can be used to schedule a refresh.
+	// It is zero if the token does not expire, or it has expired but is accepted within the leeway.
+	Remaining time.Duration
 }
 
-// ValidateMap validates a ClaimsMap according to the options provided.
-func (v *Validator) ValidateMap(claims ClaimsMap) error {
-	if claims == nil {
-		return fmt.Errorf("cose/cwt: Validator.Validate: nil ClaimsMap")
+// ValidateMapWithResult validates a ClaimsMap as ValidateMap, and returns the *ValidationResult
+// with the remaining lifetime of the token.
+func (v *Validator) ValidateMapWithResult(claims ClaimsMap) (*ValidationResult, error) {
+	now := v.now()
+	if err := v.ValidateMap(claims); err != nil {
+		return nil, err
 	}
 
-	now := time.Now()
-	if !v.opts.FixedNow.IsZero() {
-		now = v.opts.FixedNow
+	r := &ValidationResult{ValidatedAt: now}
+	if exp, err := ExtractUint64(claims[iana.CWTClaimExp]); err == nil && claims.Has(iana.CWTClaimExp) {
+		r.ExpiresAt = toTime(exp)
+	}
+	if iat, err := ExtractUint64(claims[iana.CWTClaimIat]); err == nil && iat > 0 && v.opts.MaxAge > 0 {
+		if t := toTime(iat).Add(v.opts.MaxAge); r.ExpiresAt.IsZero() || t.Before(r.ExpiresAt) {
+			r.ExpiresAt = t
+		}
 	}
+	if !r.ExpiresAt.IsZero() && r.ExpiresAt.After(now) {
+		r.Remaining = r.ExpiresAt.Sub(now)
+	}
+	return r, nil
+}
+
+func (v *Validator) now() time.Time {
+	switch {
+	case v.opts.Clock != nil:
+		return v.opts.Clock.Now()
+	case !v.opts.FixedNow.IsZero():
+		return v.opts.FixedNow
+	default:
+		return SystemClock.Now()
+	}
+}
 
-	if !claims.Has(iana.CWTClaimExp) && !v.opts.AllowMissingExpiration {
-		return fmt.Errorf("cose/cwt: Validator.Validate: token doesn't have an expiration set")
+// leeway returns the leeway of a time-based claim, the ClockSkew is used if it is zero.
+func (v *Validator) leeway(d time.Duration) time.Duration {
+	if d > 0 {
+		return d
 	}
+	return v.opts.ClockSkew
+}
 
-	if claims.Has(iana.CWTClaimExp) {
-		exp, err := claims.GetUint64(iana.CWTClaimExp)
-		if err != nil {
-			return fmt.Errorf("cose/cwt: Validator.Validate: token has an invalid exp claim, %w", err)
+// registeredRules returns the Rules for the registered claims in RFC8392 according to the options provided.
+func (v *Validator) registeredRules() []*Rule {
+	exp := NewRule(iana.CWTClaimExp, ExtractUint64, func(exp uint64) error {
+		if !toTime(exp).After(v.now().Add(-v.leeway(v.opts.ExpLeeway))) {
+			return errors.New("token has expired")
 		}
+		return nil
+	})
+	exp.Required = !v.opts.AllowMissingExpiration
+	exp.MissingErr = errors.New("token doesn't have an expiration set")
 
-		if !toTime(exp).After(now.Add(-v.opts.ClockSkew)) {
-			return fmt.Errorf("cose/cwt: Validator.Validate: token has expired")
+	nbf := NewRule(iana.CWTClaimNbf, ExtractUint64, func(nbf uint64) error {
+		if t := toTime(nbf); t.IsZero() || t.After(v.now().Add(v.leeway(v.opts.NbfLeeway))) {
+			return errors.New("token cannot be used yet")
 		}
-	}
+		return nil
+	})
 
-	if claims.Has(iana.CWTClaimNbf) {
-		nbf, err := claims.GetUint64(iana.CWTClaimNbf)
-		if err != nil {
-			return fmt.Errorf("cose/cwt: Validator.Validate: token has an invalid nbf claim, %w", err)
+	iat := NewRule(iana.CWTClaimIat, ExtractUint64, func(iat uint64) error {
+		now, leeway := v.now(), v.leeway(v.opts.IatLeeway)
+		if iat > 0 && v.opts.ExpectIssuedInThePast {
+			if t := toTime(iat); t.IsZero() || t.After(now.Add(leeway)) {
+				return errors.New("token has an invalid iat claim in the future")
+			}
+		}
+		if v.opts.MaxAge > 0 {
+			if t := toTime(iat); t.IsZero() || now.Sub(t) > v.opts.MaxAge+leeway {
+				return errors.New("token is too old")
+			}
 		}
-		if t := toTime(nbf); t.IsZero() || t.After(now.Add(v.opts.ClockSkew)) {
-			return fmt.Errorf("cose/cwt: Validator.Validate: token cannot be used yet")
+		return nil
+	})
+	iat.Required = v.opts.MaxAge > 0
+	iat.MissingErr = errors.New("token doesn't have an iat claim")
+
+	iss := NewRule(iana.CWTClaimIss, ExtractString, func(iss string) error {
+		if v.opts.ExpectedIssuer != "" && v.opts.ExpectedIssuer != iss {
+			return fmt.Errorf("issuer mismatch, expected %q, got %q", v.opts.ExpectedIssuer, iss)
 		}
+		return nil
+	})
+	iss.Required = v.opts.ExpectedIssuer != ""
+	iss.MissingErr = fmt.Errorf("issuer mismatch, expected %q, got %q", v.opts.ExpectedIssuer, "")
+
+	expected := ClaimStrings(v.opts.ExpectedAudiences)
+	if v.opts.ExpectedAudience != "" {
+		expected = append(ClaimStrings{v.opts.ExpectedAudience}, expected...)
 	}
 
-	if claims.Has(iana.CWTClaimIat) {
-		iat, err := claims.GetUint64(iana.CWTClaimIat)
-		if err != nil {
-			return fmt.Errorf("cose/cwt: Validator.Validate: token has an invalid iat claim, %w", err)
+	aud := NewRule(iana.CWTClaimAud, ExtractStrings, func(aud []string) error {
+		if len(expected) == 0 {
+			return nil
 		}
-		if iat > 0 && v.opts.ExpectIssuedInThePast {
-			if t := toTime(iat); t.IsZero() || t.After(now.Add(v.opts.ClockSkew)) {
-				return fmt.Errorf("cose/cwt: Validator.Validate: token has an invalid iat claim in the future")
+		for _, a := range aud {
+			if expected.Has(a) {
+				return nil
 			}
 		}
-	}
+		return audienceMismatch(expected, aud)
+	})
+	aud.Required = len(expected) > 0
+	aud.MissingErr = audienceMismatch(expected, []string{""})
 
-	iss, err := claims.GetString(iana.CWTClaimIss)
-	if err != nil {
-		return fmt.Errorf("cose/cwt: Validator.Validate: token has an invalid iss claim, %w", err)
-	}
-	if v.opts.ExpectedIssuer != "" && v.opts.ExpectedIssuer != iss {
-		return fmt.Errorf("cose/cwt: Validator.Validate: issuer mismatch, expected %q, got %q",
-			v.opts.ExpectedIssuer, iss)
-	}
+	cnf := NewRule(iana.CWTClaimCnf, ExtractConfirmation, nil)
+	cnf.Required = v.opts.RequireConfirmation
+	cnf.MissingErr = errors.New("token doesn't have a proof-of-possession key")
 
-	aud, err := claims.GetString(iana.CWTClaimAud)
-	if err != nil {
-		return fmt.Errorf("cose/cwt: Validator.Validate: token has an invalid aud claim, %w", err)
+	return []*Rule{exp, nbf, iat, iss, aud, cnf}
+}
+
+func audienceMismatch(expected, aud []string) error {
+	var exp, got any = expected, aud
+	if len(expected) == 1 {
+		exp = expected[0]
 	}
-	if v.opts.ExpectedAudience != "" && v.opts.ExpectedAudience != aud {
-		return fmt.Errorf("cose/cwt: Validator.Validate: audience mismatch, expected %q, got %q",
-			v.opts.ExpectedAudience, aud)
+	if len(aud) == 1 {
+		got = aud[0]
 	}
 
-	return nil
+	if len(expected) > 1 {
+		return fmt.Errorf("audience mismatch, expected one of %q, got %q", exp, got)
+	}
+	return fmt.Errorf("audience mismatch, expected %q, got %q", exp, got)
 }
 
 func toTime(u uint64) time.Time {
 	if u >= math.MaxInt64 {
 		return time.Time{}
 	}
-
 	return time.Unix(int64(u), 0)
 }
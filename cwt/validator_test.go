@@ -4,6 +4,8 @@
 package cwt
 
 import (
+	"errors"
+	"fmt"
 	"math"
 	"testing"
 	"time"
@@ -142,6 +144,131 @@ func TestValidator(t *testing.T) {
 		}))
 	})
 
+	t.Run("Clock and leeways", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		_, err := NewValidator(&ValidatorOpts{ExpLeeway: 25 * time.Hour})
+		assert.ErrorContains(err, "invalid exp leeway")
+		_, err = NewValidator(&ValidatorOpts{NbfLeeway: -time.Second})
+		assert.ErrorContains(err, "invalid nbf leeway")
+		_, err = NewValidator(&ValidatorOpts{IatLeeway: 25 * time.Hour})
+		assert.ErrorContains(err, "invalid iat leeway")
+		_, err = NewValidator(&ValidatorOpts{MaxAge: -time.Second})
+		assert.ErrorContains(err, "invalid max age")
+
+		now := time.Unix(3600*24, 0)
+		va, err := NewValidator(&ValidatorOpts{
+			ExpectIssuedInThePast: true,
+			ClockSkew:             time.Minute,
+			ExpLeeway:             2 * time.Hour,
+			NbfLeeway:             time.Hour,
+			// the Clock takes precedence over FixedNow.
+			FixedNow: time.Unix(0, 0),
+			Clock:    FixedClock(now),
+		})
+		require.NoError(err)
+
+		assert.NoError(va.ValidateMap(ClaimsMap{
+			iana.CWTClaimExp: now.Add(-2*time.Hour + time.Second).Unix(),
+			iana.CWTClaimNbf: now.Add(time.Hour).Unix(),
+			iana.CWTClaimIat: now.Add(time.Minute).Unix(),
+		}))
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{
+			iana.CWTClaimExp: now.Add(-2 * time.Hour).Unix(),
+		}), "token has expired")
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{
+			iana.CWTClaimExp: now.Add(time.Hour).Unix(),
+			iana.CWTClaimNbf: now.Add(time.Hour + time.Second).Unix(),
+		}), "token cannot be used yet")
+		// the iat leeway is the ClockSkew by default.
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{
+			iana.CWTClaimExp: now.Add(time.Hour).Unix(),
+			iana.CWTClaimIat: now.Add(time.Minute + time.Second).Unix(),
+		}), "token has an invalid iat claim in the future")
+
+		// a device clock that is behind.
+		va, err = NewValidator(&ValidatorOpts{Clock: OffsetClock(FixedClock(now), -time.Hour)})
+		require.NoError(err)
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{
+			iana.CWTClaimExp: now.Add(time.Hour).Unix(),
+			iana.CWTClaimNbf: now.Unix(),
+		}), "token cannot be used yet")
+	})
+
+	t.Run("MaxAge", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		now := time.Unix(3600*24, 0)
+		va, err := NewValidator(&ValidatorOpts{
+			AllowMissingExpiration: true,
+			MaxAge:                 time.Hour,
+			IatLeeway:              time.Minute,
+			FixedNow:               now,
+		})
+		require.NoError(err)
+
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{}), "token doesn't have an iat claim")
+		assert.NoError(va.ValidateMap(ClaimsMap{
+			iana.CWTClaimIat: now.Add(-time.Hour - time.Minute).Unix(),
+		}))
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{
+			iana.CWTClaimIat: now.Add(-time.Hour - time.Minute - time.Second).Unix(),
+		}), "token is too old")
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{
+			iana.CWTClaimIat: 0,
+		}), "token is too old")
+	})
+
+	t.Run("ValidateMapWithResult", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		now := time.Unix(3600*24, 0)
+		va, err := NewValidator(&ValidatorOpts{
+			AllowMissingExpiration: true,
+			ExpLeeway:              time.Minute,
+			FixedNow:               now,
+		})
+		require.NoError(err)
+
+		r, err := va.ValidateMapWithResult(ClaimsMap{iana.CWTClaimExp: now.Add(time.Hour).Unix()})
+		require.NoError(err)
+		assert.Equal(&ValidationResult{
+			ValidatedAt: now,
+			ExpiresAt:   now.Add(time.Hour),
+			Remaining:   time.Hour,
+		}, r)
+
+		// the expired token accepted within the leeway.
+		r, err = va.ValidateMapWithResult(ClaimsMap{iana.CWTClaimExp: now.Add(-time.Second).Unix()})
+		require.NoError(err)
+		assert.Equal(now.Add(-time.Second), r.ExpiresAt)
+		assert.Equal(time.Duration(0), r.Remaining)
+
+		r, err = va.ValidateMapWithResult(ClaimsMap{})
+		require.NoError(err)
+		assert.True(r.ExpiresAt.IsZero())
+		assert.Equal(time.Duration(0), r.Remaining)
+
+		_, err = va.ValidateMapWithResult(ClaimsMap{iana.CWTClaimExp: now.Add(-time.Hour).Unix()})
+		assert.ErrorContains(err, "token has expired")
+		_, err = va.ValidateMapWithResult(nil)
+		assert.ErrorContains(err, "nil ClaimsMap")
+
+		// the MaxAge policy expires the token earlier.
+		va, err = NewValidator(&ValidatorOpts{MaxAge: 10 * time.Minute, FixedNow: now})
+		require.NoError(err)
+		r, err = va.ValidateMapWithResult(ClaimsMap{
+			iana.CWTClaimExp: now.Add(time.Hour).Unix(),
+			iana.CWTClaimIat: now.Add(-time.Minute).Unix(),
+		})
+		require.NoError(err)
+		assert.Equal(now.Add(9*time.Minute), r.ExpiresAt)
+		assert.Equal(9*time.Minute, r.Remaining)
+	})
+
 	t.Run("ExpectedIssuer", func(t *testing.T) {
 		assert := assert.New(t)
 
@@ -211,4 +338,105 @@ func TestValidator(t *testing.T) {
 		assert.NoError(va.Validate(&Claims{Audience: "ldclabs"}))
 		assert.NoError(va.ValidateMap(ClaimsMap{iana.CWTClaimAud: "ldclabs"}))
 	})
+
+	t.Run("ExpectedAudiences", func(t *testing.T) {
+		assert := assert.New(t)
+
+		va, err := NewValidator(&ValidatorOpts{
+			AllowMissingExpiration: true,
+			ExpectedAudiences:      []string{"ldclabs", "ldc:txpool"},
+		})
+		require.NoError(t, err)
+
+		assert.ErrorContains(va.Validate(&Claims{}),
+			`audience mismatch, expected one of ["ldclabs" "ldc:txpool"], got ""`)
+		assert.ErrorContains(va.Validate(&Claims{Audience: "alice", ExtraAudiences: ClaimStrings{"bob"}}),
+			`audience mismatch, expected one of ["ldclabs" "ldc:txpool"], got ["alice" "bob"]`)
+
+		assert.NoError(va.Validate(&Claims{Audience: "ldc:txpool"}))
+		assert.NoError(va.Validate(&Claims{Audience: "alice", ExtraAudiences: ClaimStrings{"ldclabs"}}))
+		assert.NoError(va.ValidateMap(ClaimsMap{iana.CWTClaimAud: []any{"alice", "ldc:txpool"}}))
+
+		va, err = NewValidator(&ValidatorOpts{
+			AllowMissingExpiration: true,
+			ExpectedAudience:       "alice",
+			ExpectedAudiences:      []string{"ldclabs"},
+		})
+		require.NoError(t, err)
+		assert.NoError(va.Validate(&Claims{Audience: "alice"}))
+		assert.NoError(va.Validate(&Claims{Audience: "ldclabs"}))
+		assert.ErrorContains(va.Validate(&Claims{Audience: "bob"}),
+			`audience mismatch, expected one of ["alice" "ldclabs"], got "bob"`)
+	})
+}
+
+func TestValidatorRules(t *testing.T) {
+	t.Run("collect all violations", func(t *testing.T) {
+		assert := assert.New(t)
+
+		fixedNow := time.Unix(3600, 0)
+		va, err := NewValidator(&ValidatorOpts{
+			ExpectedIssuer:   "ldclabs",
+			ExpectedAudience: "ldclabs",
+			FixedNow:         fixedNow,
+		})
+		require.NoError(t, err)
+
+		for _, err := range []error{
+			va.Validate(&Claims{Issuer: "alice", NotBefore: 3601}),
+			va.ValidateMap(ClaimsMap{iana.CWTClaimIss: "alice", iana.CWTClaimNbf: 3601}),
+		} {
+			assert.Equal(`cose/cwt: Validator.Validate: token doesn't have an expiration set; token cannot be used yet; issuer mismatch, expected "ldclabs", got "alice"; audience mismatch, expected "ldclabs", got ""`, err.Error())
+
+			var ve *ValidationError
+			require.True(t, errors.As(err, &ve))
+			assert.Equal(4, len(ve.Violations))
+			assert.True(ve.Has(iana.CWTClaimExp))
+			assert.True(ve.Has(iana.CWTClaimNbf))
+			assert.True(ve.Has(iana.CWTClaimIss))
+			assert.True(ve.Has(iana.CWTClaimAud))
+		}
+	})
+
+	t.Run("multi-valued aud", func(t *testing.T) {
+		assert := assert.New(t)
+
+		va, err := NewValidator(&ValidatorOpts{
+			AllowMissingExpiration: true,
+			ExpectedAudience:       "ldclabs",
+		})
+		require.NoError(t, err)
+
+		assert.NoError(va.ValidateMap(ClaimsMap{iana.CWTClaimAud: []any{"alice", "ldclabs"}}))
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{iana.CWTClaimAud: []any{"alice", "bob"}}),
+			`audience mismatch, expected "ldclabs", got ["alice" "bob"]`)
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{iana.CWTClaimAud: []any{"alice", 1}}),
+			"token has an invalid aud claim")
+	})
+
+	t.Run("private claims", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := NewValidator(&ValidatorOpts{Rules: []*Rule{nil}})
+		assert.ErrorContains(err, "cose/cwt: NewValidator: cose/cwt: RuleSet.Add: invalid Rule")
+
+		role := NewRule("role", ExtractString, func(role string) error {
+			if role != "admin" {
+				return fmt.Errorf("role %q is not allowed", role)
+			}
+			return nil
+		})
+		role.Required = true
+
+		va, err := NewValidator(&ValidatorOpts{
+			AllowMissingExpiration: true,
+			Rules:                  []*Rule{role},
+		})
+		require.NoError(t, err)
+
+		assert.NoError(va.ValidateMap(ClaimsMap{"role": "admin"}))
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{"role": "user"}), `role "user" is not allowed`)
+		assert.ErrorContains(va.ValidateMap(ClaimsMap{}), "token doesn't have the role claim")
+		assert.ErrorContains(va.Validate(&Claims{}), "token doesn't have the role claim")
+	})
 }
@@ -0,0 +1,206 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+const (
+	cwtMaxNestingLevels = 4
+)
+
+// VerifierOpts defines the keys and the validator for CWT verifiers.
+type VerifierOpts struct {
+	// Verifiers verify the tokens protected with COSE_Sign1.
+	Verifiers key.Verifiers
+	// MACers verify the tokens protected with COSE_Mac0.
+	MACers []key.MACer
+	// Encryptors decrypt the tokens protected with COSE_Encrypt0.
+	Encryptors []key.Encryptor
+	// Policy is checked for all nested COSE objects before the verification or decryption, optional.
+	Policy *cose.Policy
+
+	// Validator validates the claims of the verified tokens. It is required.
+	Validator *Validator
+}
+
+// Verifier verifies and validates CBOR Web Tokens (CWT) protected with COSE_Sign1, COSE_Mac0 or COSE_Encrypt0,
+// including the nested CWTs, such as a signed CWT that is then encrypted.
+// The key of a COSE object is selected with the "kid" header parameter,
+// the only key of the type is used if the "kid" is not present.
+type Verifier struct {
+	opts VerifierOpts
+}
+
+// Token represents a verified CWT.
+type Token struct {
+	// Claims is the claims set of the token.
+	Claims ClaimsMap
+	// Protected is the protected header parameters of the innermost COSE object.
+	Protected cose.Headers
+	// Unprotected is the unprotected header parameters of the innermost COSE object.
+	Unprotected cose.Headers
+	// Result is the result of the validation, such as the remaining lifetime of the token.
+	Result *ValidationResult
+}
+
+// NewVerifier creates a new CWT Verifier.
+func NewVerifier(opts *VerifierOpts) (*Verifier, error) {
+	if opts == nil {
+		return nil, errors.New("cose/cwt: NewVerifier: nil VerifierOpts")
+	}
+	if opts.Validator == nil {
+		return nil, errors.New("cose/cwt: NewVerifier: nil Validator")
+	}
+	if len(opts.Verifiers) == 0 && len(opts.MACers) == 0 && len(opts.Encryptors) == 0 {
+		return nil, errors.New("cose/cwt: NewVerifier: no keys provided")
+	}
+
+	return &Verifier{opts: *opts}, nil
+}
+
+// Verify detects the COSE structure of the token, verifies the signature or the MAC,
+// decrypts the ciphertext, and then validates the claims with the Validator.
+// `externalData` should be the same as the one used when issuing, it is used for all nested COSE objects.
+func (v *Verifier) Verify(token, externalData []byte) (*Token, error) {
+	t, err := v.unwrap(token, externalData, 0)
+	if err != nil {
+		return nil, fmt.Errorf("cose/cwt: Verifier.Verify: %w", err)
+	}
+
+	if t.Result, err = v.opts.Validator.ValidateMapWithResult(t.Claims); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (v *Verifier) unwrap(data, externalData []byte, level int) (*Token, error) {
+	if level >= cwtMaxNestingLevels {
+		return nil, fmt.Errorf("too many nesting levels, expected <= %d", cwtMaxNestingLevels)
+	}
+
+	var tag cbor.RawTag
+	if err := key.UnmarshalCBOR(data, &tag); err != nil {
+		return nil, fmt.Errorf("invalid CWT, %w", err)
+	}
+
+	if tag.Number == iana.CBORTagCWT {
+		if level > 0 {
+			return nil, errors.New("unexpected CWT tag in nested CWT")
+		}
+		if err := key.UnmarshalCBOR(tag.Content, &tag); err != nil {
+			return nil, fmt.Errorf("invalid CWT, %w", err)
+		}
+	}
+
+	t := &Token{}
+	var payload []byte
+
+	switch tag.Number {
+	case iana.CBORTagCOSESign1:
+		obj := &cose.Sign1Message[cbor.RawMessage]{}
+		if err := obj.UnmarshalCBOR(tag.Content); err != nil {
+			return nil, err
+		}
+
+		kid := LookupKid(obj.Protected, obj.Unprotected)
+		verifier := LookupKey(v.opts.Verifiers, kid)
+		if verifier == nil {
+			return nil, fmt.Errorf("no Verifier for kid %x", kid)
+		}
+
+		if err := obj.VerifyWithPolicy(v.opts.Policy, verifier, externalData); err != nil {
+			return nil, err
+		}
+		t.Protected, t.Unprotected, payload = obj.Protected, obj.Unprotected, obj.Payload
+
+	case iana.CBORTagCOSEMac0:
+		obj := &cose.Mac0Message[cbor.RawMessage]{}
+		if err := obj.UnmarshalCBOR(tag.Content); err != nil {
+			return nil, err
+		}
+
+		kid := LookupKid(obj.Protected, obj.Unprotected)
+		macer := LookupKey(v.opts.MACers, kid)
+		if macer == nil {
+			return nil, fmt.Errorf("no MACer for kid %x", kid)
+		}
+
+		if err := obj.VerifyWithPolicy(v.opts.Policy, macer, externalData); err != nil {
+			return nil, err
+		}
+		t.Protected, t.Unprotected, payload = obj.Protected, obj.Unprotected, obj.Payload
+
+	case iana.CBORTagCOSEEncrypt0:
+		obj := &cose.Encrypt0Message[cbor.RawMessage]{}
+		if err := obj.UnmarshalCBOR(tag.Content); err != nil {
+			return nil, err
+		}
+
+		kid := LookupKid(obj.Protected, obj.Unprotected)
+		encryptor := LookupKey(v.opts.Encryptors, kid)
+		if encryptor == nil {
+			return nil, fmt.Errorf("no Encryptor for kid %x", kid)
+		}
+
+		if err := obj.DecryptWithPolicy(v.opts.Policy, encryptor, externalData); err != nil {
+			return nil, err
+		}
+		t.Protected, t.Unprotected, payload = obj.Protected, obj.Unprotected, obj.Payload
+
+	default:
+		return nil, fmt.Errorf("unsupported COSE structure with tag %d", tag.Number)
+	}
+
+	// the payload of a nested CWT is a tagged COSE object, a major type 6 data item.
+	if len(payload) > 0 && payload[0]>>5 == 6 {
+		return v.unwrap(payload, externalData, level+1)
+	}
+
+	if err := key.UnmarshalCBOR(payload, &t.Claims); err != nil {
+		return nil, fmt.Errorf("invalid claims, %w", err)
+	}
+	if t.Claims == nil {
+		return nil, errors.New("invalid claims, nil ClaimsMap")
+	}
+	return t, nil
+}
+
+// LookupKid returns the key identifier in the protected or unprotected header parameters,
+// the protected one is preferred.
+func LookupKid(protected, unprotected cose.Headers) []byte {
+	if kid, _ := protected.GetBytes(iana.HeaderParameterKid); len(kid) > 0 {
+		return kid
+	}
+	kid, _ := unprotected.GetBytes(iana.HeaderParameterKid)
+	return kid
+}
+
+// LookupKey returns the Signer, Verifier, MACer or Encryptor for the given key identifier.
+// If the key identifier is empty and there is only one key object, it is returned.
+func LookupKey[T interface{ Key() key.Key }](objs []T, kid []byte) T {
+	var zero T
+	if len(kid) == 0 {
+		if len(objs) == 1 {
+			return objs[0]
+		}
+		return zero
+	}
+
+	for _, o := range objs {
+		if bytes.Equal(o.Key().Kid(), kid) {
+			return o
+		}
+	}
+	return zero
+}
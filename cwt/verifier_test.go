@@ -0,0 +1,277 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/aesgcm"
+	"github.com/ldclabs/cose/key/ed25519"
+	"github.com/ldclabs/cose/key/hmac"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifier(t *testing.T) {
+
+	va, err := NewValidator(&ValidatorOpts{
+		ExpectedIssuer: "ldc:ca",
+		FixedNow:       time.Unix(1670123000, 0),
+	})
+	require.NoError(t, err)
+
+	var signers key.Signers
+	var verifiers key.Verifiers
+	for i := 0; i < 2; i++ {
+		k, err := ed25519.GenerateKey()
+		require.NoError(t, err)
+		signer, err := k.Signer()
+		require.NoError(t, err)
+		pk, err := ed25519.ToPublicKey(k)
+		require.NoError(t, err)
+		verifier, err := pk.Verifier()
+		require.NoError(t, err)
+		signers = append(signers, signer)
+		verifiers = append(verifiers, verifier)
+	}
+
+	k, err := hmac.GenerateKey(iana.AlgorithmHMAC_256_256)
+	require.NoError(t, err)
+	macer, err := k.MACer()
+	require.NoError(t, err)
+
+	k, err = aesgcm.GenerateKey(iana.AlgorithmA256GCM)
+	require.NoError(t, err)
+	encryptor, err := k.Encryptor()
+	require.NoError(t, err)
+
+	_, err = NewVerifier(nil)
+	assert.ErrorContains(t, err, "nil VerifierOpts")
+	_, err = NewVerifier(&VerifierOpts{Verifiers: verifiers})
+	assert.ErrorContains(t, err, "nil Validator")
+	_, err = NewVerifier(&VerifierOpts{Validator: va})
+	assert.ErrorContains(t, err, "no keys provided")
+
+	vr, err := NewVerifier(&VerifierOpts{
+		Verifiers:  verifiers,
+		MACers:     []key.MACer{macer},
+		Encryptors: []key.Encryptor{encryptor},
+		Validator:  va,
+	})
+	require.NoError(t, err)
+
+	claims := &Claims{Issuer: "ldc:ca", Expiration: 1670123579, CWTID: key.ByteStr{1, 2, 3, 4}}
+
+	sign1Issuer, err := NewSign1Issuer(signers[1])
+	require.NoError(t, err)
+	mac0Issuer, err := NewMac0Issuer(macer)
+	require.NoError(t, err)
+	encrypt0Issuer, err := NewEncrypt0Issuer(encryptor)
+	require.NoError(t, err)
+
+	for _, is := range []*Issuer{sign1Issuer, mac0Issuer, encrypt0Issuer} {
+		data, err := is.Issue(claims, []byte("external"))
+		require.NoError(t, err)
+
+		tk, err := vr.Verify(data, []byte("external"))
+		require.NoError(t, err)
+		assert.Equal(t, "ldc:ca", tk.Claims[iana.CWTClaimIss])
+		assert.Equal(t, []byte{1, 2, 3, 4}, tk.Claims[iana.CWTClaimCti])
+		assert.Equal(t, time.Unix(1670123579, 0), tk.Result.ExpiresAt)
+		assert.Equal(t, 579*time.Second, tk.Result.Remaining)
+
+		_, err = vr.Verify(data, nil)
+		assert.Error(t, err)
+
+		// untagged with CWT tag
+		tk, err = vr.Verify(data[2:], []byte("external"))
+		require.NoError(t, err)
+		assert.Equal(t, "ldc:ca", tk.Claims[iana.CWTClaimIss])
+	}
+
+	data, err := sign1Issuer.Issue(claims, nil)
+	require.NoError(t, err)
+	tk, err := vr.Verify(data, nil)
+	require.NoError(t, err)
+	assert.Equal(t, signers[1].Key().Kid(), key.ByteStr(tk.Unprotected[iana.HeaderParameterKid].([]byte)))
+
+	t.Run("nested CWT", func(t *testing.T) {
+		assert := assert.New(t)
+
+		inner, err := sign1Issuer.Issue(claims, nil)
+		require.NoError(t, err)
+		data, err := encrypt0Issuer.Wrap(inner, nil)
+		require.NoError(t, err)
+
+		tk, err := vr.Verify(data, nil)
+		require.NoError(t, err)
+		assert.Equal("ldc:ca", tk.Claims[iana.CWTClaimIss])
+		// the header parameters of the innermost COSE object
+		assert.Equal(signers[1].Key().Kid(), key.ByteStr(tk.Unprotected[iana.HeaderParameterKid].([]byte)))
+
+		inner, err = mac0Issuer.Issue(claims, nil)
+		require.NoError(t, err)
+		data, err = sign1Issuer.Wrap(inner, nil)
+		require.NoError(t, err)
+		data, err = encrypt0Issuer.Wrap(data, nil)
+		require.NoError(t, err)
+
+		tk, err = vr.Verify(data, nil)
+		require.NoError(t, err)
+		assert.Equal("ldc:ca", tk.Claims[iana.CWTClaimIss])
+
+		for i := 0; i < 2; i++ {
+			data, err = encrypt0Issuer.Wrap(data, nil)
+			require.NoError(t, err)
+		}
+		_, err = vr.Verify(data, nil)
+		assert.ErrorContains(err, "too many nesting levels")
+
+		// the inner CWT should not be tagged with the CWT tag
+		obj := &cose.Encrypt0Message[[]byte]{Payload: inner}
+		data, err = obj.EncryptAndEncode(encryptor, nil)
+		require.NoError(t, err)
+		_, err = vr.Verify(data, nil)
+		assert.ErrorContains(err, "unexpected CWT tag in nested CWT")
+	})
+
+	t.Run("key selection", func(t *testing.T) {
+		assert := assert.New(t)
+
+		is, err := NewSign1Issuer(signers[0])
+		require.NoError(t, err)
+		data, err := is.Issue(claims, nil)
+		require.NoError(t, err)
+
+		vr2, err := NewVerifier(&VerifierOpts{Verifiers: verifiers[1:], Validator: va})
+		require.NoError(t, err)
+		_, err = vr2.Verify(data, nil)
+		assert.ErrorContains(err, "no Verifier for kid")
+
+		// without kid, the only key is used
+		obj := &cose.Sign1Message[Claims]{
+			Protected:   cose.Headers{iana.HeaderParameterAlg: iana.AlgorithmEdDSA},
+			Unprotected: cose.Headers{},
+			Payload:     *claims,
+		}
+		data, err = obj.SignAndEncode(signers[1], nil)
+		require.NoError(t, err)
+		tk, err := vr2.Verify(data, nil)
+		require.NoError(t, err)
+		assert.Equal("ldc:ca", tk.Claims[iana.CWTClaimIss])
+
+		_, err = vr.Verify(data, nil)
+		assert.ErrorContains(err, "no Verifier for kid")
+
+		vr3, err := NewVerifier(&VerifierOpts{Verifiers: verifiers, Validator: va})
+		require.NoError(t, err)
+		data, err = mac0Issuer.Issue(claims, nil)
+		require.NoError(t, err)
+		_, err = vr3.Verify(data, nil)
+		assert.ErrorContains(err, "no MACer for kid")
+		data, err = encrypt0Issuer.Issue(claims, nil)
+		require.NoError(t, err)
+		_, err = vr3.Verify(data, nil)
+		assert.ErrorContains(err, "no Encryptor for kid")
+	})
+
+	t.Run("policy", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		vr2, err := NewVerifier(&VerifierOpts{
+			Verifiers:  verifiers,
+			MACers:     []key.MACer{macer},
+			Encryptors: []key.Encryptor{encryptor},
+			Validator:  va,
+			Policy: &cose.Policy{
+				Algorithms:          []key.Alg{iana.AlgorithmEdDSA, iana.AlgorithmA256GCM},
+				RequireProtectedAlg: true,
+			},
+		})
+		require.NoError(err)
+
+		data, err := sign1Issuer.Issue(claims, nil)
+		require.NoError(err)
+		_, err = vr2.Verify(data, nil)
+		assert.NoError(err)
+
+		data, err = mac0Issuer.Issue(claims, nil)
+		require.NoError(err)
+		_, err = vr2.Verify(data, nil)
+		assert.ErrorContains(err, "cose/cwt: Verifier.Verify: cose/cose: Policy.Check: policy violation, algorithm 5 is not allowed")
+		assert.ErrorIs(err, cose.ErrPolicyViolation)
+
+		// the policy is checked for the nested CWT
+		inner, err := sign1Issuer.Issue(claims, nil)
+		require.NoError(err)
+		data, err = encrypt0Issuer.Wrap(inner, nil)
+		require.NoError(err)
+		_, err = vr2.Verify(data, nil)
+		assert.NoError(err)
+
+		inner, err = mac0Issuer.Issue(claims, nil)
+		require.NoError(err)
+		data, err = encrypt0Issuer.Wrap(inner, nil)
+		require.NoError(err)
+		_, err = vr2.Verify(data, nil)
+		assert.ErrorIs(err, cose.ErrPolicyViolation)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := vr.Verify([]byte{0xa0}, nil)
+		assert.ErrorContains(err, "cose/cwt: Verifier.Verify: invalid CWT")
+
+		data := key.MustMarshalCBOR(cbor.Tag{Number: iana.CBORTagCWT, Content: cbor.RawMessage{0xa0}})
+		_, err = vr.Verify(data, nil)
+		assert.ErrorContains(err, "cose/cwt: Verifier.Verify: invalid CWT")
+
+		data = key.MustMarshalCBOR(cbor.Tag{Number: iana.CBORTagCOSESign, Content: []any{}})
+		_, err = vr.Verify(data, nil)
+		assert.ErrorContains(err, "unsupported COSE structure with tag 98")
+
+		data, err = sign1Issuer.Issue([]byte{1, 2, 3}, nil)
+		require.NoError(t, err)
+		_, err = vr.Verify(data, nil)
+		assert.ErrorContains(err, "invalid claims")
+
+		data, err = sign1Issuer.Issue(&Claims{Issuer: "alice", Expiration: 1670123579}, nil)
+		require.NoError(t, err)
+		_, err = vr.Verify(data, nil)
+		assert.ErrorContains(err, `cose/cwt: Validator.Validate: issuer mismatch, expected "ldc:ca", got "alice"`)
+		var ve *ValidationError
+		assert.ErrorAs(err, &ve)
+	})
+}
+
+func TestLookupKey(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var verifiers key.Verifiers
+	for i := 0; i < 2; i++ {
+		k, err := ed25519.GenerateKey()
+		require.NoError(err)
+		verifier, err := k.Verifier()
+		require.NoError(err)
+		verifiers = append(verifiers, verifier)
+	}
+
+	kid := verifiers[1].Key().Kid()
+	assert.Equal([]byte(kid), LookupKid(cose.Headers{iana.HeaderParameterKid: kid}, cose.Headers{iana.HeaderParameterKid: []byte{1}}))
+	assert.Equal([]byte(kid), LookupKid(cose.Headers{}, cose.Headers{iana.HeaderParameterKid: kid}))
+	assert.Nil(LookupKid(cose.Headers{}, cose.Headers{}))
+
+	assert.Equal(verifiers[1], LookupKey(verifiers, kid))
+	assert.Nil(LookupKey(verifiers, []byte{1, 2, 3}))
+	assert.Nil(LookupKey(verifiers, nil))
+	assert.Equal(verifiers[0], LookupKey(verifiers[:1], nil))
+}
@@ -0,0 +1,15 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package iana
+
+// ACE Profiles
+// From IANA registry https://www.iana.org/assignments/ace/ace.xhtml#ace-profiles
+const (
+	// Profile for delegating client Authentication and Authorization
+	// for Constrained Environments by establishing a Datagram Transport Layer Security (DTLS) channel
+	// between resource-constrained nodes.
+	ACEProfileCoapDTLS = 1
+	// Profile for using OSCORE to provide communication security between client and resource server.
+	ACEProfileCoapOSCORE = 2
+)
@@ -68,6 +68,20 @@ const (
 	// The section containing submodules ("submods": map) TEMPORARY, expires 2023-03-23
 	CWTClaimSubmodules = 266
 
+	// Reference https://datatracker.ietf.org/doc/html/rfc9711
+	// Uptime, seconds since the entity or submodule booted ("uptime": uint)
+	CWTClaimUptime = 261
+	// The number of times the entity or submodule has been booted ("bootcount": uint)
+	CWTClaimBootCount = 267
+	// Identifies a boot cycle ("bootseed": bstr)
+	CWTClaimBootSeed = 268
+	// Name of the software running in the entity ("swname": tstr)
+	CWTClaimSWName = 270
+	// Version of software running in the entity ("swversion": array)
+	CWTClaimSWVersion = 271
+	// Indicates intended use of the EAT ("intuse": int)
+	CWTClaimIntendedUse = 275
+
 	// Reference <https://datatracker.ietf.org/doc/draft-tschofenig-rats-psa-token/09/>
 	// PSA Client ID (N/A: signed integer)
 	CWTClaimPSAClientID = 2394
@@ -83,4 +97,12 @@ const (
 	CWTClaimPSASoftwareComponents = 2399
 	// PSA Verification Service Indicator (N/A: tstr)
 	CWTClaimPSAVerificationServiceIndicator = 2400
+
+	// Reference https://datatracker.ietf.org/doc/draft-ietf-oauth-status-list/
+	// The status list of a Status List Token ("status_list": map)
+	CWTClaimStatusList = 65533
+	// Time to live, how long the Status List Token can be cached in seconds ("ttl": uint)
+	CWTClaimTTL = 65534
+	// The status mechanisms of the token ("status": map)
+	CWTClaimStatus = 65535
 )
@@ -0,0 +1,16 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package iana
+
+// CWT Confirmation Methods
+// From IANA registry https://www.iana.org/assignments/cwt/cwt.xhtml#confirmation-methods
+// as of 2022-12-19.
+const (
+	// COSE_Key ("COSE_Key": COSE_Key)
+	CWTConfirmationCOSEKey = 1
+	// Encrypted COSE_Key ("Encrypted_COSE_Key": COSE_Encrypt or COSE_Encrypt0)
+	CWTConfirmationEncryptedCOSEKey = 2
+	// Key Identifier ("kid": bstr)
+	CWTConfirmationKid = 3
+)
@@ -58,6 +58,18 @@ const (
 	//
 	// Associated value of type COSE_Countersignature0
 	HeaderParameterCountersignature0V2 = 11
+	// A CBOR Web Token (CWT) containing a COSE_Key in a 'cnf' claim and possibly other claims
+	//
+	// Associated value of type COSE_Messages
+	HeaderParameterKCWT = 13
+	// A CWT Claims Set (CCS) containing a COSE_Key in a 'cnf' claim and possibly other claims
+	//
+	// Associated value of type map
+	HeaderParameterKCCS = 14
+	// Content type of the complete COSE object
+	//
+	// Associated value of type uint / tstr
+	HeaderParameterTyp = 16
 	// An unordered bag of X.509 certificates
 	//
 	// Associated value of type COSE_X509
@@ -77,7 +77,11 @@ func CheckKey(k key.Key) error {
 			}
 
 		case iana.KeyParameterKeyOps: // optional
-			for _, op := range k.Ops() {
+			ops := k.Ops()
+			if len(ops) == 0 {
+				return fmt.Errorf(`cose/key/aesccm: CheckKey: invalid parameter key_ops`)
+			}
+			for _, op := range ops {
 				switch op {
 				case iana.KeyOperationEncrypt, iana.KeyOperationDecrypt:
 				// continue
@@ -137,8 +141,8 @@ func New(k key.Key) (key.Encryptor, error) {
 // Encrypt encrypts a plaintext with the given iv and additional data.
 // It returns the ciphertext or error.
 func (h *aesCCM) Encrypt(iv, plaintext, additionalData []byte) ([]byte, error) {
-	if !h.key.Ops().EmptyOrHas(iana.KeyOperationEncrypt) {
-		return nil, fmt.Errorf("cose/key/aesccm: Encryptor.Encrypt: invalid key_ops")
+	if err := h.key.CheckOps(false, iana.KeyOperationEncrypt); err != nil {
+		return nil, fmt.Errorf("cose/key/aesccm: Encryptor.Encrypt: %w", err)
 	}
 
 	_, tagSize, nonceSize := getKeySize(h.key.Alg())
@@ -155,8 +159,8 @@ func (h *aesCCM) Encrypt(iv, plaintext, additionalData []byte) ([]byte, error) {
 // Decrypt decrypts a ciphertext with the given iv and additional data.
 // It returns the corresponding plaintext or error.
 func (h *aesCCM) Decrypt(iv, ciphertext, additionalData []byte) ([]byte, error) {
-	if !h.key.Ops().EmptyOrHas(iana.KeyOperationDecrypt) {
-		return nil, fmt.Errorf("cose/key/aesccm: Encryptor.Decrypt: invalid key_ops")
+	if err := h.key.CheckOps(false, iana.KeyOperationDecrypt); err != nil {
+		return nil, fmt.Errorf("cose/key/aesccm: Encryptor.Decrypt: %w", err)
 	}
 	_, tagSize, nonceSize := getKeySize(h.key.Alg())
 	if len(iv) != nonceSize {
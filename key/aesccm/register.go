@@ -17,4 +17,26 @@ func init() {
 	key.RegisterEncryptor(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_16_128_256, New)
 	key.RegisterEncryptor(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_64_128_128, New)
 	key.RegisterEncryptor(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_64_128_256, New)
+
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_16_64_128, 0, generate)
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_16_64_256, 0, generate)
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_64_64_128, 0, generate)
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_64_64_256, 0, generate)
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_16_128_128, 0, generate)
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_16_128_256, 0, generate)
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_64_128_128, 0, generate)
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_64_128_256, 0, generate)
+
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_16_64_128, 0, CheckKey)
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_16_64_256, 0, CheckKey)
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_64_64_128, 0, CheckKey)
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_64_64_256, 0, CheckKey)
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_16_128_128, 0, CheckKey)
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_16_128_256, 0, CheckKey)
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_64_128_128, 0, CheckKey)
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_64_128_256, 0, CheckKey)
+}
+
+func generate(alg, _ int) (key.Key, error) {
+	return GenerateKey(alg)
 }
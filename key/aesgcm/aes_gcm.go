@@ -74,7 +74,11 @@ func CheckKey(k key.Key) error {
 			}
 
 		case iana.KeyParameterKeyOps: // optional
-			for _, op := range k.Ops() {
+			ops := k.Ops()
+			if len(ops) == 0 {
+				return fmt.Errorf(`cose/key/aesgcm: CheckKey: invalid parameter key_ops`)
+			}
+			for _, op := range ops {
 				switch op {
 				case iana.KeyOperationEncrypt, iana.KeyOperationDecrypt:
 				// continue
@@ -131,8 +135,8 @@ func New(k key.Key) (key.Encryptor, error) {
 // Encrypt encrypts a plaintext with the given iv and additional data.
 // It returns the ciphertext or error.
 func (h *aesGCM) Encrypt(iv, plaintext, additionalData []byte) ([]byte, error) {
-	if !h.key.Ops().EmptyOrHas(iana.KeyOperationEncrypt) {
-		return nil, fmt.Errorf("cose/key/aesgcm: Encryptor.Encrypt: invalid key_ops")
+	if err := h.key.CheckOps(false, iana.KeyOperationEncrypt); err != nil {
+		return nil, fmt.Errorf("cose/key/aesgcm: Encryptor.Encrypt: %w", err)
 	}
 
 	if len(iv) != nonceSize {
@@ -148,8 +152,8 @@ func (h *aesGCM) Encrypt(iv, plaintext, additionalData []byte) ([]byte, error) {
 // Decrypt decrypts a ciphertext with the given iv and additional data.
 // It returns the corresponding plaintext or error.
 func (h *aesGCM) Decrypt(iv, ciphertext, additionalData []byte) ([]byte, error) {
-	if !h.key.Ops().EmptyOrHas(iana.KeyOperationDecrypt) {
-		return nil, fmt.Errorf("cose/key/aesgcm: Encryptor.Decrypt: invalid key_ops")
+	if err := h.key.CheckOps(false, iana.KeyOperationDecrypt); err != nil {
+		return nil, fmt.Errorf("cose/key/aesgcm: Encryptor.Decrypt: %w", err)
 	}
 
 	if len(iv) != nonceSize {
@@ -12,4 +12,16 @@ func init() {
 	key.RegisterEncryptor(iana.KeyTypeSymmetric, iana.AlgorithmA128GCM, New)
 	key.RegisterEncryptor(iana.KeyTypeSymmetric, iana.AlgorithmA192GCM, New)
 	key.RegisterEncryptor(iana.KeyTypeSymmetric, iana.AlgorithmA256GCM, New)
+
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmA128GCM, 0, generate)
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmA192GCM, 0, generate)
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmA256GCM, 0, generate)
+
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmA128GCM, 0, CheckKey)
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmA192GCM, 0, CheckKey)
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmA256GCM, 0, CheckKey)
+}
+
+func generate(alg, _ int) (key.Key, error) {
+	return GenerateKey(alg)
 }
@@ -74,7 +74,11 @@ func CheckKey(k key.Key) error {
 			}
 
 		case iana.KeyParameterKeyOps: // optional
-			for _, op := range k.Ops() {
+			ops := k.Ops()
+			if len(ops) == 0 {
+				return fmt.Errorf(`cose/key/aesmac: CheckKey: invalid parameter key_ops`)
+			}
+			for _, op := range ops {
 				switch op {
 				case iana.KeyOperationMacCreate, iana.KeyOperationMacVerify:
 				// continue
@@ -134,8 +138,8 @@ func New(k key.Key) (key.MACer, error) {
 // MACCreate implements the key.MACer interface.
 // MACCreate computes message authentication code (MAC) for the given data.
 func (h *aesMAC) MACCreate(data []byte) ([]byte, error) {
-	if !h.key.Ops().EmptyOrHas(iana.KeyOperationMacCreate) {
-		return nil, fmt.Errorf("cose/key/aesmac: MACer.MACCreate: invalid key_ops")
+	if err := h.key.CheckOps(false, iana.KeyOperationMacCreate); err != nil {
+		return nil, fmt.Errorf("cose/key/aesmac: MACer.MACCreate: %w", err)
 	}
 
 	return h.create(data), nil
@@ -144,8 +148,8 @@ func (h *aesMAC) MACCreate(data []byte) ([]byte, error) {
 // MACVerify implements the key.MACer interface.
 // MACVerify verifies whether the given MAC is a correct message authentication code (MAC) the given data.
 func (h *aesMAC) MACVerify(data, mac []byte) error {
-	if !h.key.Ops().EmptyOrHas(iana.KeyOperationMacVerify) {
-		return fmt.Errorf("cose/key/aesmac: MACer.MACVerify: invalid key_ops")
+	if err := h.key.CheckOps(false, iana.KeyOperationMacVerify); err != nil {
+		return fmt.Errorf("cose/key/aesmac: MACer.MACVerify: %w", err)
 	}
 
 	expectedMAC := h.create(data)
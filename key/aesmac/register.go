@@ -13,4 +13,18 @@ func init() {
 	key.RegisterMACer(iana.KeyTypeSymmetric, iana.AlgorithmAES_MAC_256_64, New)
 	key.RegisterMACer(iana.KeyTypeSymmetric, iana.AlgorithmAES_MAC_128_128, New)
 	key.RegisterMACer(iana.KeyTypeSymmetric, iana.AlgorithmAES_MAC_256_128, New)
+
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmAES_MAC_128_64, 0, generate)
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmAES_MAC_256_64, 0, generate)
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmAES_MAC_128_128, 0, generate)
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmAES_MAC_256_128, 0, generate)
+
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmAES_MAC_128_64, 0, CheckKey)
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmAES_MAC_256_64, 0, CheckKey)
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmAES_MAC_128_128, 0, CheckKey)
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmAES_MAC_256_128, 0, CheckKey)
+}
+
+func generate(alg, _ int) (key.Key, error) {
+	return GenerateKey(alg)
 }
@@ -0,0 +1,63 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package argon2 implements the password-based key derivation function Argon2id as defined in RFC9106.
+// https://datatracker.ietf.org/doc/html/rfc9106
+package argon2
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Limits of the Params, to bound the work of deriving a key with the parameters from an untrusted source.
+const (
+	MaxTime    = 64
+	MaxMemory  = 4 * 1024 * 1024 // 4 GiB in KiB
+	MinSalt    = 8
+	MaxThreads = 255
+)
+
+// Params is the Argon2id parameters.
+type Params struct {
+	// Time is the number of passes over the memory.
+	Time uint32
+	// Memory is the size of the memory in KiB.
+	Memory uint32
+	// Threads is the number of threads.
+	Threads uint8
+}
+
+// DefaultParams is the second recommended option of RFC9106 with 64 MiB memory.
+// https://datatracker.ietf.org/doc/html/rfc9106#section-4
+var DefaultParams = Params{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// Validate checks whether the parameters are in the limits.
+func (p Params) Validate() error {
+	if p.Time == 0 || p.Time > MaxTime {
+		return fmt.Errorf("cose/key/argon2: Params.Validate: invalid time %d", p.Time)
+	}
+	if p.Threads == 0 {
+		return errors.New("cose/key/argon2: Params.Validate: invalid threads 0")
+	}
+	if p.Memory < 8*uint32(p.Threads) || p.Memory > MaxMemory {
+		return fmt.Errorf("cose/key/argon2: Params.Validate: invalid memory %d", p.Memory)
+	}
+	return nil
+}
+
+// Argon2id derives a key from the given password, salt, parameters and key size, using Argon2id.
+func Argon2id(password, salt []byte, params Params, keySize int) ([]byte, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	if len(salt) < MinSalt {
+		return nil, fmt.Errorf("cose/key/argon2: Argon2id: salt too short, expected >= %d, got %d", MinSalt, len(salt))
+	}
+	if keySize <= 0 {
+		return nil, fmt.Errorf("cose/key/argon2: Argon2id: invalid key size %d", keySize)
+	}
+	return argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, uint32(keySize)), nil
+}
@@ -0,0 +1,71 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package argon2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/argon2"
+
+	"github.com/ldclabs/cose/key"
+)
+
+func TestArgon2id(t *testing.T) {
+	t.Run("derive", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		params := Params{Time: 1, Memory: 64, Threads: 1}
+		salt := key.HexBytesify("0102030405060708")
+		k, err := Argon2id([]byte("password"), salt, params, 32)
+		require.NoError(err)
+		assert.Len(k, 32)
+		assert.Equal(argon2.IDKey([]byte("password"), salt, 1, 64, 1, 32), k)
+
+		k2, err := Argon2id([]byte("password"), salt, params, 32)
+		require.NoError(err)
+		assert.Equal(k, k2)
+
+		k2, err = Argon2id([]byte("password1"), salt, params, 32)
+		require.NoError(err)
+		assert.NotEqual(k, k2)
+
+		k2, err = Argon2id([]byte("password"), key.HexBytesify("0102030405060709"), params, 32)
+		require.NoError(err)
+		assert.NotEqual(k, k2)
+
+		k2, err = Argon2id([]byte("password"), salt, params, 16)
+		require.NoError(err)
+		assert.Len(k2, 16)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert := assert.New(t)
+
+		salt := make([]byte, 16)
+		for _, tc := range []struct {
+			params Params
+			err    string
+		}{
+			{Params{Time: 0, Memory: 64, Threads: 1}, "invalid time 0"},
+			{Params{Time: MaxTime + 1, Memory: 64, Threads: 1}, "invalid time 65"},
+			{Params{Time: 1, Memory: 64, Threads: 0}, "invalid threads 0"},
+			{Params{Time: 1, Memory: 7, Threads: 1}, "invalid memory 7"},
+			{Params{Time: 1, Memory: 16, Threads: 4}, "invalid memory 16"},
+			{Params{Time: 1, Memory: MaxMemory + 1, Threads: 1}, "invalid memory"},
+		} {
+			_, err := Argon2id([]byte("password"), salt, tc.params, 32)
+			assert.ErrorContains(err, tc.err)
+		}
+
+		params := Params{Time: 1, Memory: 64, Threads: 1}
+		_, err := Argon2id([]byte("password"), salt[:7], params, 32)
+		assert.ErrorContains(err, "salt too short")
+		_, err = Argon2id([]byte("password"), salt, params, 0)
+		assert.ErrorContains(err, "invalid key size 0")
+		assert.NoError(DefaultParams.Validate())
+	})
+}
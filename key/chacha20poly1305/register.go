@@ -10,4 +10,12 @@ import (
 
 func init() {
 	key.RegisterEncryptor(iana.KeyTypeSymmetric, iana.AlgorithmChaCha20Poly1305, New)
+
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmChaCha20Poly1305, 0, generate)
+
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmChaCha20Poly1305, 0, CheckKey)
+}
+
+func generate(_, _ int) (key.Key, error) {
+	return GenerateKey()
 }
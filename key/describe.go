@@ -0,0 +1,107 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package key
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ldclabs/cose/iana"
+)
+
+// Description describes the properties of a key, such as for a key management UI.
+type Description struct {
+	Kid ByteStr
+	Kty int
+	// Alg is the algorithm of the key, or the algorithm that matched the curve, see Key.Alg.
+	Alg Alg
+	// Crv is the curve of the OKP and EC2 keys, or 0.
+	Crv int
+	// Size is the key size in bits, such as the curve size, the RSA modulus size,
+	// or the symmetric key size. It is 0 if unknown.
+	Size int
+	// Private is true if the key has the private or secret parameter.
+	Private bool
+	// Ops is the allowed operations, it is the key_ops parameter if present, otherwise
+	// the operations of the Signer, Verifier, MACer and Encryptor registered in the DefaultRegistry.
+	Ops Ops
+}
+
+// curveSizes is the key size in bits of the curves.
+var curveSizes = map[int]int{
+	iana.EllipticCurveP_256:     256,
+	iana.EllipticCurveP_384:     384,
+	iana.EllipticCurveP_521:     521,
+	iana.EllipticCurveX25519:    256,
+	iana.EllipticCurveX448:      448,
+	iana.EllipticCurveEd25519:   256,
+	iana.EllipticCurveEd448:     456,
+	iana.EllipticCurveSecp256k1: 256,
+}
+
+// Describe returns the Description of the key.
+func Describe(k Key) (*Description, error) {
+	if k == nil {
+		return nil, errors.New("cose/key: Describe: nil key")
+	}
+
+	d := &Description{Kid: k.Kid(), Kty: k.Kty(), Alg: k.Alg()}
+	var err error
+	switch d.Kty {
+	case iana.KeyTypeOKP, iana.KeyTypeEC2:
+		if d.Crv, err = k.GetInt(iana.OKPKeyParameterCrv); err != nil {
+			return nil, fmt.Errorf("cose/key: Describe: invalid parameter crv, %w", err)
+		}
+		d.Size = curveSizes[d.Crv]
+		d.Private = k.Has(iana.OKPKeyParameterD)
+
+	case iana.KeyTypeRSA:
+		n, err := k.GetBytes(iana.RSAKeyParameterN)
+		if err != nil {
+			return nil, fmt.Errorf("cose/key: Describe: invalid parameter n, %w", err)
+		}
+		d.Size = new(big.Int).SetBytes(n).BitLen()
+		d.Private = k.Has(iana.RSAKeyParameterD)
+
+	case iana.KeyTypeSymmetric:
+		kv, err := k.GetBytes(iana.SymmetricKeyParameterK)
+		if err != nil {
+			return nil, fmt.Errorf("cose/key: Describe: invalid parameter k, %w", err)
+		}
+		d.Size = len(kv) * 8
+		d.Private = len(kv) > 0
+	}
+
+	if d.Ops = k.Ops(); len(d.Ops) == 0 {
+		d.Ops = k.registeredOps(d.Crv, d.Private)
+	}
+	return d, nil
+}
+
+// registeredOps returns the operations of the registered factories for the key.
+func (k Key) registeredOps(crv int, private bool) Ops {
+	var ops Ops
+	if k.Kty() == iana.KeyTypeOKP && (crv == iana.EllipticCurveX25519 || crv == iana.EllipticCurveX448) {
+		if private {
+			ops = append(ops, iana.KeyOperationDeriveKey, iana.KeyOperationDeriveBits)
+		}
+		return ops
+	}
+
+	r, tk := DefaultRegistry, k.tripleKey()
+	if private && has(r, r.signers, tk) {
+		ops = append(ops, iana.KeyOperationSign)
+	}
+	if has(r, r.verifiers, tk) {
+		ops = append(ops, iana.KeyOperationVerify)
+	}
+	if has(r, r.macers, tk) {
+		ops = append(ops, iana.KeyOperationMacCreate, iana.KeyOperationMacVerify)
+	}
+	if has(r, r.encryptors, tk) {
+		ops = append(ops, iana.KeyOperationEncrypt, iana.KeyOperationDecrypt)
+	}
+	return ops
+}
@@ -0,0 +1,179 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package key_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	_ "github.com/ldclabs/cose/key/aesccm"
+	_ "github.com/ldclabs/cose/key/aesgcm"
+	_ "github.com/ldclabs/cose/key/aesmac"
+	_ "github.com/ldclabs/cose/key/chacha20poly1305"
+	"github.com/ldclabs/cose/key/ecdh"
+	"github.com/ldclabs/cose/key/ecdsa"
+	_ "github.com/ldclabs/cose/key/ed25519"
+	_ "github.com/ldclabs/cose/key/hmac"
+)
+
+func TestGenerateAndDescribe(t *testing.T) {
+	sign := key.Ops{iana.KeyOperationSign, iana.KeyOperationVerify}
+	mac := key.Ops{iana.KeyOperationMacCreate, iana.KeyOperationMacVerify}
+	enc := key.Ops{iana.KeyOperationEncrypt, iana.KeyOperationDecrypt}
+	derive := key.Ops{iana.KeyOperationDeriveKey, iana.KeyOperationDeriveBits}
+
+	t.Run("Generate", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		for i, tc := range []struct {
+			kty, alg, crv int
+			desc          key.Description
+		}{
+			{iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519,
+				key.Description{Kty: 1, Alg: -8, Crv: 6, Size: 256, Private: true, Ops: sign}},
+			{iana.KeyTypeOKP, iana.AlgorithmEdDSA, 0,
+				key.Description{Kty: 1, Alg: -8, Crv: 6, Size: 256, Private: true, Ops: sign}},
+			{iana.KeyTypeEC2, iana.AlgorithmES256, iana.EllipticCurveP_256,
+				key.Description{Kty: 2, Alg: -7, Crv: 1, Size: 256, Private: true, Ops: sign}},
+			{iana.KeyTypeEC2, iana.AlgorithmES384, 0,
+				key.Description{Kty: 2, Alg: -35, Crv: 2, Size: 384, Private: true, Ops: sign}},
+			{iana.KeyTypeEC2, iana.AlgorithmES512, 0,
+				key.Description{Kty: 2, Alg: -36, Crv: 3, Size: 521, Private: true, Ops: sign}},
+			{iana.KeyTypeOKP, iana.AlgorithmReserved, iana.EllipticCurveX25519,
+				key.Description{Kty: 1, Crv: 4, Size: 256, Private: true, Ops: derive}},
+			{iana.KeyTypeEC2, iana.AlgorithmReserved, iana.EllipticCurveP_384,
+				key.Description{Kty: 2, Alg: -35, Crv: 2, Size: 384, Private: true, Ops: sign}},
+			{iana.KeyTypeSymmetric, iana.AlgorithmHMAC_256_256, 0,
+				key.Description{Kty: 4, Alg: 5, Size: 256, Private: true, Ops: mac}},
+			{iana.KeyTypeSymmetric, iana.AlgorithmHMAC_512_512, 0,
+				key.Description{Kty: 4, Alg: 7, Size: 512, Private: true, Ops: mac}},
+			{iana.KeyTypeSymmetric, iana.AlgorithmAES_MAC_128_64, 0,
+				key.Description{Kty: 4, Alg: 14, Size: 128, Private: true, Ops: mac}},
+			{iana.KeyTypeSymmetric, iana.AlgorithmA128GCM, 0,
+				key.Description{Kty: 4, Alg: 1, Size: 128, Private: true, Ops: enc}},
+			{iana.KeyTypeSymmetric, iana.AlgorithmA256GCM, 0,
+				key.Description{Kty: 4, Alg: 3, Size: 256, Private: true, Ops: enc}},
+			{iana.KeyTypeSymmetric, iana.AlgorithmAES_CCM_16_64_256, 0,
+				key.Description{Kty: 4, Alg: 11, Size: 256, Private: true, Ops: enc}},
+			{iana.KeyTypeSymmetric, iana.AlgorithmChaCha20Poly1305, 0,
+				key.Description{Kty: 4, Alg: 24, Size: 256, Private: true, Ops: enc}},
+		} {
+			k, err := key.Generate(tc.kty, tc.alg, tc.crv)
+			require.NoError(err, fmt.Sprintf("test case %d", i))
+
+			d, err := key.Describe(k)
+			require.NoError(err, fmt.Sprintf("test case %d", i))
+			tc.desc.Kid = k.Kid()
+			assert.NotEmpty(d.Kid, fmt.Sprintf("test case %d", i))
+			assert.Equal(tc.desc, *d, fmt.Sprintf("test case %d", i))
+		}
+
+		_, err := key.Generate(iana.KeyTypeEC2, iana.AlgorithmReserved, 0)
+		assert.ErrorContains(err, "kty(2)_alg(0) requires a curve")
+		_, err = key.Generate(iana.KeyTypeEC2, iana.AlgorithmES256, iana.EllipticCurveP_384)
+		assert.ErrorContains(err, "kty(2)_alg(-7)_crv(2) is not registered")
+		_, err = key.Generate(iana.KeyTypeRSA, iana.AlgorithmPS256, 0)
+		assert.ErrorContains(err, "kty(3)_alg(-37) is not registered")
+	})
+
+	t.Run("Describe", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		k, err := ecdsa.GenerateKey(iana.AlgorithmES256)
+		require.NoError(err)
+		pk, err := ecdsa.ToPublicKey(k)
+		require.NoError(err)
+		d, err := key.Describe(pk)
+		require.NoError(err)
+		assert.False(d.Private)
+		assert.Equal(key.Ops{iana.KeyOperationVerify}, d.Ops)
+
+		pk.SetOps(iana.KeyOperationVerify, iana.KeyOperationDeriveBits)
+		d, err = key.Describe(pk)
+		require.NoError(err)
+		assert.Equal(key.Ops{iana.KeyOperationVerify, iana.KeyOperationDeriveBits}, d.Ops, "key_ops is used if present")
+
+		k, err = ecdh.GenerateKey(iana.EllipticCurveX25519)
+		require.NoError(err)
+		pk, err = ecdh.ToPublicKey(k)
+		require.NoError(err)
+		d, err = key.Describe(pk)
+		require.NoError(err)
+		assert.False(d.Private)
+		assert.Nil(d.Ops)
+
+		d, err = key.Describe(key.Key{
+			iana.KeyParameterKty:  iana.KeyTypeRSA,
+			iana.RSAKeyParameterN: key.HexBytesify("00c4a1f2b3"),
+			iana.RSAKeyParameterE: key.HexBytesify("010001"),
+		})
+		require.NoError(err)
+		assert.Equal(key.Description{Kty: 3, Size: 32}, *d)
+
+		d, err = key.Describe(key.Key{iana.KeyParameterKty: iana.KeyTypeHSS_LMS})
+		require.NoError(err)
+		assert.Equal(key.Description{Kty: 5}, *d)
+
+		for _, tc := range []struct {
+			k   key.Key
+			err string
+		}{
+			{nil, "cose/key: Describe: nil key"},
+			{key.Key{iana.KeyParameterKty: iana.KeyTypeOKP, iana.OKPKeyParameterCrv: "Ed25519"}, "invalid parameter crv"},
+			{key.Key{iana.KeyParameterKty: iana.KeyTypeRSA, iana.RSAKeyParameterN: 1}, "invalid parameter n"},
+			{key.Key{iana.KeyParameterKty: iana.KeyTypeSymmetric, iana.SymmetricKeyParameterK: 1}, "invalid parameter k"},
+		} {
+			_, err := key.Describe(tc.k)
+			assert.ErrorContains(err, tc.err)
+		}
+	})
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	assert.Contains(key.DefaultRegistry.Supported(key.KindSigner),
+		key.Triple{Kty: iana.KeyTypeOKP, Alg: iana.AlgorithmEdDSA, Crv: iana.EllipticCurveEd25519})
+	assert.Contains(key.DefaultRegistry.Supported(key.KindGenerator),
+		key.Triple{Kty: iana.KeyTypeOKP, Crv: iana.EllipticCurveX25519})
+	assert.Contains(key.DefaultRegistry.Supported(key.KindEncryptor),
+		key.Triple{Kty: iana.KeyTypeSymmetric, Alg: iana.AlgorithmA256GCM})
+	assert.Contains(key.DefaultRegistry.Supported(key.KindChecker),
+		key.Triple{Kty: iana.KeyTypeEC2, Alg: iana.AlgorithmECDH_ES_HKDF_256, Crv: iana.EllipticCurveP_256})
+	assert.Contains(key.DefaultRegistry.Supported(key.KindConverter),
+		key.Triple{Kty: iana.KeyTypeOKP, Alg: iana.AlgorithmReserved, Crv: iana.EllipticCurveX25519})
+
+	// a service that only accepts EdDSA signatures
+	reg := key.DefaultRegistry.Filter(func(kind key.FactoryKind, t key.Triple) bool {
+		return kind == key.KindVerifier && t.Alg == iana.AlgorithmEdDSA
+	})
+
+	k1, err := key.Generate(iana.KeyTypeOKP, iana.AlgorithmEdDSA, 0)
+	require.NoError(err)
+	k2, err := key.Generate(iana.KeyTypeEC2, iana.AlgorithmES256, 0)
+	require.NoError(err)
+
+	signer, err := k1.Signer()
+	require.NoError(err)
+	sig, err := signer.Sign([]byte("hello"))
+	require.NoError(err)
+	verifier, err := reg.Verifier(k1)
+	require.NoError(err)
+	assert.NoError(verifier.Verify([]byte("hello"), sig))
+
+	_, err = reg.Verifier(k2)
+	assert.ErrorContains(err, "kty(2)_alg(-7)_crv(1) is not registered")
+	_, err = k2.Verifier()
+	assert.NoError(err, "the DefaultRegistry is not changed")
+	_, err = reg.Signer(k1)
+	assert.ErrorContains(err, "is not registered")
+}
@@ -356,11 +356,12 @@ func NewECDHer(k key.Key) (*ECDHer, error) {
 }
 
 // ECDH performs a ECDH exchange and returns the shared secret. The PrivateKey and PublicKey must use the same curve.
+// The key_ops parameter of the private key must include "derive key" or "derive bits" if it is present,
+// or if key.DefaultRegistry is in strict mode.
 // https://pkg.go.dev/crypto/ecdh#PrivateKey.ECDH
 func (e *ECDHer) ECDH(remotePublic key.Key) ([]byte, error) {
-	if ops := e.key.Ops(); !ops.EmptyOrHas(iana.KeyOperationDeriveKey) &&
-		!ops.EmptyOrHas(iana.KeyOperationDeriveBits) {
-		return nil, fmt.Errorf("cose/key/ecdh: ECDHer.ECDH: invalid key_ops")
+	if err := e.key.CheckOps(key.DefaultRegistry.StrictOps(), iana.KeyOperationDeriveKey, iana.KeyOperationDeriveBits); err != nil {
+		return nil, fmt.Errorf("cose/key/ecdh: ECDHer.ECDH: %w", err)
 	}
 
 	if remotePublic.Has(iana.EC2KeyParameterD) {
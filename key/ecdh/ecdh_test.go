@@ -529,6 +529,20 @@ func TestNewECDHer(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(32, len(secret))
 
+	key.DefaultRegistry.SetStrictOps(true)
+	defer key.DefaultRegistry.SetStrictOps(false)
+	_, err = ecdher.ECDH(pubK2)
+	assert.ErrorContains(err, "missing key_ops for operation 7 in strict mode")
+	var opErr *key.OpError
+	require.ErrorAs(t, err, &opErr)
+	assert.True(opErr.Strict)
+
+	privK.SetOps(iana.KeyOperationDeriveBits)
+	secret, err = ecdher.ECDH(pubK2)
+	require.NoError(t, err)
+	key.DefaultRegistry.SetStrictOps(false)
+	privK.SetOps()
+
 	ecdher2, err := NewECDHer(privK2)
 	require.NoError(t, err)
 	secret2, err := ecdher2.ECDH(pubK)
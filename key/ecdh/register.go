@@ -0,0 +1,71 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package ecdh
+
+import (
+	goecdh "crypto/ecdh"
+	"fmt"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+func init() {
+	key.RegisterGenerator(iana.KeyTypeEC2, iana.AlgorithmReserved, iana.EllipticCurveP_256, generate)
+	key.RegisterGenerator(iana.KeyTypeEC2, iana.AlgorithmReserved, iana.EllipticCurveP_384, generate)
+	key.RegisterGenerator(iana.KeyTypeEC2, iana.AlgorithmReserved, iana.EllipticCurveP_521, generate)
+	key.RegisterGenerator(iana.KeyTypeOKP, iana.AlgorithmReserved, iana.EllipticCurveX25519, generate)
+
+	key.RegisterChecker(iana.KeyTypeOKP, iana.AlgorithmReserved, iana.EllipticCurveX25519, CheckKey)
+	key.RegisterConverter(iana.KeyTypeOKP, iana.AlgorithmReserved, iana.EllipticCurveX25519, converter)
+	for _, alg := range []int{
+		iana.AlgorithmECDH_ES_HKDF_256, iana.AlgorithmECDH_ES_HKDF_512,
+		iana.AlgorithmECDH_SS_HKDF_256, iana.AlgorithmECDH_SS_HKDF_512,
+		iana.AlgorithmECDH_ES_A128KW, iana.AlgorithmECDH_ES_A192KW, iana.AlgorithmECDH_ES_A256KW,
+		iana.AlgorithmECDH_SS_A128KW, iana.AlgorithmECDH_SS_A192KW, iana.AlgorithmECDH_SS_A256KW,
+	} {
+		key.RegisterChecker(iana.KeyTypeEC2, alg, iana.EllipticCurveP_256, CheckKey)
+		key.RegisterChecker(iana.KeyTypeEC2, alg, iana.EllipticCurveP_384, CheckKey)
+		key.RegisterChecker(iana.KeyTypeEC2, alg, iana.EllipticCurveP_521, CheckKey)
+		key.RegisterChecker(iana.KeyTypeOKP, alg, iana.EllipticCurveX25519, CheckKey)
+
+		key.RegisterConverter(iana.KeyTypeEC2, alg, iana.EllipticCurveP_256, converter)
+		key.RegisterConverter(iana.KeyTypeEC2, alg, iana.EllipticCurveP_384, converter)
+		key.RegisterConverter(iana.KeyTypeEC2, alg, iana.EllipticCurveP_521, converter)
+		key.RegisterConverter(iana.KeyTypeOKP, alg, iana.EllipticCurveX25519, converter)
+	}
+}
+
+var converter = key.CryptoConverter{FromCrypto: fromCrypto, ToCrypto: toCrypto}
+
+func generate(_, crv int) (key.Key, error) {
+	return GenerateKey(crv)
+}
+
+func fromCrypto(pk any) (key.Key, error) {
+	switch pk := pk.(type) {
+	case *goecdh.PrivateKey:
+		return KeyFromPrivate(pk)
+	case *goecdh.PublicKey:
+		return KeyFromPublic(pk)
+	default:
+		return nil, fmt.Errorf("cose/key/ecdh: unsupported crypto key %T", pk)
+	}
+}
+
+func toCrypto(k key.Key) (any, error) {
+	if k.Has(iana.EC2KeyParameterD) {
+		pk, err := KeyToPrivate(k)
+		if err != nil {
+			return nil, err
+		}
+		return pk, nil
+	}
+
+	pk, err := KeyToPublic(k)
+	if err != nil {
+		return nil, err
+	}
+	return pk, nil
+}
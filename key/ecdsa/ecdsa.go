@@ -6,7 +6,6 @@
 package ecdsa
 
 import (
-	"bytes"
 	goecdsa "crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -36,8 +35,8 @@ func GenerateKey(alg int) (key.Key, error) {
 		iana.KeyParameterKty:    iana.KeyTypeEC2,
 		iana.KeyParameterKid:    key.SumKid(pk.PublicKey.X.Bytes()), // default kid, can be set to other value.
 		iana.KeyParameterAlg:    alg,
-		iana.EC2KeyParameterCrv: crv,          // REQUIRED
-		iana.EC2KeyParameterD:   pk.D.Bytes(), // REQUIRED
+		iana.EC2KeyParameterCrv: crv,                                          // REQUIRED
+		iana.EC2KeyParameterD:   pk.D.FillBytes(make([]byte, keySize(curve))), // REQUIRED
 	}, nil
 }
 
@@ -55,12 +54,15 @@ func KeyToPrivate(k key.Key) (*goecdsa.PrivateKey, error) {
 	privKey := new(goecdsa.PrivateKey)
 	privKey.PublicKey.Curve = curve
 	privKey.D = new(big.Int).SetBytes(d)
+	if len(d) > keySize(curve) || privKey.D.Sign() == 0 || privKey.D.Cmp(curve.Params().N) >= 0 {
+		return nil, fmt.Errorf("cose/key/ecdsa: KeyToPrivate: invalid parameter d")
+	}
 	privKey.PublicKey.X, privKey.PublicKey.Y = curve.ScalarBaseMult(d)
 
-	if x, _ := k.GetBytes(iana.EC2KeyParameterX); x != nil && !bytes.Equal(privKey.PublicKey.X.Bytes(), x) {
+	if x, _ := k.GetBytes(iana.EC2KeyParameterX); x != nil && privKey.PublicKey.X.Cmp(new(big.Int).SetBytes(x)) != 0 {
 		return nil, fmt.Errorf("cose/key/ecdsa: KeyToPrivate: parameter x mismatch")
 	}
-	if y, _ := k.GetBytes(iana.EC2KeyParameterY); y != nil && !bytes.Equal(privKey.PublicKey.Y.Bytes(), y) {
+	if y, _ := k.GetBytes(iana.EC2KeyParameterY); y != nil && privKey.PublicKey.Y.Cmp(new(big.Int).SetBytes(y)) != 0 {
 		return nil, fmt.Errorf("cose/key/ecdsa: KeyToPrivate: parameter y mismatch")
 	}
 	return privKey, nil
@@ -77,8 +79,8 @@ func KeyFromPrivate(pk *goecdsa.PrivateKey) (key.Key, error) {
 		iana.KeyParameterKty:    iana.KeyTypeEC2,
 		iana.KeyParameterKid:    key.SumKid(pk.PublicKey.X.Bytes()), // default kid, can be set to other value.
 		iana.KeyParameterAlg:    alg,
-		iana.EC2KeyParameterCrv: crv,          // REQUIRED
-		iana.EC2KeyParameterD:   pk.D.Bytes(), // REQUIRED
+		iana.EC2KeyParameterCrv: crv,                                             // REQUIRED
+		iana.EC2KeyParameterD:   pk.D.FillBytes(make([]byte, keySize(pk.Curve))), // REQUIRED
 	}, nil
 }
 
@@ -98,13 +100,17 @@ func KeyFromPublic(pk *goecdsa.PublicKey) (key.Key, error) {
 		return nil, fmt.Errorf("cose/key/ecdsa: KeyFromPublic: unsupported curve %v", pk.Curve.Params().Name)
 	}
 
+	// the leading zero octets of the coordinates are preserved.
+	// https://datatracker.ietf.org/doc/html/rfc9053#name-double-coordinate-curves
+	size := keySize(pk.Curve)
+
 	return map[any]any{
 		iana.KeyParameterKty:    iana.KeyTypeEC2,
 		iana.KeyParameterKid:    key.SumKid(pk.X.Bytes()), // default kid, can be set to other value.
 		iana.KeyParameterAlg:    alg,
-		iana.EC2KeyParameterCrv: crv,          // REQUIRED
-		iana.EC2KeyParameterX:   pk.X.Bytes(), // REQUIRED
-		iana.EC2KeyParameterY:   pk.Y.Bytes(), // REQUIRED
+		iana.EC2KeyParameterCrv: crv,                                // REQUIRED
+		iana.EC2KeyParameterX:   pk.X.FillBytes(make([]byte, size)), // REQUIRED
+		iana.EC2KeyParameterY:   pk.Y.FillBytes(make([]byte, size)), // REQUIRED
 	}, nil
 }
 
@@ -163,7 +169,11 @@ func CheckKey(k key.Key) error {
 			}
 
 		case iana.KeyParameterKeyOps: // optional
-			for _, op := range k.Ops() {
+			ops := k.Ops()
+			if len(ops) == 0 {
+				return fmt.Errorf(`cose/key/ecdsa: CheckKey: invalid parameter key_ops`)
+			}
+			for _, op := range ops {
 				switch op {
 				case iana.KeyOperationSign, iana.KeyOperationVerify:
 				// continue
@@ -274,17 +284,18 @@ func ToPublicKey(k key.Key) (key.Key, error) {
 
 	curve, _ := getCurve(k.Alg())
 	ix, iy := curve.ScalarBaseMult(d)
-	x := ix.Bytes()
-	y := iy.Bytes()
+	size := keySize(curve)
+	x := ix.FillBytes(make([]byte, size))
+	y := iy.FillBytes(make([]byte, size))
 
 	if k.Has(iana.EC2KeyParameterX) {
 		x2, _ := k.GetBytes(iana.EC2KeyParameterX)
-		if !bytes.Equal(x, x2) {
+		if ix.Cmp(new(big.Int).SetBytes(x2)) != 0 {
 			return nil, fmt.Errorf(`cose/key/ecdsa: ToPublicKey: parameter x mismatch`)
 		}
 
 		y2, err := k.GetBytes(iana.EC2KeyParameterY)
-		if err == nil && !bytes.Equal(y, y2) {
+		if err == nil && iy.Cmp(new(big.Int).SetBytes(y2)) != 0 {
 			return nil, fmt.Errorf(`cose/key/ecdsa: ToPublicKey: parameter y mismatch`)
 		}
 	}
@@ -341,8 +352,8 @@ func NewSigner(k key.Key) (key.Signer, error) {
 // Sign implements the key.Signer interface.
 // Sign computes the digital signature for data.
 func (e *ecdsaSigner) Sign(data []byte) ([]byte, error) {
-	if !e.key.Ops().EmptyOrHas(iana.KeyOperationSign) {
-		return nil, fmt.Errorf("cose/key/ecdsa: Signer.Sign: invalid key_ops")
+	if err := e.key.CheckOps(false, iana.KeyOperationSign); err != nil {
+		return nil, fmt.Errorf("cose/key/ecdsa: Signer.Sign: %w", err)
 	}
 
 	hashed, err := key.ComputeHash(e.key.Alg().HashFunc(), data)
@@ -385,8 +396,8 @@ func NewVerifier(k key.Key) (key.Verifier, error) {
 // Verify implements the key.Verifier interface.
 // Verifies returns nil if signature is a valid signature for data; otherwise returns an error.
 func (e *ecdsaVerifier) Verify(data, sig []byte) error {
-	if !e.key.Ops().EmptyOrHas(iana.KeyOperationVerify) {
-		return fmt.Errorf("cose/key/ecdsa: Verifier.Verify: invalid key_ops")
+	if err := e.key.CheckOps(false, iana.KeyOperationVerify); err != nil {
+		return fmt.Errorf("cose/key/ecdsa: Verifier.Verify: %w", err)
 	}
 
 	hashed, err := key.ComputeHash(e.key.Alg().HashFunc(), data)
@@ -480,3 +491,7 @@ func getAlgCrv(curve elliptic.Curve) (alg, crv int) {
 		return 0, 0
 	}
 }
+
+func keySize(curve elliptic.Curve) int {
+	return (curve.Params().BitSize + 7) / 8
+}
@@ -7,6 +7,7 @@ import (
 	goecdsa "crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"math/big"
 	"testing"
 
 	"github.com/ldclabs/cose/iana"
@@ -127,8 +128,31 @@ func TestKeyFromPrivate(t *testing.T) {
 		assert.Equal(key.MustMarshalCBOR(k), key.MustMarshalCBOR(k2))
 	}
 
-	pk, _ := goecdsa.GenerateKey(elliptic.P224(), rand.Reader)
-	_, err := KeyFromPrivate(pk)
+	// the leading zero octets are preserved
+	pk := &goecdsa.PrivateKey{D: big.NewInt(1)}
+	pk.Curve = elliptic.P521()
+	pk.X, pk.Y = pk.Curve.Params().Gx, pk.Curve.Params().Gy
+	k, err := KeyFromPrivate(pk)
+	require.NoError(t, err)
+	d, _ := k.GetBytes(iana.EC2KeyParameterD)
+	assert.Len(d, 66)
+	pk1, err := KeyToPrivate(k)
+	require.NoError(t, err)
+	assert.True(pk.Equal(pk1))
+
+	k, err = KeyFromPublic(&pk.PublicKey)
+	require.NoError(t, err)
+	x, _ := k.GetBytes(iana.EC2KeyParameterX)
+	assert.Len(x, 66)
+	y, _ := k.GetBytes(iana.EC2KeyParameterY)
+	assert.Len(y, 66)
+
+	k[iana.EC2KeyParameterD] = make([]byte, 66)
+	_, err = KeyToPrivate(k)
+	assert.ErrorContains(err, "invalid parameter d")
+
+	pk, _ = goecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	_, err = KeyFromPrivate(pk)
 	assert.ErrorContains(err, `unsupported curve P-224`)
 }
 
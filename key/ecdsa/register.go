@@ -4,6 +4,9 @@
 package ecdsa
 
 import (
+	goecdsa "crypto/ecdsa"
+	"fmt"
+
 	"github.com/ldclabs/cose/iana"
 	"github.com/ldclabs/cose/key"
 )
@@ -16,4 +19,49 @@ func init() {
 	key.RegisterVerifier(iana.KeyTypeEC2, iana.AlgorithmES256, iana.EllipticCurveP_256, NewVerifier)
 	key.RegisterVerifier(iana.KeyTypeEC2, iana.AlgorithmES384, iana.EllipticCurveP_384, NewVerifier)
 	key.RegisterVerifier(iana.KeyTypeEC2, iana.AlgorithmES512, iana.EllipticCurveP_521, NewVerifier)
+
+	key.RegisterGenerator(iana.KeyTypeEC2, iana.AlgorithmES256, iana.EllipticCurveP_256, generate)
+	key.RegisterGenerator(iana.KeyTypeEC2, iana.AlgorithmES384, iana.EllipticCurveP_384, generate)
+	key.RegisterGenerator(iana.KeyTypeEC2, iana.AlgorithmES512, iana.EllipticCurveP_521, generate)
+
+	key.RegisterChecker(iana.KeyTypeEC2, iana.AlgorithmES256, iana.EllipticCurveP_256, CheckKey)
+	key.RegisterChecker(iana.KeyTypeEC2, iana.AlgorithmES384, iana.EllipticCurveP_384, CheckKey)
+	key.RegisterChecker(iana.KeyTypeEC2, iana.AlgorithmES512, iana.EllipticCurveP_521, CheckKey)
+
+	key.RegisterConverter(iana.KeyTypeEC2, iana.AlgorithmES256, iana.EllipticCurveP_256, converter)
+	key.RegisterConverter(iana.KeyTypeEC2, iana.AlgorithmES384, iana.EllipticCurveP_384, converter)
+	key.RegisterConverter(iana.KeyTypeEC2, iana.AlgorithmES512, iana.EllipticCurveP_521, converter)
+}
+
+var converter = key.CryptoConverter{FromCrypto: fromCrypto, ToCrypto: toCrypto}
+
+func generate(alg, _ int) (key.Key, error) {
+	return GenerateKey(alg)
+}
+
+func fromCrypto(pk any) (key.Key, error) {
+	switch pk := pk.(type) {
+	case *goecdsa.PrivateKey:
+		return KeyFromPrivate(pk)
+	case *goecdsa.PublicKey:
+		return KeyFromPublic(pk)
+	default:
+		return nil, fmt.Errorf("cose/key/ecdsa: unsupported crypto key %T", pk)
+	}
+}
+
+func toCrypto(k key.Key) (any, error) {
+	if k.Has(iana.EC2KeyParameterD) {
+		pk, err := KeyToPrivate(k)
+		if err != nil {
+			return nil, err
+		}
+		return pk, nil
+	}
+
+	pk, err := KeyToPublic(k)
+	if err != nil {
+		return nil, err
+	}
+	return pk, nil
 }
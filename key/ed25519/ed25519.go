@@ -127,7 +127,11 @@ func CheckKey(k key.Key) error {
 			}
 
 		case iana.KeyParameterKeyOps: // optional
-			for _, op := range k.Ops() {
+			ops := k.Ops()
+			if len(ops) == 0 {
+				return fmt.Errorf(`cose/key/ed25519: CheckKey: invalid parameter key_ops`)
+			}
+			for _, op := range ops {
 				switch op {
 				case iana.KeyOperationSign, iana.KeyOperationVerify:
 				// continue
@@ -246,8 +250,8 @@ func NewSigner(k key.Key) (key.Signer, error) {
 // Sign implements the key.Signer interface.
 // Sign computes the digital signature for data.
 func (e *ed25519Signer) Sign(data []byte) ([]byte, error) {
-	if !e.key.Ops().EmptyOrHas(iana.KeyOperationSign) {
-		return nil, fmt.Errorf("cose/key/ed25519: Signer.Sign: invalid key_ops")
+	if err := e.key.CheckOps(false, iana.KeyOperationSign); err != nil {
+		return nil, fmt.Errorf("cose/key/ed25519: Signer.Sign: %w", err)
 	}
 
 	return goed25519.Sign(e.privKey, data), nil
@@ -278,8 +282,8 @@ func NewVerifier(k key.Key) (key.Verifier, error) {
 // Verify implements the key.Verifier interface.
 // Verifies returns nil if signature is a valid signature for data; otherwise returns an error.
 func (e *ed25519Verifier) Verify(data, sig []byte) error {
-	if !e.key.Ops().EmptyOrHas(iana.KeyOperationVerify) {
-		return fmt.Errorf("cose/key/ed25519: Verifier.Verify: invalid key_ops")
+	if err := e.key.CheckOps(false, iana.KeyOperationVerify); err != nil {
+		return fmt.Errorf("cose/key/ed25519: Verifier.Verify: %w", err)
 	}
 
 	if !goed25519.Verify(e.pubKey, data, sig) {
@@ -4,6 +4,9 @@
 package ed25519
 
 import (
+	goed25519 "crypto/ed25519"
+	"fmt"
+
 	"github.com/ldclabs/cose/iana"
 	"github.com/ldclabs/cose/key"
 )
@@ -12,4 +15,43 @@ func init() {
 	key.RegisterSigner(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, NewSigner)
 
 	key.RegisterVerifier(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, NewVerifier)
+
+	key.RegisterGenerator(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, generate)
+
+	key.RegisterChecker(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, CheckKey)
+
+	key.RegisterConverter(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, converter)
+}
+
+var converter = key.CryptoConverter{FromCrypto: fromCrypto, ToCrypto: toCrypto}
+
+func generate(_, _ int) (key.Key, error) {
+	return GenerateKey()
+}
+
+func fromCrypto(pk any) (key.Key, error) {
+	switch pk := pk.(type) {
+	case goed25519.PrivateKey:
+		return KeyFromPrivate(pk)
+	case goed25519.PublicKey:
+		return KeyFromPublic(pk)
+	default:
+		return nil, fmt.Errorf("cose/key/ed25519: unsupported crypto key %T", pk)
+	}
+}
+
+func toCrypto(k key.Key) (any, error) {
+	if k.Has(iana.OKPKeyParameterD) {
+		pk, err := KeyToPrivate(k)
+		if err != nil {
+			return nil, err
+		}
+		return pk, nil
+	}
+
+	pk, err := KeyToPublic(k)
+	if err != nil {
+		return nil, err
+	}
+	return pk, nil
 }
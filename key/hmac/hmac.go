@@ -73,7 +73,11 @@ func CheckKey(k key.Key) error {
 			}
 
 		case iana.KeyParameterKeyOps: // optional
-			for _, op := range k.Ops() {
+			ops := k.Ops()
+			if len(ops) == 0 {
+				return fmt.Errorf(`cose/key/hmac: CheckKey: invalid parameter key_ops`)
+			}
+			for _, op := range ops {
 				switch op {
 				case iana.KeyOperationMacCreate, iana.KeyOperationMacVerify:
 				// continue
@@ -132,8 +136,8 @@ func New(k key.Key) (key.MACer, error) {
 // MACCreate implements the key.MACer interface.
 // MACCreate computes message authentication code (MAC) for the given data.
 func (h *hMAC) MACCreate(data []byte) ([]byte, error) {
-	if !h.key.Ops().EmptyOrHas(iana.KeyOperationMacCreate) {
-		return nil, fmt.Errorf("cose/key/hmac: MACCreate: invalid key_ops")
+	if err := h.key.CheckOps(false, iana.KeyOperationMacCreate); err != nil {
+		return nil, fmt.Errorf("cose/key/hmac: MACCreate: %w", err)
 	}
 
 	return h.create(data), nil
@@ -142,8 +146,8 @@ func (h *hMAC) MACCreate(data []byte) ([]byte, error) {
 // MACVerify implements the key.MACer interface.
 // MACVerify verifies whether the given MAC is a correct message authentication code (MAC) the given data.
 func (h *hMAC) MACVerify(data, mac []byte) error {
-	if !h.key.Ops().EmptyOrHas(iana.KeyOperationMacVerify) {
-		return fmt.Errorf("cose/key/hmac: MACVerify: invalid key_ops")
+	if err := h.key.CheckOps(false, iana.KeyOperationMacVerify); err != nil {
+		return fmt.Errorf("cose/key/hmac: MACVerify: %w", err)
 	}
 
 	expectedMAC := h.create(data)
@@ -177,6 +177,15 @@ func TestMACer(t *testing.T) {
 	k.SetOps(iana.KeyOperationMacVerify)
 	_, err = macer.MACCreate([]byte("hello world"))
 	assert.ErrorContains(err, "invalid key_ops")
+	var opErr *key.OpError
+	require.ErrorAs(t, err, &opErr)
+	assert.Equal(iana.KeyOperationMacCreate, opErr.Op)
+	assert.ErrorIs(err, key.ErrOpNotPermitted)
+
+	k[iana.KeyParameterKeyOps] = "MAC create"
+	_, err = macer.MACCreate([]byte("hello world"))
+	assert.ErrorIs(err, key.ErrOpNotPermitted, "malformed key_ops permits nothing")
+	assert.ErrorContains(CheckKey(k), "invalid parameter key_ops")
 
 	k.SetOps(iana.KeyOperationMacCreate)
 	assert.ErrorContains(macer.MACVerify([]byte("hello world"), tag), "invalid key_ops")
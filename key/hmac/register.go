@@ -13,4 +13,18 @@ func init() {
 	key.RegisterMACer(iana.KeyTypeSymmetric, iana.AlgorithmHMAC_256_256, New)
 	key.RegisterMACer(iana.KeyTypeSymmetric, iana.AlgorithmHMAC_384_384, New)
 	key.RegisterMACer(iana.KeyTypeSymmetric, iana.AlgorithmHMAC_512_512, New)
+
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmHMAC_256_64, 0, generate)
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmHMAC_256_256, 0, generate)
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmHMAC_384_384, 0, generate)
+	key.RegisterGenerator(iana.KeyTypeSymmetric, iana.AlgorithmHMAC_512_512, 0, generate)
+
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmHMAC_256_64, 0, CheckKey)
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmHMAC_256_256, 0, CheckKey)
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmHMAC_384_384, 0, CheckKey)
+	key.RegisterChecker(iana.KeyTypeSymmetric, iana.AlgorithmHMAC_512_512, 0, CheckKey)
+}
+
+func generate(alg, _ int) (key.Key, error) {
+	return GenerateKey(alg)
 }
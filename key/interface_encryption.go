@@ -22,5 +22,6 @@ type Encryptor interface {
 	// Key returns the symmetric key in the Encryptor.
 	// If the key's "key_ops" field is present, it MUST include "encrypt":3 when encrypting an plaintext.
 	// If the key's "key_ops" field is present, it MUST include "decrypt":4 when decrypting an ciphertext.
+	// Otherwise the operation returns an error that wraps an *OpError, see Key.CheckOps.
 	Key() Key
 }
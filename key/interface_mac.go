@@ -17,5 +17,6 @@ type MACer interface {
 	// Key returns the key in the MACer.
 	// If the key's "key_ops" field is present, it MUST include "MAC create":9 when creating an HMAC authentication tag.
 	// If the key's "key_ops" field is present, it MUST include "MAC verify":10 when verifying an HMAC authentication tag.
+	// Otherwise the operation returns an error that wraps an *OpError, see Key.CheckOps.
 	Key() Key
 }
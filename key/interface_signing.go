@@ -15,6 +15,7 @@ type Signer interface {
 
 	// Key returns the private key in the Signer.
 	// If the key's "key_ops" field is present, it MUST include "sign":1.
+	// Otherwise the operation returns an error that wraps an *OpError, see Key.CheckOps.
 	Key() Key
 }
 
@@ -27,7 +28,8 @@ type Verifier interface {
 
 	// Key returns the public key in the Verifier.
 	// The key returned by this method should not include private key bytes.
-	// If the key's "key_ops" field is present, it MUST include "verify":12.
+	// If the key's "key_ops" field is present, it MUST include "verify":2.
+	// Otherwise the operation returns an error that wraps an *OpError, see Key.CheckOps.
 	Key() Key
 }
 
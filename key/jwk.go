@@ -0,0 +1,614 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package key
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/ldclabs/cose/iana"
+)
+
+// errUnsupportedJWKType is returned when the "kty" of a JWK has no COSE mapping,
+// such keys are skipped by UnmarshalJWKS as RFC7517 requires.
+var errUnsupportedJWKType = errors.New("unsupported key type")
+
+var jwkKty = map[int]string{
+	iana.KeyTypeOKP:       "OKP",
+	iana.KeyTypeEC2:       "EC",
+	iana.KeyTypeRSA:       "RSA",
+	iana.KeyTypeSymmetric: "oct",
+}
+
+var jwkCrv = map[int]map[int]string{
+	iana.KeyTypeOKP: {
+		iana.EllipticCurveX25519:  "X25519",
+		iana.EllipticCurveX448:    "X448",
+		iana.EllipticCurveEd25519: "Ed25519",
+		iana.EllipticCurveEd448:   "Ed448",
+	},
+	iana.KeyTypeEC2: {
+		iana.EllipticCurveP_256:     "P-256",
+		iana.EllipticCurveP_384:     "P-384",
+		iana.EllipticCurveP_521:     "P-521",
+		iana.EllipticCurveSecp256k1: "secp256k1",
+	},
+}
+
+// jwkParams maps the COSE key type parameters to the JWK members,
+// the values are base64url encoded in JWK.
+var jwkParams = map[int]map[int]string{
+	iana.KeyTypeOKP: {
+		iana.OKPKeyParameterX: "x",
+		iana.OKPKeyParameterD: "d",
+	},
+	iana.KeyTypeEC2: {
+		iana.EC2KeyParameterX: "x",
+		iana.EC2KeyParameterY: "y",
+		iana.EC2KeyParameterD: "d",
+	},
+	iana.KeyTypeRSA: {
+		iana.RSAKeyParameterN:    "n",
+		iana.RSAKeyParameterE:    "e",
+		iana.RSAKeyParameterD:    "d",
+		iana.RSAKeyParameterP:    "p",
+		iana.RSAKeyParameterQ:    "q",
+		iana.RSAKeyParameterDP:   "dp",
+		iana.RSAKeyParameterDQ:   "dq",
+		iana.RSAKeyParameterQInv: "qi",
+	},
+	iana.KeyTypeSymmetric: {
+		iana.SymmetricKeyParameterK: "k",
+	},
+}
+
+// jwkRequired is the required JWK members of each key type.
+// The public key members of OKP and EC keys are optional if the private key "d" is present,
+// as the private keys generated by this package may omit them.
+var jwkRequired = map[int][]string{
+	iana.KeyTypeOKP:       {"crv"},
+	iana.KeyTypeEC2:       {"crv"},
+	iana.KeyTypeRSA:       {"n", "e"},
+	iana.KeyTypeSymmetric: {"k"},
+}
+
+// jwkAlg maps the COSE algorithms to the JOSE algorithms with the same semantics.
+//
+// Reference https://www.iana.org/assignments/jose/jose.xhtml#web-signature-encryption-algorithms
+var jwkAlg = map[int]string{
+	iana.AlgorithmES256:                       "ES256",
+	iana.AlgorithmES384:                       "ES384",
+	iana.AlgorithmES512:                       "ES512",
+	iana.AlgorithmES256K:                      "ES256K",
+	iana.AlgorithmEdDSA:                       "EdDSA",
+	iana.AlgorithmPS256:                       "PS256",
+	iana.AlgorithmPS384:                       "PS384",
+	iana.AlgorithmPS512:                       "PS512",
+	iana.AlgorithmRS256:                       "RS256",
+	iana.AlgorithmRS384:                       "RS384",
+	iana.AlgorithmRS512:                       "RS512",
+	iana.AlgorithmRSAES_OAEP_RFC_8017_default: "RSA-OAEP",
+	iana.AlgorithmRSAES_OAEP_SHA_256:          "RSA-OAEP-256",
+	iana.AlgorithmHMAC_256_256:                "HS256",
+	iana.AlgorithmHMAC_384_384:                "HS384",
+	iana.AlgorithmHMAC_512_512:                "HS512",
+	iana.AlgorithmA128GCM:                     "A128GCM",
+	iana.AlgorithmA192GCM:                     "A192GCM",
+	iana.AlgorithmA256GCM:                     "A256GCM",
+	iana.AlgorithmA128KW:                      "A128KW",
+	iana.AlgorithmA192KW:                      "A192KW",
+	iana.AlgorithmA256KW:                      "A256KW",
+	iana.AlgorithmDirect:                      "dir",
+}
+
+// jwkOps maps the COSE key operations to the JWK "key_ops" values.
+// The MAC operations of symmetric keys are "sign" and "verify" in JWK.
+var jwkOps = map[int]string{
+	iana.KeyOperationSign:       "sign",
+	iana.KeyOperationVerify:     "verify",
+	iana.KeyOperationEncrypt:    "encrypt",
+	iana.KeyOperationDecrypt:    "decrypt",
+	iana.KeyOperationWrapKey:    "wrapKey",
+	iana.KeyOperationUnwrapKey:  "unwrapKey",
+	iana.KeyOperationDeriveKey:  "deriveKey",
+	iana.KeyOperationDeriveBits: "deriveBits",
+}
+
+var (
+	jwkKtyByName = reverseMap(jwkKty)
+	jwkAlgByName = reverseMap(jwkAlg)
+	jwkOpsByName = reverseMap(jwkOps)
+)
+
+func reverseMap[K, V comparable](m map[K]V) map[V]K {
+	r := make(map[V]K, len(m))
+	for k, v := range m {
+		r[v] = k
+	}
+	return r
+}
+
+// JWKOpts is the options for converting between Key and JWK.
+type JWKOpts struct {
+	// Base64URLKid encodes the kid as a base64url string in ToJWKWithOpts,
+	// and decodes the "kid" member as a base64url string in KeyFromJWKWithOpts,
+	// so that the binary kids round-trip. It should be used by both sides.
+	Base64URLKid bool
+}
+
+// ToJWK converts the key to a RFC7517 JSON Web Key (JWK) object.
+// OKP, EC2, RSA and Symmetric keys are supported.
+//
+// The binary parameters are base64url encoded. The kid is a text string in JWK,
+// so it is used as is if it is valid UTF-8, otherwise it is base64url encoded.
+// Use ToJWKWithOpts to always encode the kid as base64url.
+// The text labeled parameters are preserved as JWK members, such as "x5c".
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc7517.
+func (k Key) ToJWK() (map[string]any, error) {
+	return k.ToJWKWithOpts(nil)
+}
+
+// ToJWKWithOpts converts the key to a RFC7517 JSON Web Key (JWK) object with the options, like ToJWK.
+func (k Key) ToJWKWithOpts(opts *JWKOpts) (map[string]any, error) {
+	kty := k.Kty()
+	name, ok := jwkKty[kty]
+	if !ok {
+		return nil, fmt.Errorf("cose/key: Key.ToJWK: %w %d", errUnsupportedJWKType, kty)
+	}
+
+	params := jwkParams[kty]
+	crvs := jwkCrv[kty]
+	jwk := map[string]any{"kty": name}
+	for label, v := range k {
+		switch label := label.(type) {
+		case string:
+			if isJWKMember(kty, label) {
+				return nil, fmt.Errorf("cose/key: Key.ToJWK: parameter %q conflicts with JWK member", label)
+			}
+			jv, err := toJSONValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("cose/key: Key.ToJWK: invalid parameter %q, %w", label, err)
+			}
+			jwk[label] = jv
+
+		case int:
+			switch {
+			case label == iana.KeyParameterKty:
+				// already set
+
+			case label == iana.KeyParameterKid:
+				kid, err := k.GetBytes(label)
+				if err != nil {
+					return nil, fmt.Errorf("cose/key: Key.ToJWK: invalid kid, %w", err)
+				}
+				if (opts == nil || !opts.Base64URLKid) && utf8.Valid(kid) {
+					jwk["kid"] = string(kid)
+				} else {
+					jwk["kid"] = ByteStr(kid).Base64()
+				}
+
+			case label == iana.KeyParameterAlg:
+				alg, err := k.GetInt(label)
+				if err != nil {
+					return nil, fmt.Errorf("cose/key: Key.ToJWK: invalid alg, %w", err)
+				}
+				name, ok := jwkAlg[alg]
+				if !ok {
+					return nil, fmt.Errorf("cose/key: Key.ToJWK: unsupported algorithm %d", alg)
+				}
+				jwk["alg"] = name
+
+			case label == iana.KeyParameterKeyOps:
+				ops := k.Ops()
+				if ops == nil {
+					return nil, errors.New("cose/key: Key.ToJWK: invalid key_ops")
+				}
+				names := make([]string, 0, len(ops))
+				for _, op := range ops {
+					if kty == iana.KeyTypeSymmetric {
+						switch op {
+						case iana.KeyOperationMacCreate:
+							op = iana.KeyOperationSign
+						case iana.KeyOperationMacVerify:
+							op = iana.KeyOperationVerify
+						}
+					}
+					name, ok := jwkOps[op]
+					if !ok {
+						return nil, fmt.Errorf("cose/key: Key.ToJWK: unsupported key operation %d", op)
+					}
+					names = append(names, name)
+				}
+				jwk["key_ops"] = names
+
+			case label == iana.OKPKeyParameterCrv && crvs != nil:
+				crv, err := k.GetInt(label)
+				if err != nil {
+					return nil, fmt.Errorf("cose/key: Key.ToJWK: invalid crv, %w", err)
+				}
+				name, ok := crvs[crv]
+				if !ok {
+					return nil, fmt.Errorf("cose/key: Key.ToJWK: unsupported curve %d", crv)
+				}
+				jwk["crv"] = name
+
+			default:
+				name, ok := params[label]
+				if !ok {
+					return nil, fmt.Errorf("cose/key: Key.ToJWK: unsupported parameter %d", label)
+				}
+				data, err := k.GetBytes(label)
+				if err != nil {
+					// such as the compressed point of EC2 key
+					return nil, fmt.Errorf("cose/key: Key.ToJWK: invalid parameter %q, %w", name, err)
+				}
+				jwk[name] = ByteStr(data).Base64()
+			}
+		}
+	}
+
+	return jwk, nil
+}
+
+// MarshalJWK returns the JSON encoding of the key as a JWK.
+func (k Key) MarshalJWK() ([]byte, error) {
+	jwk, err := k.ToJWK()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jwk)
+}
+
+// KeyFromJWK converts a RFC7517 JSON Web Key (JWK) object to a Key.
+// OKP, EC, RSA and oct keys are supported.
+//
+// The "kid" member is used as the UTF-8 kid bytes, as the JOSE side compares it.
+// Use KeyFromJWKWithOpts to decode a base64url kid.
+// The "use" member is converted to the key_ops parameter if the "key_ops" member is absent,
+// "sig" to sign and verify, "enc" to encrypt and decrypt, or wrap and unwrap.
+// The other JWK members without COSE mapping are preserved as text labeled parameters,
+// they should be removed before the key is used by the algorithm packages that reject unknown parameters.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc7517.
+func KeyFromJWK(jwk map[string]any) (Key, error) {
+	return KeyFromJWKWithOpts(jwk, nil)
+}
+
+// KeyFromJWKWithOpts converts a RFC7517 JSON Web Key (JWK) object to a Key with the options, like KeyFromJWK.
+func KeyFromJWKWithOpts(jwk map[string]any, opts *JWKOpts) (Key, error) {
+	name, _ := jwk["kty"].(string)
+	kty, ok := jwkKtyByName[name]
+	if !ok {
+		return nil, fmt.Errorf("cose/key: KeyFromJWK: %w %q", errUnsupportedJWKType, name)
+	}
+
+	for _, name := range jwkRequired[kty] {
+		if _, ok := jwk[name]; !ok {
+			return nil, fmt.Errorf("cose/key: KeyFromJWK: missing member %q", name)
+		}
+	}
+	_, private := jwk["d"]
+	if !private {
+		var coords []string
+		switch kty {
+		case iana.KeyTypeOKP:
+			coords = []string{"x"}
+		case iana.KeyTypeEC2:
+			coords = []string{"x", "y"}
+		}
+		for _, name := range coords {
+			if _, ok := jwk[name]; !ok {
+				return nil, fmt.Errorf("cose/key: KeyFromJWK: missing member %q", name)
+			}
+		}
+	}
+
+	params := reverseMap(jwkParams[kty])
+	crvs := jwkCrv[kty]
+	k := Key{iana.KeyParameterKty: kty}
+	for name, v := range jwk {
+		switch {
+		case name == "kty":
+			// already set
+
+		case name == "kid":
+			kid, ok := v.(string)
+			if !ok || kid == "" {
+				return nil, errors.New("cose/key: KeyFromJWK: invalid kid")
+			}
+			data := []byte(kid)
+			if opts != nil && opts.Base64URLKid {
+				var err error
+				if data, err = base64.RawURLEncoding.Strict().DecodeString(kid); err != nil || len(data) == 0 {
+					return nil, errors.New("cose/key: KeyFromJWK: invalid base64url kid")
+				}
+			}
+			k[iana.KeyParameterKid] = ByteStr(data)
+
+		case name == "use":
+			if _, ok := jwk["key_ops"]; ok {
+				// "use" and "key_ops" SHOULD NOT be used together, key_ops takes precedence.
+				continue
+			}
+			use, _ := v.(string)
+			alg, _ := jwk["alg"].(string)
+			ops, err := jwkUseOps(kty, use, jwkAlgByName[alg], private)
+			if err != nil {
+				return nil, fmt.Errorf("cose/key: KeyFromJWK: %w", err)
+			}
+			if len(ops) > 0 {
+				k[iana.KeyParameterKeyOps] = ops
+			}
+
+		case name == "alg":
+			s, _ := v.(string)
+			alg, ok := jwkAlgByName[s]
+			if !ok {
+				return nil, fmt.Errorf("cose/key: KeyFromJWK: unsupported algorithm %v", v)
+			}
+			k[iana.KeyParameterAlg] = alg
+
+		case name == "key_ops":
+			names, ok := v.([]any)
+			if !ok {
+				if ss, ok := v.([]string); ok {
+					names = make([]any, len(ss))
+					for i, s := range ss {
+						names[i] = s
+					}
+				} else {
+					return nil, errors.New("cose/key: KeyFromJWK: invalid key_ops")
+				}
+			}
+			ops := make(Ops, 0, len(names))
+			for _, n := range names {
+				s, _ := n.(string)
+				op, ok := jwkOpsByName[s]
+				if !ok {
+					return nil, fmt.Errorf("cose/key: KeyFromJWK: unsupported key operation %v", n)
+				}
+				if kty == iana.KeyTypeSymmetric {
+					switch op {
+					case iana.KeyOperationSign:
+						op = iana.KeyOperationMacCreate
+					case iana.KeyOperationVerify:
+						op = iana.KeyOperationMacVerify
+					}
+				}
+				ops = append(ops, op)
+			}
+			k[iana.KeyParameterKeyOps] = ops
+
+		case name == "crv" && crvs != nil:
+			s, _ := v.(string)
+			crv := 0
+			for c, n := range crvs {
+				if n == s {
+					crv = c
+					break
+				}
+			}
+			if crv == 0 {
+				return nil, fmt.Errorf("cose/key: KeyFromJWK: unsupported curve %v", v)
+			}
+			k[iana.OKPKeyParameterCrv] = crv
+
+		case name == "oth" && kty == iana.KeyTypeRSA:
+			return nil, errors.New("cose/key: KeyFromJWK: multi-prime RSA key is not supported")
+
+		default:
+			if label, ok := params[name]; ok {
+				s, _ := v.(string)
+				data, err := base64.RawURLEncoding.DecodeString(s)
+				if err != nil || len(data) == 0 {
+					return nil, fmt.Errorf("cose/key: KeyFromJWK: invalid member %q", name)
+				}
+				k[label] = data
+				continue
+			}
+
+			jv, err := fromJSONValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("cose/key: KeyFromJWK: invalid member %q, %w", name, err)
+			}
+			k[name] = jv
+		}
+	}
+
+	return k, nil
+}
+
+// UnmarshalJWK decodes a JWK from the JSON encoding to a Key.
+func UnmarshalJWK(data []byte) (Key, error) {
+	var jwk map[string]any
+	if err := unmarshalJSON(data, &jwk); err != nil {
+		return nil, fmt.Errorf("cose/key: UnmarshalJWK: %w", err)
+	}
+	return KeyFromJWK(jwk)
+}
+
+// MarshalJWKS returns the JSON encoding of the KeySet as a RFC7517 JWK Set.
+func (ks KeySet) MarshalJWKS() ([]byte, error) {
+	keys := make([]map[string]any, 0, len(ks))
+	for i, k := range ks {
+		jwk, err := k.ToJWK()
+		if err != nil {
+			return nil, fmt.Errorf("cose/key: KeySet.MarshalJWKS: key %d, %w", i, err)
+		}
+		keys = append(keys, jwk)
+	}
+	return json.Marshal(map[string]any{"keys": keys})
+}
+
+// UnmarshalJWKS decodes a RFC7517 JWK Set from the JSON encoding to a KeySet.
+// The keys with unsupported key types are ignored, as RFC7517 requires.
+func UnmarshalJWKS(data []byte) (KeySet, error) {
+	var jwks struct {
+		Keys []map[string]any `json:"keys"`
+	}
+	if err := unmarshalJSON(data, &jwks); err != nil {
+		return nil, fmt.Errorf("cose/key: UnmarshalJWKS: %w", err)
+	}
+	if jwks.Keys == nil {
+		return nil, errors.New("cose/key: UnmarshalJWKS: missing keys")
+	}
+
+	ks := make(KeySet, 0, len(jwks.Keys))
+	for i, jwk := range jwks.Keys {
+		k, err := KeyFromJWK(jwk)
+		switch {
+		case errors.Is(err, errUnsupportedJWKType):
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("cose/key: UnmarshalJWKS: key %d, %w", i, err)
+		}
+		ks = append(ks, k)
+	}
+	return ks, nil
+}
+
+// jwkUseOps converts the JWK "use" member to the key operations.
+// The public EC and OKP keys for key agreement have no key operations, as the ecdh package requires.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc7517#section-4.2
+func jwkUseOps(kty int, use string, alg int, private bool) (Ops, error) {
+	switch use {
+	case "sig":
+		switch {
+		case kty == iana.KeyTypeSymmetric:
+			return Ops{iana.KeyOperationMacCreate, iana.KeyOperationMacVerify}, nil
+		case private:
+			return Ops{iana.KeyOperationSign, iana.KeyOperationVerify}, nil
+		default:
+			return Ops{iana.KeyOperationVerify}, nil
+		}
+
+	case "enc":
+		switch kty {
+		case iana.KeyTypeSymmetric:
+			switch alg {
+			case iana.AlgorithmA128KW, iana.AlgorithmA192KW, iana.AlgorithmA256KW:
+				return Ops{iana.KeyOperationWrapKey, iana.KeyOperationUnwrapKey}, nil
+			case iana.AlgorithmReserved:
+				return Ops{iana.KeyOperationEncrypt, iana.KeyOperationDecrypt,
+					iana.KeyOperationWrapKey, iana.KeyOperationUnwrapKey}, nil
+			default:
+				return Ops{iana.KeyOperationEncrypt, iana.KeyOperationDecrypt}, nil
+			}
+		case iana.KeyTypeRSA:
+			if private {
+				return Ops{iana.KeyOperationEncrypt, iana.KeyOperationDecrypt,
+					iana.KeyOperationWrapKey, iana.KeyOperationUnwrapKey}, nil
+			}
+			return Ops{iana.KeyOperationEncrypt, iana.KeyOperationWrapKey}, nil
+		default:
+			if private {
+				return Ops{iana.KeyOperationDeriveKey, iana.KeyOperationDeriveBits}, nil
+			}
+			return nil, nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported use %q", use)
+	}
+}
+
+func isJWKMember(kty int, name string) bool {
+	switch name {
+	case "kty", "kid", "alg", "key_ops", "use":
+		return true
+	case "crv":
+		return jwkCrv[kty] != nil
+	}
+	for _, n := range jwkParams[kty] {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func unmarshalJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// fromJSONValue converts a JSON value decoded with UseNumber to a CBOR friendly value.
+func fromJSONValue(v any) (any, error) {
+	switch x := v.(type) {
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i, nil
+		}
+		return x.Float64()
+
+	case []any:
+		arr := make([]any, len(x))
+		for i, e := range x {
+			ev, err := fromJSONValue(e)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = ev
+		}
+		return arr, nil
+
+	case map[string]any:
+		m := make(map[string]any, len(x))
+		for k, e := range x {
+			ev, err := fromJSONValue(e)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = ev
+		}
+		return m, nil
+
+	default:
+		return v, nil
+	}
+}
+
+// toJSONValue converts a CBOR decoded value to a JSON friendly value.
+func toJSONValue(v any) (any, error) {
+	switch x := v.(type) {
+	case []any:
+		arr := make([]any, len(x))
+		for i, e := range x {
+			ev, err := toJSONValue(e)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = ev
+		}
+		return arr, nil
+
+	case CoseMap:
+		return toJSONValue(map[any]any(x))
+
+	case map[any]any:
+		m := make(map[string]any, len(x))
+		for k, e := range x {
+			s, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid map key %v", k)
+			}
+			ev, err := toJSONValue(e)
+			if err != nil {
+				return nil, err
+			}
+			m[s] = ev
+		}
+		return m, nil
+
+	default:
+		return v, nil
+	}
+}
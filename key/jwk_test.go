@@ -0,0 +1,378 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package key_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/ecdsa"
+	"github.com/ldclabs/cose/key/ed25519"
+	"github.com/ldclabs/cose/key/hmac"
+)
+
+func TestJWK(t *testing.T) {
+	t.Run("RFC7517 examples", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		// https://datatracker.ietf.org/doc/html/rfc7517#appendix-A.1
+		k, err := key.UnmarshalJWK([]byte(`{"kty":"EC","crv":"P-256",
+			"x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4",
+			"y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM",
+			"use":"enc","kid":"1"}`))
+		require.NoError(err)
+		assert.Equal(iana.KeyTypeEC2, k.Kty())
+		assert.Equal(key.ByteStr("1"), k.Kid())
+		assert.Equal(iana.AlgorithmES256, int(k.Alg()))
+		crv, _ := k.GetInt(iana.EC2KeyParameterCrv)
+		assert.Equal(iana.EllipticCurveP_256, crv)
+		assert.Equal(key.Base64Bytesify("MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4"), k.Get(iana.EC2KeyParameterX))
+		assert.False(k.Has("use"))
+		assert.False(k.Has(iana.KeyParameterKeyOps), "public key for key agreement has no key_ops")
+
+		data, err := k.MarshalJWK()
+		require.NoError(err)
+		assert.JSONEq(`{"kty":"EC","crv":"P-256",
+			"x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4",
+			"y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM",
+			"kid":"1"}`, string(data))
+
+		_, err = k.Verifier()
+		require.NoError(err)
+
+		// https://datatracker.ietf.org/doc/html/rfc7517#appendix-A.3
+		k, err = key.UnmarshalJWK([]byte(`{"kty":"oct","alg":"A128KW","k":"GawgguFyGrWKav7AX4VKUg"}`))
+		require.NoError(err)
+		assert.Equal(iana.KeyTypeSymmetric, k.Kty())
+		assert.Equal(iana.AlgorithmA128KW, int(k.Alg()))
+		assert.Equal(key.Base64Bytesify("GawgguFyGrWKav7AX4VKUg"), k.Get(iana.SymmetricKeyParameterK))
+
+		// https://datatracker.ietf.org/doc/html/rfc8037#appendix-A.1
+		k, err = key.UnmarshalJWK([]byte(`{"kty":"OKP","crv":"Ed25519",
+			"d":"nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A",
+			"x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`))
+		require.NoError(err)
+		assert.Equal(iana.AlgorithmEdDSA, int(k.Alg()))
+		assert.NoError(ed25519.CheckKey(k))
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		k1, err := ed25519.GenerateKey()
+		require.NoError(err)
+		k1.SetKid([]byte("ed25519-key"))
+		k2, err := ecdsa.GenerateKey(iana.AlgorithmES384)
+		require.NoError(err)
+		k2.SetKid([]byte("ecdsa-key"))
+		k3, err := hmac.GenerateKey(iana.AlgorithmHMAC_256_256)
+		require.NoError(err)
+		k3.SetKid([]byte("hmac-key"))
+		k3.SetOps(iana.KeyOperationMacCreate, iana.KeyOperationMacVerify)
+		k4 := key.Key{
+			iana.KeyParameterKty:     iana.KeyTypeRSA,
+			iana.KeyParameterKid:     []byte("rsa-key"),
+			iana.KeyParameterAlg:     iana.AlgorithmPS256,
+			iana.KeyParameterKeyOps:  key.Ops{iana.KeyOperationVerify},
+			iana.RSAKeyParameterN:    key.HexBytesify("c4a1f2b3"),
+			iana.RSAKeyParameterE:    key.HexBytesify("010001"),
+			iana.RSAKeyParameterD:    key.HexBytesify("01020304"),
+			iana.RSAKeyParameterP:    key.HexBytesify("05"),
+			iana.RSAKeyParameterQ:    key.HexBytesify("06"),
+			iana.RSAKeyParameterDP:   key.HexBytesify("07"),
+			iana.RSAKeyParameterDQ:   key.HexBytesify("08"),
+			iana.RSAKeyParameterQInv: key.HexBytesify("09"),
+			"x5t#S256":               "ZXhhbXBsZQ",
+		}
+
+		for _, k := range []key.Key{k1, k2, k3, k4} {
+			data, err := k.MarshalJWK()
+			require.NoError(err)
+			k0, err := key.UnmarshalJWK(data)
+			require.NoError(err)
+			assert.Equal(k.Bytesify(), k0.Bytesify())
+		}
+
+		jwk, err := k3.ToJWK()
+		require.NoError(err)
+		assert.Equal([]string{"sign", "verify"}, jwk["key_ops"])
+		assert.Equal("HS256", jwk["alg"])
+
+		jwk, err = k4.ToJWK()
+		require.NoError(err)
+		assert.Equal("AQAB", jwk["e"])
+		assert.Equal("CQ", jwk["qi"])
+
+		signer, err := k1.Signer()
+		require.NoError(err)
+		sig, err := signer.Sign([]byte("hello"))
+		require.NoError(err)
+		pk, err := ed25519.ToPublicKey(k1)
+		require.NoError(err)
+		data, err := pk.MarshalJWK()
+		require.NoError(err)
+		assert.NotContains(string(data), `"d"`)
+		pk, err = key.UnmarshalJWK(data)
+		require.NoError(err)
+		verifier, err := pk.Verifier()
+		require.NoError(err)
+		assert.NoError(verifier.Verify([]byte("hello"), sig))
+	})
+
+	t.Run("kid", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		// the JWK kids are text, including the kids that happen to be base64url
+		for _, kid := range []string{"1", "k1", "key1", "abcd", "2011-04-29", "bilbo.baggins@hobbiton.example"} {
+			k0, err := key.KeyFromJWK(map[string]any{"kty": "oct", "k": "AQID", "kid": kid})
+			require.NoError(err)
+			assert.Equal(key.ByteStr(kid), k0.Kid(), kid)
+
+			jwk, err := k0.ToJWK()
+			require.NoError(err)
+			assert.Equal(kid, jwk["kid"])
+		}
+
+		// the kid that is not valid UTF-8 is base64url encoded
+		k, err := ed25519.GenerateKey()
+		require.NoError(err)
+		k.SetKid(key.HexBytesify("ff00ee11"))
+		jwk, err := k.ToJWK()
+		require.NoError(err)
+		assert.Equal("_wDuEQ", jwk["kid"])
+		k0, err := key.KeyFromJWK(jwk)
+		require.NoError(err)
+		assert.Equal(key.ByteStr("_wDuEQ"), k0.Kid())
+
+		// the binary kids round-trip with the Base64URLKid option
+		opts := &key.JWKOpts{Base64URLKid: true}
+		k0, err = key.KeyFromJWKWithOpts(jwk, opts)
+		require.NoError(err)
+		assert.Equal(key.ByteStr{0xff, 0x00, 0xee, 0x11}, k0.Kid())
+
+		k1, err := ed25519.GenerateKey()
+		require.NoError(err)
+		k2, err := ecdsa.GenerateKey(iana.AlgorithmES256)
+		require.NoError(err)
+		k3, err := ed25519.GenerateKey()
+		require.NoError(err)
+		k3.SetKid([]byte("key1"))
+		for _, k := range []key.Key{k1, k2, k3} {
+			jwk, err := k.ToJWKWithOpts(opts)
+			require.NoError(err)
+			assert.Equal(k.Kid().Base64(), jwk["kid"])
+			k0, err := key.KeyFromJWKWithOpts(jwk, opts)
+			require.NoError(err)
+			assert.Equal(k.Kid(), k0.Kid())
+			assert.Equal(k.Bytesify(), k0.Bytesify())
+		}
+
+		_, err = key.KeyFromJWKWithOpts(map[string]any{"kty": "oct", "k": "AQID", "kid": "2011-04-29"}, opts)
+		assert.ErrorContains(err, "invalid base64url kid")
+	})
+
+	t.Run("preserved members", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		k, err := key.UnmarshalJWK([]byte(`{"kty":"oct","k":"AQID","use":"sig","ext":true,
+			"x5c":["MIIB"],"exp":1700000000,"meta":{"n":1.5}}`))
+		require.NoError(err)
+		assert.False(k.Has("use"))
+		assert.Equal(key.Ops{iana.KeyOperationMacCreate, iana.KeyOperationMacVerify}, k.Ops())
+		assert.Equal(true, k.Get("ext"))
+		assert.Equal([]any{"MIIB"}, k.Get("x5c"))
+		assert.Equal(int64(1700000000), k.Get("exp"))
+		assert.Equal(map[string]any{"n": 1.5}, k.Get("meta"))
+
+		var k0 key.Key
+		require.NoError(key.UnmarshalCBOR(k.Bytesify(), &k0))
+		data, err := k0.MarshalJWK()
+		require.NoError(err)
+		assert.JSONEq(`{"kty":"oct","k":"AQID","key_ops":["sign","verify"],"ext":true,
+			"x5c":["MIIB"],"exp":1700000000,"meta":{"n":1.5}}`, string(data))
+	})
+
+	t.Run("use", func(t *testing.T) {
+		assert := assert.New(t)
+
+		for _, tc := range []struct {
+			jwk string
+			ops key.Ops
+		}{
+			{`{"kty":"OKP","crv":"Ed25519","x":"AQID","use":"sig"}`, key.Ops{iana.KeyOperationVerify}},
+			{`{"kty":"OKP","crv":"Ed25519","d":"AQID","use":"sig"}`,
+				key.Ops{iana.KeyOperationSign, iana.KeyOperationVerify}},
+			{`{"kty":"OKP","crv":"X25519","d":"AQID","use":"enc"}`,
+				key.Ops{iana.KeyOperationDeriveKey, iana.KeyOperationDeriveBits}},
+			{`{"kty":"OKP","crv":"X25519","x":"AQID","use":"enc"}`, nil},
+			{`{"kty":"oct","k":"AQID","alg":"A128GCM","use":"enc"}`,
+				key.Ops{iana.KeyOperationEncrypt, iana.KeyOperationDecrypt}},
+			{`{"kty":"oct","k":"AQID","alg":"A128KW","use":"enc"}`,
+				key.Ops{iana.KeyOperationWrapKey, iana.KeyOperationUnwrapKey}},
+			{`{"kty":"oct","k":"AQID","use":"enc"}`, key.Ops{iana.KeyOperationEncrypt, iana.KeyOperationDecrypt,
+				iana.KeyOperationWrapKey, iana.KeyOperationUnwrapKey}},
+			{`{"kty":"RSA","n":"AQID","e":"AQAB","use":"enc"}`,
+				key.Ops{iana.KeyOperationEncrypt, iana.KeyOperationWrapKey}},
+			{`{"kty":"oct","k":"AQID","use":"sig","key_ops":["verify"]}`, key.Ops{iana.KeyOperationMacVerify}},
+		} {
+			k, err := key.UnmarshalJWK([]byte(tc.jwk))
+			if assert.NoError(err, tc.jwk) {
+				assert.Equal(tc.ops, k.Ops(), tc.jwk)
+				assert.False(k.Has("use"), tc.jwk)
+			}
+		}
+
+		_, err := key.UnmarshalJWK([]byte(`{"kty":"oct","k":"AQID","use":"tls"}`))
+		assert.ErrorContains(err, `unsupported use "tls"`)
+		_, err = key.Key{iana.KeyParameterKty: iana.KeyTypeSymmetric, "use": "sig"}.ToJWK()
+		assert.ErrorContains(err, `parameter "use" conflicts with JWK member`)
+	})
+
+	t.Run("ToJWK errors", func(t *testing.T) {
+		assert := assert.New(t)
+
+		for _, tc := range []struct {
+			k   key.Key
+			err string
+		}{
+			{key.Key{}, "unsupported key type 0"},
+			{key.Key{iana.KeyParameterKty: iana.KeyTypeHSS_LMS}, "unsupported key type 5"},
+			{key.Key{
+				iana.KeyParameterKty: iana.KeyTypeSymmetric,
+				iana.KeyParameterAlg: iana.AlgorithmHMAC_256_64,
+			}, "unsupported algorithm 4"},
+			{key.Key{
+				iana.KeyParameterKty:    iana.KeyTypeSymmetric,
+				iana.KeyParameterBaseIV: []byte{1, 2, 3},
+			}, "unsupported parameter 5"},
+			{key.Key{
+				iana.KeyParameterKty:    iana.KeyTypeOKP,
+				iana.OKPKeyParameterCrv: iana.EllipticCurveP_256,
+			}, "unsupported curve 1"},
+			{key.Key{
+				iana.KeyParameterKty:    iana.KeyTypeEC2,
+				iana.EC2KeyParameterCrv: iana.EllipticCurveP_256,
+				iana.EC2KeyParameterY:   true,
+			}, `invalid parameter "y"`},
+			{key.Key{
+				iana.KeyParameterKty:    iana.KeyTypeEC2,
+				iana.KeyParameterKeyOps: key.Ops{iana.KeyOperationMacCreate},
+			}, "unsupported key operation 9"},
+			{key.Key{
+				iana.KeyParameterKty: iana.KeyTypeSymmetric,
+				"k":                  "AQID",
+			}, `parameter "k" conflicts with JWK member`},
+			{key.Key{
+				iana.KeyParameterKty: iana.KeyTypeSymmetric,
+				"meta":               map[any]any{1: 2},
+			}, `invalid parameter "meta"`},
+		} {
+			_, err := tc.k.ToJWK()
+			assert.ErrorContains(err, tc.err)
+		}
+	})
+
+	t.Run("KeyFromJWK errors", func(t *testing.T) {
+		assert := assert.New(t)
+
+		for _, tc := range []struct {
+			jwk string
+			err string
+		}{
+			{`[]`, "cannot unmarshal array"},
+			{`{}`, `unsupported key type ""`},
+			{`{"kty":"AKP"}`, `unsupported key type "AKP"`},
+			{`{"kty":"OKP","x":"AQID"}`, `missing member "crv"`},
+			{`{"kty":"OKP","crv":"Ed25519"}`, `missing member "x"`},
+			{`{"kty":"EC","crv":"P-256","x":"AQID"}`, `missing member "y"`},
+			{`{"kty":"EC","crv":"Ed25519","x":"AQID","y":"AQID"}`, `unsupported curve Ed25519`},
+			{`{"kty":"oct","k":"AQID","alg":"HS1"}`, `unsupported algorithm HS1`},
+			{`{"kty":"oct","k":"AQID","kid":1}`, `invalid kid`},
+			{`{"kty":"oct","k":"AQID","key_ops":"sign"}`, `invalid key_ops`},
+			{`{"kty":"oct","k":"AQID","key_ops":["mac"]}`, `unsupported key operation mac`},
+			{`{"kty":"oct","k":"AQ=="}`, `invalid member "k"`},
+			{`{"kty":"oct","k":""}`, `invalid member "k"`},
+			{`{"kty":"RSA","n":"AQID","e":"AQAB","oth":[]}`, `multi-prime RSA key is not supported`},
+		} {
+			_, err := key.UnmarshalJWK([]byte(tc.jwk))
+			assert.ErrorContains(err, tc.err, tc.jwk)
+		}
+	})
+}
+
+func TestJWKS(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	k1, err := ed25519.GenerateKey()
+	require.NoError(err)
+	k1.SetKid([]byte("ed25519-key"))
+	k2, err := ecdsa.GenerateKey(iana.AlgorithmES256)
+	require.NoError(err)
+	k2.SetKid([]byte("ecdsa-key"))
+
+	ks := key.KeySet{k1, k2}
+	data, err := ks.MarshalJWKS()
+	require.NoError(err)
+
+	var jwks map[string][]map[string]any
+	require.NoError(json.Unmarshal(data, &jwks))
+	require.Len(jwks["keys"], 2)
+	assert.Equal("OKP", jwks["keys"][0]["kty"])
+	assert.Equal("EC", jwks["keys"][1]["kty"])
+
+	ks0, err := key.UnmarshalJWKS(data)
+	require.NoError(err)
+	require.Len(ks0, 2)
+	assert.Equal(k1.Bytesify(), ks0[0].Bytesify())
+	assert.Equal(k2.Bytesify(), ks0[1].Bytesify())
+
+	// a standard JWKS with "use"
+	var sigJWKS struct {
+		Keys []map[string]any `json:"keys"`
+	}
+	require.NoError(json.Unmarshal(data, &sigJWKS))
+	for _, jwk := range sigJWKS.Keys {
+		jwk["use"] = "sig"
+	}
+	data, err = json.Marshal(sigJWKS)
+	require.NoError(err)
+	ks0, err = key.UnmarshalJWKS(data)
+	require.NoError(err)
+	require.Len(ks0, 2)
+	for _, k := range ks0 {
+		assert.Equal(key.Ops{iana.KeyOperationSign, iana.KeyOperationVerify}, k.Ops())
+		signer, err := k.Signer()
+		require.NoError(err)
+		sig, err := signer.Sign([]byte("hello"))
+		require.NoError(err)
+		verifier, err := k.Verifier()
+		require.NoError(err)
+		assert.NoError(verifier.Verify([]byte("hello"), sig))
+	}
+
+	ks0, err = key.UnmarshalJWKS([]byte(`{"keys":[{"kty":"AKP","pub":"AQID"},{"kty":"oct","k":"AQID","kid":"k1"}]}`))
+	require.NoError(err)
+	require.Len(ks0, 1)
+	assert.NotNil(ks0.Lookup([]byte("k1")))
+
+	_, err = key.UnmarshalJWKS([]byte(`{}`))
+	assert.ErrorContains(err, "missing keys")
+	_, err = key.UnmarshalJWKS([]byte(`{"keys":{}}`))
+	assert.ErrorContains(err, "cannot unmarshal")
+	_, err = key.UnmarshalJWKS([]byte(`{"keys":[{"kty":"oct"}]}`))
+	assert.ErrorContains(err, `key 0, cose/key: KeyFromJWK: missing member "k"`)
+
+	_, err = key.KeySet{k1, {iana.KeyParameterKty: iana.KeyTypeHSS_LMS}}.MarshalJWKS()
+	assert.ErrorContains(err, "key 1")
+}
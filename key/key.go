@@ -7,6 +7,10 @@
 package key
 
 import (
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+
 	"github.com/ldclabs/cose/iana"
 )
 
@@ -185,3 +189,56 @@ func (k *Key) UnmarshalJSON(text []byte) error {
 func (k Key) Bytesify() []byte {
 	return CoseMap(k).Bytesify()
 }
+
+// String returns the CBOR diagnostic notation of the key with the private and secret
+// parameters redacted, such as the "d" of OKP and EC2 keys and the "k" of symmetric keys.
+// It is safe for logging.
+//
+//	{1: 4, 2: h'3131', 3: 5, -1: "<redacted>"}
+func (k Key) String() string {
+	rk := make(Key, len(k))
+	kty := k.Kty()
+	for p, v := range k {
+		if isSecretParameter(kty, p) {
+			v = "<redacted>"
+		}
+		rk[p] = v
+	}
+
+	data, err := rk.MarshalCBOR()
+	if err == nil {
+		var str string
+		if str, err = cbor.Diagnose(data); err == nil {
+			return str
+		}
+	}
+	return fmt.Sprintf("<invalid key: %v>", err)
+}
+
+// isSecretParameter returns true if the key parameter may contain the private or secret key material.
+// The private use parameters with text labels, and the key type parameters of the unknown key types
+// are treated as secret.
+func isSecretParameter(kty int, p any) bool {
+	label, ok := p.(int)
+	if !ok {
+		return true
+	}
+	if label >= 0 {
+		return false
+	}
+
+	switch kty {
+	case iana.KeyTypeOKP, iana.KeyTypeEC2:
+		return label == iana.EC2KeyParameterD
+	case iana.KeyTypeRSA:
+		// d, p, q, dP, dQ, qInv, other, r_i, d_i, t_i
+		return label <= iana.RSAKeyParameterD
+	case iana.KeyTypeSymmetric:
+		return label == iana.SymmetricKeyParameterK
+	case iana.KeyTypeHSS_LMS, iana.KeyTypeWalnutDSA:
+		// public keys only
+		return false
+	default:
+		return true
+	}
+}
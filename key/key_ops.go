@@ -3,6 +3,13 @@
 
 package key
 
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ldclabs/cose/iana"
+)
+
 // Ops represents the key operations.
 type Ops []int
 
@@ -21,3 +28,65 @@ func (os Ops) Has(op int) bool {
 func (os Ops) EmptyOrHas(op int) bool {
 	return len(os) == 0 || os.Has(op)
 }
+
+// ErrOpNotPermitted is the error wrapped by OpError.
+var ErrOpNotPermitted = errors.New("operation not permitted")
+
+// OpError is the error returned when the key_ops parameter of a key does not permit
+// the requested operation. It wraps ErrOpNotPermitted.
+//
+//	var opErr *key.OpError
+//	if errors.As(err, &opErr) {
+//		// opErr.Op is not permitted by the key opErr.Kid
+//	}
+type OpError struct {
+	Kid ByteStr
+	// Op is the requested operation, such as iana.KeyOperationMacCreate.
+	Op int
+	// Ops is the key_ops parameter of the key, it is empty if the parameter is absent.
+	Ops Ops
+	// Strict is true if the operation is rejected because the key_ops parameter is absent in strict mode.
+	Strict bool
+}
+
+// Error implements the error interface.
+func (e *OpError) Error() string {
+	if e.Strict {
+		return fmt.Sprintf("invalid key_ops, missing key_ops for operation %d in strict mode", e.Op)
+	}
+	return fmt.Sprintf("invalid key_ops %v, operation %d is not permitted", []int(e.Ops), e.Op)
+}
+
+// Unwrap returns ErrOpNotPermitted.
+func (e *OpError) Unwrap() error {
+	return ErrOpNotPermitted
+}
+
+// CheckOps returns an *OpError if none of the given operations is permitted by the key_ops
+// parameter of the key. The key_ops parameter is optional, all operations are permitted if
+// it is absent, unless strict is true. A present but empty or malformed key_ops parameter
+// permits nothing. The Op of the returned OpError is the first given operation.
+//
+//	// a MAC-verify-only key can not create MAC.
+//	err := k.CheckOps(false, iana.KeyOperationMacCreate)
+func (k Key) CheckOps(strict bool, ops ...int) error {
+	var op int
+	if len(ops) > 0 {
+		op = ops[0]
+	}
+
+	kops := k.Ops()
+	if _, ok := k[iana.KeyParameterKeyOps]; !ok {
+		if strict {
+			return &OpError{Kid: k.Kid(), Op: op, Strict: true}
+		}
+		return nil
+	}
+
+	for _, o := range ops {
+		if kops.Has(o) {
+			return nil
+		}
+	}
+	return &OpError{Kid: k.Kid(), Op: op, Ops: kops}
+}
@@ -4,6 +4,7 @@
 package key
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -28,3 +29,35 @@ func TestKeyOps(t *testing.T) {
 	assert.False(ops.Has(iana.KeyOperationEncrypt))
 	assert.False(ops.EmptyOrHas(iana.KeyOperationEncrypt))
 }
+
+func TestKeyCheckOps(t *testing.T) {
+	assert := assert.New(t)
+
+	k := Key{
+		iana.KeyParameterKty: iana.KeyTypeSymmetric,
+		iana.KeyParameterKid: []byte("k1"),
+	}
+	assert.NoError(k.CheckOps(false, iana.KeyOperationMacCreate))
+	err := k.CheckOps(true, iana.KeyOperationMacCreate)
+	assert.ErrorIs(err, ErrOpNotPermitted)
+	assert.Equal("invalid key_ops, missing key_ops for operation 9 in strict mode", err.Error())
+
+	k.SetOps(iana.KeyOperationMacVerify)
+	assert.NoError(k.CheckOps(true, iana.KeyOperationMacVerify))
+	err = k.CheckOps(false, iana.KeyOperationMacCreate)
+	assert.Equal("invalid key_ops [10], operation 9 is not permitted", err.Error())
+	var opErr *OpError
+	assert.True(errors.As(err, &opErr))
+	assert.Equal(&OpError{Kid: ByteStr("k1"), Op: iana.KeyOperationMacCreate, Ops: Ops{iana.KeyOperationMacVerify}}, opErr)
+
+	// any of the operations
+	k.SetOps(iana.KeyOperationDeriveBits)
+	assert.NoError(k.CheckOps(false, iana.KeyOperationDeriveKey, iana.KeyOperationDeriveBits))
+	assert.ErrorIs(k.CheckOps(false, iana.KeyOperationSign, iana.KeyOperationVerify), ErrOpNotPermitted)
+
+	// present but empty or malformed key_ops permits nothing
+	for _, v := range []any{Ops{}, []any{}, []any{"sign"}, "sign"} {
+		k[iana.KeyParameterKeyOps] = v
+		assert.ErrorIs(k.CheckOps(false, iana.KeyOperationSign), ErrOpNotPermitted)
+	}
+}
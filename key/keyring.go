@@ -0,0 +1,285 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package key
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KeyEntry is a Key with the rotation metadata in a KeyRing.
+type KeyEntry struct {
+	Key Key
+	// NotBefore is the time before which the key is not valid, zero means no limit.
+	NotBefore time.Time
+	// NotAfter is the time after which the key is not valid, zero means no limit.
+	NotAfter time.Time
+	// Retired keys are not selected for signing, but remain valid for verification
+	// until NotAfter.
+	Retired bool
+}
+
+// ValidAt returns true if the entry is valid at the given time.
+func (e *KeyEntry) ValidAt(t time.Time) bool {
+	return (e.NotBefore.IsZero() || !t.Before(e.NotBefore)) &&
+		(e.NotAfter.IsZero() || !t.After(e.NotAfter))
+}
+
+func (e *KeyEntry) check() error {
+	if e.Key == nil {
+		return errors.New("nil key")
+	}
+	if len(e.Key.Kid()) == 0 {
+		return errors.New("missing kid")
+	}
+	if !e.NotBefore.IsZero() && !e.NotAfter.IsZero() && !e.NotAfter.After(e.NotBefore) {
+		return fmt.Errorf("invalid validity window for kid %x", []byte(e.Key.Kid()))
+	}
+	return nil
+}
+
+// KeyRing is a set of keys indexed by kid, with validity windows, primary key selection
+// for signing, and retirement of old keys for key rotation. Multiple keys can share a kid.
+// It is safe for concurrent use, the lookups are lock-free and do not block the updates.
+// The keys in the KeyRing should not be modified.
+type KeyRing struct {
+	now  func() time.Time
+	mu   sync.Mutex // serializes the updates
+	snap atomic.Pointer[keyRingSnapshot]
+}
+
+type keyRingSnapshot struct {
+	entries []*KeyEntry
+	index   map[string][]*KeyEntry
+	primary string
+}
+
+// NewKeyRing creates a KeyRing with the given entries.
+// now provides the current time for the validity windows, if it is nil, time.Now is used.
+func NewKeyRing(now func() time.Time, entries ...KeyEntry) (*KeyRing, error) {
+	if now == nil {
+		now = time.Now
+	}
+	r := &KeyRing{now: now}
+	if err := r.Reload(entries...); err != nil {
+		return nil, fmt.Errorf("cose/key: NewKeyRing: %w", err)
+	}
+	return r, nil
+}
+
+// Reload atomically replaces all entries of the KeyRing, such as when the keys are reloaded
+// from the storage. The primary kid is kept if it is still in the KeyRing.
+func (r *KeyRing) Reload(entries ...KeyEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snap := &keyRingSnapshot{}
+	for i := range entries {
+		if err := entries[i].check(); err != nil {
+			return fmt.Errorf("cose/key: KeyRing.Reload: %w", err)
+		}
+		e := entries[i]
+		snap.entries = append(snap.entries, &e)
+	}
+	if old := r.snap.Load(); old != nil {
+		snap.primary = old.primary
+	}
+	r.store(snap)
+	return nil
+}
+
+// Add adds an entry to the KeyRing.
+func (r *KeyRing) Add(entry KeyEntry) error {
+	if err := entry.check(); err != nil {
+		return fmt.Errorf("cose/key: KeyRing.Add: %w", err)
+	}
+
+	r.update(func(snap *keyRingSnapshot) {
+		snap.entries = append(snap.entries, &entry)
+	})
+	return nil
+}
+
+// Remove removes all entries with the given kid, and returns the number of removed entries.
+func (r *KeyRing) Remove(kid []byte) int {
+	n := 0
+	r.update(func(snap *keyRingSnapshot) {
+		entries := snap.entries[:0]
+		for _, e := range snap.entries {
+			if string(e.Key.Kid()) == string(kid) {
+				n++
+				continue
+			}
+			entries = append(entries, e)
+		}
+		snap.entries = entries
+	})
+	return n
+}
+
+// Retire retires all entries with the given kid, they are not selected for signing,
+// but remain valid for verification until their NotAfter.
+// If notAfter is not zero, it replaces the NotAfter of the entries to end the grace period.
+func (r *KeyRing) Retire(kid []byte, notAfter time.Time) error {
+	var err error
+	r.update(func(snap *keyRingSnapshot) {
+		found := false
+		for i, e := range snap.entries {
+			if string(e.Key.Kid()) == string(kid) {
+				found = true
+				ne := *e
+				ne.Retired = true
+				if !notAfter.IsZero() {
+					ne.NotAfter = notAfter
+				}
+				snap.entries[i] = &ne
+			}
+		}
+		if !found {
+			err = fmt.Errorf("cose/key: KeyRing.Retire: kid %x not found", kid)
+		}
+	})
+	return err
+}
+
+// SetPrimary sets the key with the given kid as the primary key for signing.
+// If the kid is nil, the primary key is selected automatically, see Primary.
+func (r *KeyRing) SetPrimary(kid []byte) error {
+	var err error
+	r.update(func(snap *keyRingSnapshot) {
+		if kid == nil {
+			snap.primary = ""
+			return
+		}
+
+		var found *KeyEntry
+		for _, e := range snap.entries {
+			if string(e.Key.Kid()) == string(kid) {
+				if found != nil {
+					err = fmt.Errorf("cose/key: KeyRing.SetPrimary: ambiguous kid %x", kid)
+					return
+				}
+				found = e
+			}
+		}
+		switch {
+		case found == nil:
+			err = fmt.Errorf("cose/key: KeyRing.SetPrimary: kid %x not found", kid)
+		case found.Retired:
+			err = fmt.Errorf("cose/key: KeyRing.SetPrimary: kid %x is retired", kid)
+		default:
+			snap.primary = string(kid)
+		}
+	})
+	return err
+}
+
+// Primary returns the key for signing at the current time.
+// It is the key set by SetPrimary if it is valid, otherwise the active key with the latest
+// NotBefore, so that a new key is used once it becomes valid.
+func (r *KeyRing) Primary() (Key, error) {
+	snap, now := r.snap.Load(), r.now()
+	if snap.primary != "" {
+		for _, e := range snap.index[snap.primary] {
+			if !e.Retired && e.ValidAt(now) {
+				return e.Key, nil
+			}
+		}
+	}
+
+	var primary *KeyEntry
+	for _, e := range snap.entries {
+		if !e.Retired && e.ValidAt(now) && (primary == nil || !e.NotBefore.Before(primary.NotBefore)) {
+			primary = e
+		}
+	}
+	if primary == nil {
+		return nil, errors.New("cose/key: KeyRing.Primary: no active key")
+	}
+	return primary.Key, nil
+}
+
+// Signer returns a Signer for the primary key.
+func (r *KeyRing) Signer() (Signer, error) {
+	k, err := r.Primary()
+	if err != nil {
+		return nil, err
+	}
+	return k.Signer()
+}
+
+// Lookup returns the keys with the given kid that are valid at the current time,
+// including the retired keys.
+func (r *KeyRing) Lookup(kid []byte) KeySet {
+	snap, now := r.snap.Load(), r.now()
+	var ks KeySet
+	for _, e := range snap.index[string(kid)] {
+		if e.ValidAt(now) {
+			ks = append(ks, e.Key)
+		}
+	}
+	return ks
+}
+
+// Verifiers returns the Verifiers for the keys with the given kid that are valid at the current time.
+func (r *KeyRing) Verifiers(kid []byte) (Verifiers, error) {
+	ks := r.Lookup(kid)
+	if len(ks) == 0 {
+		return nil, fmt.Errorf("cose/key: KeyRing.Verifiers: no valid key for kid %x", kid)
+	}
+	return ks.Verifiers()
+}
+
+// KeySet returns the keys that are not expired at the current time, including the keys
+// that are not valid yet, such as for publishing the keys before the rotation.
+func (r *KeyRing) KeySet() KeySet {
+	snap, now := r.snap.Load(), r.now()
+	ks := make(KeySet, 0, len(snap.entries))
+	for _, e := range snap.entries {
+		if e.NotAfter.IsZero() || !now.After(e.NotAfter) {
+			ks = append(ks, e.Key)
+		}
+	}
+	return ks
+}
+
+// Entries returns a copy of all entries in the KeyRing.
+func (r *KeyRing) Entries() []KeyEntry {
+	snap := r.snap.Load()
+	entries := make([]KeyEntry, len(snap.entries))
+	for i, e := range snap.entries {
+		entries[i] = *e
+	}
+	return entries
+}
+
+// update applies fn to a copy of the current snapshot and stores it.
+func (r *KeyRing) update(fn func(*keyRingSnapshot)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old := r.snap.Load()
+	snap := &keyRingSnapshot{
+		entries: append([]*KeyEntry(nil), old.entries...),
+		primary: old.primary,
+	}
+	fn(snap)
+	r.store(snap)
+}
+
+// store builds the kid index of the snapshot and stores it.
+func (r *KeyRing) store(snap *keyRingSnapshot) {
+	snap.index = make(map[string][]*KeyEntry, len(snap.entries))
+	for _, e := range snap.entries {
+		kid := string(e.Key.Kid())
+		snap.index[kid] = append(snap.index[kid], e)
+	}
+	if _, ok := snap.index[snap.primary]; !ok {
+		snap.primary = ""
+	}
+	r.snap.Store(snap)
+}
@@ -0,0 +1,218 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package key_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/ed25519"
+)
+
+func TestKeyRing(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	genKey := func(t *testing.T, kid string) key.Key {
+		k, err := ed25519.GenerateKey()
+		require.NoError(t, err)
+		k.SetKid([]byte(kid))
+		return k
+	}
+
+	t.Run("rotation", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		now := base
+		k1, k2 := genKey(t, "k1"), genKey(t, "k2")
+		r, err := key.NewKeyRing(func() time.Time { return now },
+			key.KeyEntry{Key: k1, NotAfter: base.Add(48 * time.Hour)},
+			key.KeyEntry{Key: k2, NotBefore: base.Add(24 * time.Hour)},
+		)
+		require.NoError(err)
+
+		pk, err := r.Primary()
+		require.NoError(err)
+		assert.Equal(k1, pk)
+		assert.Len(r.Lookup([]byte("k1")), 1)
+		assert.Len(r.Lookup([]byte("k2")), 0)
+		assert.Len(r.KeySet(), 2, "the next key is published before it becomes valid")
+
+		signer, err := r.Signer()
+		require.NoError(err)
+		sig, err := signer.Sign([]byte("hello"))
+		require.NoError(err)
+
+		// the new key becomes the primary key once it is valid
+		now = base.Add(25 * time.Hour)
+		pk, err = r.Primary()
+		require.NoError(err)
+		assert.Equal(k2, pk)
+
+		// the old key remains valid for verification
+		vs, err := r.Verifiers([]byte("k1"))
+		require.NoError(err)
+		require.Len(vs, 1)
+		assert.NoError(vs[0].Verify([]byte("hello"), sig))
+
+		now = base.Add(49 * time.Hour)
+		_, err = r.Verifiers([]byte("k1"))
+		assert.ErrorContains(err, "no valid key for kid 6b31")
+		assert.Equal(key.KeySet{k2}, r.KeySet())
+		assert.Len(r.Entries(), 2)
+	})
+
+	t.Run("primary and retirement", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		now := base
+		k1, k2, k3 := genKey(t, "k1"), genKey(t, "k2"), genKey(t, "k3")
+		r, err := key.NewKeyRing(func() time.Time { return now },
+			key.KeyEntry{Key: k1},
+			key.KeyEntry{Key: k2, NotBefore: base.Add(-time.Hour)},
+		)
+		require.NoError(err)
+
+		pk, err := r.Primary()
+		require.NoError(err)
+		assert.Equal(k2, pk)
+
+		require.NoError(r.SetPrimary([]byte("k1")))
+		pk, err = r.Primary()
+		require.NoError(err)
+		assert.Equal(k1, pk)
+
+		require.NoError(r.Add(key.KeyEntry{Key: k3, NotBefore: base.Add(-time.Minute)}))
+		pk, err = r.Primary()
+		require.NoError(err)
+		assert.Equal(k1, pk, "the explicit primary key is kept")
+
+		require.NoError(r.Retire([]byte("k1"), time.Time{}))
+		pk, err = r.Primary()
+		require.NoError(err)
+		assert.Equal(k3, pk, "falls back to the newest active key")
+		assert.Len(r.Lookup([]byte("k1")), 1, "retired key is valid for verification")
+		assert.ErrorContains(r.SetPrimary([]byte("k1")), "kid 6b31 is retired")
+		assert.ErrorContains(r.SetPrimary([]byte("k9")), "kid 6b39 not found")
+		assert.ErrorContains(r.Retire([]byte("k9"), time.Time{}), "kid 6b39 not found")
+
+		require.NoError(r.SetPrimary([]byte("k2")))
+		require.NoError(r.Retire([]byte("k2"), base.Add(time.Hour)))
+		now = base.Add(2 * time.Hour)
+		assert.Len(r.Lookup([]byte("k2")), 0)
+
+		require.NoError(r.SetPrimary([]byte("k3")))
+		require.NoError(r.SetPrimary(nil))
+		assert.Equal(1, r.Remove([]byte("k3")))
+		assert.Equal(0, r.Remove([]byte("k3")))
+		_, err = r.Primary()
+		assert.ErrorContains(err, "no active key")
+		_, err = r.Signer()
+		assert.ErrorContains(err, "no active key")
+	})
+
+	t.Run("multiple keys per kid", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		k1, k2 := genKey(t, "shared"), genKey(t, "shared")
+		r, err := key.NewKeyRing(nil, key.KeyEntry{Key: k1}, key.KeyEntry{Key: k2})
+		require.NoError(err)
+
+		assert.Equal(key.KeySet{k1, k2}, r.Lookup([]byte("shared")))
+		vs, err := r.Verifiers([]byte("shared"))
+		require.NoError(err)
+		assert.Len(vs, 2)
+		assert.ErrorContains(r.SetPrimary([]byte("shared")), "ambiguous kid")
+
+		require.NoError(r.Retire([]byte("shared"), time.Time{}))
+		_, err = r.Primary()
+		assert.ErrorContains(err, "no active key")
+		assert.Equal(2, r.Remove([]byte("shared")))
+		assert.Len(r.Entries(), 0)
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		k1, k2 := genKey(t, "k1"), genKey(t, "k2")
+		r, err := key.NewKeyRing(nil, key.KeyEntry{Key: k1}, key.KeyEntry{Key: k2})
+		require.NoError(err)
+		require.NoError(r.SetPrimary([]byte("k1")))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					pk, err := r.Primary()
+					assert.NoError(err)
+					assert.NotNil(pk)
+					assert.Len(r.Lookup([]byte("k2")), 1)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					assert.NoError(r.Reload(key.KeyEntry{Key: k1}, key.KeyEntry{Key: k2}))
+				}
+			}()
+		}
+		wg.Wait()
+
+		pk, err := r.Primary()
+		require.NoError(err)
+		assert.Equal(k1, pk, "the primary kid is kept after reload")
+
+		require.NoError(r.Reload(key.KeyEntry{Key: k2}))
+		pk, err = r.Primary()
+		require.NoError(err)
+		assert.Equal(k2, pk)
+
+		err = r.Reload(key.KeyEntry{Key: k1}, key.KeyEntry{})
+		assert.ErrorContains(err, "cose/key: KeyRing.Reload: nil key")
+		assert.Len(r.Entries(), 1, "failed reload keeps the entries")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := key.NewKeyRing(nil, key.KeyEntry{Key: key.Key{iana.KeyParameterKty: iana.KeyTypeOKP}})
+		assert.ErrorContains(err, "cose/key: NewKeyRing: cose/key: KeyRing.Reload: missing kid")
+
+		k := genKey(t, "k1")
+		_, err = key.NewKeyRing(nil, key.KeyEntry{Key: k, NotBefore: base, NotAfter: base})
+		assert.ErrorContains(err, "invalid validity window for kid 6b31")
+
+		r, err := key.NewKeyRing(nil)
+		assert.NoError(err)
+		assert.ErrorContains(r.Add(key.KeyEntry{}), "cose/key: KeyRing.Add: nil key")
+		assert.Len(r.KeySet(), 0)
+		assert.Nil(r.Lookup([]byte("k1")))
+	})
+}
+
+func TestKeyEntry(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Unix(1700000000, 0)
+	e := key.KeyEntry{}
+	assert.True(e.ValidAt(now))
+
+	e.NotBefore = now
+	assert.True(e.ValidAt(now))
+	assert.False(e.ValidAt(now.Add(-time.Second)))
+
+	e.NotAfter = now.Add(time.Hour)
+	assert.True(e.ValidAt(now.Add(time.Hour)))
+	assert.False(e.ValidAt(now.Add(time.Hour + time.Second)))
+}
@@ -3,7 +3,11 @@
 
 package key
 
-import "bytes"
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
 
 // KeySet is a set of Keys.
 type KeySet []Key
@@ -47,3 +51,74 @@ func (ks KeySet) Verifiers() (Verifiers, error) {
 
 	return verifiers, nil
 }
+
+// Check checks all the keys in the KeySet with the KeyCheckers registered in the DefaultRegistry.
+// See Registry.CheckKeySet.
+func (ks KeySet) Check() error {
+	return DefaultRegistry.CheckKeySet(ks)
+}
+
+// UnmarshalKeySet decodes a COSE_KeySet from the CBOR encoding and checks all the keys
+// with the KeyCheckers registered in the DefaultRegistry. See Registry.UnmarshalKeySet.
+func UnmarshalKeySet(data []byte) (KeySet, error) {
+	return DefaultRegistry.UnmarshalKeySet(data)
+}
+
+// String returns the redacted CBOR diagnostic notation of the keys, see Key.String.
+func (ks KeySet) String() string {
+	strs := make([]string, len(ks))
+	for i, k := range ks {
+		strs[i] = k.String()
+	}
+	return "[" + strings.Join(strs, ", ") + "]"
+}
+
+// KeyError is the error of an invalid key in a KeySet.
+type KeyError struct {
+	// Index is the index of the key in the KeySet.
+	Index int
+	// Kid is the key ID of the key, or nil.
+	Kid ByteStr
+	Err error
+}
+
+// Error implements the error interface.
+func (e *KeyError) Error() string {
+	if len(e.Kid) > 0 {
+		return fmt.Sprintf("key %d (kid %s), %v", e.Index, e.Kid.String(), e.Err)
+	}
+	return fmt.Sprintf("key %d, %v", e.Index, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// KeySetError is the errors of the invalid keys in a KeySet.
+//
+//	var ksErr key.KeySetError
+//	if errors.As(err, &ksErr) {
+//		for _, e := range ksErr {
+//			// e.Index, e.Kid, e.Err
+//		}
+//	}
+type KeySetError []*KeyError
+
+// Error implements the error interface.
+func (e KeySetError) Error() string {
+	strs := make([]string, len(e))
+	for i, ke := range e {
+		strs[i] = ke.Error()
+	}
+	return "invalid keys: " + strings.Join(strs, "; ")
+}
+
+// Unwrap returns the errors of the invalid keys.
+func (e KeySetError) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, ke := range e {
+		errs[i] = ke
+	}
+	return errs
+}
@@ -4,6 +4,7 @@
 package key_test
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/ldclabs/cose/iana"
 	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/ecdh"
 	_ "github.com/ldclabs/cose/key/ecdsa"
 	"github.com/ldclabs/cose/key/ed25519"
 	_ "github.com/ldclabs/cose/key/hmac"
@@ -82,3 +84,135 @@ func TestKeySet(t *testing.T) {
 	assert.Equal(k1.Kid(), verifiers[0].Key().Kid())
 	assert.Equal(k2.Kid(), verifiers[1].Key().Kid())
 }
+
+func TestUnmarshalKeySet(t *testing.T) {
+	t.Run("valid key set", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		k1, err := ed25519.GenerateKey()
+		require.NoError(err)
+		k2, err := key.Generate(iana.KeyTypeEC2, iana.AlgorithmES256, 0)
+		require.NoError(err)
+		k3, err := key.Generate(iana.KeyTypeSymmetric, iana.AlgorithmHMAC_256_256, 0)
+		require.NoError(err)
+		k4, err := ecdh.GenerateKey(iana.EllipticCurveX25519)
+		require.NoError(err)
+		k5, err := ecdh.GenerateKey(iana.EllipticCurveP_384)
+		require.NoError(err)
+		k5[iana.KeyParameterAlg] = iana.AlgorithmECDH_ES_HKDF_256
+		pk, err := ed25519.ToPublicKey(k1)
+		require.NoError(err)
+		delete(pk, iana.KeyParameterAlg)
+
+		ks := key.KeySet{k1, k2, k3, k4, k5, pk}
+		assert.NoError(ks.Check())
+
+		ks2, err := key.UnmarshalKeySet(key.MustMarshalCBOR(ks))
+		require.NoError(err)
+		assert.Equal(key.MustMarshalCBOR(ks), key.MustMarshalCBOR(ks2))
+	})
+
+	t.Run("invalid key set", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		k1, err := ed25519.GenerateKey()
+		require.NoError(err)
+
+		for _, tc := range []struct {
+			data []byte
+			err  string
+		}{
+			{nil, "cose/key: UnmarshalKeySet: EOF"},
+			{key.MustMarshalCBOR(key.KeySet{}), "cose/key: UnmarshalKeySet: empty key set"},
+			{key.MustMarshalCBOR(k1), "cose/key: UnmarshalKeySet: cbor: cannot unmarshal map"},
+			{append(key.MustMarshalCBOR(key.KeySet{k1}), 0x01), "cose/key: UnmarshalKeySet: cbor: 1 bytes of extraneous data"},
+		} {
+			_, err := key.UnmarshalKeySet(tc.data)
+			assert.ErrorContains(err, tc.err)
+		}
+
+		ks := []any{
+			k1,
+			map[any]any{
+				iana.KeyParameterKty:    iana.KeyTypeOKP,
+				iana.KeyParameterKid:    []byte("bad-crv"),
+				iana.OKPKeyParameterCrv: "Ed25519",
+			},
+			"not a key",
+			map[any]any{
+				iana.KeyParameterKty:        iana.KeyTypeSymmetric,
+				iana.KeyParameterKid:        []byte("redundant"),
+				iana.KeyParameterAlg:        iana.AlgorithmHMAC_256_256,
+				iana.SymmetricKeyParameterK: key.GetRandomBytes(32),
+				-99:                         1,
+			},
+			map[any]any{
+				iana.KeyParameterKty:  iana.KeyTypeRSA,
+				iana.RSAKeyParameterN: []byte{1, 2, 3},
+				iana.RSAKeyParameterE: []byte{1, 0, 1},
+			},
+		}
+		_, err = key.UnmarshalKeySet(key.MustMarshalCBOR(ks))
+		require.Error(err)
+		assert.ErrorContains(err, "cose/key: UnmarshalKeySet: invalid keys: ")
+
+		var ksErr key.KeySetError
+		require.ErrorAs(err, &ksErr)
+		require.Len(ksErr, 4)
+		assert.Equal(1, ksErr[0].Index)
+		assert.Equal(key.ByteStr("bad-crv"), ksErr[0].Kid)
+		assert.ErrorContains(ksErr[0], "key 1 (kid 6261642d637276), cose/key: CheckKey: invalid parameter crv")
+		assert.Equal(2, ksErr[1].Index)
+		assert.Nil(ksErr[1].Kid)
+		assert.ErrorContains(ksErr[1], "key 2, cbor: cannot unmarshal")
+		assert.Equal(3, ksErr[2].Index)
+		assert.ErrorContains(ksErr[2], "cose/key/hmac: CheckKey: redundant parameter -99")
+		assert.Equal(4, ksErr[3].Index)
+		assert.ErrorContains(ksErr[3], "cose/key: CheckKey: kty(3)_alg(0) is not registered")
+
+		assert.ErrorContains(key.KeySet{k1, nil}.Check(), "key 1, cose/key: CheckKey: nil key")
+	})
+}
+
+func TestKeySetString(t *testing.T) {
+	assert := assert.New(t)
+
+	ks := key.KeySet{
+		{
+			iana.KeyParameterKty:        iana.KeyTypeSymmetric,
+			iana.KeyParameterKid:        []byte("11"),
+			iana.KeyParameterAlg:        iana.AlgorithmHMAC_256_256,
+			iana.SymmetricKeyParameterK: key.HexBytesify("403697de87af64611c1d32a05dab0fe1fcb715a86ab435f1ec99192d79569388"),
+		},
+		{
+			iana.KeyParameterKty:    iana.KeyTypeOKP,
+			iana.KeyParameterKid:    []byte("12"),
+			iana.OKPKeyParameterCrv: iana.EllipticCurveEd25519,
+			iana.OKPKeyParameterX:   key.HexBytesify("0102"),
+			iana.OKPKeyParameterD:   key.HexBytesify("0304"),
+		},
+		{
+			iana.KeyParameterKty:  iana.KeyTypeRSA,
+			iana.RSAKeyParameterN: key.HexBytesify("0506"),
+			iana.RSAKeyParameterE: key.HexBytesify("010001"),
+			iana.RSAKeyParameterD: key.HexBytesify("0708"),
+			iana.RSAKeyParameterP: key.HexBytesify("090a"),
+		},
+		{
+			iana.KeyParameterKty: 99,
+			-1:                   key.HexBytesify("0b0c"),
+			"secret":             "text",
+		},
+	}
+
+	assert.Equal(`[{1: 4, 2: h'3131', 3: 5, -1: "<redacted>"}, `+
+		`{1: 1, 2: h'3132', -1: 6, -2: h'0102', -4: "<redacted>"}, `+
+		`{1: 3, -1: h'0506', -2: h'010001', -3: "<redacted>", -4: "<redacted>"}, `+
+		`{1: 99, -1: "<redacted>", "secret": "<redacted>"}]`, ks.String())
+	assert.Equal(ks[1].String(), fmt.Sprint(ks[1]))
+	assert.NotContains(fmt.Sprintf("%v", ks), "403697de")
+	assert.Equal("{1: 1, 2: h'3132', -1: 6, -2: h'0102', -4: \"<redacted>\"}", fmt.Sprintf("%s", ks[1]))
+	assert.Equal("<invalid key: cbor: unsupported type: chan int>", key.Key{1: make(chan int)}.String())
+}
@@ -0,0 +1,366 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package keystore implements an encrypted on-disk store of COSE keys.
+//
+// The store file is a tagged COSE_Encrypt0 object, the payload is the CBOR encoded KeySet,
+// encrypted with AES-256-GCM and a key derived from a passphrase with Argon2id.
+// The salt and the Argon2id parameters are in the protected header, so they are authenticated.
+package keystore
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/aesgcm"
+	"github.com/ldclabs/cose/key/argon2"
+)
+
+// HeaderParameterArgon2 is the protected header parameter of the Argon2id parameters,
+// a map with the "t" (time), "m" (memory in KiB) and "p" (threads) fields.
+const HeaderParameterArgon2 = "argon2id"
+
+const (
+	saltSize  = 16
+	keySize   = 32
+	maxMemory = 1024 * 1024 // 1 GiB in KiB, limits the memory used to open an untrusted file
+)
+
+// Options is the options of a KeyStore.
+type Options struct {
+	// Params is the Argon2id parameters to derive the key from the passphrase
+	// for a new store file, or when the passphrase is changed.
+	// argon2.DefaultParams is used if it is zero.
+	Params argon2.Params
+}
+
+// KeyStore is a set of keys with unique kids, stored in a file encrypted with a passphrase.
+// Every change is written to the file atomically. It is safe for concurrent use in a process,
+// but the file should not be shared by multiple processes that modify it.
+type KeyStore struct {
+	path   string
+	params argon2.Params
+
+	mu        sync.Mutex
+	salt      []byte
+	encryptor key.Encryptor
+	keys      key.KeySet
+}
+
+// Open opens the store file at the given path with the passphrase.
+// If the file does not exist, an empty KeyStore is returned, and the file is created
+// on the first change. opts can be nil.
+func Open(path string, passphrase []byte, opts *Options) (*KeyStore, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("cose/key/keystore: Open: empty passphrase")
+	}
+
+	s := &KeyStore{path: path, params: argon2.DefaultParams}
+	if opts != nil && opts.Params != (argon2.Params{}) {
+		s.params = opts.Params
+	}
+	if err := validateParams(s.params); err != nil {
+		return nil, fmt.Errorf("cose/key/keystore: Open: %w", err)
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if err = s.setPassphrase(passphrase); err != nil {
+			return nil, fmt.Errorf("cose/key/keystore: Open: %w", err)
+		}
+		return s, nil
+	case err != nil:
+		return nil, fmt.Errorf("cose/key/keystore: Open: %w", err)
+	}
+
+	if err = s.decrypt(data, passphrase); err != nil {
+		return nil, fmt.Errorf("cose/key/keystore: Open: %w", err)
+	}
+	return s, nil
+}
+
+// Path returns the path of the store file.
+func (s *KeyStore) Path() string {
+	return s.path
+}
+
+// List returns the kids of the keys in the store.
+func (s *KeyStore) List() []key.ByteStr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kids := make([]key.ByteStr, 0, len(s.keys))
+	for _, k := range s.keys {
+		kids = append(kids, k.Kid())
+	}
+	return kids
+}
+
+// Get returns the key with the given kid.
+func (s *KeyStore) Get(kid []byte) (key.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if k := s.keys.Lookup(kid); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("cose/key/keystore: KeyStore.Get: kid %x not found", kid)
+}
+
+// KeySet returns a copy of the keys in the store.
+func (s *KeyStore) KeySet() key.KeySet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append(key.KeySet{}, s.keys...)
+}
+
+// Add adds a key to the store and writes the store file.
+// The key should have a kid that is not in the store.
+func (s *KeyStore) Add(k key.Key) error {
+	if k == nil {
+		return errors.New("cose/key/keystore: KeyStore.Add: nil key")
+	}
+	kid := k.Kid()
+	if len(kid) == 0 {
+		return errors.New("cose/key/keystore: KeyStore.Add: missing kid")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.keys.Lookup(kid) != nil {
+		return fmt.Errorf("cose/key/keystore: KeyStore.Add: kid %x already exists", []byte(kid))
+	}
+
+	keys := append(append(key.KeySet{}, s.keys...), k)
+	if err := s.save(keys); err != nil {
+		return fmt.Errorf("cose/key/keystore: KeyStore.Add: %w", err)
+	}
+	s.keys = keys
+	return nil
+}
+
+// Remove removes the key with the given kid from the store and writes the store file.
+func (s *KeyStore) Remove(kid []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make(key.KeySet, 0, len(s.keys))
+	for _, k := range s.keys {
+		if string(k.Kid()) != string(kid) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == len(s.keys) {
+		return fmt.Errorf("cose/key/keystore: KeyStore.Remove: kid %x not found", kid)
+	}
+
+	if err := s.save(keys); err != nil {
+		return fmt.Errorf("cose/key/keystore: KeyStore.Remove: %w", err)
+	}
+	s.keys = keys
+	return nil
+}
+
+// ChangePassphrase re-encrypts the store file with a key derived from the new passphrase
+// and a new salt.
+func (s *KeyStore) ChangePassphrase(passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return errors.New("cose/key/keystore: KeyStore.ChangePassphrase: empty passphrase")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	salt, encryptor := s.salt, s.encryptor
+	if err := s.setPassphrase(passphrase); err != nil {
+		return fmt.Errorf("cose/key/keystore: KeyStore.ChangePassphrase: %w", err)
+	}
+	if err := s.save(s.keys); err != nil {
+		s.salt, s.encryptor = salt, encryptor
+		return fmt.Errorf("cose/key/keystore: KeyStore.ChangePassphrase: %w", err)
+	}
+	return nil
+}
+
+// setPassphrase derives the key from the passphrase with a new salt.
+func (s *KeyStore) setPassphrase(passphrase []byte) error {
+	if err := validateParams(s.params); err != nil {
+		return err
+	}
+	salt := key.GetRandomBytes(saltSize)
+	encryptor, err := deriveEncryptor(passphrase, salt, s.params)
+	if err != nil {
+		return err
+	}
+	s.salt, s.encryptor = salt, encryptor
+	return nil
+}
+
+// decrypt decrypts the store file data with the passphrase.
+func (s *KeyStore) decrypt(data, passphrase []byte) error {
+	obj := &cose.Encrypt0Message[key.KeySet]{}
+	if err := obj.UnmarshalCBOR(data); err != nil {
+		return fmt.Errorf("invalid store file, %w", err)
+	}
+
+	alg, err := obj.Protected.GetInt(iana.HeaderParameterAlg)
+	if err != nil || alg != iana.AlgorithmA256GCM {
+		return fmt.Errorf("unsupported algorithm %v", obj.Protected.Get(iana.HeaderParameterAlg))
+	}
+	salt, err := obj.Protected.GetBytes(iana.HeaderAlgorithmParameterSalt)
+	if err != nil || len(salt) < argon2.MinSalt {
+		return errors.New("invalid salt")
+	}
+	params, err := paramsFrom(obj.Protected)
+	if err != nil {
+		return err
+	}
+
+	encryptor, err := deriveEncryptor(passphrase, salt, params)
+	if err != nil {
+		return err
+	}
+	if err = obj.Decrypt(encryptor, nil); err != nil {
+		return errors.New("decryption failed, invalid passphrase or data")
+	}
+
+	seen := make(map[string]struct{}, len(obj.Payload))
+	for _, k := range obj.Payload {
+		kid := k.Kid()
+		if len(kid) == 0 {
+			return errors.New("invalid store file, missing kid")
+		}
+		if _, ok := seen[string(kid)]; ok {
+			return fmt.Errorf("invalid store file, duplicate kid %x", []byte(kid))
+		}
+		seen[string(kid)] = struct{}{}
+	}
+
+	// keeps the parameters of the file, they are changed with the passphrase.
+	s.params, s.salt, s.encryptor, s.keys = params, salt, encryptor, obj.Payload
+	return nil
+}
+
+// save encrypts the keys and writes the store file atomically.
+func (s *KeyStore) save(keys key.KeySet) error {
+	if keys == nil {
+		keys = key.KeySet{}
+	}
+	obj := &cose.Encrypt0Message[key.KeySet]{
+		Protected: cose.Headers{
+			iana.HeaderParameterAlg:           iana.AlgorithmA256GCM,
+			iana.HeaderAlgorithmParameterSalt: s.salt,
+			HeaderParameterArgon2: key.CoseMap{
+				"t": s.params.Time,
+				"m": s.params.Memory,
+				"p": s.params.Threads,
+			},
+		},
+		Unprotected: cose.Headers{},
+		Payload:     keys,
+	}
+	data, err := obj.EncryptAndEncode(s.encryptor, nil)
+	if err != nil {
+		return err
+	}
+	return writeFile(s.path, data)
+}
+
+// deriveEncryptor derives the AES-256-GCM Encryptor from the passphrase.
+func deriveEncryptor(passphrase, salt []byte, params argon2.Params) (key.Encryptor, error) {
+	k, err := argon2.Argon2id(passphrase, salt, params, keySize)
+	if err != nil {
+		return nil, err
+	}
+	ck, err := aesgcm.KeyFrom(iana.AlgorithmA256GCM, k)
+	if err != nil {
+		return nil, err
+	}
+	return aesgcm.New(ck)
+}
+
+// paramsFrom returns the Argon2id parameters from the protected header.
+func paramsFrom(h cose.Headers) (argon2.Params, error) {
+	var params argon2.Params
+	m, err := h.GetMap(HeaderParameterArgon2)
+	if err != nil || m == nil {
+		return params, errors.New("invalid Argon2id parameters")
+	}
+
+	t, err1 := m.GetUint64("t")
+	mem, err2 := m.GetUint64("m")
+	p, err3 := m.GetUint64("p")
+	if err := errors.Join(err1, err2, err3); err != nil || t > argon2.MaxTime || mem > maxMemory || p > argon2.MaxThreads {
+		return params, errors.New("invalid Argon2id parameters")
+	}
+
+	params = argon2.Params{Time: uint32(t), Memory: uint32(mem), Threads: uint8(p)}
+	if err := validateParams(params); err != nil {
+		return params, err
+	}
+	return params, nil
+}
+
+// validateParams checks the Argon2id parameters, with the memory limited to maxMemory.
+func validateParams(params argon2.Params) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	if params.Memory > maxMemory {
+		return fmt.Errorf("invalid Argon2id memory %d, exceeds %d", params.Memory, maxMemory)
+	}
+	return nil
+}
+
+// writeFile writes the data to a temporary file in the same directory,
+// then renames it to the path, so the file is either the old or the new content.
+func writeFile(path string, data []byte) (err error) {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := os.CreateTemp(dir, "."+name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err = f.Chmod(0o600); err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+
+	// persists the rename, it is not supported on some platforms.
+	if d, e := os.Open(dir); e == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
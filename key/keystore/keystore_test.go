@@ -0,0 +1,220 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package keystore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ldclabs/cose/cose"
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/aesgcm"
+	"github.com/ldclabs/cose/key/argon2"
+	"github.com/ldclabs/cose/key/ecdsa"
+	"github.com/ldclabs/cose/key/ed25519"
+)
+
+var testOptions = &Options{Params: argon2.Params{Time: 1, Memory: 64, Threads: 1}}
+
+func TestKeyStore(t *testing.T) {
+	k1, err := ed25519.GenerateKey()
+	require.NoError(t, err)
+	k2, err := ecdsa.GenerateKey(iana.AlgorithmES256)
+	require.NoError(t, err)
+
+	t.Run("add, remove and list", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "keys.cose")
+		s, err := Open(path, []byte("passphrase"), testOptions)
+		require.NoError(err)
+		assert.Equal(path, s.Path())
+		assert.Len(s.List(), 0)
+		_, err = os.Stat(path)
+		assert.ErrorIs(err, os.ErrNotExist, "the file is created on the first change")
+
+		require.NoError(s.Add(k1))
+		require.NoError(s.Add(k2))
+		assert.Equal([]key.ByteStr{k1.Kid(), k2.Kid()}, s.List())
+		assert.ErrorContains(s.Add(k1), "already exists")
+
+		fi, err := os.Stat(path)
+		require.NoError(err)
+		assert.Equal(os.FileMode(0o600), fi.Mode().Perm())
+
+		s2, err := Open(path, []byte("passphrase"), nil)
+		require.NoError(err)
+		assert.Equal(s.List(), s2.List())
+		k, err := s2.Get(k2.Kid())
+		require.NoError(err)
+		assert.Equal(key.MustMarshalCBOR(k2), key.MustMarshalCBOR(k))
+		assert.Equal(testOptions.Params, s2.params, "keeps the parameters of the file")
+
+		signer, err := k.Signer()
+		require.NoError(err)
+		sig, err := signer.Sign([]byte("hello"))
+		require.NoError(err)
+		verifier, err := k2.Verifier()
+		require.NoError(err)
+		assert.NoError(verifier.Verify([]byte("hello"), sig))
+
+		require.NoError(s2.Remove(k1.Kid()))
+		assert.ErrorContains(s2.Remove(k1.Kid()), "not found")
+		_, err = s2.Get(k1.Kid())
+		assert.ErrorContains(err, "not found")
+		assert.Equal(key.KeySet{k}, s2.KeySet())
+
+		s3, err := Open(path, []byte("passphrase"), testOptions)
+		require.NoError(err)
+		assert.Equal([]key.ByteStr{k2.Kid()}, s3.List())
+
+		entries, err := os.ReadDir(filepath.Dir(path))
+		require.NoError(err)
+		assert.Len(entries, 1, "no temporary files are left")
+	})
+
+	t.Run("file format", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "keys.cose")
+		s, err := Open(path, []byte("passphrase"), testOptions)
+		require.NoError(err)
+		require.NoError(s.Add(k1))
+
+		data, err := os.ReadFile(path)
+		require.NoError(err)
+		obj := &cose.Encrypt0Message[key.KeySet]{}
+		require.NoError(obj.UnmarshalCBOR(data))
+		alg, _ := obj.Protected.GetInt(iana.HeaderParameterAlg)
+		assert.Equal(iana.AlgorithmA256GCM, alg)
+		salt, _ := obj.Protected.GetBytes(iana.HeaderAlgorithmParameterSalt)
+		assert.Len(salt, 16)
+		params, err := paramsFrom(obj.Protected)
+		require.NoError(err)
+		assert.Equal(testOptions.Params, params)
+		iv, _ := obj.Unprotected.GetBytes(iana.HeaderParameterIV)
+		assert.Len(iv, 12)
+
+		// every write uses a new iv
+		require.NoError(s.Add(k2))
+		require.NoError(s.Remove(k2.Kid()))
+		data2, err := os.ReadFile(path)
+		require.NoError(err)
+		assert.NotEqual(data, data2)
+	})
+
+	t.Run("change passphrase", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "keys.cose")
+		s, err := Open(path, []byte("passphrase"), testOptions)
+		require.NoError(err)
+		require.NoError(s.Add(k1))
+		require.NoError(s.ChangePassphrase([]byte("new passphrase")))
+		assert.ErrorContains(s.ChangePassphrase(nil), "empty passphrase")
+
+		_, err = Open(path, []byte("passphrase"), testOptions)
+		assert.ErrorContains(err, "decryption failed, invalid passphrase or data")
+		s2, err := Open(path, []byte("new passphrase"), testOptions)
+		require.NoError(err)
+		assert.Equal([]key.ByteStr{k1.Kid()}, s2.List())
+
+		s2.params.Memory = maxMemory + 1
+		assert.ErrorContains(s2.ChangePassphrase([]byte("passphrase")), "invalid Argon2id memory")
+		_, err = Open(path, []byte("new passphrase"), testOptions)
+		require.NoError(err, "keeps the file with the old passphrase")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, "keys.cose")
+		_, err := Open(path, nil, testOptions)
+		assert.ErrorContains(err, "cose/key/keystore: Open: empty passphrase")
+		_, err = Open(path, []byte("passphrase"), &Options{Params: argon2.Params{Time: 1}})
+		assert.ErrorContains(err, "invalid threads 0")
+		_, err = Open(path, []byte("passphrase"), &Options{Params: argon2.Params{Time: 1, Memory: maxMemory + 1, Threads: 1}})
+		assert.ErrorContains(err, "invalid Argon2id memory")
+		_, err = Open(dir, []byte("passphrase"), testOptions)
+		assert.Error(err)
+
+		require.NoError(os.WriteFile(path, []byte{1, 2, 3}, 0o600))
+		_, err = Open(path, []byte("passphrase"), testOptions)
+		assert.ErrorContains(err, "invalid store file")
+
+		s, err := Open(filepath.Join(dir, "keys2.cose"), []byte("passphrase"), testOptions)
+		require.NoError(err)
+		assert.ErrorContains(s.Add(nil), "nil key")
+		assert.ErrorContains(s.Add(key.Key{iana.KeyParameterKty: iana.KeyTypeOKP}), "missing kid")
+
+		s.path = filepath.Join(dir, "missing", "keys.cose")
+		assert.ErrorContains(s.Add(k1), "cose/key/keystore: KeyStore.Add:")
+		assert.Len(s.List(), 0, "failed write keeps the keys")
+
+		for _, tc := range []struct {
+			params key.CoseMap
+			err    string
+		}{
+			{nil, "invalid Argon2id parameters"},
+			{key.CoseMap{"t": 1, "m": 64}, "invalid threads 0"},
+			{key.CoseMap{"t": 1, "m": maxMemory + 1, "p": 1}, "invalid Argon2id parameters"},
+			{key.CoseMap{"t": 1, "m": 64, "p": 256}, "invalid Argon2id parameters"},
+			{key.CoseMap{"t": 0, "m": 64, "p": 1}, "invalid time 0"},
+		} {
+			h := cose.Headers{}
+			if tc.params != nil {
+				h[HeaderParameterArgon2] = tc.params
+			}
+			_, err := paramsFrom(h)
+			assert.ErrorContains(err, tc.err)
+		}
+
+		encryptor, err := deriveEncryptor([]byte("passphrase"), make([]byte, 16), testOptions.Params)
+		require.NoError(err)
+		ck, err := aesgcm.GenerateKey(iana.AlgorithmA128GCM)
+		require.NoError(err)
+		encryptor128, err := ck.Encryptor()
+		require.NoError(err)
+		for _, tc := range []struct {
+			encryptor key.Encryptor
+			protected cose.Headers
+			err       string
+		}{
+			{encryptor128, cose.Headers{iana.HeaderParameterAlg: iana.AlgorithmA128GCM}, "unsupported algorithm 1"},
+			{encryptor, cose.Headers{iana.HeaderParameterAlg: iana.AlgorithmA256GCM}, "invalid salt"},
+			{encryptor, cose.Headers{
+				iana.HeaderParameterAlg:           iana.AlgorithmA256GCM,
+				iana.HeaderAlgorithmParameterSalt: make([]byte, 16),
+			}, "invalid Argon2id parameters"},
+		} {
+			obj := &cose.Encrypt0Message[[]byte]{Protected: tc.protected, Payload: []byte{0x80}}
+			data, err := obj.EncryptAndEncode(tc.encryptor, nil)
+			require.NoError(err)
+			assert.ErrorContains((&KeyStore{}).decrypt(data, []byte("passphrase")), tc.err)
+		}
+
+		ks := &KeyStore{params: testOptions.Params, salt: make([]byte, 16), encryptor: encryptor, path: path}
+		k := key.Key{iana.KeyParameterKty: iana.KeyTypeOKP}
+		require.NoError(ks.save(key.KeySet{k}))
+		assert.ErrorContains(ks.decrypt(mustRead(t, path), []byte("passphrase")), "missing kid")
+		require.NoError(ks.save(key.KeySet{k1, k1}))
+		assert.ErrorContains(ks.decrypt(mustRead(t, path), []byte("passphrase")), "duplicate kid")
+	})
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return data
+}
@@ -0,0 +1,238 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package key
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/ldclabs/cose/iana"
+)
+
+// PEM block types.
+const (
+	PEMPrivateKey          = "PRIVATE KEY"
+	PEMEncryptedPrivateKey = "ENCRYPTED PRIVATE KEY"
+	PEMPublicKey           = "PUBLIC KEY"
+	PEMECPrivateKey        = "EC PRIVATE KEY"
+	PEMRSAPrivateKey       = "RSA PRIVATE KEY"
+	PEMRSAPublicKey        = "RSA PUBLIC KEY"
+)
+
+// ParsePEM parses the first private or public key in the PEM data, other blocks such as
+// "EC PARAMETERS" are skipped. PKCS#8 "PRIVATE KEY", SPKI "PUBLIC KEY", SEC1 "EC PRIVATE KEY",
+// and PKCS#1 "RSA PRIVATE KEY" and "RSA PUBLIC KEY" blocks are parsed.
+// Use ParseEncryptedPEM for the "ENCRYPTED PRIVATE KEY" block.
+//
+// The Ed25519, X25519, ECDSA P-256, P-384 and P-521 keys are supported by the CryptoConverter
+// that the key packages register, so the packages must be imported. The RSA keys are rejected
+// since no RSA algorithm is registered. The EC2 keys are ECDSA keys with the algorithm of the curve,
+// and the kid is the default kid of the key packages, such as ecdsa.KeyFromPrivate.
+func ParsePEM(data []byte) (Key, error) {
+	return parsePEM(data, nil)
+}
+
+// ParseEncryptedPEM parses the first private or public key in the PEM data as ParsePEM,
+// the "ENCRYPTED PRIVATE KEY" block is decrypted with the password.
+// The PBES2 encryption scheme with PBKDF2 and AES-CBC is supported.
+func ParseEncryptedPEM(data, password []byte) (Key, error) {
+	return parsePEM(data, password)
+}
+
+func parsePEM(data, password []byte) (Key, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("cose/key: ParsePEM: no key found")
+		}
+
+		var pk any
+		var err error
+		switch block.Type {
+		case PEMPrivateKey:
+			pk, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+
+		case PEMEncryptedPrivateKey:
+			if password == nil {
+				return nil, errors.New("cose/key: ParsePEM: encrypted private key requires a password")
+			}
+			var der []byte
+			if der, err = decryptPKCS8(block.Bytes, password); err == nil {
+				pk, err = x509.ParsePKCS8PrivateKey(der)
+			}
+
+		case PEMPublicKey:
+			pk, err = x509.ParsePKIXPublicKey(block.Bytes)
+
+		case PEMECPrivateKey:
+			pk, err = x509.ParseECPrivateKey(block.Bytes)
+
+		case PEMRSAPrivateKey:
+			if _, ok := block.Headers["DEK-Info"]; ok {
+				return nil, errors.New("cose/key: ParsePEM: legacy encrypted PEM is not supported")
+			}
+			pk, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+
+		case PEMRSAPublicKey:
+			pk, err = x509.ParsePKCS1PublicKey(block.Bytes)
+
+		default:
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("cose/key: ParsePEM: invalid %q block, %w", block.Type, err)
+		}
+		k, err := keyFromCrypto(pk)
+		if err != nil {
+			return nil, fmt.Errorf("cose/key: ParsePEM: %w", err)
+		}
+		return k, nil
+	}
+}
+
+// MarshalPEM returns the PEM encoding of the key. A private key is encoded as
+// a PKCS#8 "PRIVATE KEY" block, and a public key is encoded as a SPKI "PUBLIC KEY" block.
+// The key parameters other than the key material, such as kid and alg, are not encoded.
+func MarshalPEM(k Key) ([]byte, error) {
+	pk, err := keyToCrypto(k)
+	if err != nil {
+		return nil, fmt.Errorf("cose/key: MarshalPEM: %w", err)
+	}
+
+	block := &pem.Block{}
+	if isPrivateCrypto(pk) {
+		block.Type = PEMPrivateKey
+		block.Bytes, err = x509.MarshalPKCS8PrivateKey(pk)
+	} else {
+		block.Type = PEMPublicKey
+		block.Bytes, err = x509.MarshalPKIXPublicKey(pk)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cose/key: MarshalPEM: %w", err)
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// MarshalEncryptedPEM returns the PEM encoding of the private key as a PKCS#8
+// "ENCRYPTED PRIVATE KEY" block, encrypted with PBES2 using PBKDF2-HMAC-SHA256 and AES-256-CBC.
+func MarshalEncryptedPEM(k Key, password []byte) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, errors.New("cose/key: MarshalEncryptedPEM: empty password")
+	}
+
+	pk, err := keyToCrypto(k)
+	if err != nil {
+		return nil, fmt.Errorf("cose/key: MarshalEncryptedPEM: %w", err)
+	}
+	if !isPrivateCrypto(pk) {
+		return nil, errors.New("cose/key: MarshalEncryptedPEM: not a private key")
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(pk)
+	if err != nil {
+		return nil, fmt.Errorf("cose/key: MarshalEncryptedPEM: %w", err)
+	}
+	if der, err = encryptPKCS8(der, password); err != nil {
+		return nil, fmt.Errorf("cose/key: MarshalEncryptedPEM: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMEncryptedPrivateKey, Bytes: der}), nil
+}
+
+// MarshalSEC1PEM returns the PEM encoding of the EC2 private key as a SEC1 "EC PRIVATE KEY" block.
+func MarshalSEC1PEM(k Key) ([]byte, error) {
+	pk, err := keyToCrypto(k)
+	if err != nil {
+		return nil, fmt.Errorf("cose/key: MarshalSEC1PEM: %w", err)
+	}
+	ek, ok := pk.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("cose/key: MarshalSEC1PEM: not an EC2 private key")
+	}
+
+	der, err := x509.MarshalECPrivateKey(ek)
+	if err != nil {
+		return nil, fmt.Errorf("cose/key: MarshalSEC1PEM: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMECPrivateKey, Bytes: der}), nil
+}
+
+func isPrivateCrypto(pk any) bool {
+	switch pk.(type) {
+	case ed25519.PrivateKey, *ecdh.PrivateKey, *ecdsa.PrivateKey:
+		return true
+	default:
+		return false
+	}
+}
+
+// keyFromCrypto returns a Key with the given crypto key by the CryptoConverter that
+// the key packages register, such as ecdsa.KeyFromPrivate.
+func keyFromCrypto(pk any) (Key, error) {
+	var tk tripleKey
+	switch pk := pk.(type) {
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		tk = tripleKey{iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519}
+
+	case *ecdh.PrivateKey:
+		if pk.Curve() != ecdh.X25519() {
+			return nil, fmt.Errorf("unsupported ECDH curve %v", pk.Curve())
+		}
+		tk = tripleKey{iana.KeyTypeOKP, iana.AlgorithmReserved, iana.EllipticCurveX25519}
+
+	case *ecdh.PublicKey:
+		if pk.Curve() != ecdh.X25519() {
+			return nil, fmt.Errorf("unsupported ECDH curve %v", pk.Curve())
+		}
+		tk = tripleKey{iana.KeyTypeOKP, iana.AlgorithmReserved, iana.EllipticCurveX25519}
+
+	case *ecdsa.PrivateKey:
+		if tk = ecTriple(pk.Curve); tk[0] == 0 {
+			return nil, fmt.Errorf("unsupported curve %v", pk.Curve.Params().Name)
+		}
+
+	case *ecdsa.PublicKey:
+		if tk = ecTriple(pk.Curve); tk[0] == 0 {
+			return nil, fmt.Errorf("unsupported curve %v", pk.Curve.Params().Name)
+		}
+
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return nil, errors.New("RSA key is not supported")
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", pk)
+	}
+
+	return DefaultRegistry.fromCrypto(tk, pk)
+}
+
+// keyToCrypto returns the crypto private key for the given private Key,
+// or the crypto public key for the given public Key.
+func keyToCrypto(k Key) (any, error) {
+	if k.Kty() == iana.KeyTypeRSA {
+		return nil, errors.New("RSA key is not supported")
+	}
+	return DefaultRegistry.toCrypto(k)
+}
+
+func ecTriple(curve elliptic.Curve) tripleKey {
+	switch curve {
+	case elliptic.P256():
+		return tripleKey{iana.KeyTypeEC2, iana.AlgorithmES256, iana.EllipticCurveP_256}
+	case elliptic.P384():
+		return tripleKey{iana.KeyTypeEC2, iana.AlgorithmES384, iana.EllipticCurveP_384}
+	case elliptic.P521():
+		return tripleKey{iana.KeyTypeEC2, iana.AlgorithmES512, iana.EllipticCurveP_521}
+	default:
+		return tripleKey{}
+	}
+}
@@ -0,0 +1,206 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package key
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// pbkdf2Iterations is the PBKDF2-HMAC-SHA256 iteration count for the encrypted PKCS#8 keys.
+	// https://cheatsheetseries.owasp.org/cheatsheets/Password_Storage_Cheat_Sheet.html#pbkdf2
+	pbkdf2Iterations = 600_000
+	// pbkdf2MaxIterations limits the work of decrypting a PKCS#8 key from an untrusted source.
+	pbkdf2MaxIterations = 10_000_000
+	pbkdf2SaltSize      = 16
+)
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	errDecryptPKCS8   = errors.New("decryption failed, invalid password or data")
+	pbkdf2PRFs        = []struct {
+		oid asn1.ObjectIdentifier
+		fn  func() hash.Hash
+	}{
+		{oidHMACWithSHA1, sha1.New},
+		{oidHMACWithSHA256, sha256.New},
+		{oidHMACWithSHA384, sha512.New384},
+		{oidHMACWithSHA512, sha512.New},
+	}
+	aesCBCKeySizes = []struct {
+		oid  asn1.ObjectIdentifier
+		size int
+	}{
+		{oidAES128CBC, 16},
+		{oidAES192CBC, 24},
+		{oidAES256CBC, 32},
+	}
+)
+
+// https://datatracker.ietf.org/doc/html/rfc5958#section-3
+type encryptedPrivateKeyInfo struct {
+	Algo          pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// https://datatracker.ietf.org/doc/html/rfc8018#appendix-A.4
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// https://datatracker.ietf.org/doc/html/rfc8018#appendix-A.2
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// encryptPKCS8 encrypts a DER encoded PKCS#8 private key with PBES2,
+// using PBKDF2-HMAC-SHA256 and AES-256-CBC.
+func encryptPKCS8(der, password []byte) ([]byte, error) {
+	salt := GetRandomBytes(pbkdf2SaltSize)
+	iv := GetRandomBytes(aes.BlockSize)
+	ek := pbkdf2.Key(password, salt, pbkdf2Iterations, 32, sha256.New)
+
+	block, err := aes.NewCipher(ek)
+	if err != nil {
+		return nil, err
+	}
+	padding := aes.BlockSize - len(der)%aes.BlockSize
+	data := make([]byte, len(der)+padding)
+	copy(data, der)
+	copy(data[len(der):], bytes.Repeat([]byte{byte(padding)}, padding))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: pbkdf2Iterations,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algo:          pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: data,
+	})
+}
+
+// decryptPKCS8 decrypts a DER encoded EncryptedPrivateKeyInfo with PBES2,
+// the PBKDF2 with HMAC-SHA1 or HMAC-SHA2, and AES-CBC are supported.
+func decryptPKCS8(der, password []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if rest, err := asn1.Unmarshal(der, &info); err != nil || len(rest) > 0 {
+		return nil, errors.New("invalid encrypted private key")
+	}
+	if !info.Algo.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported encryption algorithm %s", info.Algo.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algo.Parameters.FullBytes, &params); err != nil {
+		return nil, errors.New("invalid PBES2 parameters")
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation function %s", params.KeyDerivationFunc.Algorithm)
+	}
+
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, errors.New("invalid PBKDF2 parameters")
+	}
+	if kdf.IterationCount <= 0 || kdf.IterationCount > pbkdf2MaxIterations {
+		return nil, fmt.Errorf("invalid PBKDF2 iteration count %d", kdf.IterationCount)
+	}
+
+	prf := sha1.New
+	if len(kdf.PRF.Algorithm) > 0 {
+		prf = nil
+		for _, p := range pbkdf2PRFs {
+			if kdf.PRF.Algorithm.Equal(p.oid) {
+				prf = p.fn
+				break
+			}
+		}
+		if prf == nil {
+			return nil, fmt.Errorf("unsupported PBKDF2 PRF %s", kdf.PRF.Algorithm)
+		}
+	}
+
+	size := 0
+	for _, c := range aesCBCKeySizes {
+		if params.EncryptionScheme.Algorithm.Equal(c.oid) {
+			size = c.size
+			break
+		}
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("unsupported encryption scheme %s", params.EncryptionScheme.Algorithm)
+	}
+	if kdf.KeyLength != 0 && kdf.KeyLength != size {
+		return nil, fmt.Errorf("invalid PBKDF2 key length %d", kdf.KeyLength)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil || len(iv) != aes.BlockSize {
+		return nil, errors.New("invalid AES-CBC iv")
+	}
+
+	data := info.EncryptedData
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errDecryptPKCS8
+	}
+
+	block, err := aes.NewCipher(pbkdf2.Key(password, kdf.Salt, kdf.IterationCount, size, prf))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+
+	padding := int(out[len(out)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, errDecryptPKCS8
+	}
+	for _, b := range out[len(out)-padding:] {
+		if int(b) != padding {
+			return nil, errDecryptPKCS8
+		}
+	}
+	return out[:len(out)-padding], nil
+}
@@ -0,0 +1,87 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package key
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPKCS8(t *testing.T) {
+	t.Run("encryptPKCS8", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		for _, der := range [][]byte{{1}, make([]byte, 16), make([]byte, 100)} {
+			data, err := encryptPKCS8(der, []byte("secret"))
+			require.NoError(err)
+
+			out, err := decryptPKCS8(data, []byte("secret"))
+			require.NoError(err)
+			assert.Equal(der, out)
+		}
+
+		data, err := encryptPKCS8([]byte("hello"), []byte("secret"))
+		require.NoError(err)
+		_, err = decryptPKCS8(data, []byte("secret1"))
+		assert.ErrorContains(err, "decryption failed")
+		_, err = decryptPKCS8(append(data, 0), []byte("secret"))
+		assert.ErrorContains(err, "invalid encrypted private key")
+	})
+
+	t.Run("decryptPKCS8 errors", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		mustMarshal := func(v any) asn1.RawValue {
+			data, err := asn1.Marshal(v)
+			require.NoError(err)
+			return asn1.RawValue{FullBytes: data}
+		}
+		iv := mustMarshal(make([]byte, 16))
+		build := func(kdf pbkdf2Params, kdfOID, encOID asn1.ObjectIdentifier, iv asn1.RawValue, data []byte) []byte {
+			params := mustMarshal(pbes2Params{
+				KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: kdfOID, Parameters: mustMarshal(kdf)},
+				EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: encOID, Parameters: iv},
+			})
+			return mustMarshal(encryptedPrivateKeyInfo{
+				Algo:          pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: params},
+				EncryptedData: data,
+			}).FullBytes
+		}
+		kdf := pbkdf2Params{Salt: []byte{1, 2, 3}, IterationCount: 1}
+
+		for _, tc := range []struct {
+			der []byte
+			err string
+		}{
+			{[]byte{1, 2, 3}, "invalid encrypted private key"},
+			{mustMarshal(encryptedPrivateKeyInfo{
+				Algo: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}},
+			}).FullBytes, "unsupported encryption algorithm 1.2.840.113549.1.12.1.3"},
+			{mustMarshal(encryptedPrivateKeyInfo{
+				Algo: pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.NullRawValue},
+			}).FullBytes, "invalid PBES2 parameters"},
+			{build(kdf, oidPBES2, oidAES256CBC, iv, make([]byte, 16)), "unsupported key derivation function"},
+			{build(pbkdf2Params{Salt: []byte{1}}, oidPBKDF2, oidAES256CBC, iv, make([]byte, 16)), "invalid PBKDF2 iteration count 0"},
+			{build(pbkdf2Params{Salt: []byte{1}, IterationCount: pbkdf2MaxIterations + 1}, oidPBKDF2, oidAES256CBC, iv, make([]byte, 16)),
+				"invalid PBKDF2 iteration count"},
+			{build(pbkdf2Params{Salt: []byte{1}, IterationCount: 1, PRF: pkix.AlgorithmIdentifier{Algorithm: oidPBES2}},
+				oidPBKDF2, oidAES256CBC, iv, make([]byte, 16)), "unsupported PBKDF2 PRF"},
+			{build(kdf, oidPBKDF2, oidPBKDF2, iv, make([]byte, 16)), "unsupported encryption scheme"},
+			{build(pbkdf2Params{Salt: []byte{1}, IterationCount: 1, KeyLength: 16}, oidPBKDF2, oidAES256CBC, iv, make([]byte, 16)),
+				"invalid PBKDF2 key length 16"},
+			{build(kdf, oidPBKDF2, oidAES256CBC, mustMarshal(make([]byte, 8)), make([]byte, 16)), "invalid AES-CBC iv"},
+			{build(kdf, oidPBKDF2, oidAES256CBC, iv, make([]byte, 15)), "decryption failed"},
+			{build(kdf, oidPBKDF2, oidAES128CBC, iv, nil), "decryption failed"},
+		} {
+			_, err := decryptPKCS8(tc.der, []byte("secret"))
+			assert.ErrorContains(err, tc.err)
+		}
+	})
+}
@@ -4,7 +4,12 @@
 package key
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
 
 	"github.com/ldclabs/cose/iana"
 )
@@ -21,122 +26,685 @@ type MACerFactory func(Key) (MACer, error)
 // EncryptorFactory is a function that returns a Encryptor for the given key.
 type EncryptorFactory func(Key) (Encryptor, error)
 
-type tripleKey [3]int
+// GeneratorFactory is a function that generates a new private key for the given algorithm and curve.
+type GeneratorFactory func(alg, crv int) (Key, error)
 
-var (
-	signers    = map[tripleKey]SignerFactory{}
-	verifiers  = map[tripleKey]VerifierFactory{}
-	macers     = map[tripleKey]MACerFactory{}
-	encryptors = map[tripleKey]EncryptorFactory{}
+// KeyChecker is a function that checks whether the given key is a valid key, such as ed25519.CheckKey.
+type KeyChecker func(Key) error
+
+// CryptoConverter converts between a Key and the crypto key of the standard library,
+// such as ed25519.PrivateKey and *ecdsa.PublicKey. It is used by ParsePEM and MarshalPEM.
+type CryptoConverter struct {
+	// FromCrypto returns a Key for the crypto private or public key.
+	FromCrypto func(pk any) (Key, error)
+	// ToCrypto returns the crypto private key for a private Key, or the crypto public key for a public Key.
+	ToCrypto func(k Key) (any, error)
+}
+
+// FactoryKind is the kind of the factories in a Registry.
+type FactoryKind int
+
+const (
+	KindSigner FactoryKind = iota + 1
+	KindVerifier
+	KindMACer
+	KindEncryptor
+	KindGenerator
+	KindChecker
+	KindConverter
 )
 
-// RegisterSigner registers a SignerFactory for the given key type, algorithm, and curve.
+// String returns the name of the FactoryKind.
+func (k FactoryKind) String() string {
+	switch k {
+	case KindSigner:
+		return "Signer"
+	case KindVerifier:
+		return "Verifier"
+	case KindMACer:
+		return "MACer"
+	case KindEncryptor:
+		return "Encryptor"
+	case KindGenerator:
+		return "Generator"
+	case KindChecker:
+		return "Checker"
+	case KindConverter:
+		return "Converter"
+	default:
+		return fmt.Sprintf("FactoryKind(%d)", int(k))
+	}
+}
+
+// Triple is the key type, algorithm, and curve that a factory is registered for.
+// The curve is 0 for the MACer and Encryptor factories.
+type Triple struct {
+	Kty int
+	Alg int
+	Crv int
+}
+
+// String returns the string form of the Triple, such as "kty(1)_alg(-8)_crv(6)".
+func (t Triple) String() string {
+	return tripleKey{t.Kty, t.Alg, t.Crv}.String()
+}
+
+type tripleKey [3]int
+
+// Registry is a set of the Signer, Verifier, MACer, Encryptor, key generator factories, key checkers
+// and crypto key converters indexed by the key type, algorithm, and curve. It is safe for concurrent use.
+//
+// The key packages register their factories into the DefaultRegistry, which is used by
+// Key.Signer, Key.Verifier, Key.MACer, Key.Encryptor and Generate. An isolated Registry
+// can be created with NewRegistry or DefaultRegistry.Filter, to restrict the algorithms
+// that a service accepts, for example:
+//
+//	reg := key.DefaultRegistry.Filter(func(kind key.FactoryKind, t key.Triple) bool {
+//		return kind == key.KindVerifier && t.Alg == iana.AlgorithmEdDSA
+//	})
+//	verifier, err := reg.Verifier(k)
+type Registry struct {
+	mu         sync.RWMutex
+	signers    map[tripleKey]SignerFactory
+	verifiers  map[tripleKey]VerifierFactory
+	macers     map[tripleKey]MACerFactory
+	encryptors map[tripleKey]EncryptorFactory
+	generators map[tripleKey]GeneratorFactory
+	checkers   map[tripleKey]KeyChecker
+	converters map[tripleKey]CryptoConverter
+	strictOps  bool
+}
+
+// DefaultRegistry is the Registry that the key packages register into.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		signers:    map[tripleKey]SignerFactory{},
+		verifiers:  map[tripleKey]VerifierFactory{},
+		macers:     map[tripleKey]MACerFactory{},
+		encryptors: map[tripleKey]EncryptorFactory{},
+		generators: map[tripleKey]GeneratorFactory{},
+		checkers:   map[tripleKey]KeyChecker{},
+		converters: map[tripleKey]CryptoConverter{},
+	}
+}
+
+// RegisterSigner registers a SignerFactory for the given key type, algorithm, and curve
+// into the DefaultRegistry. It panics if the factory is already registered.
 // For example, to register a ed25519 signer factory:
 //
 //	key.RegisterSigner(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, ed25519.NewSigner)
 func RegisterSigner(kty, alg, crv int, fn SignerFactory) {
-	tk := tripleKey{kty, alg, crv}
-	if _, ok := signers[tk]; ok {
-		panic(fmt.Errorf("cose/key: RegisterSigner: %s is already registered", tk.String()))
-	}
-	signers[tk] = fn
+	DefaultRegistry.RegisterSigner(kty, alg, crv, fn)
 }
 
-// RegisterVerifier registers a VerifierFactory for the given key type, algorithm, and curve.
+// RegisterVerifier registers a VerifierFactory for the given key type, algorithm, and curve
+// into the DefaultRegistry. It panics if the factory is already registered.
 func RegisterVerifier(kty, alg, crv int, fn VerifierFactory) {
+	DefaultRegistry.RegisterVerifier(kty, alg, crv, fn)
+}
+
+// RegisterMACer registers a MACerFactory for the given key type and algorithm
+// into the DefaultRegistry. It panics if the factory is already registered.
+func RegisterMACer(kty, alg int, fn MACerFactory) {
+	DefaultRegistry.RegisterMACer(kty, alg, fn)
+}
+
+// RegisterEncryptor registers a EncryptorFactory for the given key type and algorithm
+// into the DefaultRegistry. It panics if the factory is already registered.
+func RegisterEncryptor(kty, alg int, fn EncryptorFactory) {
+	DefaultRegistry.RegisterEncryptor(kty, alg, fn)
+}
+
+// RegisterGenerator registers a GeneratorFactory for the given key type, algorithm, and curve
+// into the DefaultRegistry. It panics if the factory is already registered.
+// The algorithm is 0 for the keys without algorithm, and the curve is 0 for the symmetric keys.
+// For example, to register a ed25519 key generator:
+//
+//	key.RegisterGenerator(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, generate)
+func RegisterGenerator(kty, alg, crv int, fn GeneratorFactory) {
+	DefaultRegistry.RegisterGenerator(kty, alg, crv, fn)
+}
+
+// RegisterChecker registers a KeyChecker for the given key type, algorithm, and curve
+// into the DefaultRegistry. It panics if the checker is already registered.
+// The algorithm is 0 for the keys without algorithm, and the curve is 0 for the symmetric keys.
+// For example, to register the ed25519 key checker:
+//
+//	key.RegisterChecker(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, ed25519.CheckKey)
+func RegisterChecker(kty, alg, crv int, fn KeyChecker) {
+	DefaultRegistry.RegisterChecker(kty, alg, crv, fn)
+}
+
+// RegisterConverter registers a CryptoConverter for the given key type, algorithm, and curve
+// into the DefaultRegistry. It panics if the converter is already registered.
+// For example, to register the ed25519 crypto key converter:
+//
+//	key.RegisterConverter(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, converter)
+func RegisterConverter(kty, alg, crv int, c CryptoConverter) {
+	DefaultRegistry.RegisterConverter(kty, alg, crv, c)
+}
+
+// CheckKey checks the key with the KeyChecker registered in the DefaultRegistry.
+// See Registry.CheckKey.
+func CheckKey(k Key) error {
+	return DefaultRegistry.CheckKey(k)
+}
+
+// Generate generates a new private key with the GeneratorFactory registered in the DefaultRegistry.
+// See Registry.Generate.
+func Generate(kty, alg, crv int) (Key, error) {
+	return DefaultRegistry.Generate(kty, alg, crv)
+}
+
+// RegisterSigner registers a SignerFactory for the given key type, algorithm, and curve.
+// It panics if the factory is already registered, use OverrideSigner to replace it.
+func (r *Registry) RegisterSigner(kty, alg, crv int, fn SignerFactory) {
+	register(r, r.signers, "RegisterSigner", tripleKey{kty, alg, crv}, fn)
+}
+
+// RegisterVerifier registers a VerifierFactory for the given key type, algorithm, and curve.
+// It panics if the factory is already registered, use OverrideVerifier to replace it.
+func (r *Registry) RegisterVerifier(kty, alg, crv int, fn VerifierFactory) {
+	register(r, r.verifiers, "RegisterVerifier", tripleKey{kty, alg, crv}, fn)
+}
+
+// RegisterMACer registers a MACerFactory for the given key type and algorithm.
+// It panics if the factory is already registered, use OverrideMACer to replace it.
+func (r *Registry) RegisterMACer(kty, alg int, fn MACerFactory) {
+	register(r, r.macers, "RegisterMACer", tripleKey{kty, alg, 0}, fn)
+}
+
+// RegisterEncryptor registers a EncryptorFactory for the given key type and algorithm.
+// It panics if the factory is already registered, use OverrideEncryptor to replace it.
+func (r *Registry) RegisterEncryptor(kty, alg int, fn EncryptorFactory) {
+	register(r, r.encryptors, "RegisterEncryptor", tripleKey{kty, alg, 0}, fn)
+}
+
+// RegisterGenerator registers a GeneratorFactory for the given key type, algorithm, and curve.
+// It panics if the factory is already registered, use OverrideGenerator to replace it.
+func (r *Registry) RegisterGenerator(kty, alg, crv int, fn GeneratorFactory) {
+	register(r, r.generators, "RegisterGenerator", tripleKey{kty, alg, crv}, fn)
+}
+
+// RegisterChecker registers a KeyChecker for the given key type, algorithm, and curve.
+// It panics if the checker is already registered, use OverrideChecker to replace it.
+func (r *Registry) RegisterChecker(kty, alg, crv int, fn KeyChecker) {
+	register(r, r.checkers, "RegisterChecker", tripleKey{kty, alg, crv}, fn)
+}
+
+// RegisterConverter registers a CryptoConverter for the given key type, algorithm, and curve.
+// It panics if the converter is already registered, use OverrideConverter to replace it.
+func (r *Registry) RegisterConverter(kty, alg, crv int, c CryptoConverter) {
+	register(r, r.converters, "RegisterConverter", tripleKey{kty, alg, crv}, c)
+}
+
+// OverrideSigner registers or replaces the SignerFactory for the given key type, algorithm, and curve,
+// such as a hardware-backed implementation. It returns the replaced factory, or nil.
+func (r *Registry) OverrideSigner(kty, alg, crv int, fn SignerFactory) SignerFactory {
+	return override(r, r.signers, tripleKey{kty, alg, crv}, fn)
+}
+
+// OverrideVerifier registers or replaces the VerifierFactory for the given key type, algorithm, and curve.
+// It returns the replaced factory, or nil.
+func (r *Registry) OverrideVerifier(kty, alg, crv int, fn VerifierFactory) VerifierFactory {
+	return override(r, r.verifiers, tripleKey{kty, alg, crv}, fn)
+}
+
+// OverrideMACer registers or replaces the MACerFactory for the given key type and algorithm.
+// It returns the replaced factory, or nil.
+func (r *Registry) OverrideMACer(kty, alg int, fn MACerFactory) MACerFactory {
+	return override(r, r.macers, tripleKey{kty, alg, 0}, fn)
+}
+
+// OverrideEncryptor registers or replaces the EncryptorFactory for the given key type and algorithm.
+// It returns the replaced factory, or nil.
+func (r *Registry) OverrideEncryptor(kty, alg int, fn EncryptorFactory) EncryptorFactory {
+	return override(r, r.encryptors, tripleKey{kty, alg, 0}, fn)
+}
+
+// OverrideGenerator registers or replaces the GeneratorFactory for the given key type, algorithm, and curve.
+// It returns the replaced factory, or nil.
+func (r *Registry) OverrideGenerator(kty, alg, crv int, fn GeneratorFactory) GeneratorFactory {
+	return override(r, r.generators, tripleKey{kty, alg, crv}, fn)
+}
+
+// OverrideChecker registers or replaces the KeyChecker for the given key type, algorithm, and curve.
+// It returns the replaced checker, or nil.
+func (r *Registry) OverrideChecker(kty, alg, crv int, fn KeyChecker) KeyChecker {
+	return override(r, r.checkers, tripleKey{kty, alg, crv}, fn)
+}
+
+// OverrideConverter registers or replaces the CryptoConverter for the given key type, algorithm, and curve.
+// It returns the replaced converter.
+func (r *Registry) OverrideConverter(kty, alg, crv int, c CryptoConverter) CryptoConverter {
+	return override(r, r.converters, tripleKey{kty, alg, crv}, c)
+}
+
+// Unregister removes the factory of the given kind for the given key type, algorithm, and curve.
+// It returns true if the factory was registered.
+func (r *Registry) Unregister(kind FactoryKind, kty, alg, crv int) bool {
 	tk := tripleKey{kty, alg, crv}
-	if _, ok := verifiers[tk]; ok {
-		panic(fmt.Errorf("cose/key: RegisterVerifier: %s is already registered", tk.String()))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ok bool
+	switch kind {
+	case KindSigner:
+		_, ok = r.signers[tk]
+		delete(r.signers, tk)
+	case KindVerifier:
+		_, ok = r.verifiers[tk]
+		delete(r.verifiers, tk)
+	case KindMACer:
+		_, ok = r.macers[tk]
+		delete(r.macers, tk)
+	case KindEncryptor:
+		_, ok = r.encryptors[tk]
+		delete(r.encryptors, tk)
+	case KindGenerator:
+		_, ok = r.generators[tk]
+		delete(r.generators, tk)
+	case KindChecker:
+		_, ok = r.checkers[tk]
+		delete(r.checkers, tk)
+	case KindConverter:
+		_, ok = r.converters[tk]
+		delete(r.converters, tk)
 	}
-	verifiers[tk] = fn
+	return ok
 }
 
-// RegisterMACer registers a MACerFactory for the given key type and algorithm.
-func RegisterMACer(kty, alg int, fn MACerFactory) {
-	tk := tripleKey{kty, alg, 0}
-	if _, ok := macers[tk]; ok {
-		panic(fmt.Errorf("cose/key: RegisterMACer: %s is already registered", tk.String()))
+// Supported returns the triples that the factories of the given kind are registered for,
+// sorted by the key type, algorithm, and curve.
+func (r *Registry) Supported(kind FactoryKind) []Triple {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ts []Triple
+	switch kind {
+	case KindSigner:
+		ts = triples(r.signers)
+	case KindVerifier:
+		ts = triples(r.verifiers)
+	case KindMACer:
+		ts = triples(r.macers)
+	case KindEncryptor:
+		ts = triples(r.encryptors)
+	case KindGenerator:
+		ts = triples(r.generators)
+	case KindChecker:
+		ts = triples(r.checkers)
+	case KindConverter:
+		ts = triples(r.converters)
 	}
-	macers[tk] = fn
+	return ts
 }
 
-// RegisterEncryptor registers a EncryptorFactory for the given key type and algorithm.
-func RegisterEncryptor(kty, alg int, fn EncryptorFactory) {
-	tk := tripleKey{kty, alg, 0}
-	if _, ok := encryptors[tk]; ok {
-		panic(fmt.Errorf("cose/key: RegisterEncryptor: %s is already registered", tk.String()))
+// Filter returns a new Registry with the factories for which fn returns true.
+// If fn is nil, all factories are copied.
+func (r *Registry) Filter(fn func(kind FactoryKind, t Triple) bool) *Registry {
+	if fn == nil {
+		fn = func(FactoryKind, Triple) bool { return true }
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	nr := NewRegistry()
+	nr.strictOps = r.strictOps
+	filter(nr.signers, r.signers, KindSigner, fn)
+	filter(nr.verifiers, r.verifiers, KindVerifier, fn)
+	filter(nr.macers, r.macers, KindMACer, fn)
+	filter(nr.encryptors, r.encryptors, KindEncryptor, fn)
+	filter(nr.generators, r.generators, KindGenerator, fn)
+	filter(nr.checkers, r.checkers, KindChecker, fn)
+	filter(nr.converters, r.converters, KindConverter, fn)
+	return nr
+}
+
+// SetStrictOps sets the strict mode of the key_ops parameter. In strict mode, the key_ops
+// parameter of a key is REQUIRED and MUST include an operation of the requested Signer ("sign":1),
+// Verifier ("verify":2), MACer ("MAC create":9 or "MAC verify":10) or Encryptor ("encrypt":3 or "decrypt":4),
+// otherwise an *OpError is returned. The operations are always checked by the Signer, Verifier,
+// MACer and Encryptor when the key_ops parameter is present, see Key.CheckOps.
+// The strict mode of DefaultRegistry also applies to the "derive key":7 or "derive bits":8 operations
+// of ecdh.ECDHer, which is not created by a Registry.
+func (r *Registry) SetStrictOps(strict bool) {
+	r.mu.Lock()
+	r.strictOps = strict
+	r.mu.Unlock()
+}
+
+// StrictOps returns true if the Registry is in strict mode of the key_ops parameter.
+func (r *Registry) StrictOps() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.strictOps
+}
+
+// checkOps checks the key_ops parameter of the key in strict mode.
+func (r *Registry) checkOps(k Key, ops ...int) error {
+	if !r.StrictOps() {
+		return nil
 	}
-	encryptors[tk] = fn
+	return k.CheckOps(true, ops...)
 }
 
 // Signer returns a Signer for the given key.
 // If the key is nil, or SignerFactory for the given key type, algorithm, and curve not registered,
 // an error is returned.
-func (k Key) Signer() (Signer, error) {
+func (r *Registry) Signer(k Key) (Signer, error) {
 	if k == nil {
-		return nil, fmt.Errorf("cose/key: Key.Signer: nil key")
+		return nil, fmt.Errorf("cose/key: Registry.Signer: nil key")
 	}
 
-	tk := k.tripleKey()
-	fn, ok := signers[tk]
-	if !ok {
-		return nil, fmt.Errorf("cose/key: Key.Signer: %s is not registered", tk.String())
+	fn, tk := lookup(r, r.signers, k)
+	if fn == nil {
+		return nil, fmt.Errorf("cose/key: Registry.Signer: %s is not registered", tk.String())
+	}
+	if err := r.checkOps(k, iana.KeyOperationSign); err != nil {
+		return nil, fmt.Errorf("cose/key: Registry.Signer: %w", err)
 	}
-
 	return fn(k)
 }
 
 // Verifier returns a Verifier for the given key.
 // If the key is nil, or VerifierFactory for the given key type, algorithm, and curve not registered,
 // an error is returned.
-func (k Key) Verifier() (Verifier, error) {
+func (r *Registry) Verifier(k Key) (Verifier, error) {
 	if k == nil {
-		return nil, fmt.Errorf("cose/key: Key.Verifier: nil key")
+		return nil, fmt.Errorf("cose/key: Registry.Verifier: nil key")
 	}
 
-	tk := k.tripleKey()
-	fn, ok := verifiers[tk]
-	if !ok {
-		return nil, fmt.Errorf("cose/key: Key.Verifier: %s is not registered", tk.String())
+	fn, tk := lookup(r, r.verifiers, k)
+	if fn == nil {
+		return nil, fmt.Errorf("cose/key: Registry.Verifier: %s is not registered", tk.String())
+	}
+	if err := r.checkOps(k, iana.KeyOperationVerify); err != nil {
+		return nil, fmt.Errorf("cose/key: Registry.Verifier: %w", err)
 	}
-
 	return fn(k)
 }
 
 // MACer returns a MACer for the given key.
 // If the key is nil, or MACerFactory for the given key type and algorithm not registered,
 // an error is returned.
-func (k Key) MACer() (MACer, error) {
+func (r *Registry) MACer(k Key) (MACer, error) {
 	if k == nil {
-		return nil, fmt.Errorf("cose/key: Key.MACer: nil key")
+		return nil, fmt.Errorf("cose/key: Registry.MACer: nil key")
 	}
 
-	tk := k.tripleKey()
-	fn, ok := macers[tk]
-	if !ok {
-		return nil, fmt.Errorf("cose/key: Key.MACer: %s is not registered", tk.String())
+	fn, tk := lookup(r, r.macers, k)
+	if fn == nil {
+		return nil, fmt.Errorf("cose/key: Registry.MACer: %s is not registered", tk.String())
+	}
+	if err := r.checkOps(k, iana.KeyOperationMacCreate, iana.KeyOperationMacVerify); err != nil {
+		return nil, fmt.Errorf("cose/key: Registry.MACer: %w", err)
 	}
-
 	return fn(k)
 }
 
 // Encryptor returns a Encryptor for the given key.
 // If the key is nil, or EncryptorFactory for the given key type and algorithm not registered,
 // an error is returned.
-func (k Key) Encryptor() (Encryptor, error) {
+func (r *Registry) Encryptor(k Key) (Encryptor, error) {
 	if k == nil {
-		return nil, fmt.Errorf("cose/key: Key.Encryptor: nil key")
+		return nil, fmt.Errorf("cose/key: Registry.Encryptor: nil key")
 	}
 
-	tk := k.tripleKey()
-	fn, ok := encryptors[tk]
-	if !ok {
-		return nil, fmt.Errorf("cose/key: Key.Encryptor: %s is not registered", tk.String())
+	fn, tk := lookup(r, r.encryptors, k)
+	if fn == nil {
+		return nil, fmt.Errorf("cose/key: Registry.Encryptor: %s is not registered", tk.String())
+	}
+	if err := r.checkOps(k, iana.KeyOperationEncrypt, iana.KeyOperationDecrypt); err != nil {
+		return nil, fmt.Errorf("cose/key: Registry.Encryptor: %w", err)
+	}
+	return fn(k)
+}
+
+// Verifiers returns the Verifiers for the given keys.
+func (r *Registry) Verifiers(ks KeySet) (Verifiers, error) {
+	vs := make(Verifiers, 0, len(ks))
+	for _, k := range ks {
+		v, err := r.Verifier(k)
+		if err != nil {
+			return nil, err
+		}
+		vs = append(vs, v)
+	}
+	return vs, nil
+}
+
+// CheckKey checks the key with the KeyChecker registered for the key type, algorithm, and curve of the key.
+// If the key has no algorithm and no KeyChecker is registered without algorithm, the algorithm that
+// matched the curve is used, see Key.Alg. So an Ed25519 key without algorithm is checked by the
+// KeyChecker registered for EdDSA. An error is returned if the key is nil, the curve is malformed,
+// or no KeyChecker is registered.
+func (r *Registry) CheckKey(k Key) error {
+	if k == nil {
+		return errors.New("cose/key: CheckKey: nil key")
 	}
 
+	tk, err := k.paramTriple()
+	if err != nil {
+		return fmt.Errorf("cose/key: CheckKey: %w", err)
+	}
+
+	fn, ok := lookupParam(r, r.checkers, tk)
+	if !ok {
+		return fmt.Errorf("cose/key: CheckKey: %s is not registered", tk.String())
+	}
 	return fn(k)
 }
 
+// toCrypto returns the crypto private key for the given private Key, or the crypto public key
+// for the given public Key, with the CryptoConverter registered for the key type, algorithm, and curve
+// of the key. The converter is looked up as CheckKey does.
+func (r *Registry) toCrypto(k Key) (any, error) {
+	if k == nil {
+		return nil, errors.New("nil key")
+	}
+
+	tk, err := k.paramTriple()
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := lookupParam(r, r.converters, tk)
+	if !ok {
+		return nil, fmt.Errorf("%s is not registered", tk.String())
+	}
+	return c.ToCrypto(k)
+}
+
+// fromCrypto returns a Key for the crypto private or public key with the CryptoConverter
+// registered for the given key type, algorithm, and curve.
+func (r *Registry) fromCrypto(tk tripleKey, pk any) (Key, error) {
+	c, ok := lookupParam(r, r.converters, tk)
+	if !ok {
+		return nil, fmt.Errorf("%s is not registered", tk.String())
+	}
+	return c.FromCrypto(pk)
+}
+
+// CheckKeySet checks all the keys in the KeySet with CheckKey.
+// It returns a KeySetError with a KeyError for each invalid key, or nil.
+func (r *Registry) CheckKeySet(ks KeySet) error {
+	var errs KeySetError
+	for i, k := range ks {
+		if err := r.CheckKey(k); err != nil {
+			errs = append(errs, &KeyError{Index: i, Kid: k.Kid(), Err: err})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// UnmarshalKeySet decodes a COSE_KeySet from the CBOR encoding and checks all the keys with CheckKey,
+// so that the key sets from the third parties are validated on entry.
+// The keys are rejected with a KeySetError if any key is malformed or invalid.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc9052#section-7
+func (r *Registry) UnmarshalKeySet(data []byte) (KeySet, error) {
+	var items []cbor.RawMessage
+	if err := UnmarshalCBOR(data, &items); err != nil {
+		return nil, fmt.Errorf("cose/key: UnmarshalKeySet: %w", err)
+	}
+	if len(items) == 0 {
+		return nil, errors.New("cose/key: UnmarshalKeySet: empty key set")
+	}
+
+	ks := make(KeySet, len(items))
+	var errs KeySetError
+	for i, item := range items {
+		if err := ks[i].UnmarshalCBOR(item); err != nil {
+			errs = append(errs, &KeyError{Index: i, Err: err})
+			continue
+		}
+		if err := r.CheckKey(ks[i]); err != nil {
+			errs = append(errs, &KeyError{Index: i, Kid: ks[i].Kid(), Err: err})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("cose/key: UnmarshalKeySet: %w", errs)
+	}
+	return ks, nil
+}
+
+// Generate generates a new private key with the GeneratorFactory registered for the given key type,
+// algorithm, and curve. If the curve is 0 and only one curve is registered for the key type and
+// algorithm, that curve is used, so Generate(iana.KeyTypeEC2, iana.AlgorithmES384, 0) generates
+// a P-384 key.
+func (r *Registry) Generate(kty, alg, crv int) (Key, error) {
+	tk := tripleKey{kty, alg, crv}
+
+	r.mu.RLock()
+	fn, ok := r.generators[tk]
+	if !ok && crv == 0 {
+		for k, f := range r.generators {
+			if k[0] == kty && k[1] == alg {
+				if ok {
+					r.mu.RUnlock()
+					return nil, fmt.Errorf("cose/key: Generate: %s requires a curve", tk.String())
+				}
+				fn, ok, crv = f, true, k[2]
+			}
+		}
+	}
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("cose/key: Generate: %s is not registered", tk.String())
+	}
+	return fn(alg, crv)
+}
+
+// Signer returns a Signer for the given key with the DefaultRegistry.
+// If the key is nil, or SignerFactory for the given key type, algorithm, and curve not registered,
+// an error is returned.
+func (k Key) Signer() (Signer, error) {
+	return DefaultRegistry.Signer(k)
+}
+
+// Verifier returns a Verifier for the given key with the DefaultRegistry.
+// If the key is nil, or VerifierFactory for the given key type, algorithm, and curve not registered,
+// an error is returned.
+func (k Key) Verifier() (Verifier, error) {
+	return DefaultRegistry.Verifier(k)
+}
+
+// MACer returns a MACer for the given key with the DefaultRegistry.
+// If the key is nil, or MACerFactory for the given key type and algorithm not registered,
+// an error is returned.
+func (k Key) MACer() (MACer, error) {
+	return DefaultRegistry.MACer(k)
+}
+
+// Encryptor returns a Encryptor for the given key with the DefaultRegistry.
+// If the key is nil, or EncryptorFactory for the given key type and algorithm not registered,
+// an error is returned.
+func (k Key) Encryptor() (Encryptor, error) {
+	return DefaultRegistry.Encryptor(k)
+}
+
+func register[F any](r *Registry, m map[tripleKey]F, name string, tk tripleKey, fn F) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := m[tk]; ok {
+		panic(fmt.Errorf("cose/key: %s: %s is already registered", name, tk.String()))
+	}
+	m[tk] = fn
+}
+
+func override[F any](r *Registry, m map[tripleKey]F, tk tripleKey, fn F) F {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev := m[tk]
+	m[tk] = fn
+	return prev
+}
+
+func lookup[F any](r *Registry, m map[tripleKey]F, k Key) (F, tripleKey) {
+	tk := k.tripleKey()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return m[tk], tk
+}
+
+// lookupParam returns the factory registered for the triple. If the triple has no algorithm,
+// the factory registered for the algorithm that matched the curve is used as a fallback.
+func lookupParam[F any](r *Registry, m map[tripleKey]F, tk tripleKey) (F, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fn, ok := m[tk]
+	if !ok && tk[1] == iana.AlgorithmReserved {
+		fn, ok = m[tripleKey{tk[0], int(CrvAlg(tk[2])), tk[2]}]
+	}
+	return fn, ok
+}
+
+func has[F any](r *Registry, m map[tripleKey]F, tk tripleKey) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := m[tk]
+	return ok
+}
+
+func triples[F any](m map[tripleKey]F) []Triple {
+	ts := make([]Triple, 0, len(m))
+	for tk := range m {
+		ts = append(ts, Triple{tk[0], tk[1], tk[2]})
+	}
+	sort.Slice(ts, func(i, j int) bool {
+		if ts[i].Kty != ts[j].Kty {
+			return ts[i].Kty < ts[j].Kty
+		}
+		if ts[i].Alg != ts[j].Alg {
+			return ts[i].Alg < ts[j].Alg
+		}
+		return ts[i].Crv < ts[j].Crv
+	})
+	return ts
+}
+
+func filter[F any](dst, src map[tripleKey]F, kind FactoryKind, fn func(FactoryKind, Triple) bool) {
+	for tk, f := range src {
+		if fn(kind, Triple{tk[0], tk[1], tk[2]}) {
+			dst[tk] = f
+		}
+	}
+}
+
 func (k Key) tripleKey() tripleKey {
 	kty := k.Kty()
 	alg := k.Alg()
@@ -156,6 +724,24 @@ func (k Key) tripleKey() tripleKey {
 	return tripleKey{kty, int(alg), crv}
 }
 
+// paramTriple returns the key type, algorithm, and curve parameters of the key as they are.
+// The curve is only read for the OKP and EC2 keys.
+func (k Key) paramTriple() (tripleKey, error) {
+	alg, err := k.GetInt(iana.KeyParameterAlg)
+	if err != nil {
+		return tripleKey{}, fmt.Errorf("invalid parameter alg, %w", err)
+	}
+
+	var crv int
+	switch k.Kty() {
+	case iana.KeyTypeOKP, iana.KeyTypeEC2:
+		if crv, err = k.GetInt(iana.OKPKeyParameterCrv); err != nil {
+			return tripleKey{}, fmt.Errorf("invalid parameter crv, %w", err)
+		}
+	}
+	return tripleKey{k.Kty(), alg, crv}, nil
+}
+
 func (tk tripleKey) String() string {
 	str := fmt.Sprintf("kty(%d)_alg(%d)", tk[0], tk[1])
 	if tk[2] != 0 {
@@ -4,11 +4,14 @@
 package key
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/ldclabs/cose/iana"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTripleKey(t *testing.T) {
@@ -148,7 +151,7 @@ func TestRegister(t *testing.T) {
 		_, err = k.Signer()
 		assert.NoError(err)
 
-		delete(signers, k.tripleKey())
+		assert.True(DefaultRegistry.Unregister(KindSigner, iana.KeyTypeOKP, -999, -999))
 		_, err = k.Signer()
 		assert.ErrorContains(err, "kty(1)_alg(-999)_crv(-999) is not registered")
 	})
@@ -177,7 +180,7 @@ func TestRegister(t *testing.T) {
 		_, err = k.Verifier()
 		assert.NoError(err)
 
-		delete(verifiers, k.tripleKey())
+		assert.True(DefaultRegistry.Unregister(KindVerifier, iana.KeyTypeOKP, -999, -999))
 		_, err = k.Verifier()
 		assert.ErrorContains(err, "kty(1)_alg(-999)_crv(-999) is not registered")
 	})
@@ -205,7 +208,7 @@ func TestRegister(t *testing.T) {
 		_, err = k.MACer()
 		assert.NoError(err)
 
-		delete(macers, k.tripleKey())
+		assert.True(DefaultRegistry.Unregister(KindMACer, iana.KeyTypeSymmetric, -999, 0))
 		_, err = k.MACer()
 		assert.ErrorContains(err, "kty(4)_alg(-999) is not registered")
 	})
@@ -233,8 +236,288 @@ func TestRegister(t *testing.T) {
 		_, err = k.Encryptor()
 		assert.NoError(err)
 
-		delete(encryptors, k.tripleKey())
+		assert.True(DefaultRegistry.Unregister(KindEncryptor, iana.KeyTypeSymmetric, -999, 0))
 		_, err = k.Encryptor()
 		assert.ErrorContains(err, "kty(4)_alg(-999) is not registered")
 	})
+	t.Run("RegisterGenerator", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		_, err := Generate(iana.KeyTypeOKP, -999, -999)
+		assert.ErrorContains(err, "cose/key: Generate: kty(1)_alg(-999)_crv(-999) is not registered")
+
+		fn := func(alg, crv int) (Key, error) {
+			return Key{
+				iana.KeyParameterKty:    iana.KeyTypeOKP,
+				iana.KeyParameterAlg:    alg,
+				iana.OKPKeyParameterCrv: crv,
+			}, nil
+		}
+		RegisterGenerator(iana.KeyTypeOKP, -999, -999, fn)
+		assert.Panics(func() {
+			RegisterGenerator(iana.KeyTypeOKP, -999, -999, fn)
+		}, "already registered")
+
+		k, err := Generate(iana.KeyTypeOKP, -999, -999)
+		require.NoError(err)
+		assert.Equal(tripleKey{1, -999, -999}, k.tripleKey())
+
+		k, err = Generate(iana.KeyTypeOKP, -999, 0)
+		require.NoError(err)
+		assert.Equal(tripleKey{1, -999, -999}, k.tripleKey(), "the only registered curve is used")
+
+		RegisterGenerator(iana.KeyTypeOKP, -999, -998, fn)
+		_, err = Generate(iana.KeyTypeOKP, -999, 0)
+		assert.ErrorContains(err, "kty(1)_alg(-999) requires a curve")
+
+		assert.True(DefaultRegistry.Unregister(KindGenerator, iana.KeyTypeOKP, -999, -999))
+		assert.True(DefaultRegistry.Unregister(KindGenerator, iana.KeyTypeOKP, -999, -998))
+		_, err = Generate(iana.KeyTypeOKP, -999, 0)
+		assert.ErrorContains(err, "kty(1)_alg(-999) is not registered")
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	signer := func(Key) (Signer, error) { return nil, nil }
+	verifier := func(Key) (Verifier, error) { return nil, nil }
+	macer := func(Key) (MACer, error) { return nil, nil }
+	encryptor := func(Key) (Encryptor, error) { return nil, nil }
+	checker := func(k Key) error {
+		if k.Has(iana.OKPKeyParameterD) {
+			return errors.New("private key")
+		}
+		return nil
+	}
+	generator := func(alg, crv int) (Key, error) {
+		return Key{iana.KeyParameterKty: iana.KeyTypeOKP, iana.KeyParameterAlg: alg, iana.OKPKeyParameterCrv: crv}, nil
+	}
+	okp := Key{
+		iana.KeyParameterKty:    iana.KeyTypeOKP,
+		iana.KeyParameterAlg:    iana.AlgorithmEdDSA,
+		iana.OKPKeyParameterCrv: iana.EllipticCurveEd25519,
+	}
+	sym := Key{
+		iana.KeyParameterKty: iana.KeyTypeSymmetric,
+		iana.KeyParameterAlg: iana.AlgorithmA128GCM,
+	}
+
+	t.Run("isolated registry", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		r := NewRegistry()
+		_, err := r.Signer(okp)
+		assert.ErrorContains(err, "cose/key: Registry.Signer: kty(1)_alg(-8)_crv(6) is not registered")
+		_, err = r.Verifier(okp)
+		assert.ErrorContains(err, "cose/key: Registry.Verifier: kty(1)_alg(-8)_crv(6) is not registered")
+		_, err = r.MACer(sym)
+		assert.ErrorContains(err, "cose/key: Registry.MACer: kty(4)_alg(1) is not registered")
+		_, err = r.Encryptor(sym)
+		assert.ErrorContains(err, "cose/key: Registry.Encryptor: kty(4)_alg(1) is not registered")
+		_, err = r.Generate(iana.KeyTypeOKP, iana.AlgorithmEdDSA, 0)
+		assert.ErrorContains(err, "kty(1)_alg(-8) is not registered")
+		assert.ErrorContains(r.CheckKey(okp), "cose/key: CheckKey: kty(1)_alg(-8)_crv(6) is not registered")
+
+		_, err = r.Signer(nil)
+		assert.ErrorContains(err, "nil key")
+		_, err = r.Verifier(nil)
+		assert.ErrorContains(err, "nil key")
+		_, err = r.MACer(nil)
+		assert.ErrorContains(err, "nil key")
+		_, err = r.Encryptor(nil)
+		assert.ErrorContains(err, "nil key")
+		assert.ErrorContains(r.CheckKey(nil), "nil key")
+
+		r.RegisterSigner(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, signer)
+		r.RegisterVerifier(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, verifier)
+		r.RegisterMACer(iana.KeyTypeSymmetric, iana.AlgorithmA128GCM, macer)
+		r.RegisterEncryptor(iana.KeyTypeSymmetric, iana.AlgorithmA128GCM, encryptor)
+		r.RegisterGenerator(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, generator)
+		r.RegisterChecker(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, checker)
+		assert.Panics(func() {
+			r.RegisterEncryptor(iana.KeyTypeSymmetric, iana.AlgorithmA128GCM, encryptor)
+		}, "already registered")
+
+		_, err = r.Signer(okp)
+		assert.NoError(err)
+		_, err = r.Verifier(okp)
+		assert.NoError(err)
+		_, err = r.MACer(sym)
+		assert.NoError(err)
+		_, err = r.Encryptor(sym)
+		assert.NoError(err)
+		k, err := r.Generate(iana.KeyTypeOKP, iana.AlgorithmEdDSA, 0)
+		require.NoError(err)
+		assert.Equal(okp, k)
+
+		vs, err := r.Verifiers(KeySet{okp, okp})
+		require.NoError(err)
+		assert.Len(vs, 2)
+		_, err = r.Verifiers(KeySet{okp, sym})
+		assert.ErrorContains(err, "kty(4)_alg(1) is not registered")
+
+		assert.NoError(r.CheckKey(okp))
+		noAlg := Key{iana.KeyParameterKty: iana.KeyTypeOKP, iana.OKPKeyParameterCrv: iana.EllipticCurveEd25519}
+		assert.NoError(r.CheckKey(noAlg), "the algorithm that matched the curve is used")
+		noAlg[iana.OKPKeyParameterD] = []byte{1}
+		assert.ErrorContains(r.CheckKey(noAlg), "private key")
+		assert.ErrorContains(r.CheckKey(Key{iana.KeyParameterKty: iana.KeyTypeOKP, iana.KeyParameterAlg: "EdDSA"}),
+			"invalid parameter alg")
+		assert.ErrorContains(r.CheckKeySet(KeySet{okp, noAlg, sym}),
+			"invalid keys: key 1, private key; key 2, cose/key: CheckKey: kty(4)_alg(1) is not registered")
+		assert.NoError(r.CheckKeySet(KeySet{okp}))
+
+		assert.Equal([]Triple{{iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519}}, r.Supported(KindSigner))
+		assert.Equal([]Triple{{iana.KeyTypeSymmetric, iana.AlgorithmA128GCM, 0}}, r.Supported(KindEncryptor))
+		assert.Nil(r.Supported(FactoryKind(0)))
+	})
+
+	t.Run("override and unregister", func(t *testing.T) {
+		assert := assert.New(t)
+
+		r := NewRegistry()
+		hsm := func(Key) (Signer, error) { return nil, errors.New("hsm") }
+		assert.Nil(r.OverrideSigner(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, signer))
+		prev := r.OverrideSigner(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, hsm)
+		assert.NotNil(prev)
+		_, err := r.Signer(okp)
+		assert.ErrorContains(err, "hsm")
+
+		assert.Nil(r.OverrideVerifier(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, verifier))
+		assert.Nil(r.OverrideMACer(iana.KeyTypeSymmetric, iana.AlgorithmA128GCM, macer))
+		assert.Nil(r.OverrideEncryptor(iana.KeyTypeSymmetric, iana.AlgorithmA128GCM, encryptor))
+		assert.Nil(r.OverrideGenerator(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, generator))
+		assert.Nil(r.OverrideChecker(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, checker))
+		assert.Nil(r.OverrideConverter(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519,
+			CryptoConverter{}).ToCrypto)
+
+		for _, kind := range []FactoryKind{KindSigner, KindVerifier, KindGenerator, KindChecker, KindConverter} {
+			assert.True(r.Unregister(kind, iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519), kind.String())
+			assert.False(r.Unregister(kind, iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519), kind.String())
+			assert.Len(r.Supported(kind), 0)
+		}
+		for _, kind := range []FactoryKind{KindMACer, KindEncryptor} {
+			assert.True(r.Unregister(kind, iana.KeyTypeSymmetric, iana.AlgorithmA128GCM, 0), kind.String())
+			assert.Len(r.Supported(kind), 0)
+		}
+		assert.False(r.Unregister(FactoryKind(9), iana.KeyTypeOKP, 0, 0))
+		assert.Equal("FactoryKind(9)", FactoryKind(9).String())
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		assert := assert.New(t)
+
+		r := NewRegistry()
+		r.RegisterSigner(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, signer)
+		r.RegisterVerifier(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, verifier)
+		r.RegisterVerifier(iana.KeyTypeEC2, iana.AlgorithmES256, iana.EllipticCurveP_256, verifier)
+		r.RegisterVerifier(iana.KeyTypeEC2, iana.AlgorithmES384, iana.EllipticCurveP_384, verifier)
+		r.RegisterMACer(iana.KeyTypeSymmetric, iana.AlgorithmHMAC_256_64, macer)
+		r.RegisterMACer(iana.KeyTypeSymmetric, iana.AlgorithmHMAC_256_256, macer)
+
+		assert.Equal([]Triple{
+			{iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519},
+			{iana.KeyTypeEC2, iana.AlgorithmES384, iana.EllipticCurveP_384},
+			{iana.KeyTypeEC2, iana.AlgorithmES256, iana.EllipticCurveP_256},
+		}, r.Supported(KindVerifier))
+
+		nr := r.Filter(func(kind FactoryKind, t Triple) bool {
+			return kind == KindVerifier && t.Alg != iana.AlgorithmES384
+		})
+		assert.Len(nr.Supported(KindSigner), 0)
+		assert.Len(nr.Supported(KindMACer), 0)
+		assert.Equal([]Triple{
+			{iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519},
+			{iana.KeyTypeEC2, iana.AlgorithmES256, iana.EllipticCurveP_256},
+		}, nr.Supported(KindVerifier))
+
+		cr := r.Filter(nil)
+		assert.True(cr.Unregister(KindMACer, iana.KeyTypeSymmetric, iana.AlgorithmHMAC_256_64, 0))
+		assert.Len(cr.Supported(KindMACer), 1)
+		assert.Len(r.Supported(KindMACer), 2, "the filtered registry is independent")
+		assert.Equal("kty(4)_alg(4)", Triple{iana.KeyTypeSymmetric, iana.AlgorithmHMAC_256_64, 0}.String())
+	})
+
+	t.Run("strict key_ops", func(t *testing.T) {
+		assert := assert.New(t)
+
+		r := NewRegistry()
+		r.RegisterSigner(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, signer)
+		r.RegisterVerifier(iana.KeyTypeOKP, iana.AlgorithmEdDSA, iana.EllipticCurveEd25519, verifier)
+		r.RegisterMACer(iana.KeyTypeSymmetric, iana.AlgorithmHMAC_256_256, macer)
+		r.RegisterEncryptor(iana.KeyTypeSymmetric, iana.AlgorithmA128GCM, encryptor)
+		assert.False(r.StrictOps())
+
+		k := Key{
+			iana.KeyParameterKty:    iana.KeyTypeOKP,
+			iana.KeyParameterKid:    []byte("k1"),
+			iana.OKPKeyParameterCrv: iana.EllipticCurveEd25519,
+		}
+		mk := Key{
+			iana.KeyParameterKty: iana.KeyTypeSymmetric,
+			iana.KeyParameterAlg: iana.AlgorithmHMAC_256_256,
+		}
+		_, err := r.Signer(k)
+		assert.NoError(err)
+		_, err = r.MACer(mk)
+		assert.NoError(err)
+
+		r.SetStrictOps(true)
+		assert.True(r.StrictOps())
+		assert.True(r.Filter(nil).StrictOps(), "the filtered registry keeps the strict mode")
+
+		_, err = r.Signer(k)
+		assert.ErrorContains(err, "cose/key: Registry.Signer: invalid key_ops, missing key_ops for operation 1 in strict mode")
+		var opErr *OpError
+		assert.True(errors.As(err, &opErr))
+		assert.Equal(&OpError{Kid: ByteStr("k1"), Op: iana.KeyOperationSign, Strict: true}, opErr)
+		assert.ErrorIs(err, ErrOpNotPermitted)
+		_, err = r.MACer(mk)
+		assert.ErrorIs(err, ErrOpNotPermitted)
+		_, err = r.Encryptor(Key{iana.KeyParameterKty: iana.KeyTypeSymmetric, iana.KeyParameterAlg: iana.AlgorithmA128GCM})
+		assert.ErrorIs(err, ErrOpNotPermitted)
+
+		k.SetOps(iana.KeyOperationSign)
+		_, err = r.Signer(k)
+		assert.NoError(err)
+		_, err = r.Verifier(k)
+		assert.ErrorContains(err, "cose/key: Registry.Verifier: invalid key_ops [1], operation 2 is not permitted")
+
+		mk.SetOps(iana.KeyOperationMacVerify)
+		_, err = r.MACer(mk)
+		assert.NoError(err, "a MAC-verify-only key is checked by MACer.MACCreate")
+
+		r.SetStrictOps(false)
+		_, err = r.Verifier(k)
+		assert.NoError(err)
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		assert := assert.New(t)
+
+		r := NewRegistry()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					r.OverrideSigner(iana.KeyTypeOKP, -1000-i, j, signer)
+					r.Unregister(KindSigner, iana.KeyTypeOKP, -1000-i, j)
+				}
+				r.RegisterSigner(iana.KeyTypeOKP, -1000-i, 0, signer)
+			}(i)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					_, _ = r.Signer(okp)
+					_ = r.Supported(KindSigner)
+					_ = r.Filter(nil)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Len(r.Supported(KindSigner), 8)
+	})
 }
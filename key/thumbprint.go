@@ -0,0 +1,262 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package key
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ldclabs/cose/iana"
+)
+
+// ThumbprintURIPrefix is the prefix of the COSE Key Thumbprint URI.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc9679#name-cose-key-thumbprint-uris
+const ThumbprintURIPrefix = "urn:ietf:params:oauth:ckt:"
+
+// thumbprintHashNames is the hash names from the Named Information Hash Algorithm Registry.
+//
+// Reference https://www.iana.org/assignments/named-information/named-information.xhtml
+var thumbprintHashNames = map[crypto.Hash]string{
+	crypto.SHA256:   "sha-256",
+	crypto.SHA384:   "sha-384",
+	crypto.SHA512:   "sha-512",
+	crypto.SHA3_256: "sha3-256",
+	crypto.SHA3_384: "sha3-384",
+	crypto.SHA3_512: "sha3-512",
+}
+
+// thumbprintParams is the required parameters of each key type for the thumbprint.
+var thumbprintParams = map[int][]int{
+	iana.KeyTypeOKP:       {iana.OKPKeyParameterCrv, iana.OKPKeyParameterX},
+	iana.KeyTypeEC2:       {iana.EC2KeyParameterCrv, iana.EC2KeyParameterX, iana.EC2KeyParameterY},
+	iana.KeyTypeRSA:       {iana.RSAKeyParameterN, iana.RSAKeyParameterE},
+	iana.KeyTypeSymmetric: {iana.SymmetricKeyParameterK},
+	iana.KeyTypeHSS_LMS:   {iana.HSS_LMSKeyParameterPub},
+}
+
+// Thumbprint computes the COSE Key Thumbprint of the key with the given hash, such as crypto.SHA256.
+// The thumbprint is the hash of the deterministic CBOR encoding of the required public parameters
+// of the key type. The present public parameters are encoded directly. For the OKP and EC2
+// private keys without the public parameters, the public key is derived for the Ed25519, X25519,
+// P-256, P-384 and P-521 curves by the CryptoConverter that the key packages register.
+// The EC2 coordinates are left-padded to the full length of the curve, and the compressed point
+// is decompressed, so the equivalent representations of a key have the same thumbprint.
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc9679.
+func (k Key) Thumbprint(h crypto.Hash) ([]byte, error) {
+	data, err := k.thumbprintInput()
+	if err != nil {
+		return nil, fmt.Errorf("cose/key: Key.Thumbprint: %w", err)
+	}
+	if _, ok := thumbprintHashNames[h]; !ok {
+		return nil, fmt.Errorf("cose/key: Key.Thumbprint: unsupported hash function %v", h)
+	}
+	return ComputeHash(h, data)
+}
+
+// ThumbprintURI returns the COSE Key Thumbprint URI of the key with the given hash,
+// such as "urn:ietf:params:oauth:ckt:sha-256:SWvYr63zB-WwjGSwQhv53AFSijRKQ72oj63RZp2iU-w".
+//
+// Reference https://datatracker.ietf.org/doc/html/rfc9679#name-cose-key-thumbprint-uris.
+func (k Key) ThumbprintURI(h crypto.Hash) (string, error) {
+	tp, err := k.Thumbprint(h)
+	if err != nil {
+		return "", err
+	}
+	return ThumbprintURIPrefix + thumbprintHashNames[h] + ":" + base64.RawURLEncoding.EncodeToString(tp), nil
+}
+
+// SetKidFromThumbprint sets the key identifier to the COSE Key Thumbprint with the given hash,
+// so that the key has a stable identity across systems.
+func (k Key) SetKidFromThumbprint(h crypto.Hash) error {
+	tp, err := k.Thumbprint(h)
+	if err != nil {
+		return err
+	}
+	k.SetKid(tp)
+	return nil
+}
+
+// ParseThumbprintURI parses a COSE Key Thumbprint URI, and returns the hash and the thumbprint.
+func ParseThumbprintURI(uri string) (crypto.Hash, []byte, error) {
+	s, ok := strings.CutPrefix(uri, ThumbprintURIPrefix)
+	if !ok {
+		return 0, nil, errors.New("cose/key: ParseThumbprintURI: invalid prefix")
+	}
+
+	name, value, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, nil, errors.New("cose/key: ParseThumbprintURI: missing hash name")
+	}
+
+	for h, n := range thumbprintHashNames {
+		if n == name {
+			tp, err := base64.RawURLEncoding.DecodeString(value)
+			if err != nil || len(tp) != h.Size() {
+				return 0, nil, errors.New("cose/key: ParseThumbprintURI: invalid thumbprint")
+			}
+			return h, tp, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("cose/key: ParseThumbprintURI: unsupported hash name %q", name)
+}
+
+// thumbprintInput returns the deterministic CBOR encoding of the required parameters.
+func (k Key) thumbprintInput() ([]byte, error) {
+	if k == nil {
+		return nil, errors.New("nil key")
+	}
+
+	kty := k.Kty()
+	params, ok := thumbprintParams[kty]
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %d", kty)
+	}
+
+	src := k
+	switch {
+	case kty == iana.KeyTypeOKP && !k.Has(iana.OKPKeyParameterX):
+		pk, err := k.derivePublic()
+		if err != nil {
+			return nil, err
+		}
+		src = pk
+
+	case kty == iana.KeyTypeEC2:
+		pk, err := k.ec2Public()
+		if err != nil {
+			return nil, err
+		}
+		src = pk
+	}
+
+	m := CoseMap{iana.KeyParameterKty: kty}
+	for _, p := range params {
+		if !src.Has(p) {
+			return nil, fmt.Errorf("missing parameter %d", p)
+		}
+
+		switch {
+		case p == iana.OKPKeyParameterCrv && (kty == iana.KeyTypeOKP || kty == iana.KeyTypeEC2):
+			crv, err := src.GetInt(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid parameter crv, %w", err)
+			}
+			m[p] = crv
+
+		default:
+			b, err := src.GetBytes(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid parameter %d, %w", p, err)
+			}
+			m[p] = b
+		}
+	}
+	return m.MarshalCBOR()
+}
+
+// ec2Public returns the public EC2 key of the key, with the coordinates left-padded to
+// the full length of the P-256, P-384 and P-521 curves. The x and y coordinates are used
+// directly if present, otherwise the public key is derived or decompressed by the CryptoConverter.
+func (k Key) ec2Public() (Key, error) {
+	crv, _ := k.GetInt(iana.EC2KeyParameterCrv)
+	x, errX := k.GetBytes(iana.EC2KeyParameterX)
+	y, errY := k.GetBytes(iana.EC2KeyParameterY)
+	if !k.Has(iana.EC2KeyParameterX) || !k.Has(iana.EC2KeyParameterY) || errY != nil {
+		// the private key only, or the compressed point with the sign bit of y.
+		if ec2Size(crv) == 0 {
+			return k, nil
+		}
+		return k.derivePublic()
+	}
+	if errX != nil {
+		return nil, fmt.Errorf("invalid parameter x, %w", errX)
+	}
+
+	if size := ec2Size(crv); size > 0 {
+		if len(x) > size || len(y) > size {
+			return nil, errors.New("invalid coordinate length")
+		}
+		x = append(make([]byte, size-len(x), size), x...)
+		y = append(make([]byte, size-len(y), size), y...)
+	}
+	return Key{
+		iana.KeyParameterKty:    iana.KeyTypeEC2,
+		iana.EC2KeyParameterCrv: crv,
+		iana.EC2KeyParameterX:   x,
+		iana.EC2KeyParameterY:   y,
+	}, nil
+}
+
+// ec2Size returns the coordinate size in bytes of the NIST curves, or 0 for the other curves.
+func ec2Size(crv int) int {
+	switch crv {
+	case iana.EllipticCurveP_256:
+		return 32
+	case iana.EllipticCurveP_384:
+		return 48
+	case iana.EllipticCurveP_521:
+		return 66
+	default:
+		return 0
+	}
+}
+
+// derivePublic returns the public OKP or EC2 key of the key, with the full length coordinates.
+func (k Key) derivePublic() (Key, error) {
+	pk, err := keyToCrypto(k)
+	if err != nil {
+		return nil, err
+	}
+
+	var pub *ecdh.PublicKey
+	switch pk := pk.(type) {
+	case ed25519.PrivateKey:
+		return Key{
+			iana.KeyParameterKty:    iana.KeyTypeOKP,
+			iana.OKPKeyParameterCrv: iana.EllipticCurveEd25519,
+			iana.OKPKeyParameterX:   []byte(pk.Public().(ed25519.PublicKey)),
+		}, nil
+	case *ecdh.PrivateKey:
+		pub = pk.PublicKey()
+	case *ecdh.PublicKey:
+		pub = pk
+	case *ecdsa.PrivateKey:
+		if pub, err = pk.PublicKey.ECDH(); err != nil {
+			return nil, err
+		}
+	case *ecdsa.PublicKey:
+		if pub, err = pk.ECDH(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("missing public key")
+	}
+
+	crv, _ := k.GetInt(iana.OKPKeyParameterCrv)
+	data := pub.Bytes()
+	if pub.Curve() == ecdh.X25519() {
+		return Key{
+			iana.KeyParameterKty:    iana.KeyTypeOKP,
+			iana.OKPKeyParameterCrv: crv,
+			iana.OKPKeyParameterX:   data,
+		}, nil
+	}
+
+	// the uncompressed point 0x04 || x || y
+	size := (len(data) - 1) / 2
+	return Key{
+		iana.KeyParameterKty:    iana.KeyTypeEC2,
+		iana.EC2KeyParameterCrv: crv,
+		iana.EC2KeyParameterX:   data[1 : 1+size],
+		iana.EC2KeyParameterY:   data[1+size:],
+	}, nil
+}
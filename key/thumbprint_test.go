@@ -0,0 +1,213 @@
+// (c) 2022-present, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package key_test
+
+import (
+	"crypto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/ldclabs/cose/key/ecdh"
+	"github.com/ldclabs/cose/key/ecdsa"
+	"github.com/ldclabs/cose/key/ed25519"
+	"github.com/ldclabs/cose/key/hmac"
+)
+
+func TestThumbprint(t *testing.T) {
+	t.Run("RFC9679 example", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		// https://datatracker.ietf.org/doc/html/rfc9679#name-example
+		k := key.Key{
+			iana.KeyParameterKty:    iana.KeyTypeEC2,
+			iana.KeyParameterKid:    key.HexBytesify("496bd8afadf307e5b08c64b0421bf9dc01528a344a43bda88fadd1669da253ec"),
+			iana.EC2KeyParameterCrv: iana.EllipticCurveP_256,
+			iana.EC2KeyParameterX:   key.HexBytesify("65eda5a12577c2bae829437fe338701a10aaa375e1bb5b5de108de439c08551d"),
+			iana.EC2KeyParameterY:   key.HexBytesify("1e52ed75701163f7f9e40ddf9f341b3dc9ba860af7e0ca7ca7e9eecd0084d19c"),
+		}
+
+		tp, err := k.Thumbprint(crypto.SHA256)
+		require.NoError(err)
+		assert.Equal([]byte(k.Kid()), tp)
+
+		uri, err := k.ThumbprintURI(crypto.SHA256)
+		require.NoError(err)
+		assert.Equal("urn:ietf:params:oauth:ckt:sha-256:SWvYr63zB-WwjGSwQhv53AFSijRKQ72oj63RZp2iU-w", uri)
+
+		h, tp2, err := key.ParseThumbprintURI(uri)
+		require.NoError(err)
+		assert.Equal(crypto.SHA256, h)
+		assert.Equal(tp, tp2)
+
+		// the coordinates are encoded directly, without a CryptoConverter for the algorithm
+		k2 := key.Key{
+			iana.KeyParameterKty:    iana.KeyTypeEC2,
+			iana.KeyParameterAlg:    iana.AlgorithmES256K,
+			iana.EC2KeyParameterCrv: iana.EllipticCurveP_256,
+			iana.EC2KeyParameterX:   k.Get(iana.EC2KeyParameterX),
+			iana.EC2KeyParameterY:   k.Get(iana.EC2KeyParameterY),
+		}
+		tp2, err = k2.Thumbprint(crypto.SHA256)
+		require.NoError(err)
+		assert.Equal(tp, tp2)
+
+		// the coordinates are left-padded to the full length
+		tp, err = key.Key{
+			iana.KeyParameterKty:    iana.KeyTypeEC2,
+			iana.EC2KeyParameterCrv: iana.EllipticCurveP_256,
+			iana.EC2KeyParameterX:   []byte{1, 2, 3},
+			iana.EC2KeyParameterY:   []byte{4, 5, 6},
+		}.Thumbprint(crypto.SHA256)
+		require.NoError(err)
+		tp2, err = key.Key{
+			iana.KeyParameterKty:    iana.KeyTypeEC2,
+			iana.EC2KeyParameterCrv: iana.EllipticCurveP_256,
+			iana.EC2KeyParameterX:   append(make([]byte, 29), 1, 2, 3),
+			iana.EC2KeyParameterY:   append(make([]byte, 29), 4, 5, 6),
+		}.Thumbprint(crypto.SHA256)
+		require.NoError(err)
+		assert.Equal(tp, tp2)
+		tp, err = k.Thumbprint(crypto.SHA256)
+		require.NoError(err)
+
+		// the compressed point has the same thumbprint
+		k[iana.EC2KeyParameterY] = false
+		tp2, err = k.Thumbprint(crypto.SHA256)
+		require.NoError(err)
+		assert.Equal(tp, tp2)
+
+		// the optional parameters are ignored
+		delete(k, iana.KeyParameterKid)
+		k[iana.KeyParameterAlg] = iana.AlgorithmES256
+		k.SetOps(iana.KeyOperationVerify)
+		require.NoError(k.SetKidFromThumbprint(crypto.SHA256))
+		assert.Equal(key.ByteStr(tp), k.Kid())
+
+		tp, err = k.Thumbprint(crypto.SHA512)
+		require.NoError(err)
+		assert.Len(tp, 64)
+		uri, err = k.ThumbprintURI(crypto.SHA3_256)
+		require.NoError(err)
+		assert.Contains(uri, "urn:ietf:params:oauth:ckt:sha3-256:")
+	})
+
+	t.Run("private and public keys", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		k1, err := ed25519.GenerateKey()
+		require.NoError(err)
+		k2, err := ecdsa.GenerateKey(iana.AlgorithmES384)
+		require.NoError(err)
+		k3, err := ecdh.GenerateKey(iana.EllipticCurveX25519)
+		require.NoError(err)
+
+		for _, tc := range []struct {
+			k        key.Key
+			toPublic func(key.Key) (key.Key, error)
+		}{
+			{k1, ed25519.ToPublicKey},
+			{k2, ecdsa.ToPublicKey},
+			{k3, ecdh.ToPublicKey},
+		} {
+			pk, err := tc.toPublic(tc.k)
+			require.NoError(err)
+
+			tp, err := tc.k.Thumbprint(crypto.SHA256)
+			require.NoError(err)
+			tp2, err := pk.Thumbprint(crypto.SHA256)
+			require.NoError(err)
+			assert.Equal(tp, tp2)
+		}
+
+		k4, err := hmac.GenerateKey(iana.AlgorithmHMAC_256_256)
+		require.NoError(err)
+		tp, err := k4.Thumbprint(crypto.SHA256)
+		require.NoError(err)
+		kv, _ := k4.GetBytes(iana.SymmetricKeyParameterK)
+		data := key.MustMarshalCBOR(map[int]any{
+			iana.KeyParameterKty:        iana.KeyTypeSymmetric,
+			iana.SymmetricKeyParameterK: kv,
+		})
+		sum, err := key.ComputeHash(crypto.SHA256, data)
+		require.NoError(err)
+		assert.Equal(sum, tp)
+
+		k5 := key.Key{
+			iana.KeyParameterKty:  iana.KeyTypeRSA,
+			iana.RSAKeyParameterN: key.HexBytesify("c4a1f2b3"),
+			iana.RSAKeyParameterE: key.HexBytesify("010001"),
+			iana.RSAKeyParameterD: key.HexBytesify("01020304"),
+		}
+		tp, err = k5.Thumbprint(crypto.SHA256)
+		require.NoError(err)
+		delete(k5, iana.RSAKeyParameterD)
+		tp2, err := k5.Thumbprint(crypto.SHA256)
+		require.NoError(err)
+		assert.Equal(tp, tp2)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert := assert.New(t)
+
+		for _, tc := range []struct {
+			k   key.Key
+			err string
+		}{
+			{nil, "nil key"},
+			{key.Key{iana.KeyParameterKty: iana.KeyTypeWalnutDSA}, "unsupported key type 6"},
+			{key.Key{iana.KeyParameterKty: iana.KeyTypeSymmetric}, "missing parameter -1"},
+			{key.Key{iana.KeyParameterKty: iana.KeyTypeRSA, iana.RSAKeyParameterN: []byte{1}}, "missing parameter -2"},
+			{key.Key{iana.KeyParameterKty: iana.KeyTypeSymmetric, iana.SymmetricKeyParameterK: 1}, "invalid parameter -1"},
+			{key.Key{iana.KeyParameterKty: iana.KeyTypeOKP, iana.OKPKeyParameterCrv: iana.EllipticCurveEd448}, "kty(1)_alg(0)_crv(7) is not registered"},
+			{key.Key{
+				iana.KeyParameterKty:    iana.KeyTypeEC2,
+				iana.EC2KeyParameterCrv: iana.EllipticCurveP_256,
+				iana.EC2KeyParameterX:   make([]byte, 33),
+				iana.EC2KeyParameterY:   []byte{4, 5, 6},
+			}, "invalid coordinate length"},
+			{key.Key{
+				iana.KeyParameterKty:    iana.KeyTypeEC2,
+				iana.EC2KeyParameterCrv: iana.EllipticCurveP_256,
+				iana.EC2KeyParameterX:   1,
+				iana.EC2KeyParameterY:   []byte{4, 5, 6},
+			}, "invalid parameter x"},
+			{key.Key{
+				iana.KeyParameterKty:    iana.KeyTypeEC2,
+				iana.EC2KeyParameterCrv: iana.EllipticCurveSecp256k1,
+				iana.EC2KeyParameterX:   []byte{1, 2, 3},
+			}, "missing parameter -3"},
+		} {
+			_, err := tc.k.Thumbprint(crypto.SHA256)
+			assert.ErrorContains(err, tc.err)
+		}
+
+		k := key.Key{iana.KeyParameterKty: iana.KeyTypeSymmetric, iana.SymmetricKeyParameterK: []byte{1}}
+		_, err := k.Thumbprint(crypto.MD5)
+		assert.ErrorContains(err, "unsupported hash function")
+		_, err = k.ThumbprintURI(crypto.SHA1)
+		assert.ErrorContains(err, "unsupported hash function")
+		assert.Error(k.SetKidFromThumbprint(crypto.SHA1))
+		assert.Nil(k.Kid())
+
+		for _, tc := range []struct {
+			uri string
+			err string
+		}{
+			{"urn:ietf:params:oauth:jwk-thumbprint:sha-256:AQID", "invalid prefix"},
+			{"urn:ietf:params:oauth:ckt:sha-256", "missing hash name"},
+			{"urn:ietf:params:oauth:ckt:md5:AQID", `unsupported hash name "md5"`},
+			{"urn:ietf:params:oauth:ckt:sha-256:AQID", "invalid thumbprint"},
+			{"urn:ietf:params:oauth:ckt:sha-256:SWvYr63zB+WwjGSwQhv53AFSijRKQ72oj63RZp2iU+w", "invalid thumbprint"},
+		} {
+			_, _, err := key.ParseThumbprintURI(tc.uri)
+			assert.ErrorContains(err, tc.err)
+		}
+	})
+}